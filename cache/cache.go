@@ -0,0 +1,283 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// Package cache provides an in-memory cache with a maximum number of entries,
+// evicting the least recently used entry when full, and optional per-entry
+// expiry. Concurrent loads of the same missing key are collapsed into a
+// single call.
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// now is overridden within the tests.
+var now = time.Now
+
+// EvictReason is why an entry was removed from the cache.
+type EvictReason int
+
+const (
+	// EvictCapacity is used when the entry was the least recently used entry
+	// and the cache was full.
+	EvictCapacity EvictReason = iota
+
+	// EvictExpired is used when the entry's TTL passed.
+	EvictExpired
+
+	// EvictDeleted is used when the entry was removed by Delete, Purge, or
+	// replaced by Set.
+	EvictDeleted
+)
+
+// String returns the name of the reason.
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// Stats are the counters of a Cache's activity.
+type Stats struct {
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	Evictions  uint64 `json:"evictions"`
+	Expired    uint64 `json:"expired"`
+	Loads      uint64 `json:"loads"`
+	LoadErrors uint64 `json:"load_errors"`
+}
+
+// Cache is a fixed size, least recently used cache safe for concurrent use.
+type Cache struct {
+	// OnEvict, if set, is called after an entry is removed from the cache
+	// along with the reason it was removed. It is called without the cache
+	// locked so it may use the cache.
+	OnEvict func(key, value interface{}, reason EvictReason)
+
+	maxEntries int
+	ttl        time.Duration
+
+	mutex   sync.Mutex
+	entries map[interface{}]*list.Element
+	lru     *list.List
+	loads   map[interface{}]*load
+	stats   Stats
+}
+
+type entry struct {
+	key     interface{}
+	value   interface{}
+	expires time.Time
+}
+
+// load is an in-flight call to a loader.
+type load struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+type eviction struct {
+	key, value interface{}
+	reason     EvictReason
+}
+
+// New returns a Cache holding up to maxEntries entries, each expiring after
+// ttl. A maxEntries of zero means no limit, and a ttl of zero means entries
+// don't expire.
+func New(maxEntries int, ttl time.Duration) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		entries:    make(map[interface{}]*list.Element),
+		lru:        list.New(),
+		loads:      make(map[interface{}]*load),
+	}
+}
+
+// Get returns the value for the key, if it is cached and has not expired.
+func (c *Cache) Get(key interface{}) (interface{}, bool) {
+	c.mutex.Lock()
+	value, ok, evicted := c.get(key)
+	c.mutex.Unlock()
+	c.notify(evicted)
+	return value, ok
+}
+
+// get looks up the key, counting the hit or miss. It must be called with the
+// cache locked.
+func (c *Cache) get(key interface{}) (interface{}, bool, []eviction) {
+	elem, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false, nil
+	}
+	e := elem.Value.(*entry)
+	if !e.expires.IsZero() && !now().Before(e.expires) {
+		c.stats.Misses++
+		c.stats.Expired++
+		c.remove(elem)
+		return nil, false, []eviction{{e.key, e.value, EvictExpired}}
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(elem)
+	return e.value, true, nil
+}
+
+// Set adds the value to the cache using the cache's TTL.
+func (c *Cache) Set(key, value interface{}) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL adds the value to the cache, expiring after ttl. A ttl of zero
+// means the entry doesn't expire.
+func (c *Cache) SetWithTTL(key, value interface{}, ttl time.Duration) {
+	c.mutex.Lock()
+	evicted := c.set(key, value, ttl)
+	c.mutex.Unlock()
+	c.notify(evicted)
+}
+
+// set must be called with the cache locked.
+func (c *Cache) set(key, value interface{}, ttl time.Duration) []eviction {
+	var evicted []eviction
+	var expires time.Time
+	if ttl > 0 {
+		expires = now().Add(ttl)
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		e := elem.Value.(*entry)
+		evicted = append(evicted, eviction{e.key, e.value, EvictDeleted})
+		e.value, e.expires = value, expires
+		c.lru.MoveToFront(elem)
+		return evicted
+	}
+
+	c.entries[key] = c.lru.PushFront(&entry{key: key, value: value, expires: expires})
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		e := c.remove(c.lru.Back())
+		c.stats.Evictions++
+		evicted = append(evicted, eviction{e.key, e.value, EvictCapacity})
+	}
+	return evicted
+}
+
+// GetOrLoad returns the cached value for the key, or calls load to get the
+// value and caches it if the key is missing. Concurrent calls for the same
+// missing key wait for a single call to load and share its result. Errors
+// from load are returned and not cached. If load panics, the calls waiting
+// for it return an error and the panic continues in the call which loaded.
+func (c *Cache) GetOrLoad(key interface{}, loader func() (interface{}, error)) (interface{}, error) {
+	c.mutex.Lock()
+	value, ok, evicted := c.get(key)
+	if ok {
+		c.mutex.Unlock()
+		c.notify(evicted)
+		return value, nil
+	}
+	if l, ok := c.loads[key]; ok {
+		c.mutex.Unlock()
+		c.notify(evicted)
+		<-l.done
+		return l.value, l.err
+	}
+	l := &load{done: make(chan struct{})}
+	c.loads[key] = l
+	c.stats.Loads++
+	c.mutex.Unlock()
+	c.notify(evicted)
+
+	defer func() {
+		r := recover()
+		if r != nil {
+			l.value, l.err = nil, fmt.Errorf("cache: loader panicked: %v", r)
+		}
+		c.finishLoad(key, l)
+		if r != nil {
+			panic(r)
+		}
+	}()
+	l.value, l.err = loader()
+	return l.value, l.err
+}
+
+// finishLoad caches the result of a load if it succeeded, and releases the
+// calls waiting for it.
+func (c *Cache) finishLoad(key interface{}, l *load) {
+	var evicted []eviction
+	c.mutex.Lock()
+	delete(c.loads, key)
+	if l.err != nil {
+		c.stats.LoadErrors++
+	} else {
+		evicted = c.set(key, l.value, c.ttl)
+	}
+	c.mutex.Unlock()
+	close(l.done)
+	c.notify(evicted)
+}
+
+// Delete removes the key from the cache.
+func (c *Cache) Delete(key interface{}) {
+	c.mutex.Lock()
+	var evicted []eviction
+	if elem, ok := c.entries[key]; ok {
+		e := c.remove(elem)
+		evicted = append(evicted, eviction{e.key, e.value, EvictDeleted})
+	}
+	c.mutex.Unlock()
+	c.notify(evicted)
+}
+
+// Purge removes every entry from the cache.
+func (c *Cache) Purge() {
+	c.mutex.Lock()
+	evicted := make([]eviction, 0, c.lru.Len())
+	for c.lru.Len() > 0 {
+		e := c.remove(c.lru.Back())
+		evicted = append(evicted, eviction{e.key, e.value, EvictDeleted})
+	}
+	c.mutex.Unlock()
+	c.notify(evicted)
+}
+
+// Len returns the number of entries in the cache, including any which have
+// expired but not yet been removed.
+func (c *Cache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lru.Len()
+}
+
+// Stats returns the cache's counters.
+func (c *Cache) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats
+}
+
+// remove must be called with the cache locked.
+func (c *Cache) remove(elem *list.Element) *entry {
+	e := c.lru.Remove(elem).(*entry)
+	delete(c.entries, e.key)
+	return e
+}
+
+// notify calls OnEvict for the evicted entries.
+func (c *Cache) notify(evicted []eviction) {
+	if c.OnEvict == nil {
+		return
+	}
+	for _, ev := range evicted {
+		c.OnEvict(ev.key, ev.value, ev.reason)
+	}
+}
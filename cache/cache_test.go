@@ -0,0 +1,192 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// fakeClock replaces now for the duration of the test.
+func fakeClock(testHelper *tt.TestTool) *time.Time {
+	t := time.Unix(1000, 0)
+	now = func() time.Time { return t }
+	testHelper.AddTestFinalizer(func() { now = time.Now })
+	return &t
+}
+
+func TestLRU(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	type evicted struct {
+		key, value interface{}
+		reason     EvictReason
+	}
+	var evictions []evicted
+
+	c := New(2, 0)
+	c.OnEvict = func(key, value interface{}, reason EvictReason) {
+		evictions = append(evictions, evicted{key, value, reason})
+	}
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	// a becomes the most recently used, so b is evicted
+	v, ok := c.Get("a")
+	tt.TestTrue(t, ok)
+	tt.TestEqual(t, v, 1)
+	c.Set("c", 3)
+	tt.TestEqual(t, c.Len(), 2)
+	_, ok = c.Get("b")
+	tt.TestFalse(t, ok)
+	tt.TestEqual(t, evictions, []evicted{{"b", 2, EvictCapacity}})
+
+	// replacing and deleting
+	evictions = nil
+	c.Set("a", 10)
+	v, _ = c.Get("a")
+	tt.TestEqual(t, v, 10)
+	c.Delete("c")
+	c.Delete("missing")
+	tt.TestEqual(t, evictions, []evicted{{"a", 1, EvictDeleted}, {"c", 3, EvictDeleted}})
+
+	evictions = nil
+	c.Purge()
+	tt.TestEqual(t, c.Len(), 0)
+	tt.TestEqual(t, evictions, []evicted{{"a", 10, EvictDeleted}})
+
+	tt.TestEqual(t, c.Stats(), Stats{Hits: 2, Misses: 1, Evictions: 1})
+}
+
+func TestTTL(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+	clock := fakeClock(testHelper)
+
+	var reasons []EvictReason
+	c := New(0, time.Minute)
+	c.OnEvict = func(key, value interface{}, reason EvictReason) {
+		reasons = append(reasons, reason)
+	}
+	c.Set("a", 1)
+	c.SetWithTTL("b", 2, time.Hour)
+	c.SetWithTTL("c", 3, 0)
+
+	*clock = clock.Add(time.Minute - 1)
+	_, ok := c.Get("a")
+	tt.TestTrue(t, ok)
+
+	*clock = clock.Add(1)
+	_, ok = c.Get("a")
+	tt.TestFalse(t, ok)
+	tt.TestEqual(t, reasons, []EvictReason{EvictExpired})
+
+	*clock = clock.Add(24 * time.Hour)
+	_, ok = c.Get("b")
+	tt.TestFalse(t, ok)
+	_, ok = c.Get("c")
+	tt.TestTrue(t, ok)
+
+	stats := c.Stats()
+	tt.TestEqual(t, stats.Expired, uint64(2))
+	tt.TestEqual(t, stats.Misses, uint64(2))
+	tt.TestEqual(t, stats.Hits, uint64(2))
+}
+
+func TestGetOrLoad(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	c := New(10, 0)
+	var calls int32
+	release := make(chan struct{})
+	loader := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "loaded", nil
+	}
+
+	// concurrent loads of the same key share one call
+	var wg sync.WaitGroup
+	results := make([]interface{}, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := c.GetOrLoad("key", loader)
+			tt.TestExpectSuccess(t, err)
+			results[i] = v
+		}(i)
+	}
+	tt.Timeout(t, time.Second, time.Millisecond, func() bool {
+		return atomic.LoadInt32(&calls) == 1
+	})
+	close(release)
+	wg.Wait()
+	tt.TestEqual(t, atomic.LoadInt32(&calls), int32(1))
+	for _, v := range results {
+		tt.TestEqual(t, v, "loaded")
+	}
+
+	// the value is now cached
+	v, err := c.GetOrLoad("key", func() (interface{}, error) {
+		t.Fatal("unexpected load")
+		return nil, nil
+	})
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, v, "loaded")
+
+	// errors are not cached
+	_, err = c.GetOrLoad("bad", func() (interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	tt.TestExpectError(t, err)
+	_, ok := c.Get("bad")
+	tt.TestFalse(t, ok)
+
+	stats := c.Stats()
+	tt.TestEqual(t, stats.Loads, uint64(2))
+	tt.TestEqual(t, stats.LoadErrors, uint64(1))
+}
+
+func TestGetOrLoadPanic(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	c := New(10, 0)
+	started := make(chan struct{})
+	waited := make(chan error)
+
+	// a waiting call gets an error, and the panic reaches the loading call
+	go func() {
+		<-started
+		_, err := c.GetOrLoad("key", func() (interface{}, error) {
+			return "unexpected", nil
+		})
+		waited <- err
+	}()
+	tt.TestExpectPanic(t, func() {
+		c.GetOrLoad("key", func() (interface{}, error) {
+			close(started)
+			tt.Timeout(t, time.Second, time.Millisecond, func() bool {
+				return c.Stats().Misses == 2
+			})
+			panic("boom")
+		})
+	}, "boom")
+	tt.TestEqual(t, (<-waited).Error(), "cache: loader panicked: boom")
+
+	// later calls load the key again
+	v, err := c.GetOrLoad("key", func() (interface{}, error) {
+		return "loaded", nil
+	})
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, v, "loaded")
+	tt.TestEqual(t, c.Stats().LoadErrors, uint64(1))
+}
@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/url"
 	"path"
+	"regexp"
 	"strings"
 )
 
@@ -21,7 +22,7 @@ import (
 //
 // Possible formats:
 //
-// <scheme>://[user:password@]<host>[:<port>][/<namespace>/<repo>[:<tag>]]
+// <scheme>://[user:password@]<host>[:<port>][/<namespace>/<repo>[:<tag>][@<digest>]]
 type DockerRegistryURL struct {
 	// Scheme can be http or https.
 	Scheme string `json:",omitempty"`
@@ -45,12 +46,17 @@ type DockerRegistryURL struct {
 	// Tag specifies a desired version of the docker image. For instance, to
 	// specify ubuntu 14.04, the tag is 14.04.
 	Tag string `json:",omitempty"`
+
+	// Digest pins the image to the content with the given digest, such as
+	// sha256:<hex>. It may be set along with a Tag, in which case the digest
+	// takes precedence when pulling.
+	Digest string `json:",omitempty"`
 }
 
 // ParseDockerRegistryURL parses a Docker Registry URL. It does not need to be
 // a full URL. If the url starts with either http or https, the input path will
 // be passed to ParseFullDockerRegistryURL. If not, it will try to parse the
-// URL as if it is a (namespace/)*repo(:tag)?
+// URL as if it is a (namespace/)*repo(:tag)?(@digest)?
 func ParseDockerRegistryURL(s string) (*DockerRegistryURL, error) {
 	registryURL, err := ParseFullDockerRegistryURL(s)
 	if err == nil {
@@ -144,8 +150,8 @@ func splitHostPort(hostport string) (string, string, error) {
 }
 
 // parsePath parses the Registry URL path (after <scheme>://<host>:<port>)
-// into namespace, repo, and tag. All of these are optional parts of the
-// path.
+// into namespace, repo, tag, and digest. All of these are optional parts of
+// the path.
 func (url *DockerRegistryURL) parsePath(s string) error {
 	s, err := cleanPath(s)
 	if err != nil {
@@ -156,26 +162,76 @@ func (url *DockerRegistryURL) parsePath(s string) error {
 		return nil
 	}
 
+	s, digest, err := parseDigest(s)
+	if err != nil {
+		return err
+	}
+
 	imageName, tag, err := parseTag(s)
 	if err != nil {
 		return err
 	}
 	url.Tag = tag
 	url.ImageName = imageName
+	url.Digest = digest
 
 	return nil
 }
 
 // cleanPath removes leading and trailing forward slashes
-// and makes sure that the path does not only contain a tag.
+// and makes sure that the path does not only contain a tag or digest.
 func cleanPath(s string) (string, error) {
 	s = strings.Trim(s, "/")
 	if strings.HasPrefix(s, ":") {
 		return "", fmt.Errorf("Path cannot be made up of just a tag: %q", s)
 	}
+	if strings.HasPrefix(s, "@") {
+		return "", fmt.Errorf("Path cannot be made up of just a digest: %q", s)
+	}
 	return s, nil
 }
 
+// digestRegexp matches the algorithm and encoded parts of a digest.
+var digestRegexp = regexp.MustCompile(`^([a-z0-9]+(?:[.+_-][a-z0-9]+)*):([a-zA-Z0-9=_-]+)$`)
+
+// digestHexLengths are the lengths of the hex encoded digests of the
+// algorithms supported by registries.
+var digestHexLengths = map[string]int{
+	"sha256": 64,
+	"sha384": 96,
+	"sha512": 128,
+}
+
+// parseDigest splits a digest, following an "@", from the rest of the path.
+func parseDigest(s string) (prefix, digest string, err error) {
+	i := strings.Index(s, "@")
+	if i < 0 {
+		return s, "", nil
+	}
+	prefix, digest = s[:i], s[i+1:]
+	if err := ValidateDigest(digest); err != nil {
+		return "", "", err
+	}
+	return prefix, digest, nil
+}
+
+// ValidateDigest checks that a digest is of the form <algorithm>:<encoded>. The
+// encoded part of sha256, sha384, and sha512 digests must be lowercase hex of
+// the right length.
+func ValidateDigest(digest string) error {
+	m := digestRegexp.FindStringSubmatch(digest)
+	if m == nil {
+		return fmt.Errorf("Invalid digest: %q", digest)
+	}
+	algorithm, encoded := m[1], m[2]
+	if length, ok := digestHexLengths[algorithm]; ok {
+		if len(encoded) != length || strings.Trim(encoded, "0123456789abcdef") != "" {
+			return fmt.Errorf("Invalid %s digest: %q", algorithm, digest)
+		}
+	}
+	return nil
+}
+
 // parseTag splits the Repository tag from the prefix.
 func parseTag(s string) (prefix, tag string, err error) {
 	splitString := strings.Split(s, ":")
@@ -247,13 +303,25 @@ func (u *DockerRegistryURL) BaseURLNoCredentials() string {
 }
 
 // Path returns the string path segment of a DockerRegistryURL. The full format
-// of a path is [namespace/]repo[:tag].
+// of a path is [namespace/]repo[:tag][@digest].
 func (u *DockerRegistryURL) Path() string {
-	if u.Tag == "" {
-		return u.ImageName
-	} else {
-		return fmt.Sprintf("%s:%s", u.ImageName, u.Tag)
+	s := u.ImageName
+	if u.Tag != "" {
+		s = fmt.Sprintf("%s:%s", s, u.Tag)
+	}
+	if u.Digest != "" {
+		s = fmt.Sprintf("%s@%s", s, u.Digest)
+	}
+	return s
+}
+
+// Reference returns the tag or digest to request the image's manifest by,
+// preferring the digest.
+func (u *DockerRegistryURL) Reference() string {
+	if u.Digest != "" {
+		return u.Digest
 	}
+	return u.Tag
 }
 
 // baseString is wrapped by String and StringNoCredentials.
@@ -14,7 +14,10 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"sort"
 	"strings"
+
+	"github.com/apcera/util/multierror"
 )
 
 var (
@@ -27,6 +30,7 @@ type Image struct {
 	Name string
 
 	tags      map[string]string // Tags available for the image.
+	mirrors   []string          // Mirror base URLs, tried before endpoints.
 	endpoints []string          // Docker registry endpoints.
 	token     string            // Docker auth token.
 
@@ -39,10 +43,31 @@ type Image struct {
 // registry. If the registry is an empty string it defaults to the DockerHub.
 // The integer return value is the status code of the HTTP response.
 func GetImage(name, registryURL string) (*Image, int, error) {
+	return GetImageWithMirrors(name, registryURL, nil)
+}
+
+// GetImageWithMirrors is like GetImage, but image metadata and layer requests
+// are tried against each of the mirror base URLs (e.g.
+// "https://mirror.example.com") in order before falling back to the endpoints
+// returned by the registry. The registry's auth token is sent to the mirrors
+// too. The repository index itself is always fetched from the registry.
+func GetImageWithMirrors(name, registryURL string, mirrors []string) (*Image, int, error) {
 	if name == "" {
 		return nil, -1, errors.New("image name is empty")
 	}
 
+	mirrorURLs := make([]string, 0, len(mirrors))
+	for _, m := range mirrors {
+		mu, err := url.Parse(m)
+		if err != nil {
+			return nil, -1, err
+		}
+		if mu.Scheme == "" || mu.Host == "" {
+			return nil, -1, fmt.Errorf("invalid mirror URL %q", m)
+		}
+		mirrorURLs = append(mirrorURLs, strings.TrimRight(m, "/"))
+	}
+
 	var ru *url.URL
 	var err error
 	if len(registryURL) != 0 {
@@ -111,6 +136,7 @@ func GetImage(name, registryURL string) (*Image, int, error) {
 	img := &Image{
 		Name:      name,
 		client:    client,
+		mirrors:   mirrorURLs,
 		endpoints: endpoints,
 		token:     token,
 		scheme:    ru.Scheme,
@@ -184,11 +210,11 @@ func (i *Image) LayerReader(id string) (io.ReadCloser, error) {
 	return resp.Body, nil
 }
 
-// LayerURLs returns several URLs for a specific layer.
+// LayerURLs returns several URLs for a specific layer, mirrors first.
 func (i *Image) LayerURLs(id string) []string {
 	var urls []string
-	for _, ep := range i.endpoints {
-		urls = append(urls, fmt.Sprintf("%s://%s/v1/images/%s/layer", i.scheme, ep, id))
+	for _, base := range i.baseURLs() {
+		urls = append(urls, fmt.Sprintf("%s/v1/images/%s/layer", base, id))
 	}
 	return urls
 }
@@ -216,15 +242,27 @@ func (i *Image) fetchTags() (map[string]string, error) {
 	return tags, nil
 }
 
+// baseURLs returns the base URLs to try requests against, in order: the
+// configured mirrors followed by the Docker API endpoints.
+func (i *Image) baseURLs() []string {
+	urls := make([]string, 0, len(i.mirrors)+len(i.endpoints))
+	urls = append(urls, i.mirrors...)
+	for _, ep := range i.endpoints {
+		urls = append(urls, fmt.Sprintf("%s://%s", i.scheme, ep))
+	}
+	return urls
+}
+
 // getAPIResponse takes a path and tries to get Docker API response from each
-// available Docker API endpoint. It returns raw HTTP response.
+// mirror and then each available Docker API endpoint. It returns raw HTTP
+// response.
 func (i *Image) getResponse(path string) (*http.Response, error) {
 	errors := make(map[string]error)
 
-	for _, ep := range i.endpoints {
-		resp, err := i.getResponseFromURL(fmt.Sprintf("%s://%s/%s", i.scheme, ep, path))
+	for _, base := range i.baseURLs() {
+		resp, err := i.getResponseFromURL(fmt.Sprintf("%s/%s", base, path))
 		if err != nil {
-			errors[ep] = err
+			errors[base] = err
 			continue
 		}
 
@@ -235,15 +273,16 @@ func (i *Image) getResponse(path string) (*http.Response, error) {
 }
 
 // parseJSONResponse takes a path and tries to get Docker API response from each
-// available Docker API endpoint. It tries to parse response as JSON and saves
-// the parsed version in the provided 'result' variable.
+// mirror and then each available Docker API endpoint. It tries to parse
+// response as JSON and saves the parsed version in the provided 'result'
+// variable.
 func (i *Image) parseResponse(path string, result interface{}) error {
 	errors := make(map[string]error)
 
-	for _, ep := range i.endpoints {
-		err := i.parseResponseFromURL(fmt.Sprintf("%s://%s/%s", i.scheme, ep, path), result)
+	for _, base := range i.baseURLs() {
+		err := i.parseResponseFromURL(fmt.Sprintf("%s/%s", base, path), result)
 		if err != nil {
-			errors[ep] = err
+			errors[base] = err
 			continue
 		}
 
@@ -324,9 +363,19 @@ func (i *Image) Cookie(u string) (string, error) {
 // while talking to them and returns a single error that contains all endpoint URLs
 // along with error for each URL.
 func combineEndpointErrors(allErrors map[string]error) error {
-	var parts []string
-	for ep, err := range allErrors {
-		parts = append(parts, fmt.Sprintf("%s: %s", ep, err))
+	if len(allErrors) == 0 {
+		return errors.New("no endpoints available")
+	}
+
+	endpoints := make([]string, 0, len(allErrors))
+	for ep := range allErrors {
+		endpoints = append(endpoints, ep)
+	}
+	sort.Strings(endpoints)
+
+	var result *multierror.Error
+	for _, ep := range endpoints {
+		result = multierror.Append(result, fmt.Errorf("%s: %w", ep, allErrors[ep]))
 	}
-	return errors.New(strings.Join(parts, ", "))
+	return result.ErrorOrNil()
 }
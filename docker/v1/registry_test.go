@@ -5,7 +5,12 @@ package v1
 
 import (
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
 	"sort"
+	"sync/atomic"
 	"testing"
 
 	"github.com/apcera/util/dockertest/v1"
@@ -142,3 +147,63 @@ func TestReadLayer(t *testing.T) {
 	r, err = img.LayerReader("badbad")
 	tt.TestExpectError(t, err)
 }
+
+func TestGetImageWithMirrors(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	registryURL, err := url.Parse(DockerHubRegistryURL)
+	tt.TestExpectSuccess(t, err)
+
+	var brokenHits, mirrorHits int32
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&brokenHits, 1)
+		http.Error(w, "mirror down", http.StatusBadGateway)
+	}))
+	defer broken.Close()
+	proxy := httputil.NewSingleHostReverseProxy(registryURL)
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&mirrorHits, 1)
+		proxy.ServeHTTP(w, r)
+	}))
+	defer mirror.Close()
+
+	// Requests go to the first working mirror, after the broken one.
+	img, statusCode, err := GetImageWithMirrors("foo/bar", "", []string{broken.URL, mirror.URL + "/"})
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, statusCode, 200)
+	tt.TestEqual(t, img.LayerURLs("deadbeef")[:2], []string{
+		broken.URL + "/v1/images/deadbeef/layer",
+		mirror.URL + "/v1/images/deadbeef/layer",
+	})
+
+	r, err := img.LayerReader("deadbeef")
+	tt.TestExpectSuccess(t, err)
+	body, err := ioutil.ReadAll(r)
+	r.Close()
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, body, []byte{0xd4, 0xe5, 0xf6})
+	tt.TestEqual(t, atomic.LoadInt32(&brokenHits), int32(2)) // Tags and layer.
+	tt.TestEqual(t, atomic.LoadInt32(&mirrorHits), int32(2))
+
+	// With every mirror failing, requests fall back to the registry.
+	img, statusCode, err = GetImageWithMirrors("foo/bar", "", []string{broken.URL})
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, statusCode, 200)
+	h, err := img.History("latest")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, h, []string{"deadbeef", "badcafe"})
+	tt.TestEqual(t, atomic.LoadInt32(&brokenHits), int32(4))
+	tt.TestEqual(t, atomic.LoadInt32(&mirrorHits), int32(2))
+
+	// When everything fails, the error names every endpoint tried.
+	_, err = img.LayerReader("badbad")
+	tt.TestExpectError(t, err)
+	tt.TestContains(t, err.Error(), broken.URL+": "+broken.URL+"/v1/images/badbad/layer: HTTP 502")
+	tt.TestContains(t, err.Error(), DockerHubRegistryURL+": ")
+	tt.TestContains(t, err.Error(), "http://test.example.com: ")
+
+	_, _, err = GetImageWithMirrors("foo/bar", "", []string{"mirror.example.com"})
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, err.Error(), `invalid mirror URL "mirror.example.com"`)
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package v1
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/apcera/util/tarhelper"
+)
+
+// AssembleOptions controls how the layers of an image are applied by
+// AssembleRootFS.
+type AssembleOptions struct {
+	// PreserveOwners restores the owners of files from the layers, passed
+	// through the mapping functions if they are set.
+	PreserveOwners bool
+
+	// OwnerMappingFunc and GroupMappingFunc map the UIDs and GIDs within the
+	// layers to those used on the host. See tarhelper.Untar.
+	OwnerMappingFunc func(int) (int, error)
+	GroupMappingFunc func(int) (int, error)
+
+	// SkipSpecialDevices skips the creation of device nodes, which requires
+	// privileges.
+	SkipSpecialDevices bool
+}
+
+// AssembleRootFS downloads the layers of the tagged image and applies them in
+// order, from the base layer up, into destDir to produce the image's root
+// filesystem. Whiteout entries within the layers remove the files they mark
+// from the layers below. The options may be nil to use the defaults.
+func (i *Image) AssembleRootFS(tagName, destDir string, opts *AssembleOptions) error {
+	if opts == nil {
+		opts = &AssembleOptions{}
+	}
+
+	history, err := i.History(tagName)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+
+	// History is ordered from the top layer down.
+	for j := len(history) - 1; j >= 0; j-- {
+		if err := i.applyLayer(history[j], destDir, opts); err != nil {
+			return fmt.Errorf("failed to apply layer %s: %v", history[j], err)
+		}
+	}
+	return nil
+}
+
+// applyLayer extracts a single layer on top of destDir.
+func (i *Image) applyLayer(id, destDir string, opts *AssembleOptions) error {
+	r, err := i.LayerReader(id)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	u := tarhelper.NewUntar(r, destDir)
+	u.AbsoluteRoot = destDir
+	u.Compression = tarhelper.DETECT
+	u.PreserveOwners = opts.PreserveOwners
+	u.SkipSpecialDevices = opts.SkipSpecialDevices
+	if opts.OwnerMappingFunc != nil {
+		u.OwnerMappingFunc = opts.OwnerMappingFunc
+	}
+	if opts.GroupMappingFunc != nil {
+		u.GroupMappingFunc = opts.GroupMappingFunc
+	}
+
+	u.Whiteouts = true
+	return u.Extract()
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package v1
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apcera/util/dockertest/v1"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// makeLayer returns a tar containing the entries. Names ending in a slash are
+// directories, the rest are files containing their name.
+func makeLayer(t *testing.T, names ...string) []byte {
+	buf := &bytes.Buffer{}
+	w := tar.NewWriter(buf)
+	for _, name := range names {
+		header := &tar.Header{Name: name, Mode: 0755, Typeflag: tar.TypeDir}
+		if !strings.HasSuffix(name, "/") {
+			header.Mode = 0644
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(name))
+		}
+		tt.TestExpectSuccess(t, w.WriteHeader(header))
+		if header.Typeflag == tar.TypeReg {
+			_, err := w.Write([]byte(name))
+			tt.TestExpectSuccess(t, err)
+		}
+	}
+	tt.TestExpectSuccess(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestAssembleRootFS(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	v1.AddImage("rootfs/test", "latest", []string{"rootfs1", "rootfs2", "rootfs3"}, [][]byte{
+		makeLayer(t, "./", "etc/", "etc/passwd", "etc/hosts", "opt/", "opt/a", "opt/sub/", "opt/sub/b", "tmp/", "tmp/x"),
+		makeLayer(t, "etc/", "etc/hosts", "etc/.wh.passwd", "tmp/.wh.x"),
+		makeLayer(t, "opt/", "opt/c", "opt/sub/", "opt/sub/d", "opt/.wh..wh..opq"),
+	})
+
+	img, _, err := GetImage("rootfs/test", "")
+	tt.TestExpectSuccess(t, err)
+
+	dest := filepath.Join(testHelper.TempDir(), "rootfs")
+	tt.TestExpectSuccess(t, img.AssembleRootFS("latest", dest, nil))
+
+	exists := func(name string) bool {
+		_, err := os.Lstat(filepath.Join(dest, name))
+		return err == nil
+	}
+
+	// whiteouts remove lower layers' files, but are not extracted
+	tt.TestFalse(t, exists("etc/passwd"))
+	tt.TestFalse(t, exists("etc/.wh.passwd"))
+	tt.TestFalse(t, exists("tmp/x"))
+	tt.TestTrue(t, exists("tmp"))
+	contents, err := ioutil.ReadFile(filepath.Join(dest, "etc/hosts"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, string(contents), "etc/hosts")
+
+	// opaque directories only contain the upper layer's contents
+	tt.TestFalse(t, exists("opt/a"))
+	tt.TestFalse(t, exists("opt/sub/b"))
+	tt.TestFalse(t, exists("opt/.wh..wh..opq"))
+	tt.TestTrue(t, exists("opt/c"))
+	tt.TestTrue(t, exists("opt/sub/d"))
+
+	// unknown tags fail
+	tt.TestExpectError(t, img.AssembleRootFS("missing", dest, nil))
+}
@@ -0,0 +1,186 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apcera/util/tarhelper"
+)
+
+// The fixtures restored by ResetFixtures.
+var (
+	defaultLayers = map[string]map[string]string{
+		"badcafe": {
+			"json":     `{"id":"badcafe","k1": "v1"}`,
+			"ancestry": `["badcafe"]`,
+			"layer":    string([]byte{0xa1, 0xb2, 0xc3}),
+			"checksum": "1ac330d",
+		},
+		"deadbeef": {
+			"json":     `{"id":"deadbeef","k2": "v2"}`,
+			"ancestry": `["deadbeef", "badcafe"]`,
+			"layer":    string([]byte{0xd4, 0xe5, 0xf6}),
+			"checksum": "2bd330f",
+		},
+		"bd51c4e1b5aceec2ff4bdd87d3fe5f1f16e1120490dee47e2999036f5bc55ccf": { // A random (valid) LayerID
+			"json":     `{"id":"bd51c4e1b5aceec2ff4bdd87d3fe5f1f16e1120490dee47e2999036f5bc55ccf","k1": "v1"}`,
+			"ancestry": `["bd51c4e1b5aceec2ff4bdd87d3fe5f1f16e1120490dee47e2999036f5bc55ccf"]`,
+			"layer":    string([]byte{0xa1, 0xb2, 0xc3}),
+			"checksum": "abcd1234",
+		},
+	}
+	defaultRepositories = map[string]map[string]string{
+		"foo/bar": {
+			"latest": "deadbeef",
+			"base":   "badcafe",
+		},
+		"some/image": {
+			"latest": "bd51c4e1b5aceec2ff4bdd87d3fe5f1f16e1120490dee47e2999036f5bc55ccf",
+		},
+		"base": {
+			"latest": "badcafe",
+		},
+	}
+)
+
+func init() {
+	ResetFixtures()
+}
+
+// ResetFixtures restores the mock registry's images and layers to the
+// defaults, removing any added by tests.
+func ResetFixtures() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	testLayers = make(map[string]map[string]string)
+	for id, layer := range defaultLayers {
+		testLayers[id] = copyStrings(layer)
+	}
+	testRepositories = make(map[string]map[string]string)
+	for repository, tags := range defaultRepositories {
+		testRepositories[repository] = copyStrings(tags)
+	}
+}
+
+func copyStrings(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// AddLayerContent registers a layer with the mock registry, replacing any
+// layer with the same ID. The parent is the ID of an already registered layer,
+// or empty for a base layer. The layer's JSON metadata only contains its ID and
+// parent.
+func AddLayerContent(id, parent string, content []byte) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return addLayer(id, parent, content)
+}
+
+// addLayer must be called with mu held.
+func addLayer(id, parent string, content []byte) error {
+	ancestry := []string{id}
+	metadata := map[string]string{"id": id}
+	if parent != "" {
+		p, ok := testLayers[parent]
+		if !ok {
+			return fmt.Errorf("parent layer %q is not registered", parent)
+		}
+		var parentAncestry []string
+		if err := json.Unmarshal([]byte(p["ancestry"]), &parentAncestry); err != nil {
+			return fmt.Errorf("invalid ancestry of parent layer %q: %v", parent, err)
+		}
+		ancestry = append(ancestry, parentAncestry...)
+		metadata["parent"] = parent
+	}
+
+	// maps of strings and slices of strings always marshal
+	metadataJSON, _ := json.Marshal(metadata)
+	ancestryJSON, _ := json.Marshal(ancestry)
+	testLayers[id] = map[string]string{
+		"json":     string(metadataJSON),
+		"ancestry": string(ancestryJSON),
+		"layer":    string(content),
+		"checksum": id,
+	}
+	return nil
+}
+
+// AddImage registers an image with the mock registry under the repository and
+// tag. The layers are ordered from the base layer to the top layer, and the
+// layer with the same index in ids is given their contents.
+func AddImage(repository, tag string, ids []string, layers [][]byte) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	parent := ""
+	for i, id := range ids {
+		// the parent was registered by the previous iteration
+		addLayer(id, parent, layers[i])
+		parent = id
+	}
+
+	if testRepositories[repository] == nil {
+		testRepositories[repository] = make(map[string]string)
+	}
+	testRepositories[repository][tag] = ids[len(ids)-1]
+}
+
+// LayerFromDir returns an uncompressed layer tarball of the contents of the
+// directory, created with tarhelper.
+func LayerFromDir(dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	tw := tarhelper.NewTar(&buf, dir)
+	tw.ExcludeRootPath = true
+	if err := tw.Archive(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// LayerFromFiles returns an uncompressed layer tarball containing the files,
+// mapping their paths to their contents. Names ending in a slash are created as
+// empty directories, and the parent directories of every file are created.
+// Whiteout files, such as "etc/.wh.passwd", can be included to remove files
+// from lower layers.
+func LayerFromFiles(files map[string]string) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "dockertest-layer")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			return nil, err
+		}
+	}
+	return LayerFromDir(dir)
+}
@@ -19,38 +19,13 @@ import (
 var (
 	testVerbose    = false // Change to true in order to see HTTP requests in test output.
 	testHttpServer *httptest.Server
-	testLayers     = map[string]map[string]string{
-		"badcafe": {
-			"json":     `{"id":"badcafe","k1": "v1"}`,
-			"ancestry": `["badcafe"]`,
-			"layer":    string([]byte{0xa1, 0xb2, 0xc3}),
-			"checksum": "1ac330d",
-		},
-		"deadbeef": {
-			"json":     `{"id":"deadbeef","k2": "v2"}`,
-			"ancestry": `["deadbeef", "badcafe"]`,
-			"layer":    string([]byte{0xd4, 0xe5, 0xf6}),
-			"checksum": "2bd330f",
-		},
-		"bd51c4e1b5aceec2ff4bdd87d3fe5f1f16e1120490dee47e2999036f5bc55ccf": { // A random (valid) LayerID
-			"json":     `{"id":"bd51c4e1b5aceec2ff4bdd87d3fe5f1f16e1120490dee47e2999036f5bc55ccf","k1": "v1"}`,
-			"ancestry": `["bd51c4e1b5aceec2ff4bdd87d3fe5f1f16e1120490dee47e2999036f5bc55ccf"]`,
-			"layer":    string([]byte{0xa1, 0xb2, 0xc3}),
-			"checksum": "abcd1234",
-		},
-	}
-	testRepositories = map[string]map[string]string{
-		"foo/bar": {
-			"latest": "deadbeef",
-			"base":   "badcafe",
-		},
-		"some/image": {
-			"latest": "bd51c4e1b5aceec2ff4bdd87d3fe5f1f16e1120490dee47e2999036f5bc55ccf",
-		},
-		"base": {
-			"latest": "badcafe",
-		},
-	}
+
+	// testLayers and testRepositories hold the fixtures served by the mock
+	// registry. They are set to the defaults by ResetFixtures and can be
+	// extended with AddImage and AddLayerContent.
+	testLayers       map[string]map[string]string
+	testRepositories map[string]map[string]string
+
 	mu sync.Mutex
 )
 
@@ -124,12 +99,14 @@ func handlerImages(w http.ResponseWriter, r *http.Request) {
 
 	var images []map[string]string
 
+	mu.Lock()
 	for imageID, layer := range testLayers {
 		image := make(map[string]string)
 		image["id"] = imageID
 		image["checksum"] = layer["checksum"]
 		images = append(images, image)
 	}
+	mu.Unlock()
 	writeResponse(w, 200, images)
 }
 
@@ -139,7 +116,9 @@ func handlerImage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	vars := mux.Vars(r)
+	mu.Lock()
 	layer, exists := testLayers[vars["image_id"]]
+	mu.Unlock()
 	if !exists {
 		http.NotFound(w, r)
 		return
@@ -156,7 +135,13 @@ func handlerTags(w http.ResponseWriter, r *http.Request) {
 	}
 
 	vars := mux.Vars(r)
-	tags, exists := testRepositories[vars["repository"]]
+	mu.Lock()
+	tags := make(map[string]string)
+	repoTags, exists := testRepositories[vars["repository"]]
+	for tag, id := range repoTags {
+		tags[tag] = id
+	}
+	mu.Unlock()
 	if !exists {
 		http.NotFound(w, r)
 		return
@@ -0,0 +1,180 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package v2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"time"
+)
+
+var (
+	// faults are the injected faults, checked in the order they were added.
+	faults []*injectedFault
+)
+
+// Fault describes a failure injected into the mock registry's responses to
+// exercise a client's retry handling. For example, to fail the second request
+// for any blob with 429 Too Many Requests:
+//
+//	InjectFault("GET", "/v2/*/*/blobs/*", Fault{
+//		After:      1,
+//		Times:      1,
+//		Status:     http.StatusTooManyRequests,
+//		RetryAfter: time.Second,
+//	})
+//	defer ClearFaults()
+type Fault struct {
+	// After is the number of matching requests to let through before the
+	// fault is applied.
+	After int
+
+	// Times is the number of requests the fault is applied to, after which
+	// requests are handled as usual. Zero means every request.
+	Times int
+
+	// Latency delays the response.
+	Latency time.Duration
+
+	// Status responds with the status code instead of handling the request,
+	// with Body as the response body.
+	Status int
+	Body   string
+
+	// RetryAfter sets the Retry-After header, in whole seconds, on a Status
+	// response.
+	RetryAfter time.Duration
+
+	// AuthFailure responds with 401 Unauthorized and a token challenge,
+	// regardless of any Authorization header.
+	AuthFailure bool
+
+	// TruncateAfter, if positive, handles the request as usual but closes
+	// the connection after this many bytes of the body are sent. The
+	// Content-Length of the whole body is still sent.
+	TruncateAfter int
+}
+
+type injectedFault struct {
+	method  string
+	pattern string
+	fault   Fault
+	seen    int
+}
+
+// InjectFault adds a fault to requests with the method and a path matching
+// the pattern, using the syntax of path.Match. Faults apply whether or not a
+// Scenario is active.
+func InjectFault(method, pattern string, fault Fault) error {
+	if _, err := path.Match(pattern, "/"); err != nil {
+		return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	faults = append(faults, &injectedFault{method: method, pattern: pattern, fault: fault})
+	return nil
+}
+
+// ClearFaults removes every injected fault.
+func ClearFaults() {
+	mu.Lock()
+	defer mu.Unlock()
+	faults = nil
+}
+
+// matchFault returns the fault to apply to the request, if any.
+func matchFault(r *http.Request) *Fault {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, f := range faults {
+		if f.method != r.Method {
+			continue
+		}
+		if ok, _ := path.Match(f.pattern, r.URL.Path); !ok {
+			continue
+		}
+		f.seen++
+		n := f.seen - f.fault.After
+		if n <= 0 || (f.fault.Times > 0 && n > f.fault.Times) {
+			continue
+		}
+		fault := f.fault
+		return &fault
+	}
+	return nil
+}
+
+// faultHandler applies any injected fault before falling back to the handler.
+func faultHandler(handler http.Handler) http.Handler {
+	fh := func(w http.ResponseWriter, r *http.Request) {
+		fault := matchFault(r)
+		if fault == nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		if fault.Latency > 0 {
+			select {
+			case <-time.After(fault.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		switch {
+		case fault.AuthFailure:
+			writeChallenge(w, r)
+		case fault.Status != 0:
+			if fault.RetryAfter > 0 {
+				seconds := int((fault.RetryAfter + time.Second - 1) / time.Second)
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			}
+			w.WriteHeader(fault.Status)
+			w.Write([]byte(fault.Body))
+		case fault.TruncateAfter > 0:
+			writeTruncated(w, r, handler, fault.TruncateAfter)
+		default:
+			handler.ServeHTTP(w, r)
+		}
+	}
+	return http.HandlerFunc(fh)
+}
+
+// writeTruncated sends the handler's response, closing the connection after n
+// bytes of the body.
+func writeTruncated(w http.ResponseWriter, r *http.Request, handler http.Handler, n int) {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	body := rec.Body.Bytes()
+	if n >= len(body) {
+		copyRecorded(w, rec)
+		return
+	}
+
+	for key, values := range rec.Header() {
+		w.Header()[key] = values
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(rec.Code)
+	w.Write(body[:n])
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	if h, ok := w.(http.Hijacker); ok {
+		if conn, _, err := h.Hijack(); err == nil {
+			conn.Close()
+		}
+	}
+}
+
+// copyRecorded writes a recorded response.
+func copyRecorded(w http.ResponseWriter, rec *httptest.ResponseRecorder) {
+	for key, values := range rec.Header() {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(rec.Code)
+	w.Write(rec.Body.Bytes())
+}
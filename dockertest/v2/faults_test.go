@@ -0,0 +1,217 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package v2
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestInjectFault(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	blob := AddBlob([]byte("faulty blob"))
+	blobPath := "/v2/library/nats/blobs/" + blob
+
+	tests := []struct {
+		name    string
+		method  string
+		pattern string
+		fault   Fault
+
+		// requests are made with reqMethod (GET by default) to path,
+		// expecting the statuses in want in turn, each with the body if
+		// set and taking at least minElapsed
+		reqMethod  string
+		path       string
+		want       []int
+		retryAfter string
+		body       string
+		minElapsed time.Duration
+	}{
+		{
+			name:    "status and body",
+			method:  "GET",
+			pattern: "/v2/*/*/blobs/*",
+			fault:   Fault{Status: http.StatusServiceUnavailable, Body: "down"},
+			path:    blobPath,
+			want:    []int{503, 503},
+			body:    "down",
+		},
+		{
+			name:    "after and times",
+			method:  "GET",
+			pattern: "/v2/*/*/blobs/*",
+			fault:   Fault{After: 1, Times: 2, Status: http.StatusTooManyRequests},
+			path:    blobPath,
+			want:    []int{200, 429, 429, 200, 200},
+		},
+		{
+			name:    "times without after",
+			method:  "GET",
+			pattern: "/v2/*/*/blobs/*",
+			fault:   Fault{Times: 1, Status: http.StatusBadGateway},
+			path:    blobPath,
+			want:    []int{502, 200},
+		},
+		{
+			name:       "retry after whole seconds",
+			method:     "GET",
+			pattern:    "/v2/*/*/blobs/*",
+			fault:      Fault{Status: http.StatusTooManyRequests, RetryAfter: 2 * time.Second},
+			path:       blobPath,
+			want:       []int{429},
+			retryAfter: "2",
+		},
+		{
+			name:       "retry after rounds up",
+			method:     "GET",
+			pattern:    "/v2/*/*/blobs/*",
+			fault:      Fault{Status: http.StatusTooManyRequests, RetryAfter: 1500 * time.Millisecond},
+			path:       blobPath,
+			want:       []int{429},
+			retryAfter: "2",
+		},
+		{
+			name:       "retry after under a second",
+			method:     "GET",
+			pattern:    "/v2/*/*/blobs/*",
+			fault:      Fault{Status: http.StatusTooManyRequests, RetryAfter: time.Millisecond},
+			path:       blobPath,
+			want:       []int{429},
+			retryAfter: "1",
+		},
+		{
+			name:    "retry after needs a status",
+			method:  "GET",
+			pattern: "/v2/*/*/blobs/*",
+			fault:   Fault{RetryAfter: time.Second},
+			path:    blobPath,
+			want:    []int{200},
+		},
+		{
+			name:    "auth failure",
+			method:  "GET",
+			pattern: "/v2/*/*/manifests/*",
+			fault:   Fault{Times: 1, AuthFailure: true},
+			path:    "/v2/library/nats/manifests/latest",
+			want:    []int{401, 200},
+		},
+		{
+			name:       "latency",
+			method:     "GET",
+			pattern:    "/v2/",
+			fault:      Fault{Latency: 50 * time.Millisecond},
+			path:       "/v2/",
+			want:       []int{200},
+			minElapsed: 50 * time.Millisecond,
+		},
+		{
+			name:       "latency then status",
+			method:     "GET",
+			pattern:    "/v2/",
+			fault:      Fault{Latency: 50 * time.Millisecond, Status: http.StatusGatewayTimeout},
+			path:       "/v2/",
+			want:       []int{504},
+			minElapsed: 50 * time.Millisecond,
+		},
+		{
+			name:    "truncation past the end",
+			method:  "GET",
+			pattern: "/v2/*/*/blobs/*",
+			fault:   Fault{TruncateAfter: 100},
+			path:    blobPath,
+			want:    []int{200},
+			body:    "faulty blob",
+		},
+		{
+			name:      "other method",
+			method:    "GET",
+			pattern:   "/v2/*/*/blobs/*",
+			fault:     Fault{Status: http.StatusInternalServerError},
+			reqMethod: "HEAD",
+			path:      blobPath,
+			want:      []int{200},
+		},
+		{
+			name:    "other path",
+			method:  "GET",
+			pattern: "/v2/*/*/manifests/*",
+			fault:   Fault{Status: http.StatusInternalServerError},
+			path:    blobPath,
+			want:    []int{200},
+			body:    "faulty blob",
+		},
+	}
+
+	for _, test := range tests {
+		tt.TestExpectSuccess(t, InjectFault(test.method, test.pattern, test.fault), test.name)
+		method := test.reqMethod
+		if method == "" {
+			method = "GET"
+		}
+		for i, want := range test.want {
+			start := time.Now()
+			resp, body := request(t, method, test.path, true)
+			elapsed := time.Since(start)
+			msg := fmt.Sprintf("%s: request %d", test.name, i)
+			tt.TestEqual(t, resp.StatusCode, want, msg)
+			if want == http.StatusUnauthorized {
+				tt.TestHasPrefix(t, resp.Header.Get("WWW-Authenticate"), "Bearer realm=", msg)
+			} else {
+				tt.TestEqual(t, resp.Header.Get("WWW-Authenticate"), "", msg)
+			}
+			if want != http.StatusOK {
+				tt.TestEqual(t, resp.Header.Get("Retry-After"), test.retryAfter, msg)
+			} else {
+				tt.TestEqual(t, resp.Header.Get("Retry-After"), "", msg)
+			}
+			if test.body != "" {
+				tt.TestEqual(t, string(body), test.body, msg)
+			}
+			tt.TestEqual(t, elapsed >= test.minElapsed, true, msg, elapsed.String())
+		}
+		ClearFaults()
+	}
+
+	tt.TestExpectError(t, InjectFault("GET", "/v2/[", Fault{}))
+}
+
+func TestInjectFaultTruncate(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	content := []byte("a blob which is cut short")
+	blob := AddBlob(content)
+	tt.TestExpectSuccess(t, InjectFault("GET", "/v2/*/*/blobs/*", Fault{Times: 1, TruncateAfter: 5}))
+	defer ClearFaults()
+
+	req, err := http.NewRequest("GET", RunMockRegistry().URL+"/v2/library/nats/blobs/"+blob, nil)
+	tt.TestExpectSuccess(t, err)
+	req.Header.Set("Authorization", "Bearer someBearerToken")
+
+	resp, err := http.DefaultClient.Do(req)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, resp.StatusCode, http.StatusOK)
+	tt.TestEqual(t, resp.ContentLength, int64(len(content)))
+	tt.TestEqual(t, resp.Header.Get("Docker-Content-Digest"), blob)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	tt.TestErrorIs(t, err, io.ErrUnexpectedEOF)
+	tt.TestEqual(t, body, content[:5])
+
+	// the fault only applies once
+	resp, err = http.DefaultClient.Do(req)
+	tt.TestExpectSuccess(t, err)
+	body, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, body, content)
+}
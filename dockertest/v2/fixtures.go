@@ -0,0 +1,100 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package v2
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// Media types of the manifests and blobs served by the mock registry.
+const (
+	MediaTypeManifestV1  = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+	MediaTypeManifestV2  = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeImageConfig = "application/vnd.docker.container.image.v1+json"
+	MediaTypeLayer       = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+var (
+	// testBlobs maps the digests of registered blobs to their content.
+	testBlobs = map[string][]byte{}
+
+	// testManifestTypes maps the keys of testImageManifests to their media
+	// type, for manifests which aren't schema1.
+	testManifestTypes = map[string]string{}
+)
+
+// Descriptor refers to a blob from a schema2 manifest.
+type Descriptor struct {
+	MediaType string `json:"mediaType"`
+	Size      int64  `json:"size"`
+	Digest    string `json:"digest"`
+}
+
+// Schema2Manifest is an image manifest of schema version 2.
+type Schema2Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+}
+
+// Digest returns the sha256 digest of the content, in the form used by the
+// registry API.
+func Digest(content []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+}
+
+// AddBlob registers a blob with the mock registry, returning its digest. The
+// blob is served for any repository.
+func AddBlob(content []byte) string {
+	digest := Digest(content)
+	mu.Lock()
+	defer mu.Unlock()
+	testBlobs[digest] = content
+	return digest
+}
+
+// AddManifest registers a manifest of the given media type for the image, such
+// as "library/nats", under the reference, which is normally a tag. The
+// manifest can also be fetched by its digest, which is returned.
+func AddManifest(name, reference, mediaType, manifest string) string {
+	digest := Digest([]byte(manifest))
+	mu.Lock()
+	defer mu.Unlock()
+	for _, ref := range []string{reference, digest} {
+		key := fmt.Sprintf("%s:%s", name, ref)
+		testImageManifests[key] = manifest
+		testManifestTypes[key] = mediaType
+	}
+	return digest
+}
+
+// AddSchema2Image registers a schema2 image made up of the config and the
+// layers, ordered from the base layer, along with their blobs. It returns the
+// manifest so the digests of the blobs are available to the test, along with
+// the digest of the manifest.
+func AddSchema2Image(name, tag string, config []byte, layers ...[]byte) (*Schema2Manifest, string) {
+	manifest := &Schema2Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeManifestV2,
+		Config: Descriptor{
+			MediaType: MediaTypeImageConfig,
+			Size:      int64(len(config)),
+			Digest:    AddBlob(config),
+		},
+	}
+	for _, layer := range layers {
+		manifest.Layers = append(manifest.Layers, Descriptor{
+			MediaType: MediaTypeLayer,
+			Size:      int64(len(layer)),
+			Digest:    AddBlob(layer),
+		})
+	}
+
+	// the manifest only contains strings and numbers, so it can't fail to
+	// marshal
+	b, _ := json.Marshal(manifest)
+	return manifest, AddManifest(name, tag, MediaTypeManifestV2, string(b))
+}
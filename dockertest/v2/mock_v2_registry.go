@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"sync"
 
 	"github.com/gorilla/mux"
@@ -40,10 +41,10 @@ func RunMockRegistry() *httptest.Server {
 
 	r.HandleFunc("/token", handlerToken).Methods("GET")
 	r.HandleFunc("/v2/", handlerSupport).Methods("GET")
-	r.HandleFunc("/v2/{repo:[^/]+}/{image_name:[^/]+}/manifests/{image_ref:[^/]+}", handlerImageManifest).Methods("GET")
-	r.HandleFunc("/v2/{repo:[^/]+}/{image_name:[^/]+}/blobs/{blob_ref:[^/]+}", handlerBlob).Methods("GET")
+	r.HandleFunc("/v2/{repo:[^/]+}/{image_name:[^/]+}/manifests/{image_ref:[^/]+}", handlerImageManifest).Methods("GET", "HEAD")
+	r.HandleFunc("/v2/{repo:[^/]+}/{image_name:[^/]+}/blobs/{blob_ref:[^/]+}", handlerBlob).Methods("GET", "HEAD")
 
-	testHttpServer = httptest.NewServer(logHandler(r))
+	testHttpServer = httptest.NewServer(logHandler(faultHandler(scenarioHandler(r))))
 	return testHttpServer
 }
 
@@ -88,13 +89,20 @@ func checkAuth(w http.ResponseWriter, r *http.Request) bool {
 		return true
 	}
 
+	writeChallenge(w, r)
+	return false
+}
+
+// writeChallenge responds with 401 Unauthorized and a bearer token challenge
+// for the mock registry's token endpoint.
+func writeChallenge(w http.ResponseWriter, r *http.Request) {
 	realm := fmt.Sprintf("http://%s/token", r.Host)
 	service := fmt.Sprintf("http://%s", r.Host)
 
 	// TODO: allow user to specify a scope on the request and have it respected?
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
 	w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q,service=%q`, realm, service))
 	writeResponse(w, http.StatusUnauthorized, "Bad auth")
-	return false
 }
 
 func handlerToken(w http.ResponseWriter, r *http.Request) {
@@ -152,11 +160,22 @@ func handlerImageManifest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	manifest, exists := testImageManifests[fmt.Sprintf("%s/%s:%s", repo, imageName, imageRef)]
+	key := fmt.Sprintf("%s/%s:%s", repo, imageName, imageRef)
+	mu.Lock()
+	manifest, exists := testImageManifests[key]
+	mediaType := testManifestTypes[key]
+	mu.Unlock()
 	if !exists {
 		http.NotFound(w, r)
 		return
 	}
+	if mediaType == "" {
+		mediaType = MediaTypeManifestV1
+	}
+	w.Header().Set("Content-Type", mediaType)
+	if mediaType != MediaTypeManifestV1 {
+		w.Header().Set("Docker-Content-Digest", Digest([]byte(manifest)))
+	}
 	io.WriteString(w, manifest)
 }
 
@@ -184,6 +203,18 @@ func handlerBlob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Just write back the blob reference; completely fake content, not even a tar.
+	mu.Lock()
+	content, exists := testBlobs[blobRef]
+	mu.Unlock()
+	if exists {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Header().Set("Docker-Content-Digest", blobRef)
+		w.Write(content)
+		return
+	}
+
+	// Blobs which weren't added with AddBlob get fake content: just the blob
+	// reference written back, not even a tar.
 	io.WriteString(w, blobRef)
 }
@@ -0,0 +1,142 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package v2
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// request makes a request to the mock registry, with an Authorization header
+// unless auth is false, returning the response and its body.
+func request(t *testing.T, method, path string, auth bool) (*http.Response, []byte) {
+	server := RunMockRegistry()
+	req, err := http.NewRequest(method, server.URL+path, nil)
+	tt.TestExpectSuccess(t, err)
+	if auth {
+		req.Header.Set("Authorization", "Bearer someBearerToken")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	tt.TestExpectSuccess(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	tt.TestExpectSuccess(t, err)
+	return resp, body
+}
+
+func TestMockRegistryAuth(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	resp, _ := request(t, "GET", "/v2/", false)
+	tt.TestEqual(t, resp.StatusCode, http.StatusUnauthorized)
+	tt.TestEqual(t, resp.Header.Get("Docker-Distribution-API-Version"), "registry/2.0")
+	host := resp.Request.URL.Host
+	tt.TestEqual(t, resp.Header.Get("WWW-Authenticate"),
+		`Bearer realm="http://`+host+`/token",service="http://`+host+`"`)
+
+	resp, body := request(t, "GET", "/token?service=http://"+host+"&scope=repository:library/nats:pull", false)
+	tt.TestEqual(t, resp.StatusCode, http.StatusOK)
+	var token struct {
+		Token string `json:"token"`
+	}
+	tt.TestExpectSuccess(t, json.Unmarshal(body, &token))
+	tt.TestEqual(t, token.Token, "Bearer someBearerToken")
+
+	resp, body = request(t, "GET", "/v2/", true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusOK)
+	tt.TestEqual(t, string(body), "v2 API supported!")
+
+	SetSkipAuth(true)
+	defer SetSkipAuth(false)
+	resp, _ = request(t, "GET", "/v2/library/nats/manifests/latest", false)
+	tt.TestEqual(t, resp.StatusCode, http.StatusOK)
+}
+
+func TestMockRegistrySchema2Image(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	layers := [][]byte{[]byte("base layer"), []byte("top layer")}
+	manifest, digest := AddSchema2Image("library/mock", "v1.0", config, layers...)
+	tt.TestEqual(t, len(manifest.Layers), 2)
+
+	// the manifest is served by tag and by digest
+	for _, ref := range []string{"v1.0", digest} {
+		resp, _ := request(t, "GET", "/v2/library/mock/manifests/"+ref, false)
+		tt.TestEqual(t, resp.StatusCode, http.StatusUnauthorized, ref)
+
+		resp, body := request(t, "GET", "/v2/library/mock/manifests/"+ref, true)
+		tt.TestEqual(t, resp.StatusCode, http.StatusOK, ref)
+		tt.TestEqual(t, resp.Header.Get("Content-Type"), MediaTypeManifestV2, ref)
+		tt.TestEqual(t, resp.Header.Get("Docker-Content-Digest"), digest, ref)
+		tt.TestEqual(t, Digest(body), digest, ref)
+		var got Schema2Manifest
+		tt.TestExpectSuccess(t, json.Unmarshal(body, &got))
+		tt.TestEqual(t, &got, manifest, ref)
+
+		resp, body = request(t, "HEAD", "/v2/library/mock/manifests/"+ref, true)
+		tt.TestEqual(t, resp.StatusCode, http.StatusOK, ref)
+		tt.TestEqual(t, resp.Header.Get("Docker-Content-Digest"), digest, ref)
+		tt.TestEqual(t, len(body), 0, ref)
+	}
+
+	// the config and layers are served as blobs matching their descriptors
+	descriptors := append([]Descriptor{manifest.Config}, manifest.Layers...)
+	contents := append([][]byte{config}, layers...)
+	tt.TestEqual(t, manifest.Config.MediaType, MediaTypeImageConfig)
+	for i, desc := range descriptors {
+		if i > 0 {
+			tt.TestEqual(t, desc.MediaType, MediaTypeLayer)
+		}
+		resp, body := request(t, "GET", "/v2/library/mock/blobs/"+desc.Digest, true)
+		tt.TestEqual(t, resp.StatusCode, http.StatusOK, desc.Digest)
+		tt.TestEqual(t, resp.Header.Get("Content-Type"), "application/octet-stream")
+		tt.TestEqual(t, resp.Header.Get("Docker-Content-Digest"), desc.Digest)
+		tt.TestEqual(t, resp.ContentLength, desc.Size)
+		tt.TestEqual(t, body, contents[i])
+		tt.TestEqual(t, Digest(body), desc.Digest)
+	}
+
+	resp, _ := request(t, "GET", "/v2/library/mock/manifests/v2.0", true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusNotFound)
+	resp, _ = request(t, "GET", "/v2/library/other/manifests/v1.0", true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusNotFound)
+}
+
+func TestMockRegistrySchema1Image(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	resp, body := request(t, "GET", "/v2/library/foobar/manifests/latest", true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusOK)
+	tt.TestEqual(t, resp.Header.Get("Content-Type"), MediaTypeManifestV1)
+	tt.TestEqual(t, resp.Header.Get("Docker-Content-Digest"), "")
+	tt.TestEqual(t, string(body), libraryFoobarLatestManifest)
+
+	// blobs which weren't added are served as their reference
+	var manifest struct {
+		FSLayers []struct {
+			BlobSum string `json:"blobSum"`
+		} `json:"fsLayers"`
+	}
+	tt.TestExpectSuccess(t, json.Unmarshal(body, &manifest))
+	tt.TestEqual(t, len(manifest.FSLayers), 2)
+	for _, layer := range manifest.FSLayers {
+		resp, body := request(t, "GET", "/v2/library/foobar/blobs/"+layer.BlobSum, true)
+		tt.TestEqual(t, resp.StatusCode, http.StatusOK)
+		tt.TestEqual(t, string(body), layer.BlobSum)
+	}
+
+	// a manifest added with a media type is served with it
+	digest := AddManifest("library/foobar", "schema1", MediaTypeManifestV1, libraryFoobarLatestManifest)
+	resp, body = request(t, "GET", "/v2/library/foobar/manifests/"+digest, true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusOK)
+	tt.TestEqual(t, resp.Header.Get("Content-Type"), MediaTypeManifestV1)
+	tt.TestEqual(t, Digest(body), digest)
+}
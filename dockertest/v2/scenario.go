@@ -0,0 +1,215 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package v2
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	// scenario is the active Scenario, if one is set.
+	scenario *Scenario
+)
+
+// Scenario scripts a sequence of responses from the mock registry so that a
+// client's retry and authentication handling can be verified step by step. For
+// example:
+//
+//	s := NewScenario()
+//	s.Step("GET", "/v2/library/nats/manifests/latest").Challenge()
+//	s.Step("GET", "/v2/library/nats/manifests/latest").Passthrough()
+//	s.Step("GET", "/v2/library/nats/blobs/sha256:1234").Status(503)
+//	s.Step("GET", "/v2/library/nats/blobs/sha256:1234").Passthrough()
+//	SetScenario(s)
+//	defer SetScenario(nil)
+//
+//	// ... exercise the client ...
+//
+//	if err := s.Verify(); err != nil {
+//		t.Fatal(err)
+//	}
+//
+// Requests for a method and path with pending steps must arrive in the order
+// the steps were added, and each step is consumed by a single request. Any
+// other requests are handled by the mock registry as usual.
+type Scenario struct {
+	mu     sync.Mutex
+	steps  []*Step
+	next   int
+	calls  []Call
+	errors []string
+}
+
+// Step is a single scripted response within a Scenario. The methods on Step
+// configure the response and return the Step so they can be chained.
+type Step struct {
+	method      string
+	path        string
+	status      int
+	header      http.Header
+	body        string
+	challenge   bool
+	passthrough bool
+}
+
+// Call records a request received by the mock registry while a Scenario was
+// active.
+type Call struct {
+	Method string
+	Path   string
+
+	// Step is the index of the step which handled the request, or -1 if the
+	// request was not scripted.
+	Step int
+}
+
+// NewScenario returns an empty Scenario.
+func NewScenario() *Scenario {
+	return &Scenario{}
+}
+
+// SetScenario sets the Scenario used by the mock registry. Passing nil
+// restores the default behavior.
+func SetScenario(s *Scenario) {
+	mu.Lock()
+	defer mu.Unlock()
+	scenario = s
+}
+
+// Step appends a step to the scenario which handles a request with the given
+// method and URL path. By default the step responds with 200 OK and an empty
+// body.
+func (s *Scenario) Step(method, path string) *Step {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	step := &Step{
+		method: method,
+		path:   path,
+		status: http.StatusOK,
+		header: make(http.Header),
+	}
+	s.steps = append(s.steps, step)
+	return step
+}
+
+// Status sets the status code of the response.
+func (st *Step) Status(code int) *Step {
+	st.status = code
+	return st
+}
+
+// Header adds a header to the response.
+func (st *Step) Header(key, value string) *Step {
+	st.header.Add(key, value)
+	return st
+}
+
+// Body sets the body of the response.
+func (st *Step) Body(body string) *Step {
+	st.body = body
+	return st
+}
+
+// Challenge responds with 401 Unauthorized and a bearer token challenge for the
+// mock registry's token endpoint, regardless of any Authorization header.
+func (st *Step) Challenge() *Step {
+	st.challenge = true
+	return st
+}
+
+// Passthrough lets the mock registry handle the request as usual, so the step
+// only asserts that the request was made in order.
+func (st *Step) Passthrough() *Step {
+	st.passthrough = true
+	return st
+}
+
+// Calls returns the requests received while the scenario was active.
+func (s *Scenario) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	calls := make([]Call, len(s.calls))
+	copy(calls, s.calls)
+	return calls
+}
+
+// Verify returns an error if any request arrived out of order or if any steps
+// were not consumed.
+func (s *Scenario) Verify() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errors := s.errors
+	for i := s.next; i < len(s.steps); i++ {
+		errors = append(errors, fmt.Sprintf("step %d (%s %s) was never reached",
+			i, s.steps[i].method, s.steps[i].path))
+	}
+	if len(errors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("scenario failed:\n%s", strings.Join(errors, "\n"))
+}
+
+// match finds the step for the request, returning nil if the request was not
+// scripted. A request for a scripted method and path which is not the next step
+// is recorded as an error and returns false.
+func (s *Scenario) match(r *http.Request) (*Step, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := s.next; i < len(s.steps); i++ {
+		step := s.steps[i]
+		if step.method != r.Method || step.path != r.URL.Path {
+			continue
+		}
+		if i != s.next {
+			next := s.steps[s.next]
+			s.errors = append(s.errors, fmt.Sprintf("%s %s arrived as step %d, expected %s %s",
+				r.Method, r.URL.Path, i, next.method, next.path))
+			s.calls = append(s.calls, Call{Method: r.Method, Path: r.URL.Path, Step: -1})
+			return nil, false
+		}
+		s.next++
+		s.calls = append(s.calls, Call{Method: r.Method, Path: r.URL.Path, Step: i})
+		return step, true
+	}
+
+	s.calls = append(s.calls, Call{Method: r.Method, Path: r.URL.Path, Step: -1})
+	return nil, true
+}
+
+// scenarioHandler applies the active Scenario, if any, before falling back to
+// the handler.
+func scenarioHandler(handler http.Handler) http.Handler {
+	sh := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		s := scenario
+		mu.Unlock()
+		if s == nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		step, ok := s.match(r)
+		switch {
+		case !ok:
+			writeResponse(w, http.StatusInternalServerError, "request out of order")
+		case step == nil || step.passthrough:
+			handler.ServeHTTP(w, r)
+		case step.challenge:
+			writeChallenge(w, r)
+		default:
+			for key, values := range step.header {
+				for _, value := range values {
+					w.Header().Add(key, value)
+				}
+			}
+			w.WriteHeader(step.status)
+			io.WriteString(w, step.body)
+		}
+	}
+	return http.HandlerFunc(sh)
+}
@@ -0,0 +1,114 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package v2
+
+import (
+	"net/http"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestScenarioChallengeThenSuccess(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	manifestPath := "/v2/library/nats/manifests/latest"
+	s := NewScenario()
+	s.Step("GET", manifestPath).Challenge()
+	s.Step("GET", manifestPath).Passthrough()
+	SetScenario(s)
+	defer SetScenario(nil)
+
+	// the challenge is sent even though the request is authorized
+	resp, _ := request(t, "GET", manifestPath, true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusUnauthorized)
+	tt.TestHasPrefix(t, resp.Header.Get("WWW-Authenticate"), "Bearer realm=")
+	tt.TestEqual(t, s.Verify().Error(), "scenario failed:\n"+
+		"step 1 (GET "+manifestPath+") was never reached")
+
+	// requests which aren't scripted are handled as usual
+	resp, _ = request(t, "GET", "/v2/", true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusOK)
+
+	resp, body := request(t, "GET", manifestPath, true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusOK)
+	tt.TestEqual(t, string(body), libraryNatsLatestManifest)
+	tt.TestExpectSuccess(t, s.Verify())
+	tt.TestEqual(t, s.Calls(), []Call{
+		{Method: "GET", Path: manifestPath, Step: 0},
+		{Method: "GET", Path: "/v2/", Step: -1},
+		{Method: "GET", Path: manifestPath, Step: 1},
+	})
+
+	// once the steps are consumed, requests are handled as usual
+	resp, _ = request(t, "GET", manifestPath, true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusOK)
+	tt.TestExpectSuccess(t, s.Verify())
+}
+
+func TestScenarioResponse(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	blobPath := "/v2/library/nats/blobs/sha256:1234"
+	s := NewScenario()
+	s.Step("HEAD", blobPath).Status(http.StatusNotFound)
+	s.Step("GET", blobPath).Status(http.StatusServiceUnavailable).
+		Header("Retry-After", "3").Body("try later")
+	s.Step("GET", blobPath)
+	SetScenario(s)
+	defer SetScenario(nil)
+
+	resp, _ := request(t, "HEAD", blobPath, true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusNotFound)
+
+	resp, body := request(t, "GET", blobPath, true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusServiceUnavailable)
+	tt.TestEqual(t, resp.Header.Get("Retry-After"), "3")
+	tt.TestEqual(t, string(body), "try later")
+
+	// a step defaults to 200 OK with an empty body
+	resp, body = request(t, "GET", blobPath, true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusOK)
+	tt.TestEqual(t, len(body), 0)
+	tt.TestExpectSuccess(t, s.Verify())
+
+	// without a scenario, the mock registry serves the blob
+	SetScenario(nil)
+	resp, body = request(t, "GET", blobPath, true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusOK)
+	tt.TestEqual(t, string(body), "sha256:1234")
+}
+
+func TestScenarioOutOfOrder(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	manifestPath := "/v2/library/nats/manifests/latest"
+	blobPath := "/v2/library/nats/blobs/sha256:1234"
+	s := NewScenario()
+	s.Step("GET", manifestPath).Passthrough()
+	s.Step("GET", blobPath).Passthrough()
+	SetScenario(s)
+	defer SetScenario(nil)
+
+	// the blob is requested before the manifest, so it isn't consumed
+	resp, _ := request(t, "GET", blobPath, true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusInternalServerError)
+
+	resp, _ = request(t, "GET", manifestPath, true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusOK)
+	resp, _ = request(t, "GET", blobPath, true)
+	tt.TestEqual(t, resp.StatusCode, http.StatusOK)
+
+	tt.TestEqual(t, s.Calls(), []Call{
+		{Method: "GET", Path: blobPath, Step: -1},
+		{Method: "GET", Path: manifestPath, Step: 0},
+		{Method: "GET", Path: blobPath, Step: 1},
+	})
+	err := s.Verify()
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, err.Error(), "scenario failed:\n"+
+		"GET "+blobPath+" arrived as step 1, expected GET "+manifestPath)
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// Package envflag sets flags from environment variables, so binaries which
+// configure themselves with the flag package can also be configured within a
+// container's environment.
+//
+// A flag named "listen-addr" is set from the variable LISTEN_ADDR, or
+// MYAPP_LISTEN_ADDR when a prefix of "MYAPP" is used. Flags given on the
+// command line take precedence over the environment, which takes precedence
+// over the flag's default.
+//
+//	fs := flag.NewFlagSet("myapp", flag.ExitOnError)
+//	addr := fs.String("listen-addr", ":8080", "address to listen on")
+//	if err := envflag.Parse(fs, "MYAPP", os.Args[1:]); err != nil {
+//		log.Fatal(err)
+//	}
+package envflag
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// lookupEnv is overridden within the tests.
+var lookupEnv = os.LookupEnv
+
+// Parse parses the command line arguments into the flag set, and then sets any
+// flag which was not given on the command line from its environment variable.
+func Parse(fs *flag.FlagSet, prefix string, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return Apply(fs, prefix)
+}
+
+// Apply sets each flag within the flag set which has not already been set from
+// its environment variable, if the variable is set. Flags which have already
+// been set, such as by FlagSet.Parse, are left alone. An error is returned if
+// a variable's value is not valid for its flag.
+func Apply(fs *flag.FlagSet, prefix string) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] {
+			return
+		}
+		name := VarName(prefix, f.Name)
+		value, ok := lookupEnv(name)
+		if !ok {
+			return
+		}
+		if serr := fs.Set(f.Name, value); serr != nil {
+			err = fmt.Errorf("invalid value %q for environment variable %s: %v", value, name, serr)
+		}
+	})
+	return err
+}
+
+// VarName returns the name of the environment variable used for the flag. The
+// flag name is upper cased and any character other than a letter or digit is
+// replaced with an underscore. A non-empty prefix is joined with an
+// underscore.
+func VarName(prefix, flagName string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, flagName)
+	if prefix != "" {
+		name = prefix + "_" + name
+	}
+	return name
+}
@@ -0,0 +1,73 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package envflag
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// fakeEnv replaces the environment for the duration of the test.
+func fakeEnv(testHelper *tt.TestTool, env map[string]string) {
+	lookupEnv = func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+	testHelper.AddTestFinalizer(func() { lookupEnv = os.LookupEnv })
+}
+
+func TestVarName(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	tt.TestEqual(t, VarName("", "listen-addr"), "LISTEN_ADDR")
+	tt.TestEqual(t, VarName("MYAPP", "log.level"), "MYAPP_LOG_LEVEL")
+	tt.TestEqual(t, VarName("X", "maxConns2"), "X_MAXCONNS2")
+}
+
+func TestParse(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	fakeEnv(testHelper, map[string]string{
+		"APP_ADDR":    ":9090",
+		"APP_VERBOSE": "true",
+		"APP_TIMEOUT": "5s",
+		"APP_NAME":    "env",
+		"ADDR":        "unprefixed",
+	})
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "")
+	verbose := fs.Bool("verbose", false, "")
+	timeout := fs.Duration("timeout", time.Second, "")
+	name := fs.String("name", "default", "")
+	count := fs.Int("count", 3, "")
+
+	tt.TestExpectSuccess(t, Parse(fs, "APP", []string{"-name", "cmdline"}))
+	tt.TestEqual(t, *addr, ":9090")
+	tt.TestEqual(t, *verbose, true)
+	tt.TestEqual(t, *timeout, 5*time.Second)
+	tt.TestEqual(t, *name, "cmdline")
+	tt.TestEqual(t, *count, 3)
+}
+
+func TestApplyInvalid(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	fakeEnv(testHelper, map[string]string{"COUNT": "many"})
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.Int("count", 3, "")
+	err := Apply(fs, "")
+	tt.TestExpectError(t, err)
+	tt.TestTrue(t, strings.Contains(err.Error(), "COUNT"))
+}
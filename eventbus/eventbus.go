@@ -0,0 +1,276 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// Package eventbus provides a small in-process publish/subscribe mechanism.
+// Events are published to named topics and delivered to every subscriber of
+// that topic through a bounded buffer with a configurable overflow policy.
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned by Subscription.Receive once the subscription has been
+// closed, either directly or by closing the Bus.
+var ErrClosed = errors.New("eventbus: subscription closed")
+
+// Topic is the name events are published to and subscribed from.
+type Topic string
+
+// OverflowPolicy controls what happens when an event is published to a
+// subscriber whose buffer is full.
+type OverflowPolicy int
+
+const (
+	// DropNewest discards the event being published.
+	DropNewest OverflowPolicy = iota
+
+	// DropOldest discards the oldest buffered event to make room for the
+	// event being published.
+	DropOldest
+
+	// Block blocks the publisher until there is room in the buffer or the
+	// subscription is closed.
+	Block
+)
+
+// TopicStats contains the fan-out metrics for a single topic.
+type TopicStats struct {
+	// Subscribers is the current number of subscribers.
+	Subscribers int `json:"subscribers"`
+
+	// Published is the number of events published to the topic.
+	Published uint64 `json:"published"`
+
+	// Delivered is the number of events delivered to subscribers. An event
+	// published to several subscribers is counted once for each of them.
+	Delivered uint64 `json:"delivered"`
+
+	// Dropped is the number of events dropped due to full buffers.
+	Dropped uint64 `json:"dropped"`
+}
+
+// Bus is an in-process event bus. The zero value is not usable, a Bus should be
+// created with New.
+type Bus struct {
+	mutex  sync.RWMutex
+	topics map[Topic]*topic
+	closed bool
+}
+
+type topic struct {
+	subs      map[*Subscription]bool
+	published uint64
+	delivered uint64
+	dropped   uint64
+}
+
+// New returns a new Bus.
+func New() *Bus {
+	return &Bus{topics: make(map[Topic]*topic)}
+}
+
+// Subscribe adds a subscriber to the topic which buffers up to bufferSize
+// events, handling a full buffer according to policy. A bufferSize less than 1
+// is treated as 1. Subscribing to a closed Bus returns a closed Subscription.
+func (b *Bus) Subscribe(t Topic, bufferSize int, policy OverflowPolicy) *Subscription {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	s := &Subscription{
+		bus:    b,
+		topic:  t,
+		policy: policy,
+		ch:     make(chan interface{}, bufferSize),
+		done:   make(chan struct{}),
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		s.close()
+		return s
+	}
+	tp := b.topics[t]
+	if tp == nil {
+		tp = &topic{subs: make(map[*Subscription]bool)}
+		b.topics[t] = tp
+	}
+	tp.subs[s] = true
+	return s
+}
+
+// Publish delivers the event to every current subscriber of the topic and
+// returns the number of subscribers it was delivered to. Publishing to a
+// closed Bus is a no-op.
+func (b *Bus) Publish(t Topic, event interface{}) int {
+	b.mutex.Lock()
+	tp := b.topics[t]
+	if b.closed || tp == nil {
+		b.mutex.Unlock()
+		return 0
+	}
+	tp.published++
+	subs := make([]*Subscription, 0, len(tp.subs))
+	for s := range tp.subs {
+		subs = append(subs, s)
+	}
+	b.mutex.Unlock()
+
+	// Deliver outside of the bus lock so a blocking subscriber does not
+	// prevent others from subscribing or unsubscribing.
+	var delivered, dropped uint64
+	for _, s := range subs {
+		d, n := s.deliver(event)
+		if d {
+			delivered++
+		}
+		dropped += n
+	}
+
+	b.mutex.Lock()
+	tp.delivered += delivered
+	tp.dropped += dropped
+	b.mutex.Unlock()
+	return int(delivered)
+}
+
+// Stats returns the metrics for every topic which has had subscribers.
+func (b *Bus) Stats() map[Topic]TopicStats {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	stats := make(map[Topic]TopicStats, len(b.topics))
+	for name, tp := range b.topics {
+		stats[name] = TopicStats{
+			Subscribers: len(tp.subs),
+			Published:   tp.published,
+			Delivered:   tp.delivered,
+			Dropped:     tp.dropped,
+		}
+	}
+	return stats
+}
+
+// Close closes every subscription. Further publishes are ignored.
+func (b *Bus) Close() {
+	b.mutex.Lock()
+	b.closed = true
+	var subs []*Subscription
+	for _, tp := range b.topics {
+		for s := range tp.subs {
+			subs = append(subs, s)
+		}
+		tp.subs = make(map[*Subscription]bool)
+	}
+	b.mutex.Unlock()
+
+	for _, s := range subs {
+		s.close()
+	}
+}
+
+func (b *Bus) unsubscribe(s *Subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if tp := b.topics[s.topic]; tp != nil {
+		delete(tp.subs, s)
+	}
+}
+
+// Subscription is a single subscriber to a topic.
+type Subscription struct {
+	bus    *Bus
+	topic  Topic
+	policy OverflowPolicy
+
+	ch        chan interface{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// mutex serializes deliveries with closing the channel.
+	mutex  sync.Mutex
+	closed bool
+}
+
+// C returns the channel events are delivered on. It is closed when the
+// subscription is closed.
+func (s *Subscription) C() <-chan interface{} {
+	return s.ch
+}
+
+// Receive waits for the next event. It returns ErrClosed if the subscription is
+// closed, or the context's error if it is done first.
+func (s *Subscription) Receive(ctx context.Context) (interface{}, error) {
+	select {
+	case ev, ok := <-s.ch:
+		if !ok {
+			return nil, ErrClosed
+		}
+		return ev, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close removes the subscription from the bus and closes its channel. Events
+// still buffered can continue to be read from C.
+func (s *Subscription) Close() {
+	s.bus.unsubscribe(s)
+	s.close()
+}
+
+func (s *Subscription) close() {
+	s.closeOnce.Do(func() {
+		// Closing done first releases any publisher blocked in deliver
+		// so the mutex can be acquired.
+		close(s.done)
+		s.mutex.Lock()
+		s.closed = true
+		close(s.ch)
+		s.mutex.Unlock()
+	})
+}
+
+// deliver sends the event according to the overflow policy. It returns whether
+// the event was delivered and the number of events dropped.
+func (s *Subscription) deliver(event interface{}) (bool, uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return false, 0
+	}
+
+	switch s.policy {
+	case Block:
+		select {
+		case s.ch <- event:
+			return true, 0
+		case <-s.done:
+			return false, 0
+		}
+
+	case DropOldest:
+		var dropped uint64
+		for {
+			select {
+			case s.ch <- event:
+				return true, dropped
+			default:
+			}
+			select {
+			case <-s.ch:
+				dropped++
+			default:
+			}
+		}
+
+	default:
+		select {
+		case s.ch <- event:
+			return true, 0
+		default:
+			return false, 1
+		}
+	}
+}
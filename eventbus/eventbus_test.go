@@ -0,0 +1,130 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package eventbus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestPublishSubscribe(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	b := New()
+	defer b.Close()
+
+	s1 := b.Subscribe("a", 10, DropNewest)
+	s2 := b.Subscribe("a", 10, DropNewest)
+	s3 := b.Subscribe("b", 10, DropNewest)
+
+	tt.TestEqual(t, b.Publish("a", 1), 2)
+	tt.TestEqual(t, b.Publish("c", 1), 0)
+
+	tt.TestEqual(t, <-s1.C(), 1)
+	tt.TestEqual(t, <-s2.C(), 1)
+	select {
+	case ev := <-s3.C():
+		tt.Fatalf(t, "Unexpected event on topic b: %v", ev)
+	default:
+	}
+
+	s2.Close()
+	tt.TestEqual(t, b.Publish("a", 2), 1)
+	_, ok := <-s2.C()
+	tt.TestFalse(t, ok)
+
+	stats := b.Stats()
+	tt.TestEqual(t, stats["a"], TopicStats{
+		Subscribers: 1,
+		Published:   2,
+		Delivered:   3,
+	})
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	b := New()
+	defer b.Close()
+
+	newest := b.Subscribe("t", 2, DropNewest)
+	oldest := b.Subscribe("t", 2, DropOldest)
+	for i := 1; i <= 4; i++ {
+		b.Publish("t", i)
+	}
+
+	tt.TestEqual(t, <-newest.C(), 1)
+	tt.TestEqual(t, <-newest.C(), 2)
+	tt.TestEqual(t, <-oldest.C(), 3)
+	tt.TestEqual(t, <-oldest.C(), 4)
+	tt.TestEqual(t, b.Stats()["t"].Dropped, uint64(4))
+}
+
+func TestBlockPolicy(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	b := New()
+	s := b.Subscribe("t", 1, Block)
+	b.Publish("t", 1)
+
+	published := make(chan int)
+	go func() {
+		published <- b.Publish("t", 2)
+	}()
+
+	select {
+	case <-published:
+		tt.Fatalf(t, "Publish should have blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	tt.TestEqual(t, <-s.C(), 1)
+	tt.TestEqual(t, <-published, 1)
+	tt.TestEqual(t, <-s.C(), 2)
+
+	// closing releases a blocked publisher
+	b.Publish("t", 3)
+	go func() {
+		published <- b.Publish("t", 4)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	b.Close()
+	select {
+	case n := <-published:
+		tt.TestEqual(t, n, 0)
+	case <-time.After(time.Second):
+		tt.Fatalf(t, "Publish did not return after Close")
+	}
+}
+
+func TestReceive(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	b := New()
+	s := b.Subscribe("t", 1, DropNewest)
+
+	b.Publish("t", "hello")
+	ev, err := s.Receive(context.Background())
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, ev, "hello")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = s.Receive(ctx)
+	tt.TestEqual(t, err, context.DeadlineExceeded)
+
+	b.Close()
+	_, err = s.Receive(context.Background())
+	tt.TestEqual(t, err, ErrClosed)
+
+	// subscribing to a closed bus returns a closed subscription
+	_, err = b.Subscribe("t", 1, DropNewest).Receive(context.Background())
+	tt.TestEqual(t, err, ErrClosed)
+}
@@ -20,6 +20,7 @@ type IPRangeAllocator struct {
 	size        int64
 	remaining   int64
 	reserved    map[int64]bool
+	excluded    map[int64]bool
 	startBig    *big.Int
 	startIsIPv4 bool
 	mutex       sync.Mutex
@@ -30,6 +31,7 @@ func NewAllocator(ipr *IPRange) *IPRangeAllocator {
 	a := &IPRangeAllocator{
 		ipRange:     ipr,
 		reserved:    make(map[int64]bool),
+		excluded:    make(map[int64]bool),
 		startIsIPv4: bytes.Compare(ipr.Start.To16()[0:12], ipv6in4) == 0,
 	}
 
@@ -87,21 +89,20 @@ func (a *IPRangeAllocator) Reserve(ip net.IP) {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
-	// ensure the specified IP is within the range
-	if !a.ipRange.Contains(ip) {
-		return
+	if idx, ok := a.index(ip); ok {
+		a.reserve(idx)
 	}
+}
 
-	// calculate the idx from the start
-	ipBig := big.NewInt(0)
-	ipBig.SetBytes(ip)
-	idx := ipBig.Sub(ipBig, a.startBig).Int64()
+// Exclude marks an IP address within the range as never to be allocated, such
+// as a gateway address. Unlike a reserved address, it is not freed by Release.
+func (a *IPRangeAllocator) Exclude(ip net.IP) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
 
-	// if it isn't already reserved, then mark it reserved and decrement the
-	// remaining count
-	if !a.reserved[idx] {
-		a.reserved[idx] = true
-		a.remaining--
+	if idx, ok := a.index(ip); ok {
+		a.excluded[idx] = true
+		a.reserve(idx)
 	}
 }
 
@@ -111,18 +112,38 @@ func (a *IPRangeAllocator) Release(ip net.IP) {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
-	// calculate the idx from the start
-	ipBig := big.NewInt(0)
-	ipBig.SetBytes(ip)
-	idx := ipBig.Sub(ipBig, a.startBig).Int64()
-
-	// check if the idx is reserved
-	if a.reserved[idx] {
+	// check if the idx is reserved, and not excluded
+	idx, ok := a.index(ip)
+	if ok && a.reserved[idx] && !a.excluded[idx] {
 		delete(a.reserved, idx)
 		a.remaining++
 	}
 }
 
+// index returns the offset of the IP address from the start of the range, and
+// false if it isn't within the range.
+func (a *IPRangeAllocator) index(ip net.IP) (int64, bool) {
+	// ensure the specified IP is within the range
+	if !a.ipRange.Contains(ip) {
+		return 0, false
+	}
+
+	// calculate the idx from the start, using the 16 byte form so it lines up
+	// with the start of the range
+	ipBig := big.NewInt(0)
+	ipBig.SetBytes(ip.To16())
+	return ipBig.Sub(ipBig, a.startBig).Int64(), true
+}
+
+// reserve marks the index as reserved, if it isn't already, and decrements
+// the remaining count.
+func (a *IPRangeAllocator) reserve(idx int64) {
+	if !a.reserved[idx] {
+		a.reserved[idx] = true
+		a.remaining--
+	}
+}
+
 // Subtract marks all of the IPs from another IPRange as reserved in the current
 // allocator.
 func (a *IPRangeAllocator) Subtract(iprange *IPRange) {
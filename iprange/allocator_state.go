@@ -0,0 +1,109 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package iprange
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+)
+
+// AllocatorState is the state of an IPRangeAllocator, which can be saved and
+// later restored to an allocator for the same range.
+type AllocatorState struct {
+	// Allocated contains the allocated and reserved IP addresses.
+	Allocated []string `json:"allocated"`
+
+	// Excluded contains the excluded IP addresses.
+	Excluded []string `json:"excluded"`
+}
+
+// AllocatorStore persists the state of an IPRangeAllocator, such as in a file
+// or a database.
+type AllocatorStore interface {
+	Save(state *AllocatorState) error
+	Load() (*AllocatorState, error)
+}
+
+// Snapshot returns the allocator's state, with the addresses in order.
+func (a *IPRangeAllocator) Snapshot() *AllocatorState {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	idxs := make([]int64, 0, len(a.reserved))
+	for idx := range a.reserved {
+		idxs = append(idxs, idx)
+	}
+	sort.Slice(idxs, func(i, j int) bool { return idxs[i] < idxs[j] })
+
+	state := &AllocatorState{Allocated: []string{}, Excluded: []string{}}
+	for _, idx := range idxs {
+		ip := a.bigIntToIP(big.NewInt(0).Add(a.startBig, big.NewInt(idx))).String()
+		if a.excluded[idx] {
+			state.Excluded = append(state.Excluded, ip)
+		} else {
+			state.Allocated = append(state.Allocated, ip)
+		}
+	}
+	return state
+}
+
+// Restore replaces the allocator's state with one returned by Snapshot. An
+// error is returned, and the allocator left unchanged, if any address isn't
+// valid or isn't within the range.
+func (a *IPRangeAllocator) Restore(state *AllocatorState) error {
+	parse := func(ips []string) ([]int64, error) {
+		idxs := make([]int64, 0, len(ips))
+		for _, s := range ips {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("failed to parse the IP address %q", s)
+			}
+			idx, ok := a.index(ip)
+			if !ok {
+				return nil, fmt.Errorf("the IP address %s is not within the range", s)
+			}
+			idxs = append(idxs, idx)
+		}
+		return idxs, nil
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	allocated, err := parse(state.Allocated)
+	if err != nil {
+		return err
+	}
+	excluded, err := parse(state.Excluded)
+	if err != nil {
+		return err
+	}
+
+	a.reserved = make(map[int64]bool)
+	a.excluded = make(map[int64]bool)
+	a.remaining = a.size
+	for _, idx := range allocated {
+		a.reserve(idx)
+	}
+	for _, idx := range excluded {
+		a.excluded[idx] = true
+		a.reserve(idx)
+	}
+	return nil
+}
+
+// SaveTo saves the allocator's state to the store.
+func (a *IPRangeAllocator) SaveTo(store AllocatorStore) error {
+	return store.Save(a.Snapshot())
+}
+
+// LoadFrom restores the allocator's state from the store.
+func (a *IPRangeAllocator) LoadFrom(store AllocatorStore) error {
+	state, err := store.Load()
+	if err != nil {
+		return err
+	}
+	return a.Restore(state)
+}
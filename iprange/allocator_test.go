@@ -157,3 +157,114 @@ func TestRelease(t *testing.T) {
 	tt.TestEqual(t, alloc.Allocate().String(), "192.168.1.11")
 	tt.TestEqual(t, alloc.remaining, int64(0))
 }
+
+func TestExclude(t *testing.T) {
+	ipr, err := ParseIPRange("192.168.1.10-19")
+	tt.TestExpectSuccess(t, err)
+	alloc := NewAllocator(ipr)
+
+	gateway := net.ParseIP("192.168.1.10")
+	alloc.Exclude(gateway)
+	alloc.Exclude(net.ParseIP("10.0.0.1"))
+	tt.TestEqual(t, alloc.Remaining(), int64(9))
+
+	// releasing an excluded IP does nothing
+	alloc.Release(gateway)
+	tt.TestEqual(t, alloc.Remaining(), int64(9))
+
+	// excluding a reserved IP keeps it from being released
+	reserved := net.ParseIP("192.168.1.11")
+	alloc.Reserve(reserved)
+	alloc.Exclude(reserved)
+	tt.TestEqual(t, alloc.Remaining(), int64(8))
+	alloc.Release(reserved)
+	tt.TestEqual(t, alloc.Remaining(), int64(8))
+
+	for alloc.Remaining() > 0 {
+		ip := alloc.Allocate()
+		tt.TestNotEqual(t, ip.String(), gateway.String())
+		tt.TestNotEqual(t, ip.String(), reserved.String())
+	}
+}
+
+// memoryStore is an AllocatorStore which keeps the state in memory.
+type memoryStore struct {
+	state *AllocatorState
+}
+
+func (s *memoryStore) Save(state *AllocatorState) error {
+	s.state = state
+	return nil
+}
+
+func (s *memoryStore) Load() (*AllocatorState, error) {
+	if s.state == nil {
+		return nil, fmt.Errorf("nothing saved")
+	}
+	return s.state, nil
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	ipr, err := ParseIPRange("192.168.1.10-19")
+	tt.TestExpectSuccess(t, err)
+	alloc := NewAllocator(ipr)
+	tt.TestEqual(t, alloc.Snapshot(), &AllocatorState{Allocated: []string{}, Excluded: []string{}})
+
+	alloc.Exclude(net.ParseIP("192.168.1.10"))
+	alloc.Reserve(net.ParseIP("192.168.1.15"))
+	alloc.Reserve(net.ParseIP("192.168.1.12"))
+	tt.TestEqual(t, alloc.Snapshot(), &AllocatorState{
+		Allocated: []string{"192.168.1.12", "192.168.1.15"},
+		Excluded:  []string{"192.168.1.10"},
+	})
+
+	store := &memoryStore{}
+	tt.TestExpectSuccess(t, alloc.SaveTo(store))
+
+	restored := NewAllocator(ipr)
+	restored.Reserve(net.ParseIP("192.168.1.19"))
+	tt.TestExpectSuccess(t, restored.LoadFrom(store))
+	tt.TestEqual(t, restored.Remaining(), int64(7))
+	tt.TestEqual(t, restored.Snapshot(), alloc.Snapshot())
+	restored.Release(net.ParseIP("192.168.1.10"))
+	restored.Release(net.ParseIP("192.168.1.12"))
+	tt.TestEqual(t, restored.Remaining(), int64(8))
+
+	// invalid states leave the allocator unchanged
+	tt.TestExpectError(t, restored.Restore(&AllocatorState{Allocated: []string{"192.168.1.11", "10.0.0.1"}}))
+	tt.TestExpectError(t, restored.Restore(&AllocatorState{Excluded: []string{"bogus"}}))
+	tt.TestExpectError(t, NewAllocator(ipr).LoadFrom(&memoryStore{}))
+	tt.TestEqual(t, restored.Remaining(), int64(8))
+}
+
+func TestAllocatorConcurrent(t *testing.T) {
+	ipr, err := ParseIPRange("10.0.0.0-0.255")
+	tt.TestExpectSuccess(t, err)
+	alloc := NewAllocator(ipr)
+	alloc.Exclude(net.ParseIP("10.0.0.1"))
+
+	results := make(chan net.IP, 255)
+	done := make(chan bool)
+	for i := 0; i < 5; i++ {
+		go func() {
+			for j := 0; j < 51; j++ {
+				results <- alloc.Allocate()
+				alloc.Snapshot()
+			}
+			done <- true
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		<-done
+	}
+	close(results)
+
+	seen := make(map[string]bool)
+	for ip := range results {
+		tt.TestNotEqual(t, ip, nil)
+		tt.TestFalse(t, seen[ip.String()])
+		seen[ip.String()] = true
+	}
+	tt.TestEqual(t, len(seen), 255)
+	tt.TestEqual(t, alloc.Remaining(), int64(0))
+}
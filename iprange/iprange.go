@@ -3,7 +3,6 @@
 package iprange
 
 import (
-	"bytes"
 	"fmt"
 	"net"
 	"strconv"
@@ -51,15 +50,24 @@ func ParseIPRange(s string) (*IPRange, error) {
 	if len(ips) > 2 {
 		return nil, fmt.Errorf("unexpected number of IPs specified in the provided string")
 	}
-	ipr.Start = net.ParseIP(ips[0])
+	// IPs are stored in their 16 byte form so that comparisons are always made
+	// between the same representations.
+	ipr.Start = net.ParseIP(ips[0]).To16()
+	if ipr.Start == nil {
+		return nil, fmt.Errorf("failed to parse the IP address %q", ips[0])
+	}
 	if len(ips) > 1 {
-		ipr.End = net.ParseIP(spliceIP(ips[0], ips[1]))
+		end := spliceIP(ips[0], ips[1])
+		ipr.End = net.ParseIP(end).To16()
+		if ipr.End == nil {
+			return nil, fmt.Errorf("failed to parse the IP address %q", end)
+		}
 	} else {
 		ipr.End = ipr.Start
 	}
 
 	// ensure the end is after the start
-	if bytes.Compare([]byte(ipr.End), []byte(ipr.Start)) < 0 {
+	if ipr.end().cmp(ipr.start()) < 0 {
 		return nil, fmt.Errorf("the end of the range cannot be less than the start of the range")
 	}
 
@@ -78,14 +86,14 @@ func ParseIPRange(s string) (*IPRange, error) {
 }
 
 // Contains returns whether or not the given IP address is within the specified
-// IPRange.
+// IPRange. Both the 4 and 16 byte representations of IPv4 addresses are
+// supported.
 func (ipr *IPRange) Contains(ip net.IP) bool {
-	// if ip is less than start, return false
-	if bytes.Compare([]byte(ip), []byte(ipr.Start)) < 0 {
+	v, ok := toIPValue(ip)
+	if !ok {
 		return false
 	}
-	// return true if ip is less than or equal to end
-	return bytes.Compare([]byte(ip), []byte(ipr.End)) <= 0
+	return v.cmp(ipr.start()) >= 0 && v.cmp(ipr.end()) <= 0
 }
 
 // Overlaps checks whether another IPRange instance has an overlap in IPs with
@@ -94,15 +102,24 @@ func (ipr *IPRange) Contains(ip net.IP) bool {
 func (ipr *IPRange) Overlaps(o *IPRange) bool {
 	// if the start of o is less than our start, we need to make sure the end of o
 	// is less than our start
-	if bytes.Compare([]byte(o.Start), []byte(ipr.Start)) < 0 {
-		return bytes.Compare([]byte(o.End), []byte(ipr.Start)) >= 0
-	}
-	// if the start of o is greater than our end, then no overlap
-	if bytes.Compare([]byte(o.Start), []byte(ipr.End)) > 0 {
-		return false
+	if o.start().cmp(ipr.start()) < 0 {
+		return o.end().cmp(ipr.start()) >= 0
 	}
-	// otherwise, their start is within our range, and thus there is overlap
-	return true
+	// if the start of o is greater than our end, then no overlap, otherwise
+	// their start is within our range, and thus there is overlap
+	return o.start().cmp(ipr.end()) <= 0
+}
+
+// start returns the start of the range as an ipValue.
+func (ipr *IPRange) start() ipValue {
+	v, _ := toIPValue(ipr.Start)
+	return v
+}
+
+// end returns the end of the range as an ipValue.
+func (ipr *IPRange) end() ipValue {
+	v, _ := toIPValue(ipr.End)
+	return v
 }
 
 // FIXME this only handles IPv4 at the moment
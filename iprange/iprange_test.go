@@ -3,6 +3,7 @@
 package iprange
 
 import (
+	"encoding/json"
 	"net"
 	"regexp"
 	"testing"
@@ -138,6 +139,60 @@ func TestIPRangeContains(t *testing.T) {
 	tt.TestEqual(t, ipr1.Contains(net.ParseIP("192.168.1.50")), true)
 }
 
+func TestIPRangeMixedRepresentations(t *testing.T) {
+	ipr, err := ParseIPRange("192.168.1.10-50")
+	tt.TestExpectSuccess(t, err)
+
+	// the 4 and 16 byte forms of the same address should behave the same
+	tt.TestEqual(t, ipr.Contains(net.IPv4(192, 168, 1, 10).To4()), true)
+	tt.TestEqual(t, ipr.Contains(net.IPv4(192, 168, 1, 10).To16()), true)
+	tt.TestEqual(t, ipr.Contains(net.IPv4(192, 168, 1, 50).To4()), true)
+	tt.TestEqual(t, ipr.Contains(net.IPv4(192, 168, 1, 9).To4()), false)
+	tt.TestEqual(t, ipr.Contains(net.IPv4(192, 168, 1, 51).To4()), false)
+	tt.TestEqual(t, ipr.Contains(net.IP{}), false)
+	tt.TestEqual(t, ipr.Contains(nil), false)
+
+	other := &IPRange{
+		Start: net.IPv4(192, 168, 1, 50).To4(),
+		End:   net.IPv4(192, 168, 1, 60).To4(),
+	}
+	tt.TestEqual(t, ipr.Overlaps(other), true)
+	tt.TestEqual(t, other.Overlaps(ipr), true)
+
+	other = &IPRange{
+		Start: net.IPv4(192, 168, 1, 51).To4(),
+		End:   net.IPv4(192, 168, 1, 60).To4(),
+	}
+	tt.TestEqual(t, ipr.Overlaps(other), false)
+	tt.TestEqual(t, other.Overlaps(ipr), false)
+
+	// IPv6 ranges
+	ipr, err = ParseIPRange("fd00::10-fd00::50")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, ipr.Contains(net.ParseIP("fd00::10")), true)
+	tt.TestEqual(t, ipr.Contains(net.ParseIP("fd00::50")), true)
+	tt.TestEqual(t, ipr.Contains(net.ParseIP("fd00::51")), false)
+	tt.TestEqual(t, ipr.Contains(net.ParseIP("fd01::10")), false)
+	tt.TestEqual(t, ipr.Contains(net.ParseIP("192.168.1.10")), false)
+
+	// an unparseable address is an error
+	_, err = ParseIPRange("192.168.1.300")
+	tt.TestExpectError(t, err)
+}
+
+func TestIPRangeAllocatorMixedRepresentations(t *testing.T) {
+	ipr, err := ParseIPRange("192.168.1.10-19")
+	tt.TestExpectSuccess(t, err)
+	alloc := NewAllocator(ipr)
+
+	alloc.Reserve(net.IPv4(192, 168, 1, 10).To4())
+	tt.TestEqual(t, alloc.Remaining(), int64(9))
+	alloc.Reserve(net.IPv4(192, 168, 1, 10).To16())
+	tt.TestEqual(t, alloc.Remaining(), int64(9))
+	alloc.Release(net.IPv4(192, 168, 1, 10).To16())
+	tt.TestEqual(t, alloc.Remaining(), int64(10))
+}
+
 func TestIPRangeOverlappingSubnets(t *testing.T) {
 
 	subnet1 := "10.0.1.0/16"
@@ -239,3 +294,94 @@ func TestIPRangeOverlappingSubnetsInvalids(t *testing.T) {
 	tt.TestEqual(t, err.Error(), "failed to parse the subnet 256.0.1.0/6: invalid CIDR address: 256.0.1.0/6")
 
 }
+
+func BenchmarkContains(b *testing.B) {
+	ipr, err := ParseIPRange("192.168.1.10-50")
+	if err != nil {
+		b.Fatal(err)
+	}
+	ip4 := net.IPv4(192, 168, 1, 20).To4()
+	ip16 := net.IPv4(192, 168, 1, 20).To16()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ipr.Contains(ip4)
+		ipr.Contains(ip16)
+	}
+}
+
+func BenchmarkOverlaps(b *testing.B) {
+	ipr1, err := ParseIPRange("192.168.1.10-50")
+	if err != nil {
+		b.Fatal(err)
+	}
+	ipr2, err := ParseIPRange("192.168.1.40-60")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ipr1.Overlaps(ipr2)
+	}
+}
+
+func TestIPRangeString(t *testing.T) {
+	for _, s := range []string{
+		"192.168.1.1",
+		"192.168.1.1-100",
+		"192.168.1.1-100/24",
+		"192.168.1.1-2.1",
+		"192.168.1.1-169.1.1",
+		"10.0.0.0-11.0.0.0",
+		"10.0.0.5/8",
+	} {
+		ipr, err := ParseIPRange(s)
+		tt.TestExpectSuccess(t, err)
+		tt.TestEqual(t, ipr.String(), s)
+	}
+
+	// a full end address is shortened
+	ipr, err := ParseIPRange("192.168.1.1-192.168.1.100")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, ipr.String(), "192.168.1.1-100")
+
+	ipr = &IPRange{Start: net.ParseIP("fe80::1"), End: net.ParseIP("fe80::1")}
+	tt.TestEqual(t, ipr.String(), "fe80::1")
+}
+
+func TestIPRangeMarshal(t *testing.T) {
+	type config struct {
+		Range  *IPRange  `json:"range"`
+		Ranges []IPRange `json:"ranges"`
+	}
+
+	var c config
+	tt.TestExpectSuccess(t, json.Unmarshal([]byte(`{"range":"10.0.0.1-50/24","ranges":["10.0.1.1","10.0.2.1-3.1"]}`), &c))
+	tt.TestEqual(t, c.Range.Start.String(), "10.0.0.1")
+	tt.TestEqual(t, c.Range.End.String(), "10.0.0.50")
+	tt.TestEqual(t, len(c.Ranges), 2)
+	tt.TestEqual(t, c.Ranges[1].End.String(), "10.0.3.1")
+
+	b, err := json.Marshal(&c)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, string(b), `{"range":"10.0.0.1-50/24","ranges":["10.0.1.1","10.0.2.1-3.1"]}`)
+
+	tt.TestExpectError(t, json.Unmarshal([]byte(`{"range":"10.0.0.50-1"}`), &c))
+	tt.TestExpectError(t, json.Unmarshal([]byte(`{"range":5}`), &c))
+
+	var ipr IPRange
+	tt.TestExpectSuccess(t, ipr.UnmarshalText([]byte("172.16.0.1-10")))
+	text, err := ipr.MarshalText()
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, string(text), "172.16.0.1-10")
+
+	// a range held by value marshals the same way
+	type valueConfig struct {
+		Range IPRange `json:"range"`
+	}
+	b, err = json.Marshal(valueConfig{Range: ipr})
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, string(b), `{"range":"172.16.0.1-10"}`)
+	var vc valueConfig
+	tt.TestExpectSuccess(t, json.Unmarshal(b, &vc))
+	tt.TestEqual(t, vc.Range.String(), "172.16.0.1-10")
+}
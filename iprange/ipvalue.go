@@ -0,0 +1,95 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package iprange
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+)
+
+// ipValue is an IP address in its canonical 16 byte form represented as a
+// big-endian 128-bit unsigned integer. IPv4 addresses are represented in their
+// IPv4-in-IPv6 form so that the 4 and 16 byte representations of the same
+// address compare as equal.
+type ipValue struct {
+	hi uint64
+	lo uint64
+}
+
+// toIPValue converts the IP into an ipValue. The returned bool is false if the
+// IP is not a valid 4 or 16 byte address.
+func toIPValue(ip net.IP) (ipValue, bool) {
+	switch len(ip) {
+	case net.IPv4len:
+		// Avoid the allocation of To16() for the common IPv4 case.
+		return ipValue{
+			lo: 0xffff<<32 | uint64(binary.BigEndian.Uint32(ip)),
+		}, true
+	case net.IPv6len:
+		return ipValue{
+			hi: binary.BigEndian.Uint64(ip[0:8]),
+			lo: binary.BigEndian.Uint64(ip[8:16]),
+		}, true
+	}
+	return ipValue{}, false
+}
+
+// ip returns the 16 byte net.IP form of the value.
+func (v ipValue) ip() net.IP {
+	ip := make(net.IP, net.IPv6len)
+	binary.BigEndian.PutUint64(ip[0:8], v.hi)
+	binary.BigEndian.PutUint64(ip[8:16], v.lo)
+	return ip
+}
+
+// cmp returns -1, 0, or 1 if v is less than, equal to, or greater than o.
+func (v ipValue) cmp(o ipValue) int {
+	switch {
+	case v.hi < o.hi:
+		return -1
+	case v.hi > o.hi:
+		return 1
+	case v.lo < o.lo:
+		return -1
+	case v.lo > o.lo:
+		return 1
+	}
+	return 0
+}
+
+// add returns v+n, wrapping around on overflow.
+func (v ipValue) add(n uint64) ipValue {
+	lo := v.lo + n
+	hi := v.hi
+	if lo < v.lo {
+		hi++
+	}
+	return ipValue{hi: hi, lo: lo}
+}
+
+// sub returns v-n, wrapping around on underflow.
+func (v ipValue) sub(n uint64) ipValue {
+	lo := v.lo - n
+	hi := v.hi
+	if lo > v.lo {
+		hi--
+	}
+	return ipValue{hi: hi, lo: lo}
+}
+
+// rangeSize returns the number of addresses between start and end inclusive.
+// Sizes which do not fit within an int64 are capped at math.MaxInt64.
+func rangeSize(start, end ipValue) int64 {
+	if end.cmp(start) < 0 {
+		return 0
+	}
+	d := ipValue{hi: end.hi - start.hi, lo: end.lo - start.lo}
+	if end.lo < start.lo {
+		d.hi--
+	}
+	if d.hi > 0 || d.lo >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(d.lo) + 1
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package iprange
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// String returns the range in the form parsed by ParseIPRange, such as
+// "192.168.1.1-100/24". The end of an IPv4 range omits the octets it shares
+// with the start, and a single address is written without an end.
+func (ipr IPRange) String() string {
+	s := ipr.Start.String()
+	if !ipr.End.Equal(ipr.Start) {
+		s += "-" + shortEnd(s, ipr.End.String())
+	}
+	if len(ipr.Mask) > 0 {
+		ones, _ := ipr.Mask.Size()
+		s += fmt.Sprintf("/%d", ones)
+	}
+	return s
+}
+
+// shortEnd drops the leading octets the end of an IPv4 range shares with the
+// start, always keeping the last one.
+func shortEnd(start, end string) string {
+	startParts := strings.Split(start, ".")
+	endParts := strings.Split(end, ".")
+	if len(startParts) != 4 || len(endParts) != 4 {
+		return end
+	}
+	i := 0
+	for i < 3 && startParts[i] == endParts[i] {
+		i++
+	}
+	return strings.Join(endParts[i:], ".")
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (ipr IPRange) MarshalText() ([]byte, error) {
+	return []byte(ipr.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, parsing the range with
+// ParseIPRange.
+func (ipr *IPRange) UnmarshalText(text []byte) error {
+	parsed, err := ParseIPRange(string(text))
+	if err != nil {
+		return err
+	}
+	*ipr = *parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, encoding the range as a string.
+func (ipr IPRange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ipr.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler, parsing a string with
+// ParseIPRange.
+func (ipr *IPRange) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("an IP range must be a string: %v", err)
+	}
+	return ipr.UnmarshalText([]byte(s))
+}
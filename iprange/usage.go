@@ -0,0 +1,101 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package iprange
+
+import (
+	"net"
+	"sort"
+)
+
+// Usage is a report of how much of an IPRange is in use.
+type Usage struct {
+	// Range is the range that was analyzed.
+	Range *IPRange `json:"range"`
+
+	// Size is the total number of addresses within the range.
+	Size int64 `json:"size"`
+
+	// Used is the number of distinct in use addresses within the range.
+	// Addresses outside of the range are ignored.
+	Used int64 `json:"used"`
+
+	// Free contains the contiguous ranges of addresses which are not in use,
+	// in ascending order.
+	Free []*IPRange `json:"free"`
+
+	// LargestFree is the largest contiguous free range, or nil if the range
+	// is fully used. If there are several of the same size, the lowest is
+	// returned.
+	LargestFree *IPRange `json:"largest_free"`
+}
+
+// Utilization returns the percentage of the range which is in use, from 0 to
+// 100.
+func (u *Usage) Utilization() float64 {
+	if u.Size == 0 {
+		return 0
+	}
+	return float64(u.Used) / float64(u.Size) * 100
+}
+
+// Analyze compares the provided in use addresses against the range and reports
+// the free sub-ranges, the utilization, and the largest contiguous free block.
+// Sizes which do not fit within an int64, such as very large IPv6 ranges, are
+// capped at math.MaxInt64.
+func Analyze(ipr *IPRange, inUse []net.IP) *Usage {
+	start, end := ipr.start(), ipr.end()
+	u := &Usage{
+		Range: ipr,
+		Size:  rangeSize(start, end),
+		Free:  make([]*IPRange, 0),
+	}
+
+	// filter to the addresses within the range and sort them
+	used := make([]ipValue, 0, len(inUse))
+	for _, ip := range inUse {
+		v, ok := toIPValue(ip)
+		if !ok || v.cmp(start) < 0 || v.cmp(end) > 0 {
+			continue
+		}
+		used = append(used, v)
+	}
+	sort.Sort(ipValues(used))
+
+	var largest int64
+	addFree := func(s, e ipValue) {
+		r := &IPRange{Start: s.ip(), End: e.ip(), Mask: ipr.Mask}
+		u.Free = append(u.Free, r)
+		if size := rangeSize(s, e); size > largest {
+			largest = size
+			u.LargestFree = r
+		}
+	}
+
+	// walk the sorted addresses, recording the gaps between them
+	cur := start
+	for i, v := range used {
+		if i > 0 && v.cmp(used[i-1]) == 0 {
+			continue
+		}
+		u.Used++
+		if v.cmp(cur) > 0 {
+			addFree(cur, v.sub(1))
+		}
+		if v.cmp(end) == 0 {
+			// the end of the range is in use, so there is no tail to
+			// record, and cur can't be advanced past it without the
+			// possibility of wrapping around
+			return u
+		}
+		cur = v.add(1)
+	}
+	addFree(cur, end)
+	return u
+}
+
+// ipValues implements sort.Interface for a slice of ipValue.
+type ipValues []ipValue
+
+func (s ipValues) Len() int           { return len(s) }
+func (s ipValues) Less(i, j int) bool { return s[i].cmp(s[j]) < 0 }
+func (s ipValues) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,87 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package iprange
+
+import (
+	"math"
+	"net"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestAnalyze(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	ipr, err := ParseIPRange("192.168.1.10-29/24")
+	tt.TestExpectSuccess(t, err)
+
+	// nothing in use
+	u := Analyze(ipr, nil)
+	tt.TestEqual(t, u.Size, int64(20))
+	tt.TestEqual(t, u.Used, int64(0))
+	tt.TestEqual(t, u.Utilization(), float64(0))
+	tt.TestEqual(t, len(u.Free), 1)
+	tt.TestEqual(t, u.Free[0].Start.String(), "192.168.1.10")
+	tt.TestEqual(t, u.Free[0].End.String(), "192.168.1.29")
+	tt.TestEqual(t, u.LargestFree, u.Free[0])
+
+	// some in use, with duplicates, mixed representations and addresses
+	// outside of the range
+	u = Analyze(ipr, []net.IP{
+		net.ParseIP("192.168.1.15"),
+		net.ParseIP("192.168.1.10"),
+		net.IPv4(192, 168, 1, 15).To4(),
+		net.ParseIP("192.168.1.16"),
+		net.ParseIP("192.168.1.20"),
+		net.ParseIP("192.168.1.100"),
+		net.ParseIP("10.0.0.1"),
+	})
+	tt.TestEqual(t, u.Used, int64(4))
+	tt.TestEqual(t, u.Utilization(), float64(20))
+	tt.TestEqual(t, len(u.Free), 3)
+	tt.TestEqual(t, u.Free[0].Start.String(), "192.168.1.11")
+	tt.TestEqual(t, u.Free[0].End.String(), "192.168.1.14")
+	tt.TestEqual(t, u.Free[1].Start.String(), "192.168.1.17")
+	tt.TestEqual(t, u.Free[1].End.String(), "192.168.1.19")
+	tt.TestEqual(t, u.Free[2].Start.String(), "192.168.1.21")
+	tt.TestEqual(t, u.Free[2].End.String(), "192.168.1.29")
+	tt.TestEqual(t, u.Free[2].Mask, ipr.Mask)
+	tt.TestEqual(t, u.LargestFree, u.Free[2])
+
+	// fully used
+	ipr, err = ParseIPRange("192.168.1.10-12")
+	tt.TestExpectSuccess(t, err)
+	u = Analyze(ipr, []net.IP{
+		net.ParseIP("192.168.1.12"),
+		net.ParseIP("192.168.1.11"),
+		net.ParseIP("192.168.1.10"),
+	})
+	tt.TestEqual(t, u.Used, int64(3))
+	tt.TestEqual(t, u.Utilization(), float64(100))
+	tt.TestEqual(t, len(u.Free), 0)
+	tt.TestTrue(t, u.LargestFree == nil)
+}
+
+func TestAnalyzeIPv6(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	ipr, err := ParseIPRange("fd00::-fd00::ffff")
+	tt.TestExpectSuccess(t, err)
+
+	u := Analyze(ipr, []net.IP{net.ParseIP("fd00::1")})
+	tt.TestEqual(t, u.Size, int64(65536))
+	tt.TestEqual(t, u.Used, int64(1))
+	tt.TestEqual(t, len(u.Free), 2)
+	tt.TestEqual(t, u.Free[0].Start.String(), "fd00::")
+	tt.TestEqual(t, u.Free[0].End.String(), "fd00::")
+	tt.TestEqual(t, u.LargestFree.Start.String(), "fd00::2")
+	tt.TestEqual(t, u.LargestFree.End.String(), "fd00::ffff")
+
+	// sizes too large for an int64 are capped
+	ipr, err = ParseIPRange("fd00::-fd01::")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, Analyze(ipr, nil).Size, int64(math.MaxInt64))
+}
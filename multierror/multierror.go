@@ -0,0 +1,119 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// Package multierror aggregates multiple errors into a single error.
+package multierror
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error is a list of errors which is itself an error. The errors it contains
+// can be matched with errors.Is and errors.As.
+type Error struct {
+	// Errors are the errors which have been appended.
+	Errors []error
+
+	// Max, if greater than zero, limits the number of errors which are kept.
+	// Errors appended beyond the limit are only counted, and are summarized
+	// in the message as "and N more". This bounds the memory used when
+	// aggregating an unknown number of errors.
+	Max int
+
+	// dropped is the number of errors which were not kept due to Max.
+	dropped int
+}
+
+// New returns an empty Error keeping at most max errors. A max of zero means
+// no limit.
+func New(max int) *Error {
+	return &Error{Max: max}
+}
+
+// Append adds the errors to err, returning the resulting *Error. If err is an
+// *Error it is appended to, otherwise a new *Error is created containing err.
+// Any nil errors are ignored, and the errors within any appended *Error are
+// added individually.
+func Append(err error, errs ...error) *Error {
+	e, ok := err.(*Error)
+	switch {
+	case ok && e == nil:
+		e = &Error{}
+	case !ok:
+		e = &Error{}
+		e.append(err)
+	}
+	for _, err := range errs {
+		e.append(err)
+	}
+	return e
+}
+
+func (e *Error) append(err error) {
+	switch err := err.(type) {
+	case nil:
+		return
+	case *Error:
+		if err == nil {
+			return
+		}
+		for _, inner := range err.Errors {
+			e.append(inner)
+		}
+		e.dropped += err.dropped
+		return
+	}
+
+	if e.Max > 0 && len(e.Errors) >= e.Max {
+		e.dropped++
+		return
+	}
+	e.Errors = append(e.Errors, err)
+}
+
+// Len returns the number of errors appended, including any which were not kept
+// due to Max.
+func (e *Error) Len() int {
+	if e == nil {
+		return 0
+	}
+	return len(e.Errors) + e.dropped
+}
+
+// ErrorOrNil returns nil if no errors have been appended, so that the result
+// can be returned as an error without creating a non-nil interface holding a
+// nil or empty *Error.
+func (e *Error) ErrorOrNil() error {
+	if e.Len() == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error returns the messages of the errors joined with semicolons.
+func (e *Error) Error() string {
+	if e.Len() == 0 {
+		return "no errors"
+	}
+	if e.Len() == 1 && len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	parts := make([]string, 0, len(e.Errors)+1)
+	for _, err := range e.Errors {
+		parts = append(parts, err.Error())
+	}
+	if e.dropped > 0 {
+		parts = append(parts, fmt.Sprintf("and %d more", e.dropped))
+	}
+	return fmt.Sprintf("%d errors occurred: %s", e.Len(), strings.Join(parts, "; "))
+}
+
+// Unwrap returns the errors which were kept, for use by errors.Is and
+// errors.As.
+func (e *Error) Unwrap() []error {
+	if e == nil {
+		return nil
+	}
+	return e.Errors
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package multierror
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestAppend(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	var e *Error
+	tt.TestEqual(t, e.Len(), 0)
+	tt.TestEqual(t, e.ErrorOrNil(), nil)
+
+	e = Append(e, nil)
+	tt.TestEqual(t, e.Len(), 0)
+	tt.TestEqual(t, e.ErrorOrNil(), nil)
+
+	e = Append(e, errors.New("one"))
+	tt.TestEqual(t, e.Len(), 1)
+	tt.TestEqual(t, e.Error(), "one")
+
+	e = Append(e, errors.New("two"), nil, errors.New("three"))
+	tt.TestEqual(t, e.Len(), 3)
+	tt.TestEqual(t, e.Error(), "3 errors occurred: one; two; three")
+
+	// appending to a plain error wraps it
+	e = Append(errors.New("first"), errors.New("second"))
+	tt.TestEqual(t, e.Error(), "2 errors occurred: first; second")
+
+	// appending an *Error flattens it
+	e = Append(errors.New("first"), Append(nil, errors.New("second"), errors.New("third")))
+	tt.TestEqual(t, len(e.Errors), 3)
+}
+
+func TestMax(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	e := New(2)
+	for i := 0; i < 5; i++ {
+		e = Append(e, fmt.Errorf("error %d", i))
+	}
+	tt.TestEqual(t, len(e.Errors), 2)
+	tt.TestEqual(t, e.Len(), 5)
+	tt.TestEqual(t, e.Error(), "5 errors occurred: error 0; error 1; and 3 more")
+
+	// the dropped count is carried across
+	other := Append(nil, errors.New("other"))
+	other = Append(other, e)
+	tt.TestEqual(t, other.Len(), 6)
+	tt.TestEqual(t, other.Error(), "6 errors occurred: other; error 0; error 1; and 3 more")
+
+	// a single kept error with others dropped still summarizes
+	e = New(1)
+	e = Append(e, errors.New("a"), errors.New("b"))
+	tt.TestEqual(t, e.Error(), "2 errors occurred: a; and 1 more")
+}
+
+func TestIsAs(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	_, statErr := os.Stat(testHelper.TempDir() + "/missing")
+	err := Append(errors.New("first"), fmt.Errorf("wrapped: %w", statErr)).ErrorOrNil()
+
+	tt.TestTrue(t, errors.Is(err, os.ErrNotExist))
+	tt.TestFalse(t, errors.Is(err, os.ErrExist))
+
+	var pathErr *os.PathError
+	tt.TestTrue(t, errors.As(err, &pathErr))
+	tt.TestEqual(t, pathErr, statErr)
+}
@@ -0,0 +1,240 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package proc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The directory where the cgroup hierarchies are mounted. Typically this is
+// only modified by unit testing.
+var CgroupRoot string = "/sys/fs/cgroup"
+
+// cgroupV1Unlimited is the smallest memory limit treated as unlimited by
+// cgroup v1, which reports no limit as the largest page aligned int64.
+const cgroupV1Unlimited = 1 << 62
+
+// CgroupMembership is a line of /proc/<pid>/cgroup, naming the cgroup a
+// process is in within one hierarchy.
+type CgroupMembership struct {
+	// HierarchyID is zero for the unified cgroup v2 hierarchy.
+	HierarchyID int
+
+	// Controllers are the controllers bound to a cgroup v1 hierarchy, such
+	// as "cpu" and "cpuacct". It is empty for cgroup v2.
+	Controllers []string
+
+	// Path is the cgroup's path relative to the hierarchy's mount point.
+	Path string
+}
+
+// ReadProcessCgroups returns the cgroups the process with the pid is in.
+func ReadProcessCgroups(pid int) ([]CgroupMembership, error) {
+	file := filepath.Join(ProcessDir, strconv.Itoa(pid), "cgroup")
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var cgroups []CgroupMembership
+	for i, line := range strings.Split(string(contents), "\n") {
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf(
+				"Invalid cgroup on line %d of file %s: %s", i, file, line)
+		}
+		id, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf(
+				"Invalid hierarchy ID on line %d of file %s: %s", i, file, parts[0])
+		}
+		cg := CgroupMembership{HierarchyID: id, Path: parts[2]}
+		if parts[1] != "" {
+			cg.Controllers = strings.Split(parts[1], ",")
+		}
+		cgroups = append(cgroups, cg)
+	}
+	return cgroups, nil
+}
+
+// IsCgroupV2 returns true if CgroupRoot is the unified cgroup v2 hierarchy
+// rather than a directory of cgroup v1 hierarchies.
+func IsCgroupV2() bool {
+	_, err := os.Stat(filepath.Join(CgroupRoot, "cgroup.controllers"))
+	return err == nil
+}
+
+// CgroupStats stores the memory and CPU limits and usage of a cgroup.
+type CgroupStats struct {
+	// MemoryLimit is in bytes, and zero if there is no limit.
+	MemoryLimit uint64
+	MemoryUsage uint64
+
+	// CPULimit is the number of CPUs the cgroup may use, and zero if there
+	// is no limit.
+	CPULimit float64
+
+	// CPUUsage is the total CPU time used by the cgroup.
+	CPUUsage time.Duration
+}
+
+// ReadCgroupStats returns the limits and usage of the cgroup the process with
+// the pid is in, from the cgroup v2 hierarchy if it is mounted at CgroupRoot and
+// otherwise from the memory, cpu, and cpuacct cgroup v1 hierarchies.
+func ReadCgroupStats(pid int) (*CgroupStats, error) {
+	cgroups, err := ReadProcessCgroups(pid)
+	if err != nil {
+		return nil, err
+	}
+	if IsCgroupV2() {
+		for _, cg := range cgroups {
+			if cg.HierarchyID == 0 {
+				return readCgroupV2Stats(filepath.Join(CgroupRoot, cg.Path))
+			}
+		}
+		return nil, fmt.Errorf("Process %d is not in a cgroup v2 hierarchy", pid)
+	}
+	return readCgroupV1Stats(cgroups)
+}
+
+func readCgroupV2Stats(dir string) (*CgroupStats, error) {
+	stats := &CgroupStats{}
+	var err error
+	if stats.MemoryLimit, err = readCgroupValue(filepath.Join(dir, "memory.max")); err != nil {
+		return nil, err
+	}
+	if stats.MemoryUsage, err = readCgroupValue(filepath.Join(dir, "memory.current")); err != nil {
+		return nil, err
+	}
+
+	// cpu.max contains the quota, or "max", and the period.
+	file := filepath.Join(dir, "cpu.max")
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(contents))
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("Invalid contents of file %s: %q", file, contents)
+	}
+	if fields[0] != "max" {
+		quota, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid quota in file %s: %s", file, fields[0])
+		}
+		period, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil || period == 0 {
+			return nil, fmt.Errorf("Invalid period in file %s: %s", file, fields[1])
+		}
+		stats.CPULimit = float64(quota) / float64(period)
+	}
+
+	file = filepath.Join(dir, "cpu.stat")
+	found := false
+	el := func(line int, index int, elm string) error {
+		if index == 0 {
+			found = elm == "usage_usec"
+		} else if index == 1 && found {
+			n, err := strconv.ParseUint(elm, 10, 64)
+			if err != nil {
+				return fmt.Errorf(
+					"Error parsing column %d on line %d of file %s: %s",
+					index, line, file, elm)
+			}
+			stats.CPUUsage = time.Duration(n) * time.Microsecond
+		}
+		return nil
+	}
+	if err := ParseSimpleProcFile(file, nil, el); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func readCgroupV1Stats(cgroups []CgroupMembership) (*CgroupStats, error) {
+	// dir returns the directory of the process's cgroup in the hierarchy
+	// with the controller, which is mounted at a directory named after all
+	// of the hierarchy's controllers, such as "cpu,cpuacct".
+	dir := func(controller string) (string, error) {
+		for _, cg := range cgroups {
+			for _, c := range cg.Controllers {
+				if c == controller {
+					mount := strings.Join(cg.Controllers, ",")
+					return filepath.Join(CgroupRoot, mount, cg.Path), nil
+				}
+			}
+		}
+		return "", fmt.Errorf("No cgroup v1 hierarchy with the %s controller", controller)
+	}
+
+	stats := &CgroupStats{}
+	memory, err := dir("memory")
+	if err != nil {
+		return nil, err
+	}
+	if stats.MemoryLimit, err = readCgroupValue(filepath.Join(memory, "memory.limit_in_bytes")); err != nil {
+		return nil, err
+	}
+	if stats.MemoryLimit >= cgroupV1Unlimited {
+		stats.MemoryLimit = 0
+	}
+	if stats.MemoryUsage, err = readCgroupValue(filepath.Join(memory, "memory.usage_in_bytes")); err != nil {
+		return nil, err
+	}
+
+	cpu, err := dir("cpu")
+	if err != nil {
+		return nil, err
+	}
+	quota, err := ReadInt64(filepath.Join(cpu, "cpu.cfs_quota_us"))
+	if err != nil {
+		return nil, err
+	}
+	if quota > 0 {
+		period, err := ReadInt64(filepath.Join(cpu, "cpu.cfs_period_us"))
+		if err != nil {
+			return nil, err
+		} else if period <= 0 {
+			return nil, fmt.Errorf("Invalid CFS period in %s: %d", cpu, period)
+		}
+		stats.CPULimit = float64(quota) / float64(period)
+	}
+
+	cpuacct, err := dir("cpuacct")
+	if err != nil {
+		return nil, err
+	}
+	usage, err := readCgroupValue(filepath.Join(cpuacct, "cpuacct.usage"))
+	if err != nil {
+		return nil, err
+	}
+	stats.CPUUsage = time.Duration(usage)
+	return stats, nil
+}
+
+// readCgroupValue reads a file containing a single number, or "max" which is
+// returned as zero.
+func readCgroupValue(file string) (uint64, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	s := strings.TrimSpace(string(contents))
+	if s == "max" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid contents of file %s: %q", file, s)
+	}
+	return n, nil
+}
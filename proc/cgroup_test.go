@@ -0,0 +1,122 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package proc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// writeFiles writes each file, relative to dir, creating directories as
+// needed.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		tt.TestExpectSuccess(t, os.MkdirAll(filepath.Dir(path), 0755))
+		tt.TestExpectSuccess(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+}
+
+func TestCgroupV2(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	ProcessDir = testHelper.TempDir()
+	CgroupRoot = testHelper.TempDir()
+	defer func() { ProcessDir, CgroupRoot = "/proc", "/sys/fs/cgroup" }()
+
+	writeFiles(t, ProcessDir, map[string]string{"7/cgroup": "0::/system.slice/app.service\n"})
+	writeFiles(t, CgroupRoot, map[string]string{
+		"cgroup.controllers":                        "cpu memory\n",
+		"system.slice/app.service/memory.max":       "536870912\n",
+		"system.slice/app.service/memory.current":   "1048576\n",
+		"system.slice/app.service/cpu.max":          "150000 100000\n",
+		"system.slice/app.service/cpu.stat":         "usage_usec 2500000\nuser_usec 2000000\n",
+		"system.slice/other.service/memory.max":     "max\n",
+		"system.slice/other.service/memory.current": "0\n",
+		"system.slice/other.service/cpu.max":        "max 100000\n",
+		"system.slice/other.service/cpu.stat":       "usage_usec 0\n",
+	})
+
+	cgroups, err := ReadProcessCgroups(7)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, cgroups, []CgroupMembership{{HierarchyID: 0, Path: "/system.slice/app.service"}})
+	tt.TestTrue(t, IsCgroupV2())
+
+	stats, err := ReadCgroupStats(7)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, stats, &CgroupStats{
+		MemoryLimit: 512 << 20,
+		MemoryUsage: 1 << 20,
+		CPULimit:    1.5,
+		CPUUsage:    2500 * time.Millisecond,
+	})
+
+	writeFiles(t, ProcessDir, map[string]string{"7/cgroup": "0::/system.slice/other.service\n"})
+	stats, err = ReadCgroupStats(7)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, stats, &CgroupStats{})
+
+	writeFiles(t, ProcessDir, map[string]string{"7/cgroup": "bad\n"})
+	_, err = ReadCgroupStats(7)
+	tt.TestExpectError(t, err)
+}
+
+func TestCgroupV1(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	ProcessDir = testHelper.TempDir()
+	CgroupRoot = testHelper.TempDir()
+	defer func() { ProcessDir, CgroupRoot = "/proc", "/sys/fs/cgroup" }()
+
+	writeFiles(t, ProcessDir, map[string]string{"7/cgroup": "" +
+		"11:memory:/docker/abc\n" +
+		"4:cpu,cpuacct:/docker/abc\n" +
+		"1:name=systemd:/docker/abc\n" +
+		"0::/\n"})
+	writeFiles(t, CgroupRoot, map[string]string{
+		"memory/docker/abc/memory.limit_in_bytes":  "9223372036854771712\n",
+		"memory/docker/abc/memory.usage_in_bytes":  "4096\n",
+		"cpu,cpuacct/docker/abc/cpu.cfs_quota_us":  "50000\n",
+		"cpu,cpuacct/docker/abc/cpu.cfs_period_us": "100000\n",
+		"cpu,cpuacct/docker/abc/cpuacct.usage":     "123456789\n",
+	})
+
+	cgroups, err := ReadProcessCgroups(7)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(cgroups), 4)
+	tt.TestEqual(t, cgroups[1], CgroupMembership{
+		HierarchyID: 4,
+		Controllers: []string{"cpu", "cpuacct"},
+		Path:        "/docker/abc",
+	})
+	tt.TestFalse(t, IsCgroupV2())
+
+	stats, err := ReadCgroupStats(7)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, stats, &CgroupStats{
+		MemoryLimit: 0,
+		MemoryUsage: 4096,
+		CPULimit:    0.5,
+		CPUUsage:    123456789,
+	})
+
+	writeFiles(t, CgroupRoot, map[string]string{
+		"memory/docker/abc/memory.limit_in_bytes": "1073741824\n",
+		"cpu,cpuacct/docker/abc/cpu.cfs_quota_us": "-1\n",
+	})
+	stats, err = ReadCgroupStats(7)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, stats.MemoryLimit, uint64(1<<30))
+	tt.TestEqual(t, stats.CPULimit, 0.0)
+
+	writeFiles(t, ProcessDir, map[string]string{"7/cgroup": "4:cpu,cpuacct:/docker/abc\n"})
+	_, err = ReadCgroupStats(7)
+	tt.TestExpectError(t, err)
+}
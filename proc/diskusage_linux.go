@@ -0,0 +1,23 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// +build linux
+
+package proc
+
+import (
+	"syscall"
+)
+
+// ReadDiskUsage returns the usage of the file system mounted at path.
+func ReadDiskUsage(path string) (*DiskUsage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return nil, err
+	}
+	bsize := uint64(st.Bsize)
+	return &DiskUsage{
+		Total:     st.Blocks * bsize,
+		Free:      st.Bfree * bsize,
+		Available: st.Bavail * bsize,
+	}, nil
+}
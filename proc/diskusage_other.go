@@ -0,0 +1,14 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// +build !linux
+
+package proc
+
+import (
+	"fmt"
+)
+
+// ReadDiskUsage returns the usage of the file system mounted at path.
+func ReadDiskUsage(path string) (*DiskUsage, error) {
+	return nil, fmt.Errorf("disk usage is only supported on linux")
+}
@@ -0,0 +1,319 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package proc
+
+import (
+	"bytes"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exporter periodically samples the system statistics exposed by this package
+// and publishes them as expvar variables or Prometheus metrics, so it can be
+// used as a node metrics source.
+//
+//	e := proc.NewExporter(15 * time.Second)
+//	e.Labels = map[string]string{"cluster": "east"}
+//	e.Start()
+//	defer e.Stop()
+//	e.PublishExpvar("node")
+//	http.Handle("/metrics", e)
+type Exporter struct {
+	// Interval is how often the statistics are sampled.
+	Interval time.Duration
+
+	// Labels are added to every Prometheus metric and included in the
+	// expvar output. They should be set before the exporter is started.
+	Labels map[string]string
+
+	mu       sync.RWMutex
+	snapshot *SystemSnapshot
+	usage    map[string]*DiskUsage
+	errors   uint64
+	lastErr  error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// ExporterSample is the expvar representation of the latest sample.
+type ExporterSample struct {
+	Labels    map[string]string     `json:"labels,omitempty"`
+	Snapshot  *SystemSnapshot       `json:"snapshot"`
+	DiskUsage map[string]*DiskUsage `json:"disk_usage"`
+	Errors    uint64                `json:"errors"`
+	LastError string                `json:"last_error,omitempty"`
+}
+
+// NewExporter returns an Exporter sampling at the given interval.
+func NewExporter(interval time.Duration) *Exporter {
+	return &Exporter{Interval: interval}
+}
+
+// Start takes an initial sample and then continues sampling in the background
+// until Stop is called.
+func (e *Exporter) Start() {
+	e.mu.Lock()
+	if e.stop != nil {
+		e.mu.Unlock()
+		return
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	e.stop, e.done = stop, done
+	e.mu.Unlock()
+
+	e.Sample()
+	go e.run(stop, done)
+}
+
+// Stop stops sampling. The last sample remains available.
+func (e *Exporter) Stop() {
+	e.mu.Lock()
+	stop, done := e.stop, e.done
+	e.stop, e.done = nil, nil
+	e.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+func (e *Exporter) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			e.Sample()
+		}
+	}
+}
+
+// Sample takes a sample immediately. A failure keeps the previous sample and
+// is counted in the exported error count.
+func (e *Exporter) Sample() error {
+	s, err := Snapshot()
+	if err != nil {
+		e.mu.Lock()
+		e.errors++
+		e.lastErr = err
+		e.mu.Unlock()
+		return err
+	}
+
+	// file systems which can't be read or have no size, such as proc, are
+	// left out
+	usage := make(map[string]*DiskUsage)
+	for path := range s.Mounts {
+		if du, err := ReadDiskUsage(path); err == nil && du.Total > 0 {
+			usage[path] = du
+		}
+	}
+
+	e.mu.Lock()
+	e.snapshot = s
+	e.usage = usage
+	e.mu.Unlock()
+	return nil
+}
+
+// Latest returns the latest sample, or nil if no sample has succeeded.
+func (e *Exporter) Latest() *ExporterSample {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.snapshot == nil && e.errors == 0 {
+		return nil
+	}
+	sample := &ExporterSample{
+		Labels:    e.Labels,
+		Snapshot:  e.snapshot,
+		DiskUsage: e.usage,
+		Errors:    e.errors,
+	}
+	if e.lastErr != nil {
+		sample.LastError = e.lastErr.Error()
+	}
+	return sample
+}
+
+// PublishExpvar publishes the latest sample as an expvar variable with the
+// given name. Like expvar.Publish, it panics if the name is already in use.
+func (e *Exporter) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return e.Latest()
+	}))
+}
+
+// ServeHTTP writes the latest sample in the Prometheus text exposition format.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(e.prometheus())
+}
+
+// metric is a single Prometheus metric family.
+type metric struct {
+	name    string
+	help    string
+	typ     string
+	samples []metricSample
+}
+
+type metricSample struct {
+	labels []string
+	value  float64
+}
+
+func (m *metric) add(value float64, labels ...string) {
+	m.samples = append(m.samples, metricSample{labels: labels, value: value})
+}
+
+// prometheus renders the latest sample in the Prometheus text format.
+func (e *Exporter) prometheus() []byte {
+	e.mu.RLock()
+	s, usage, errors := e.snapshot, e.usage, e.errors
+	e.mu.RUnlock()
+
+	metrics := []*metric{
+		{name: "node_sample_errors_total", help: "Number of failed samples.", typ: "counter"},
+	}
+	metrics[0].add(float64(errors))
+
+	if s != nil {
+		metrics = append(metrics, snapshotMetrics(s, usage)...)
+	}
+
+	// constant labels, sorted for stable output
+	names := make([]string, 0, len(e.Labels))
+	for name := range e.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	constLabels := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		constLabels = append(constLabels, name, e.Labels[name])
+	}
+
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		fmt.Fprintf(&buf, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", m.name, m.typ)
+		for _, sample := range m.samples {
+			buf.WriteString(m.name)
+			writeLabels(&buf, append(append([]string(nil), sample.labels...), constLabels...))
+			fmt.Fprintf(&buf, " %v\n", sample.value)
+		}
+	}
+	return buf.Bytes()
+}
+
+// snapshotMetrics converts a snapshot into Prometheus metrics.
+func snapshotMetrics(s *SystemSnapshot, usage map[string]*DiskUsage) []*metric {
+	var metrics []*metric
+	gauge := func(name, help string, value float64) {
+		m := &metric{name: name, help: help, typ: "gauge"}
+		m.add(value)
+		metrics = append(metrics, m)
+	}
+
+	gauge("node_sample_duration_seconds", "Time taken to gather the last sample.", s.Duration.Seconds())
+
+	if la := s.LoadAvg; la != nil {
+		gauge("node_load1", "1 minute load average.", la.Load1)
+		gauge("node_load5", "5 minute load average.", la.Load5)
+		gauge("node_load15", "15 minute load average.", la.Load15)
+		gauge("node_procs_running", "Number of runnable processes.", float64(la.Running))
+		gauge("node_procs_total", "Number of processes.", float64(la.Total))
+	}
+
+	if mi := s.MemInfo; mi != nil {
+		gauge("node_memory_total_bytes", "Total memory.", float64(mi.MemTotal))
+		gauge("node_memory_free_bytes", "Free memory.", float64(mi.MemFree))
+		gauge("node_memory_available_bytes", "Memory available for new processes.", float64(mi.MemAvailable))
+		gauge("node_memory_buffers_bytes", "Memory used by buffers.", float64(mi.Buffers))
+		gauge("node_memory_cached_bytes", "Memory used by the page cache.", float64(mi.Cached))
+		gauge("node_swap_total_bytes", "Total swap.", float64(mi.SwapTotal))
+		gauge("node_swap_free_bytes", "Free swap.", float64(mi.SwapFree))
+	}
+
+	if len(s.Interfaces) > 0 {
+		devices := make([]string, 0, len(s.Interfaces))
+		for device := range s.Interfaces {
+			devices = append(devices, device)
+		}
+		sort.Strings(devices)
+
+		counters := []struct {
+			name  string
+			help  string
+			value func(is InterfaceStat) uint64
+		}{
+			{"node_network_receive_bytes_total", "Bytes received.", func(is InterfaceStat) uint64 { return is.RxBytes }},
+			{"node_network_receive_packets_total", "Packets received.", func(is InterfaceStat) uint64 { return is.RxPackets }},
+			{"node_network_receive_errors_total", "Receive errors.", func(is InterfaceStat) uint64 { return is.RxErrors }},
+			{"node_network_receive_drop_total", "Received packets dropped.", func(is InterfaceStat) uint64 { return is.RxDrop }},
+			{"node_network_transmit_bytes_total", "Bytes transmitted.", func(is InterfaceStat) uint64 { return is.TxBytes }},
+			{"node_network_transmit_packets_total", "Packets transmitted.", func(is InterfaceStat) uint64 { return is.TxPackets }},
+			{"node_network_transmit_errors_total", "Transmit errors.", func(is InterfaceStat) uint64 { return is.TxErrors }},
+			{"node_network_transmit_drop_total", "Transmitted packets dropped.", func(is InterfaceStat) uint64 { return is.TxDrop }},
+		}
+		for _, c := range counters {
+			m := &metric{name: c.name, help: c.help, typ: "counter"}
+			for _, device := range devices {
+				m.add(float64(c.value(s.Interfaces[device])), "device", device)
+			}
+			metrics = append(metrics, m)
+		}
+	}
+
+	if len(usage) > 0 {
+		paths := make([]string, 0, len(usage))
+		for path := range usage {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		size := &metric{name: "node_filesystem_size_bytes", help: "File system size.", typ: "gauge"}
+		free := &metric{name: "node_filesystem_free_bytes", help: "File system free space.", typ: "gauge"}
+		avail := &metric{name: "node_filesystem_avail_bytes", help: "File system space available to unprivileged users.", typ: "gauge"}
+		for _, path := range paths {
+			du := usage[path]
+			var fstype string
+			if mp := s.Mounts[path]; mp != nil {
+				fstype = mp.Fstype
+			}
+			size.add(float64(du.Total), "mountpoint", path, "fstype", fstype)
+			free.add(float64(du.Free), "mountpoint", path, "fstype", fstype)
+			avail.add(float64(du.Available), "mountpoint", path, "fstype", fstype)
+		}
+		metrics = append(metrics, size, free, avail)
+	}
+
+	return metrics
+}
+
+// writeLabels writes a list of name, value pairs as a Prometheus label set.
+func writeLabels(buf *bytes.Buffer, pairs []string) {
+	if len(pairs) == 0 {
+		return
+	}
+	buf.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(buf, "%s=\"%s\"", pairs[i], labelEscaper.Replace(pairs[i+1]))
+	}
+	buf.WriteByte('}')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
@@ -0,0 +1,73 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package proc
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestExporter(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	MountProcFile = testHelper.WriteTempFile("rootfs1 / rootfs2 rw 0 0")
+	DeviceStatsFile = testHelper.WriteTempFile(strings.Join([]string{
+		"Inter-|   Receive                                                |  Transmit",
+		" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed",
+		"    lo: 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16",
+	}, "\n"))
+	MemInfoFile = testHelper.WriteTempFile("MemTotal: 1 kB\nMemFree: 2 kB")
+	LoadAvgFile = testHelper.WriteTempFile("0.11 0.14 0.07 2/72 6066")
+	useTestCgroup(t, testHelper)
+
+	e := NewExporter(time.Hour)
+	e.Labels = map[string]string{"zone": "a\"b", "cluster": "east"}
+	tt.TestTrue(t, e.Latest() == nil)
+	e.Start()
+	defer e.Stop()
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, line := range []string{
+		"# TYPE node_load1 gauge",
+		`node_load1{cluster="east",zone="a\"b"} 0.11`,
+		`node_procs_total{cluster="east",zone="a\"b"} 72`,
+		`node_memory_free_bytes{cluster="east",zone="a\"b"} 2048`,
+		"# TYPE node_network_transmit_bytes_total counter",
+		`node_network_transmit_bytes_total{device="lo",cluster="east",zone="a\"b"} 9`,
+		`node_sample_errors_total{cluster="east",zone="a\"b"} 0`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			tt.Fatalf(t, "missing %q in:\n%s", line, body)
+		}
+	}
+	if runtime.GOOS == "linux" {
+		tt.TestTrue(t, strings.Contains(body, `node_filesystem_size_bytes{mountpoint="/",fstype="rootfs2",`))
+	}
+
+	// expvar publishes the same sample. Names can't be reused, so each run
+	// of the test uses its own.
+	name := "proc_exporter_test_" + tt.RandomTestString(10)
+	e.PublishExpvar(name)
+	var sample ExporterSample
+	tt.TestExpectSuccess(t, json.Unmarshal([]byte(expvar.Get(name).String()), &sample))
+	tt.TestEqual(t, sample.Labels["cluster"], "east")
+	tt.TestEqual(t, sample.Snapshot.LoadAvg.Total, 72)
+
+	// failures keep the previous sample
+	LoadAvgFile = testHelper.TempDir() + "/missing"
+	tt.TestExpectError(t, e.Sample())
+	latest := e.Latest()
+	tt.TestEqual(t, latest.Errors, uint64(1))
+	tt.TestNotEqual(t, latest.LastError, "")
+	tt.TestEqual(t, latest.Snapshot.LoadAvg.Total, 72)
+}
@@ -3,6 +3,7 @@
 package proc
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"strconv"
@@ -32,6 +33,41 @@ func ReadInt64(file string) (int64, error) {
 	return v, nil
 }
 
+// LineError records a failure to parse a single line of a proc file.
+type LineError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d of file %s: %s", e.Line, e.File, e.Err)
+}
+
+// PartialParseError is returned by the tolerant parsers when one or more lines
+// of a file could not be parsed. The entries that were parsed successfully are
+// still returned alongside it.
+type PartialParseError struct {
+	File   string
+	Errors []*LineError
+}
+
+func (e *PartialParseError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, le := range e.Errors {
+		msgs[i] = le.Error()
+	}
+	return fmt.Sprintf("failed to parse %d line(s) of file %s: %s",
+		len(e.Errors), e.File, strings.Join(msgs, "; "))
+}
+
+// parseFunc is the signature shared by ParseSimpleProcFile and
+// ParseSimpleProcFileTolerant.
+type parseFunc func(
+	filename string,
+	lf func(index int, line string) error,
+	ef func(line, index int, elm string) error) error
+
 // Parses the given file into various elements. This function assumes basic
 // white space semantics (' ' and '\t' for column splitting, and '\n' for
 // row splitting.
@@ -40,6 +76,28 @@ func ParseSimpleProcFile(
 	lf func(index int, line string) error,
 	ef func(line, index int, elm string) error) error {
 
+	return parseProcFile(filename, lf, ef, false)
+}
+
+// ParseSimpleProcFileTolerant works like ParseSimpleProcFile except that an
+// error returned from lf or ef does not stop the parsing. Instead the rest of
+// the offending line is skipped (lf is not called for it) and parsing continues
+// with the next line. If any lines failed a *PartialParseError listing them is
+// returned once the whole file has been processed.
+func ParseSimpleProcFileTolerant(
+	filename string,
+	lf func(index int, line string) error,
+	ef func(line, index int, elm string) error) error {
+
+	return parseProcFile(filename, lf, ef, true)
+}
+
+func parseProcFile(
+	filename string,
+	lf func(index int, line string) error,
+	ef func(line, index int, elm string) error,
+	tolerant bool) error {
+
 	fd, err := os.Open(filename)
 	if err != nil {
 		return err
@@ -62,16 +120,39 @@ func ParseSimpleProcFile(
 	contents := string(contentsBytes)
 	lines := strings.Split(contents, "\n")
 
+	var partial *PartialParseError
 	for li, l := range lines {
-		for ei, e := range strings.Fields(l) {
-			if err := ef(li, ei, e); err != nil {
-				return err
-			}
-		}
-		if err := lf(li, l); err != nil {
+		err := parseProcLine(li, l, lf, ef)
+		if err == nil {
+			continue
+		} else if !tolerant {
 			return err
 		}
+		if partial == nil {
+			partial = &PartialParseError{File: filename}
+		}
+		partial.Errors = append(partial.Errors, &LineError{
+			File: filename,
+			Line: li,
+			Err:  err,
+		})
 	}
 
+	if partial != nil {
+		return partial
+	}
 	return nil
 }
+
+func parseProcLine(
+	li int, l string,
+	lf func(index int, line string) error,
+	ef func(line, index int, elm string) error) error {
+
+	for ei, e := range strings.Fields(l) {
+		if err := ef(li, ei, e); err != nil {
+			return err
+		}
+	}
+	return lf(li, l)
+}
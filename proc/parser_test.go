@@ -135,3 +135,52 @@ func TestParseSimpleProcFile(t *testing.T) {
 			return nil
 		})
 }
+
+func TestParseSimpleProcFileTolerant(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	f := testHelper.WriteTempFile(strings.Join([]string{
+		"a 1",
+		"b bad",
+		"c 3",
+		"d bad"}, "\n"))
+
+	var values []string
+	var lines []int
+	err := ParseSimpleProcFileTolerant(
+		f,
+		func(index int, line string) error {
+			lines = append(lines, index)
+			return nil
+		},
+		func(line int, index int, elm string) error {
+			if elm == "bad" {
+				return fmt.Errorf("bad element")
+			}
+			values = append(values, elm)
+			return nil
+		})
+	tt.TestExpectError(t, err)
+	perr, ok := err.(*PartialParseError)
+	tt.TestTrue(t, ok)
+	tt.TestEqual(t, perr.File, f)
+	tt.TestEqual(t, len(perr.Errors), 2)
+	tt.TestEqual(t, perr.Errors[0].Line, 1)
+	tt.TestEqual(t, perr.Errors[1].Line, 3)
+
+	// The lines after the failures are still processed, but lf is not called
+	// for the failed lines.
+	tt.TestEqual(t, values, []string{"a", "1", "b", "c", "3", "d"})
+	tt.TestEqual(t, lines, []int{0, 2})
+
+	// No errors means a nil error.
+	err = ParseSimpleProcFileTolerant(f, nil, nil)
+	tt.TestExpectSuccess(t, err)
+
+	// A missing file is still a hard failure.
+	err = ParseSimpleProcFileTolerant(f+".missing", nil, nil)
+	tt.TestExpectError(t, err)
+	_, ok = err.(*PartialParseError)
+	tt.TestFalse(t, ok)
+}
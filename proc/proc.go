@@ -24,11 +24,31 @@ var MountProcFile string = "/proc/mounts"
 // Reads through /proc/mounts and returns the data associated with the mount
 // points as a list of MountPoint structures.
 func MountPoints() (map[string]*MountPoint, error) {
+	return mountPoints(ParseSimpleProcFile)
+}
+
+// MountPointsTolerant works like MountPoints except that malformed lines are
+// skipped rather than failing the whole read. If any lines were skipped then
+// the successfully parsed mount points are returned along with a
+// *PartialParseError describing the failures.
+func MountPointsTolerant() (map[string]*MountPoint, error) {
+	return mountPoints(ParseSimpleProcFileTolerant)
+}
+
+func mountPoints(parse parseFunc) (map[string]*MountPoint, error) {
 	mp := make(map[string]*MountPoint, 0)
 	var current *MountPoint
-	err := ParseSimpleProcFile(
+	err := parse(
 		MountProcFile,
-		nil,
+		func(index int, line string) error {
+			// Only record the mount point once the whole line has been
+			// parsed so a partially parsed line is never returned.
+			if current != nil && current.Path != "" {
+				mp[current.Path] = current
+			}
+			current = nil
+			return nil
+		},
 		func(line int, index int, elm string) error {
 			switch index {
 			case 0:
@@ -41,7 +61,6 @@ func MountPoints() (map[string]*MountPoint, error) {
 						line, MountProcFile, elm)
 				}
 				current.Path = elm
-				mp[elm] = current
 			case 2:
 				current.Fstype = elm
 			case 3:
@@ -70,11 +89,23 @@ func MountPoints() (map[string]*MountPoint, error) {
 			return nil
 		})
 	if err != nil {
+		if _, ok := err.(*PartialParseError); ok {
+			return mp, err
+		}
 		return nil, err
 	}
 	return mp, nil
 }
 
+// DiskUsage stores the usage of a mounted file system. All values are in
+// bytes. Available is the space available to unprivileged users, which may be
+// less than Free.
+type DiskUsage struct {
+	Total     uint64
+	Free      uint64
+	Available uint64
+}
+
 // Stores interface statistics that are gleaned from /proc/dev/net.
 type InterfaceStat struct {
 	Device       string
@@ -97,10 +128,22 @@ type InterfaceStat struct {
 }
 
 // The file that stores network device statistics.
-var DeviceStatsFile string = "/proc/det/dev"
+var DeviceStatsFile string = "/proc/net/dev"
 
 // Returns the interface statistics as a map keyed off the interface name.
 func InterfaceStats() (map[string]InterfaceStat, error) {
+	return interfaceStats(ParseSimpleProcFile)
+}
+
+// InterfaceStatsTolerant works like InterfaceStats except that malformed lines
+// are skipped rather than failing the whole read. If any lines were skipped then
+// the successfully parsed interfaces are returned along with a
+// *PartialParseError describing the failures.
+func InterfaceStatsTolerant() (map[string]InterfaceStat, error) {
+	return interfaceStats(ParseSimpleProcFileTolerant)
+}
+
+func interfaceStats(parse parseFunc) (map[string]InterfaceStat, error) {
 	ret := make(map[string]InterfaceStat, 0)
 	var current InterfaceStat
 	lastline := -1
@@ -114,9 +157,13 @@ func InterfaceStats() (map[string]InterfaceStat, error) {
 		return nil
 	}
 	el := func(line int, index int, elm string) (err error) {
+		// The first two lines of the file are column headers.
+		if line < 2 {
+			return nil
+		}
 		switch index {
 		case 0:
-			current.Device = strings.Split(elm, ":")[0]
+			current = InterfaceStat{Device: strings.Split(elm, ":")[0]}
 		case 1:
 			current.RxBytes, err = strconv.ParseUint(elm, 10, 64)
 		case 2:
@@ -156,9 +203,134 @@ func InterfaceStats() (map[string]InterfaceStat, error) {
 	}
 
 	// Now actually attempt to parse the config
-	if err := ParseSimpleProcFile(DeviceStatsFile, lf, el); err != nil {
+	if err := parse(DeviceStatsFile, lf, el); err != nil {
+		if _, ok := err.(*PartialParseError); ok {
+			return ret, err
+		}
 		return nil, err
 	}
 
 	return ret, nil
 }
+
+// MemInfo stores memory statistics that are gleaned from /proc/meminfo. All
+// values are in bytes.
+type MemInfo struct {
+	MemTotal     uint64
+	MemFree      uint64
+	MemAvailable uint64
+	Buffers      uint64
+	Cached       uint64
+	SwapTotal    uint64
+	SwapFree     uint64
+
+	// Fields contains every value found in the file keyed off the name as it
+	// appears in the file. Values with a kB unit are converted to bytes.
+	Fields map[string]uint64
+}
+
+// The file that stores memory statistics.
+var MemInfoFile string = "/proc/meminfo"
+
+// ReadMemInfo reads through /proc/meminfo and returns the memory statistics.
+func ReadMemInfo() (*MemInfo, error) {
+	return readMemInfo(ParseSimpleProcFile)
+}
+
+// ReadMemInfoTolerant works like ReadMemInfo except that malformed lines are
+// skipped rather than failing the whole read. If any lines were skipped then
+// the successfully parsed statistics are returned along with a
+// *PartialParseError describing the failures.
+func ReadMemInfoTolerant() (*MemInfo, error) {
+	return readMemInfo(ParseSimpleProcFileTolerant)
+}
+
+func readMemInfo(parse parseFunc) (*MemInfo, error) {
+	mi := &MemInfo{Fields: make(map[string]uint64)}
+	var key string
+	el := func(line int, index int, elm string) error {
+		switch index {
+		case 0:
+			key = strings.TrimSuffix(elm, ":")
+		case 1:
+			n, err := strconv.ParseUint(elm, 10, 64)
+			if err != nil {
+				return fmt.Errorf(
+					"Error parsing column %d on line %d of file %s: %s",
+					index, line, MemInfoFile, elm)
+			}
+			mi.Fields[key] = n
+		case 2:
+			if elm == "kB" {
+				mi.Fields[key] *= 1024
+			}
+		}
+		return nil
+	}
+	err := parse(MemInfoFile, nil, el)
+	if err != nil {
+		if _, ok := err.(*PartialParseError); !ok {
+			return nil, err
+		}
+	}
+
+	mi.MemTotal = mi.Fields["MemTotal"]
+	mi.MemFree = mi.Fields["MemFree"]
+	mi.MemAvailable = mi.Fields["MemAvailable"]
+	mi.Buffers = mi.Fields["Buffers"]
+	mi.Cached = mi.Fields["Cached"]
+	mi.SwapTotal = mi.Fields["SwapTotal"]
+	mi.SwapFree = mi.Fields["SwapFree"]
+	return mi, err
+}
+
+// LoadAvg stores the system load averages that are gleaned from
+// /proc/loadavg.
+type LoadAvg struct {
+	Load1   float64
+	Load5   float64
+	Load15  float64
+	Running int
+	Total   int
+	LastPID int
+}
+
+// The file that stores the system load averages.
+var LoadAvgFile string = "/proc/loadavg"
+
+// ReadLoadAvg reads through /proc/loadavg and returns the load averages.
+func ReadLoadAvg() (*LoadAvg, error) {
+	la := &LoadAvg{}
+	el := func(line int, index int, elm string) (err error) {
+		switch index {
+		case 0:
+			la.Load1, err = strconv.ParseFloat(elm, 64)
+		case 1:
+			la.Load5, err = strconv.ParseFloat(elm, 64)
+		case 2:
+			la.Load15, err = strconv.ParseFloat(elm, 64)
+		case 3:
+			p := strings.Split(elm, "/")
+			if len(p) != 2 {
+				return fmt.Errorf(
+					"Invalid process counts on line %d of file %s: %s",
+					line, LoadAvgFile, elm)
+			}
+			if la.Running, err = strconv.Atoi(p[0]); err != nil {
+				return err
+			}
+			la.Total, err = strconv.Atoi(p[1])
+		case 4:
+			la.LastPID, err = strconv.Atoi(elm)
+		default:
+			return fmt.Errorf(
+				"Too many colums on line %d of file %s",
+				line, LoadAvgFile)
+		}
+		return
+	}
+	if err := ParseSimpleProcFile(LoadAvgFile, nil, el); err != nil {
+		return nil, err
+	}
+	return la, nil
+}
@@ -217,3 +217,89 @@ func TestInterfaceStats(t *testing.T) {
 		tt.Fatalf(t, "Expected error not returned.")
 	}
 }
+
+func TestReadMemInfo(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	MemInfoFile = testHelper.WriteTempFile(strings.Join([]string{
+		"MemTotal:        6158152 kB",
+		"MemFree:         4987044 kB",
+		"MemAvailable:    5691960 kB",
+		"Buffers:           63644 kB",
+		"Cached:           841020 kB",
+		"SwapTotal:             0 kB",
+		"SwapFree:              0 kB",
+		"HugePages_Total:       4",
+	}, "\n"))
+	mi, err := ReadMemInfo()
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, mi.MemTotal, uint64(6158152*1024))
+	tt.TestEqual(t, mi.MemFree, uint64(4987044*1024))
+	tt.TestEqual(t, mi.MemAvailable, uint64(5691960*1024))
+	tt.TestEqual(t, mi.Buffers, uint64(63644*1024))
+	tt.TestEqual(t, mi.Cached, uint64(841020*1024))
+	tt.TestEqual(t, mi.SwapTotal, uint64(0))
+	tt.TestEqual(t, mi.Fields["HugePages_Total"], uint64(4))
+
+	MemInfoFile = testHelper.WriteTempFile("MemTotal: NaN kB")
+	_, err = ReadMemInfo()
+	tt.TestExpectError(t, err)
+}
+
+func TestReadLoadAvg(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	LoadAvgFile = testHelper.WriteTempFile("0.11 0.14 0.07 2/72 6066\n")
+	la, err := ReadLoadAvg()
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, la, &LoadAvg{
+		Load1:   0.11,
+		Load5:   0.14,
+		Load15:  0.07,
+		Running: 2,
+		Total:   72,
+		LastPID: 6066,
+	})
+
+	LoadAvgFile = testHelper.WriteTempFile("0.11 0.14 0.07 72 6066\n")
+	_, err = ReadLoadAvg()
+	tt.TestExpectError(t, err)
+}
+
+func TestTolerantParsers(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	MountProcFile = testHelper.WriteTempFile(strings.Join([]string{
+		"rootfs1 / rootfs2 rw 0 0",
+		"bad /bad ext4 rw NaN 0",
+		"tmpfs /tmp tmpfs rw 0 0",
+	}, "\n"))
+	_, err := MountPoints()
+	tt.TestExpectError(t, err)
+	mp, err := MountPointsTolerant()
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, len(err.(*PartialParseError).Errors), 1)
+	tt.TestEqual(t, len(mp), 2)
+	tt.TestEqual(t, mp["/tmp"].Fstype, "tmpfs")
+	tt.TestTrue(t, mp["/bad"] == nil)
+
+	DeviceStatsFile = testHelper.WriteTempFile(strings.Join([]string{
+		"header 1",
+		"header 2",
+		"dev0: 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16",
+		"dev1: NaN NaN NaN NaN NaN NaN NaN NaN NaN NaN NaN NaN NaN NaN NaN NaN",
+	}, "\n"))
+	stats, err := InterfaceStatsTolerant()
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, len(stats), 1)
+	tt.TestEqual(t, stats["dev0"].TxMulticast, uint64(16))
+
+	MemInfoFile = testHelper.WriteTempFile("MemTotal: 1 kB\nMemFree: NaN kB")
+	mi, err := ReadMemInfoTolerant()
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, mi.MemTotal, uint64(1024))
+	tt.TestEqual(t, mi.MemFree, uint64(0))
+}
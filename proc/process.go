@@ -0,0 +1,187 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package proc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, the units of the times in /proc/<pid>/stat. It is
+// fixed at 100 on all the platforms Linux supports.
+const clockTicks = 100
+
+// ProcessInfo describes a running process.
+type ProcessInfo struct {
+	PID  int
+	PPID int
+
+	// Name is the command name from /proc/<pid>/stat, which the kernel
+	// truncates to 15 characters.
+	Name string
+
+	// Cmdline is the command line, which is empty for kernel threads and
+	// zombies.
+	Cmdline []string
+
+	// UID is the real user ID.
+	UID int
+
+	StartTime time.Time
+}
+
+// ReadProcessInfo returns information about the process with the pid.
+func ReadProcessInfo(pid int) (*ProcessInfo, error) {
+	st, err := ReadStat()
+	if err != nil {
+		return nil, err
+	}
+	return readProcessInfo(pid, st.BootTime)
+}
+
+func readProcessInfo(pid int, bootTime time.Time) (*ProcessInfo, error) {
+	ps, err := ReadProcessStat(pid)
+	if err != nil {
+		return nil, err
+	}
+	pi := &ProcessInfo{
+		PID:       ps.PID,
+		PPID:      ps.PPID,
+		Name:      ps.Comm,
+		StartTime: bootTime.Add(time.Duration(ps.StartTime) * time.Second / clockTicks),
+	}
+
+	dir := filepath.Join(ProcessDir, strconv.Itoa(pid))
+	cmdline, err := ioutil.ReadFile(filepath.Join(dir, "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+	cmdline = bytes.TrimRight(cmdline, "\x00")
+	if len(cmdline) > 0 {
+		pi.Cmdline = strings.Split(string(cmdline), "\x00")
+	}
+
+	statusFile := filepath.Join(dir, "status")
+	found := false
+	el := func(line int, index int, elm string) error {
+		if index == 0 {
+			found = elm == "Uid:"
+		} else if index == 1 && found {
+			n, err := strconv.Atoi(elm)
+			if err != nil {
+				return fmt.Errorf(
+					"Error parsing column %d on line %d of file %s: %s",
+					index, line, statusFile, elm)
+			}
+			pi.UID = n
+		}
+		return nil
+	}
+	if err := ParseSimpleProcFile(statusFile, nil, el); err != nil {
+		return nil, err
+	}
+	return pi, nil
+}
+
+// ListProcesses returns every running process, sorted by pid. Processes which
+// exit while the list is being built are left out.
+func ListProcesses() ([]*ProcessInfo, error) {
+	st, err := ReadStat()
+	if err != nil {
+		return nil, err
+	}
+	names, err := readDirNames(ProcessDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var procs []*ProcessInfo
+	for _, name := range names {
+		pid, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		pi, err := readProcessInfo(pid, st.BootTime)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		procs = append(procs, pi)
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i].PID < procs[j].PID })
+	return procs, nil
+}
+
+// readDirNames returns the names of the entries in the directory.
+func readDirNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdirnames(-1)
+}
+
+// FindProcessesByName returns the processes whose name, or the base name of
+// the first word of their command line, is name.
+func FindProcessesByName(name string) ([]*ProcessInfo, error) {
+	return findProcesses(func(pi *ProcessInfo) bool {
+		return pi.Name == name || (len(pi.Cmdline) > 0 && filepath.Base(pi.Cmdline[0]) == name)
+	})
+}
+
+// FindProcessesByCmdline returns the processes whose command line, joined with
+// spaces, contains s.
+func FindProcessesByCmdline(s string) ([]*ProcessInfo, error) {
+	return findProcesses(func(pi *ProcessInfo) bool {
+		return strings.Contains(strings.Join(pi.Cmdline, " "), s)
+	})
+}
+
+func findProcesses(match func(pi *ProcessInfo) bool) ([]*ProcessInfo, error) {
+	procs, err := ListProcesses()
+	if err != nil {
+		return nil, err
+	}
+	var found []*ProcessInfo
+	for _, pi := range procs {
+		if match(pi) {
+			found = append(found, pi)
+		}
+	}
+	return found, nil
+}
+
+// ProcessNode is a process within a tree built by BuildProcessTree.
+type ProcessNode struct {
+	*ProcessInfo
+	Children []*ProcessNode
+}
+
+// BuildProcessTree arranges the processes into trees by their parent pid,
+// returning the roots. A process is a root if its parent isn't in the list,
+// such as init and kthreadd. Children are kept in the order of the list.
+func BuildProcessTree(procs []*ProcessInfo) []*ProcessNode {
+	nodes := make(map[int]*ProcessNode, len(procs))
+	for _, pi := range procs {
+		nodes[pi.PID] = &ProcessNode{ProcessInfo: pi}
+	}
+	var roots []*ProcessNode
+	for _, pi := range procs {
+		node := nodes[pi.PID]
+		if parent, ok := nodes[pi.PPID]; ok && parent != node {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
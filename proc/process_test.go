@@ -0,0 +1,85 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package proc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// writeProcess writes the files read by ReadProcessInfo for a fake process.
+func writeProcess(t *testing.T, pid, ppid int, comm, cmdline string, uid int, start uint64) {
+	dir := filepath.Join(ProcessDir, strconv.Itoa(pid))
+	tt.TestExpectSuccess(t, os.Mkdir(dir, 0755))
+	stat := fmt.Sprintf("%d (%s) S %d %d %d 0 -1 0 0 0 0 0 1 1 0 0 20 0 1 0 %d 1024 1\n",
+		pid, comm, ppid, pid, pid, start)
+	status := fmt.Sprintf("Name:\t%s\nUid:\t%d\t%d\t%d\t%d\n", comm, uid, uid+1, uid, uid)
+	tt.TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0644))
+	tt.TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "status"), []byte(status), 0644))
+	tt.TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0644))
+}
+
+func TestListProcesses(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	StatFile = testHelper.WriteTempFile("cpu 1 2 3 4\nbtime 1500000000\n")
+	ProcessDir = testHelper.TempDir()
+	defer func() { ProcessDir = "/proc" }()
+	writeProcess(t, 1, 0, "init", "/sbin/init\x00", 0, 100)
+	writeProcess(t, 2, 0, "kthreadd", "", 0, 100)
+	writeProcess(t, 10, 1, "sshd", "/usr/sbin/sshd\x00-D\x00", 0, 250)
+	writeProcess(t, 25, 10, "bash", "-bash\x00", 1000, 1000)
+	writeProcess(t, 30, 1, "python3", "/usr/bin/python3\x00/opt/agent.py\x00--verbose\x00", 1000, 1500)
+	tt.TestExpectSuccess(t, os.Mkdir(filepath.Join(ProcessDir, "self"), 0755))
+
+	procs, err := ListProcesses()
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(procs), 5)
+	tt.TestEqual(t, procs[3], &ProcessInfo{
+		PID:       25,
+		PPID:      10,
+		Name:      "bash",
+		Cmdline:   []string{"-bash"},
+		UID:       1000,
+		StartTime: time.Unix(1500000010, 0),
+	})
+	tt.TestEqual(t, len(procs[1].Cmdline), 0)
+
+	pi, err := ReadProcessInfo(10)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, pi.Cmdline, []string{"/usr/sbin/sshd", "-D"})
+	_, err = ReadProcessInfo(11)
+	tt.TestExpectError(t, err)
+
+	found, err := FindProcessesByName("sshd")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(found), 1)
+	tt.TestEqual(t, found[0].PID, 10)
+	found, err = FindProcessesByName("python3")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(found), 1)
+	found, err = FindProcessesByCmdline("agent.py --verbose")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(found), 1)
+	tt.TestEqual(t, found[0].PID, 30)
+	found, err = FindProcessesByCmdline("nothing")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(found), 0)
+
+	roots := BuildProcessTree(procs)
+	tt.TestEqual(t, len(roots), 2)
+	tt.TestEqual(t, roots[0].PID, 1)
+	tt.TestEqual(t, roots[1].PID, 2)
+	tt.TestEqual(t, len(roots[0].Children), 2)
+	tt.TestEqual(t, roots[0].Children[0].PID, 10)
+	tt.TestEqual(t, roots[0].Children[0].Children[0].PID, 25)
+	tt.TestEqual(t, roots[0].Children[1].PID, 30)
+}
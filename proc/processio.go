@@ -0,0 +1,114 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package proc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProcessIOStats stores the I/O counters of a process that are gleaned from
+// /proc/<pid>/io. All values are in bytes except SyscR and SyscW, which count
+// read and write system calls.
+type ProcessIOStats struct {
+	// RChar and WChar count bytes passed to read and write calls,
+	// including those satisfied by the page cache.
+	RChar uint64
+	WChar uint64
+	SyscR uint64
+	SyscW uint64
+
+	// ReadBytes and WriteBytes count bytes fetched from and sent to the
+	// storage layer.
+	ReadBytes           uint64
+	WriteBytes          uint64
+	CancelledWriteBytes uint64
+}
+
+// PerProcessIOStats reads /proc/<pid>/io and returns the I/O counters of the
+// process. Reading another user's process normally requires root.
+func PerProcessIOStats(pid int) (*ProcessIOStats, error) {
+	file := filepath.Join(ProcessDir, strconv.Itoa(pid), "io")
+	io := &ProcessIOStats{}
+	var field *uint64
+	el := func(line int, index int, elm string) error {
+		switch index {
+		case 0:
+			switch strings.TrimSuffix(elm, ":") {
+			case "rchar":
+				field = &io.RChar
+			case "wchar":
+				field = &io.WChar
+			case "syscr":
+				field = &io.SyscR
+			case "syscw":
+				field = &io.SyscW
+			case "read_bytes":
+				field = &io.ReadBytes
+			case "write_bytes":
+				field = &io.WriteBytes
+			case "cancelled_write_bytes":
+				field = &io.CancelledWriteBytes
+			default:
+				field = nil
+			}
+		case 1:
+			if field == nil {
+				return nil
+			}
+			n, err := strconv.ParseUint(elm, 10, 64)
+			if err != nil {
+				return fmt.Errorf(
+					"Error parsing column %d on line %d of file %s: %s",
+					index, line, file, elm)
+			}
+			*field = n
+		}
+		return nil
+	}
+	if err := ParseSimpleProcFile(file, nil, el); err != nil {
+		return nil, err
+	}
+	return io, nil
+}
+
+// OpenFile is a file descriptor held open by a process.
+type OpenFile struct {
+	FD int
+
+	// Target is what the descriptor refers to: a path for files, or a
+	// description such as "socket:[12345]" or "pipe:[678]".
+	Target string
+}
+
+// ListOpenFiles returns the file descriptors held open by the process, sorted
+// by number, from /proc/<pid>/fd. Descriptors closed while the list is being
+// built are left out. Reading another user's process normally requires root.
+func ListOpenFiles(pid int) ([]OpenFile, error) {
+	dir := filepath.Join(ProcessDir, strconv.Itoa(pid), "fd")
+	names, err := readDirNames(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]OpenFile, 0, len(names))
+	for _, name := range names {
+		fd, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		files = append(files, OpenFile{FD: fd, Target: target})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].FD < files[j].FD })
+	return files, nil
+}
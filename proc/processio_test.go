@@ -0,0 +1,69 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package proc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestPerProcessIOStats(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	ProcessDir = testHelper.TempDir()
+	defer func() { ProcessDir = "/proc" }()
+	writeFiles(t, ProcessDir, map[string]string{"7/io": "" +
+		"rchar: 1000\n" +
+		"wchar: 2000\n" +
+		"syscr: 10\n" +
+		"syscw: 20\n" +
+		"read_bytes: 4096\n" +
+		"write_bytes: 8192\n" +
+		"cancelled_write_bytes: 512\n"})
+
+	io, err := PerProcessIOStats(7)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, io, &ProcessIOStats{
+		RChar:               1000,
+		WChar:               2000,
+		SyscR:               10,
+		SyscW:               20,
+		ReadBytes:           4096,
+		WriteBytes:          8192,
+		CancelledWriteBytes: 512,
+	})
+
+	writeFiles(t, ProcessDir, map[string]string{"7/io": "rchar: NaN\n"})
+	_, err = PerProcessIOStats(7)
+	tt.TestExpectError(t, err)
+	_, err = PerProcessIOStats(8)
+	tt.TestExpectError(t, err)
+}
+
+func TestListOpenFiles(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	ProcessDir = testHelper.TempDir()
+	defer func() { ProcessDir = "/proc" }()
+	dir := filepath.Join(ProcessDir, "7", "fd")
+	tt.TestExpectSuccess(t, os.MkdirAll(dir, 0755))
+	tt.TestExpectSuccess(t, os.Symlink("/dev/null", filepath.Join(dir, "0")))
+	tt.TestExpectSuccess(t, os.Symlink("socket:[12345]", filepath.Join(dir, "10")))
+	tt.TestExpectSuccess(t, os.Symlink("/var/log/app.log", filepath.Join(dir, "2")))
+
+	files, err := ListOpenFiles(7)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, files, []OpenFile{
+		{FD: 0, Target: "/dev/null"},
+		{FD: 2, Target: "/var/log/app.log"},
+		{FD: 10, Target: "socket:[12345]"},
+	})
+
+	_, err = ListOpenFiles(8)
+	tt.TestExpectError(t, err)
+}
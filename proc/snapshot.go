@@ -0,0 +1,58 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package proc
+
+import (
+	"os"
+	"time"
+)
+
+// SystemSnapshot is a point in time view of the system statistics exposed by
+// this package. It is intended to be marshalled to JSON and reported as a
+// single unit so consumers don't have to stitch together reads of the
+// individual files made at different times.
+type SystemSnapshot struct {
+	// Time is when the snapshot was started.
+	Time time.Time `json:"time"`
+
+	// Duration is how long it took to gather the snapshot.
+	Duration time.Duration `json:"duration"`
+
+	Mounts     map[string]*MountPoint   `json:"mounts"`
+	Interfaces map[string]InterfaceStat `json:"interfaces"`
+	MemInfo    *MemInfo                 `json:"meminfo"`
+	LoadAvg    *LoadAvg                 `json:"loadavg"`
+
+	// Cgroup holds the limits and usage of the cgroup the calling process
+	// is in. It is nil if they can't be read, such as for a process in the
+	// root cgroup v2, which has no limits.
+	Cgroup *CgroupStats `json:"cgroup,omitempty"`
+}
+
+// Snapshot gathers the mount points, interface statistics, memory statistics,
+// load averages, and the cgroup statistics of the calling process in a single
+// call. An error reading any one of them other than the cgroup statistics will
+// cause the snapshot to fail.
+func Snapshot() (*SystemSnapshot, error) {
+	var err error
+	s := &SystemSnapshot{Time: time.Now()}
+
+	if s.Mounts, err = MountPoints(); err != nil {
+		return nil, err
+	}
+	if s.Interfaces, err = InterfaceStats(); err != nil {
+		return nil, err
+	}
+	if s.MemInfo, err = ReadMemInfo(); err != nil {
+		return nil, err
+	}
+	if s.LoadAvg, err = ReadLoadAvg(); err != nil {
+		return nil, err
+	}
+	if cgroup, err := ReadCgroupStats(os.Getpid()); err == nil {
+		s.Cgroup = cgroup
+	}
+
+	s.Duration = time.Since(s.Time)
+	return s, nil
+}
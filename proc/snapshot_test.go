@@ -0,0 +1,118 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package proc
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// useTestCgroup points ProcessDir and CgroupRoot at a cgroup v2 hierarchy
+// containing the calling process, restoring them when the test finishes.
+func useTestCgroup(t *testing.T, testHelper *tt.TestTool) {
+	ProcessDir = testHelper.TempDir()
+	CgroupRoot = testHelper.TempDir()
+	testHelper.AddTestFinalizer(func() { ProcessDir, CgroupRoot = "/proc", "/sys/fs/cgroup" })
+	writeFiles(t, ProcessDir, map[string]string{
+		strconv.Itoa(os.Getpid()) + "/cgroup": "0::/app.service\n",
+	})
+	writeFiles(t, CgroupRoot, map[string]string{
+		"cgroup.controllers":         "cpu memory\n",
+		"app.service/memory.max":     "max\n",
+		"app.service/memory.current": "4096\n",
+		"app.service/cpu.max":        "50000 100000\n",
+		"app.service/cpu.stat":       "usage_usec 1000\n",
+	})
+}
+
+// useTestFiles points the files read by Snapshot, other than the cgroup
+// files, at temporary files.
+func useTestFiles(testHelper *tt.TestTool) {
+	MountProcFile = testHelper.WriteTempFile("rootfs1 / rootfs2 rw 0 0")
+	DeviceStatsFile = testHelper.WriteTempFile(strings.Join([]string{
+		"Inter-|   Receive                                                |  Transmit",
+		" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed",
+		"    lo: 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16",
+	}, "\n"))
+	MemInfoFile = testHelper.WriteTempFile("MemTotal: 1 kB\nMemFree: 2 kB")
+	LoadAvgFile = testHelper.WriteTempFile("0.11 0.14 0.07 2/72 6066")
+}
+
+func TestSnapshot(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	useTestFiles(testHelper)
+	useTestCgroup(t, testHelper)
+
+	s, err := Snapshot()
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(s.Mounts), 1)
+	tt.TestEqual(t, s.Interfaces["lo"].TxMulticast, uint64(16))
+	tt.TestEqual(t, s.MemInfo.MemFree, uint64(2048))
+	tt.TestEqual(t, s.LoadAvg.Total, 72)
+	tt.TestEqual(t, s.Cgroup, &CgroupStats{
+		MemoryUsage: 4096,
+		CPULimit:    0.5,
+		CPUUsage:    time.Millisecond,
+	})
+	tt.TestFalse(t, s.Time.IsZero())
+
+	b, err := json.Marshal(s)
+	tt.TestExpectSuccess(t, err)
+	var out SystemSnapshot
+	tt.TestExpectSuccess(t, json.Unmarshal(b, &out))
+	tt.TestEqual(t, out.Mounts["/"].Dev, "rootfs1")
+	tt.TestEqual(t, out.LoadAvg, s.LoadAvg)
+	tt.TestEqual(t, out.Cgroup, s.Cgroup)
+
+	// A failure to read any of the files fails the snapshot.
+	LoadAvgFile = testHelper.TempDir() + "/missing"
+	_, err = Snapshot()
+	tt.TestExpectError(t, err)
+}
+
+func TestSnapshotWithoutCgroupStats(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	useTestFiles(testHelper)
+
+	// The root cgroup v2 has no memory.max or cpu.max files.
+	ProcessDir = testHelper.TempDir()
+	CgroupRoot = testHelper.TempDir()
+	defer func() { ProcessDir, CgroupRoot = "/proc", "/sys/fs/cgroup" }()
+	writeFiles(t, ProcessDir, map[string]string{
+		strconv.Itoa(os.Getpid()) + "/cgroup": "0::/\n",
+	})
+	writeFiles(t, CgroupRoot, map[string]string{
+		"cgroup.controllers": "cpu memory\n",
+		"cpu.stat":           "usage_usec 1000\n",
+	})
+	_, err := ReadCgroupStats(os.Getpid())
+	tt.TestExpectError(t, err)
+
+	s, err := Snapshot()
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, s.LoadAvg.Total, 72)
+	tt.TestTrue(t, s.Cgroup == nil)
+	b, err := json.Marshal(s)
+	tt.TestExpectSuccess(t, err)
+	tt.TestFalse(t, strings.Contains(string(b), `"cgroup"`))
+
+	e := NewExporter(time.Hour)
+	tt.TestExpectSuccess(t, e.Sample())
+	tt.TestEqual(t, e.Latest().Errors, uint64(0))
+
+	// Nor does a process whose cgroups can't be read.
+	ProcessDir = testHelper.TempDir()
+	s, err = Snapshot()
+	tt.TestExpectSuccess(t, err)
+	tt.TestTrue(t, s.Cgroup == nil)
+}
@@ -0,0 +1,293 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package proc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CPUStat stores the time a CPU has spent in each state, gleaned from
+// /proc/stat. Values are in units of USER_HZ, typically hundredths of a
+// second. Guest time is also counted in User and Nice.
+type CPUStat struct {
+	// CPU is "cpu" for the total of all CPUs, otherwise "cpu0", "cpu1", etc.
+	CPU       string
+	User      uint64
+	Nice      uint64
+	System    uint64
+	Idle      uint64
+	IOWait    uint64
+	IRQ       uint64
+	SoftIRQ   uint64
+	Steal     uint64
+	Guest     uint64
+	GuestNice uint64
+}
+
+// Total returns the total time accounted for by the CPU.
+func (c CPUStat) Total() uint64 {
+	return c.User + c.Nice + c.System + c.Idle + c.IOWait + c.IRQ + c.SoftIRQ + c.Steal
+}
+
+// Busy returns the time the CPU wasn't idle or waiting for I/O.
+func (c CPUStat) Busy() uint64 {
+	return c.Total() - c.Idle - c.IOWait
+}
+
+// Stat stores the kernel and system statistics that are gleaned from
+// /proc/stat.
+type Stat struct {
+	// CPU is the total of all CPUs, and CPUs has each CPU in the order
+	// listed.
+	CPU  CPUStat
+	CPUs []CPUStat
+
+	ContextSwitches uint64
+	BootTime        time.Time
+	Processes       uint64
+	ProcsRunning    uint64
+	ProcsBlocked    uint64
+}
+
+// The file that stores kernel and system statistics.
+var StatFile string = "/proc/stat"
+
+// ReadStat reads through /proc/stat and returns the statistics.
+func ReadStat() (*Stat, error) {
+	return readStat(ParseSimpleProcFile)
+}
+
+// ReadStatTolerant works like ReadStat except that malformed lines are skipped
+// rather than failing the whole read. If any lines were skipped then the
+// successfully parsed statistics are returned along with a *PartialParseError
+// describing the failures.
+func ReadStatTolerant() (*Stat, error) {
+	return readStat(ParseSimpleProcFileTolerant)
+}
+
+func readStat(parse parseFunc) (*Stat, error) {
+	st := &Stat{}
+	var key string
+	var cpu *CPUStat
+	lf := func(index int, line string) error {
+		if cpu != nil {
+			if cpu.CPU == "cpu" {
+				st.CPU = *cpu
+			} else {
+				st.CPUs = append(st.CPUs, *cpu)
+			}
+		}
+		cpu = nil
+		return nil
+	}
+	el := func(line int, index int, elm string) error {
+		if index == 0 {
+			key = elm
+			cpu = nil
+			if strings.HasPrefix(key, "cpu") {
+				cpu = &CPUStat{CPU: key}
+			}
+			return nil
+		}
+
+		// Lines such as intr and softirq have many columns, only the
+		// first of which is interesting.
+		if cpu == nil && index > 1 {
+			return nil
+		}
+		n, err := strconv.ParseUint(elm, 10, 64)
+		if err != nil {
+			cpu = nil
+			return fmt.Errorf(
+				"Error parsing column %d on line %d of file %s: %s",
+				index, line, StatFile, elm)
+		}
+		if cpu != nil {
+			fields := []*uint64{
+				&cpu.User, &cpu.Nice, &cpu.System, &cpu.Idle, &cpu.IOWait,
+				&cpu.IRQ, &cpu.SoftIRQ, &cpu.Steal, &cpu.Guest, &cpu.GuestNice,
+			}
+			if index <= len(fields) {
+				*fields[index-1] = n
+			}
+			return nil
+		}
+		switch key {
+		case "ctxt":
+			st.ContextSwitches = n
+		case "btime":
+			st.BootTime = time.Unix(int64(n), 0)
+		case "processes":
+			st.Processes = n
+		case "procs_running":
+			st.ProcsRunning = n
+		case "procs_blocked":
+			st.ProcsBlocked = n
+		}
+		return nil
+	}
+	if err := parse(StatFile, lf, el); err != nil {
+		if _, ok := err.(*PartialParseError); ok {
+			return st, err
+		}
+		return nil, err
+	}
+	return st, nil
+}
+
+// The directory containing the per process directories, such as
+// /proc/<pid>/stat. Typically this is only modified by unit testing.
+var ProcessDir string = "/proc"
+
+// pageSize is used to convert values reported in pages to bytes.
+var pageSize = uint64(os.Getpagesize())
+
+// ProcessStat stores the status of a process that is gleaned from
+// /proc/<pid>/stat. Times are in units of USER_HZ, typically hundredths of a
+// second.
+type ProcessStat struct {
+	PID         int
+	Comm        string
+	State       string
+	PPID        int
+	PGRP        int
+	Session     int
+	MinorFaults uint64
+	MajorFaults uint64
+	UTime       uint64
+	STime       uint64
+	CUTime      uint64
+	CSTime      uint64
+	Priority    int64
+	Nice        int64
+	NumThreads  int64
+
+	// StartTime is when the process started, measured from boot.
+	StartTime uint64
+
+	// VSize and RSS are the virtual and resident memory sizes in bytes.
+	VSize uint64
+	RSS   uint64
+}
+
+// ReadProcessStat reads /proc/<pid>/stat and returns the status of the
+// process.
+func ReadProcessStat(pid int) (*ProcessStat, error) {
+	file := filepath.Join(ProcessDir, strconv.Itoa(pid), "stat")
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// The command name is in parentheses and may contain spaces and
+	// parentheses itself, so find the last closing one.
+	s := string(contents)
+	openParen := strings.Index(s, "(")
+	closeParen := strings.LastIndex(s, ")")
+	if openParen < 0 || closeParen < openParen {
+		return nil, fmt.Errorf("Invalid command name in file %s", file)
+	}
+	ps := &ProcessStat{Comm: s[openParen+1 : closeParen]}
+	if ps.PID, err = strconv.Atoi(strings.TrimSpace(s[:openParen])); err != nil {
+		return nil, fmt.Errorf("Error parsing pid in file %s: %s", file, err)
+	}
+
+	// Fields after the command name, numbered from the state as column 2
+	// to match proc(5), skipping the pid and command.
+	fields := strings.Fields(s[closeParen+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("Too few columns in file %s", file)
+	}
+	ps.State = fields[0]
+	parse := func(column int, v interface{}) {
+		if err != nil {
+			return
+		}
+		elm := fields[column-3]
+		switch v := v.(type) {
+		case *int:
+			*v, err = strconv.Atoi(elm)
+		case *int64:
+			*v, err = strconv.ParseInt(elm, 10, 64)
+		case *uint64:
+			*v, err = strconv.ParseUint(elm, 10, 64)
+		}
+		if err != nil {
+			err = fmt.Errorf(
+				"Error parsing column %d of file %s: %s", column, file, elm)
+		}
+	}
+	parse(4, &ps.PPID)
+	parse(5, &ps.PGRP)
+	parse(6, &ps.Session)
+	parse(10, &ps.MinorFaults)
+	parse(12, &ps.MajorFaults)
+	parse(14, &ps.UTime)
+	parse(15, &ps.STime)
+	parse(16, &ps.CUTime)
+	parse(17, &ps.CSTime)
+	parse(18, &ps.Priority)
+	parse(19, &ps.Nice)
+	parse(20, &ps.NumThreads)
+	parse(22, &ps.StartTime)
+	parse(23, &ps.VSize)
+	parse(24, &ps.RSS)
+	if err != nil {
+		return nil, err
+	}
+	ps.RSS *= pageSize
+	return ps, nil
+}
+
+// ProcessStatm stores the memory usage of a process that is gleaned from
+// /proc/<pid>/statm. All values are in bytes.
+type ProcessStatm struct {
+	Size     uint64
+	Resident uint64
+	Shared   uint64
+	Text     uint64
+	Data     uint64
+}
+
+// ReadProcessStatm reads /proc/<pid>/statm and returns the memory usage of the
+// process.
+func ReadProcessStatm(pid int) (*ProcessStatm, error) {
+	file := filepath.Join(ProcessDir, strconv.Itoa(pid), "statm")
+	sm := &ProcessStatm{}
+	el := func(line int, index int, elm string) error {
+		var field *uint64
+		switch index {
+		case 0:
+			field = &sm.Size
+		case 1:
+			field = &sm.Resident
+		case 2:
+			field = &sm.Shared
+		case 3:
+			field = &sm.Text
+		case 5:
+			field = &sm.Data
+		default:
+			// lib (4) and dt (6) are always zero.
+			return nil
+		}
+		n, err := strconv.ParseUint(elm, 10, 64)
+		if err != nil {
+			return fmt.Errorf(
+				"Error parsing column %d on line %d of file %s: %s",
+				index, line, file, elm)
+		}
+		*field = n * pageSize
+		return nil
+	}
+	if err := ParseSimpleProcFile(file, nil, el); err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
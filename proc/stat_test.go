@@ -0,0 +1,127 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package proc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestReadStat(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	StatFile = testHelper.WriteTempFile(strings.Join([]string{
+		"cpu  10 1 20 300 4 5 6 7 8 9",
+		"cpu0 6 1 10 150 2 3 3 4 5 6",
+		"cpu1 4 0 10 150 2 2 3 3",
+		"intr 1234 0 9 0",
+		"ctxt 5678",
+		"btime 1500000000",
+		"processes 910",
+		"procs_running 2",
+		"procs_blocked 1",
+		"softirq 100 1 2 3",
+	}, "\n"))
+	st, err := ReadStat()
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, st.CPU, CPUStat{
+		CPU: "cpu", User: 10, Nice: 1, System: 20, Idle: 300, IOWait: 4,
+		IRQ: 5, SoftIRQ: 6, Steal: 7, Guest: 8, GuestNice: 9,
+	})
+	tt.TestEqual(t, st.CPU.Total(), uint64(353))
+	tt.TestEqual(t, st.CPU.Busy(), uint64(49))
+	tt.TestEqual(t, len(st.CPUs), 2)
+	tt.TestEqual(t, st.CPUs[0].CPU, "cpu0")
+	tt.TestEqual(t, st.CPUs[1].CPU, "cpu1")
+	tt.TestEqual(t, st.CPUs[1].Steal, uint64(3))
+	tt.TestEqual(t, st.CPUs[1].Guest, uint64(0))
+	tt.TestEqual(t, st.ContextSwitches, uint64(5678))
+	tt.TestEqual(t, st.BootTime, time.Unix(1500000000, 0))
+	tt.TestEqual(t, st.Processes, uint64(910))
+	tt.TestEqual(t, st.ProcsRunning, uint64(2))
+	tt.TestEqual(t, st.ProcsBlocked, uint64(1))
+
+	StatFile = testHelper.WriteTempFile("cpu  10 1 20 300\ncpu0 NaN 1 20 300\nctxt 5")
+	_, err = ReadStat()
+	tt.TestExpectError(t, err)
+	st, err = ReadStatTolerant()
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, len(err.(*PartialParseError).Errors), 1)
+	tt.TestEqual(t, st.CPU.Idle, uint64(300))
+	tt.TestEqual(t, len(st.CPUs), 0)
+	tt.TestEqual(t, st.ContextSwitches, uint64(5))
+}
+
+func TestReadProcessStat(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	ProcessDir = testHelper.TempDir()
+	defer func() { ProcessDir = "/proc" }()
+	dir := filepath.Join(ProcessDir, "42")
+	tt.TestExpectSuccess(t, os.Mkdir(dir, 0755))
+	writeFile := func(name, contents string) {
+		f, err := os.Create(filepath.Join(dir, name))
+		tt.TestExpectSuccess(t, err)
+		defer f.Close()
+		_, err = f.WriteString(contents)
+		tt.TestExpectSuccess(t, err)
+	}
+
+	writeFile("stat", "42 (my (odd) cmd) S 1 42 42 0 -1 4194560 500 0 7 0 "+
+		"120 30 4 2 20 0 3 0 12345 1048576 64 18446744073709551615 "+
+		"1 1 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n")
+	ps, err := ReadProcessStat(42)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, ps, &ProcessStat{
+		PID:         42,
+		Comm:        "my (odd) cmd",
+		State:       "S",
+		PPID:        1,
+		PGRP:        42,
+		Session:     42,
+		MinorFaults: 500,
+		MajorFaults: 7,
+		UTime:       120,
+		STime:       30,
+		CUTime:      4,
+		CSTime:      2,
+		Priority:    20,
+		Nice:        0,
+		NumThreads:  3,
+		StartTime:   12345,
+		VSize:       1048576,
+		RSS:         64 * uint64(os.Getpagesize()),
+	})
+
+	writeFile("stat", "42 (cmd) S 1 42")
+	_, err = ReadProcessStat(42)
+	tt.TestExpectError(t, err)
+	writeFile("stat", "42 (cmd) S 1 42 42 0 -1 4194560 500 0 7 0 NaN 30 4 2 20 0 3 0 12345 1048576 64")
+	_, err = ReadProcessStat(42)
+	tt.TestExpectError(t, err)
+	_, err = ReadProcessStat(43)
+	tt.TestExpectError(t, err)
+
+	writeFile("statm", "256 64 16 8 0 32 0\n")
+	sm, err := ReadProcessStatm(42)
+	tt.TestExpectSuccess(t, err)
+	page := uint64(os.Getpagesize())
+	tt.TestEqual(t, sm, &ProcessStatm{
+		Size:     256 * page,
+		Resident: 64 * page,
+		Shared:   16 * page,
+		Text:     8 * page,
+		Data:     32 * page,
+	})
+
+	writeFile("statm", "256 NaN 16 8 0 32 0\n")
+	_, err = ReadProcessStatm(42)
+	tt.TestExpectError(t, err)
+}
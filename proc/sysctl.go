@@ -0,0 +1,140 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package proc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The directory containing the kernel parameters.
+var SysctlDir string = "/proc/sys"
+
+// SysctlChange records a kernel parameter changed, or which would be changed,
+// by ApplySysctls.
+type SysctlChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+func (c SysctlChange) String() string {
+	return fmt.Sprintf("%s: %q -> %q", c.Name, c.Old, c.New)
+}
+
+// SysctlPath returns the file within SysctlDir for a kernel parameter named as
+// it is by sysctl, such as "net.ipv4.ip_forward". Like sysctl, names may use
+// "/" as the separator instead, which allows components containing dots such
+// as "net/ipv4/conf/eth0.100/forwarding".
+func SysctlPath(name string) (string, error) {
+	sep := "."
+	if strings.Contains(name, "/") {
+		sep = "/"
+	}
+	parts := strings.Split(strings.Trim(name, sep), sep)
+	for _, p := range parts {
+		if p == "" || p == "." || p == ".." || strings.ContainsAny(p, "/\x00") {
+			return "", fmt.Errorf("invalid kernel parameter name: %q", name)
+		}
+	}
+	return filepath.Join(append([]string{SysctlDir}, parts...)...), nil
+}
+
+// GetSysctl returns the value of a kernel parameter. Values made up of several
+// fields, such as net.ipv4.ip_local_port_range, are returned with the fields
+// separated by single spaces.
+func GetSysctl(name string) (string, error) {
+	file, err := SysctlPath(name)
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return normalizeSysctl(string(b)), nil
+}
+
+// GetSysctlInt returns the value of a kernel parameter holding an integer.
+func GetSysctlInt(name string) (int64, error) {
+	value, err := GetSysctl(name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("kernel parameter %s is not an integer: %q", name, value)
+	}
+	return n, nil
+}
+
+// SetSysctl sets a kernel parameter. The parameter must already exist.
+func SetSysctl(name, value string) error {
+	file, err := SysctlPath(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(value + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set kernel parameter %s: %v", name, err)
+	}
+	return nil
+}
+
+// SetSysctlInt sets a kernel parameter holding an integer.
+func SetSysctlInt(name string, value int64) error {
+	return SetSysctl(name, strconv.FormatInt(value, 10))
+}
+
+// ApplySysctls sets each of the kernel parameters to its value, skipping those
+// already set to it, and returns the changes in order of name. Every parameter
+// is read before any is written, so an invalid or missing parameter fails
+// before anything is changed. If dryRun is true nothing is written and the
+// changes which would be made are returned. If writing fails part way
+// through, the changes made so far are returned along with the error.
+func ApplySysctls(values map[string]string, dryRun bool) ([]SysctlChange, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var changes []SysctlChange
+	for _, name := range names {
+		current, err := GetSysctl(name)
+		if err != nil {
+			return nil, err
+		}
+		if want := normalizeSysctl(values[name]); current != want {
+			changes = append(changes, SysctlChange{Name: name, Old: current, New: want})
+		}
+	}
+	if dryRun {
+		return changes, nil
+	}
+
+	for i, c := range changes {
+		if err := SetSysctl(c.Name, c.New); err != nil {
+			return changes[:i], err
+		}
+	}
+	return changes, nil
+}
+
+// normalizeSysctl collapses the whitespace within a value so values can be
+// compared regardless of how their fields are separated.
+func normalizeSysctl(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package proc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// fakeSysctls points SysctlDir at a temporary directory holding the values.
+func fakeSysctls(testHelper *tt.TestTool, values map[string]string) {
+	dir := testHelper.TempDir()
+	for file, value := range values {
+		name := filepath.Join(dir, file)
+		tt.TestExpectSuccess(testHelper, os.MkdirAll(filepath.Dir(name), 0755))
+		tt.TestExpectSuccess(testHelper, ioutil.WriteFile(name, []byte(value), 0644))
+	}
+	orig := SysctlDir
+	SysctlDir = dir
+	testHelper.AddTestFinalizer(func() { SysctlDir = orig })
+}
+
+func TestSysctl(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	fakeSysctls(testHelper, map[string]string{
+		"net/ipv4/ip_forward":               "0\n",
+		"net/ipv4/ip_local_port_range":      "32768\t60999\n",
+		"net/ipv4/conf/eth0.100/forwarding": "1\n",
+		"kernel/hostname":                   "box\n",
+	})
+
+	n, err := GetSysctlInt("net.ipv4.ip_forward")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, n, int64(0))
+	value, err := GetSysctl("net.ipv4.ip_local_port_range")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, value, "32768 60999")
+	value, err = GetSysctl("net/ipv4/conf/eth0.100/forwarding")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, value, "1")
+	_, err = GetSysctlInt("kernel.hostname")
+	tt.TestExpectError(t, err)
+
+	tt.TestExpectSuccess(t, SetSysctlInt("net.ipv4.ip_forward", 1))
+	n, err = GetSysctlInt("net.ipv4.ip_forward")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, n, int64(1))
+
+	// parameters must exist and names can't escape the directory
+	tt.TestExpectError(t, SetSysctl("net.ipv4.missing", "1"))
+	for _, name := range []string{"", "net..ipv4", "net/../../etc/passwd", "a/./b"} {
+		_, err := SysctlPath(name)
+		tt.TestExpectError(t, err)
+	}
+}
+
+func TestApplySysctls(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	fakeSysctls(testHelper, map[string]string{
+		"net/ipv4/ip_forward":          "0\n",
+		"net/ipv4/ip_local_port_range": "32768\t60999\n",
+		"fs/file-max":                  "100\n",
+	})
+
+	values := map[string]string{
+		"net.ipv4.ip_forward":          "1",
+		"net.ipv4.ip_local_port_range": "32768 60999",
+		"fs.file-max":                  "200",
+	}
+	want := []SysctlChange{
+		{Name: "fs.file-max", Old: "100", New: "200"},
+		{Name: "net.ipv4.ip_forward", Old: "0", New: "1"},
+	}
+
+	// a dry run reports the changes without making them
+	changes, err := ApplySysctls(values, true)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, changes, want)
+	n, err := GetSysctlInt("fs.file-max")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, n, int64(100))
+
+	changes, err = ApplySysctls(values, false)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, changes, want)
+	n, err = GetSysctlInt("fs.file-max")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, n, int64(200))
+
+	// applying again changes nothing
+	changes, err = ApplySysctls(values, false)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(changes), 0)
+
+	// a missing parameter fails before anything is written
+	values["fs.file-max"] = "300"
+	values["net.missing"] = "1"
+	_, err = ApplySysctls(values, false)
+	tt.TestExpectError(t, err)
+	n, err = GetSysctlInt("fs.file-max")
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, n, int64(200))
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Keyed maintains a separate Limiter per key, such as per client address or
+// per remote host. Limiters which have not been used within the TTL are
+// discarded.
+type Keyed struct {
+	newLimiter func() Limiter
+	ttl        time.Duration
+
+	mutex     sync.Mutex
+	limiters  map[string]*keyedEntry
+	lastSweep time.Time
+}
+
+type keyedEntry struct {
+	limiter  Limiter
+	lastUsed time.Time
+}
+
+// NewKeyed returns a Keyed which creates limiters for new keys with
+// newLimiter, and discards them once unused for ttl.
+func NewKeyed(newLimiter func() Limiter, ttl time.Duration) *Keyed {
+	return &Keyed{
+		newLimiter: newLimiter,
+		ttl:        ttl,
+		limiters:   make(map[string]*keyedEntry),
+		lastSweep:  now(),
+	}
+}
+
+// Get returns the Limiter for the key, creating it if needed.
+func (k *Keyed) Get(key string) Limiter {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	t := now()
+	if t.Sub(k.lastSweep) >= k.ttl {
+		k.sweep(t)
+	}
+
+	e := k.limiters[key]
+	if e == nil {
+		e = &keyedEntry{limiter: k.newLimiter()}
+		k.limiters[key] = e
+	}
+	e.lastUsed = t
+	return e.limiter
+}
+
+// Allow reports whether an event for the key may happen now.
+func (k *Keyed) Allow(key string) bool {
+	return k.Get(key).Allow()
+}
+
+// Wait blocks until an event for the key may happen or the context is done.
+func (k *Keyed) Wait(ctx context.Context, key string) error {
+	return k.Get(key).Wait(ctx)
+}
+
+// Len returns the number of keys currently being tracked.
+func (k *Keyed) Len() int {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	return len(k.limiters)
+}
+
+// sweep discards expired limiters. The mutex must be held.
+func (k *Keyed) sweep(t time.Time) {
+	for key, e := range k.limiters {
+		if t.Sub(e.lastUsed) >= k.ttl {
+			delete(k.limiters, key)
+		}
+	}
+	k.lastSweep = t
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LeakyBucket is a limiter which spaces events evenly at a fixed rate. Unlike
+// TokenBucket it does not allow bursts, instead up to capacity events may be
+// queued waiting for their turn.
+type LeakyBucket struct {
+	interval time.Duration
+	capacity int
+
+	mutex sync.Mutex
+	next  time.Time
+}
+
+// NewLeakyBucket returns a LeakyBucket which allows rate events per second,
+// with up to capacity events waiting at a time.
+func NewLeakyBucket(rate float64, capacity int) *LeakyBucket {
+	return &LeakyBucket{
+		interval: time.Duration(float64(time.Second) / rate),
+		capacity: capacity,
+	}
+}
+
+// Allow reports whether an event may happen now without waiting.
+func (lb *LeakyBucket) Allow() bool {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	t := now()
+	if lb.next.After(t) {
+		return false
+	}
+	lb.next = t.Add(lb.interval)
+	return true
+}
+
+// Wait blocks until it is the event's turn. If the queue is already full then
+// ErrExceedsCapacity is returned. If the context is done, or its deadline would
+// pass first, then an error is returned and the slot is released.
+func (lb *LeakyBucket) Wait(ctx context.Context) error {
+	lb.mutex.Lock()
+	t := now()
+	slot := lb.next
+	if slot.Before(t) {
+		slot = t
+	}
+	if slot.Sub(t) > time.Duration(lb.capacity)*lb.interval {
+		lb.mutex.Unlock()
+		return ErrExceedsCapacity
+	}
+	lb.next = slot.Add(lb.interval)
+	lb.mutex.Unlock()
+
+	if err := sleep(ctx, slot.Sub(t)); err != nil {
+		// Give the slot back if nothing has been scheduled after it.
+		lb.mutex.Lock()
+		if lb.next.Equal(slot.Add(lb.interval)) {
+			lb.next = slot
+		}
+		lb.mutex.Unlock()
+		return err
+	}
+	return nil
+}
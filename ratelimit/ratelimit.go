@@ -0,0 +1,50 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// Package ratelimit provides token bucket and leaky bucket rate limiters along
+// with a map of per-key limiters which expire when unused.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrExceedsDeadline is returned by Wait when the context's deadline would pass
+// before the limiter allows the event.
+var ErrExceedsDeadline = errors.New("ratelimit: wait would exceed context deadline")
+
+// ErrExceedsCapacity is returned by Wait when the limiter can never allow the
+// request, such as waiting for more tokens than the bucket can hold.
+var ErrExceedsCapacity = errors.New("ratelimit: request exceeds limiter capacity")
+
+// Limiter is implemented by all of the limiters within this package.
+type Limiter interface {
+	// Allow reports whether an event may happen now. If it returns true the
+	// event is counted against the limit.
+	Allow() bool
+
+	// Wait blocks until an event may happen or the context is done.
+	Wait(ctx context.Context) error
+}
+
+// now is overridden within the tests.
+var now = time.Now
+
+// sleep waits for the duration or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(now().Add(d)) {
+		return ErrExceedsDeadline
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// fakeClock replaces now for the duration of a test.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func useFakeClock(testHelper *tt.TestTool) *fakeClock {
+	c := &fakeClock{t: time.Unix(1000, 0)}
+	now = c.now
+	testHelper.AddTestFinalizer(func() { now = time.Now })
+	return c
+}
+
+func TestTokenBucketAllow(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+	clock := useFakeClock(testHelper)
+
+	tb := NewTokenBucket(10, 3)
+	tt.TestTrue(t, tb.Allow())
+	tt.TestTrue(t, tb.Allow())
+	tt.TestTrue(t, tb.Allow())
+	tt.TestFalse(t, tb.Allow())
+
+	// 100ms refills a single token
+	clock.advance(100 * time.Millisecond)
+	tt.TestTrue(t, tb.Allow())
+	tt.TestFalse(t, tb.Allow())
+
+	// refilling stops at the burst size
+	clock.advance(time.Hour)
+	tt.TestTrue(t, tb.AllowN(3))
+	tt.TestFalse(t, tb.Allow())
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	tb := NewTokenBucket(100, 1)
+	tt.TestExpectSuccess(t, tb.Wait(context.Background()))
+
+	start := time.Now()
+	tt.TestExpectSuccess(t, tb.Wait(context.Background()))
+	tt.TestTrue(t, time.Since(start) >= 5*time.Millisecond)
+
+	// more than the burst can never be satisfied
+	tt.TestEqual(t, tb.WaitN(context.Background(), 2), ErrExceedsCapacity)
+
+	// a deadline which would pass first fails fast and returns the tokens
+	tb = NewTokenBucket(1, 1)
+	tt.TestTrue(t, tb.Allow())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	tt.TestEqual(t, tb.Wait(ctx), ErrExceedsDeadline)
+	tt.TestTrue(t, tb.tokens > -0.5)
+
+	// cancellation
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	tt.TestEqual(t, tb.Wait(ctx), context.Canceled)
+}
+
+func TestLeakyBucket(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+	clock := useFakeClock(testHelper)
+
+	lb := NewLeakyBucket(10, 2)
+	tt.TestTrue(t, lb.Allow())
+	tt.TestFalse(t, lb.Allow())
+	clock.advance(50 * time.Millisecond)
+	tt.TestFalse(t, lb.Allow())
+	clock.advance(50 * time.Millisecond)
+	tt.TestTrue(t, lb.Allow())
+
+	// the queue only holds capacity waiters
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	lb.next = clock.t.Add(time.Second)
+	tt.TestEqual(t, lb.Wait(ctx), ErrExceedsCapacity)
+}
+
+func TestLeakyBucketWait(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	lb := NewLeakyBucket(100, 5)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		tt.TestExpectSuccess(t, lb.Wait(context.Background()))
+	}
+	// the first is immediate, the following two are spaced by 10ms
+	tt.TestTrue(t, time.Since(start) >= 15*time.Millisecond)
+}
+
+func TestKeyed(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+	clock := useFakeClock(testHelper)
+
+	k := NewKeyed(func() Limiter { return NewTokenBucket(1, 1) }, time.Minute)
+	tt.TestTrue(t, k.Allow("a"))
+	tt.TestFalse(t, k.Allow("a"))
+	tt.TestTrue(t, k.Allow("b"))
+	tt.TestEqual(t, k.Len(), 2)
+
+	clock.advance(30 * time.Second)
+	tt.TestTrue(t, k.Allow("a"))
+
+	// b is now unused for a minute and is swept, a is retained
+	clock.advance(30 * time.Second)
+	k.Get("c")
+	tt.TestEqual(t, k.Len(), 2)
+	_, ok := k.limiters["b"]
+	tt.TestFalse(t, ok)
+}
@@ -0,0 +1,100 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a limiter which refills at a fixed rate up to a maximum burst.
+// Events may happen as long as there are tokens available, allowing short
+// bursts above the average rate.
+type TokenBucket struct {
+	rate  float64
+	burst float64
+
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket returns a TokenBucket which allows rate events per second with
+// bursts of up to burst events. The bucket starts full.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now(),
+	}
+}
+
+// advance refills the bucket based on the time elapsed. The mutex must be held.
+func (tb *TokenBucket) advance(t time.Time) {
+	if elapsed := t.Sub(tb.last); elapsed > 0 {
+		tb.tokens += elapsed.Seconds() * tb.rate
+		if tb.tokens > tb.burst {
+			tb.tokens = tb.burst
+		}
+		tb.last = t
+	}
+}
+
+// Allow is shorthand for AllowN(1).
+func (tb *TokenBucket) Allow() bool {
+	return tb.AllowN(1)
+}
+
+// AllowN reports whether n events may happen now, consuming the tokens if so.
+func (tb *TokenBucket) AllowN(n int) bool {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	tb.advance(now())
+	if tb.tokens < float64(n) {
+		return false
+	}
+	tb.tokens -= float64(n)
+	return true
+}
+
+// Wait is shorthand for WaitN(ctx, 1).
+func (tb *TokenBucket) Wait(ctx context.Context) error {
+	return tb.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n tokens are available and consumes them. If the context
+// is done, or its deadline would pass before the tokens are available, then an
+// error is returned and no tokens are consumed.
+func (tb *TokenBucket) WaitN(ctx context.Context, n int) error {
+	if float64(n) > tb.burst || (tb.rate <= 0 && float64(n) > 0) {
+		if !tb.AllowN(n) {
+			return ErrExceedsCapacity
+		}
+		return nil
+	}
+
+	// Reserve the tokens up front, allowing the bucket to go negative, so
+	// that concurrent waiters are served in order.
+	tb.mutex.Lock()
+	tb.advance(now())
+	tb.tokens -= float64(n)
+	var wait time.Duration
+	if tb.tokens < 0 {
+		wait = time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+	}
+	tb.mutex.Unlock()
+
+	if err := sleep(ctx, wait); err != nil {
+		// Return the reservation.
+		tb.mutex.Lock()
+		tb.tokens += float64(n)
+		if tb.tokens > tb.burst {
+			tb.tokens = tb.burst
+		}
+		tb.mutex.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+)
+
+// APIError is an error decoded from the JSON body of a failed response by
+// DecodeAPIError.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int `json:"-"`
+
+	Code    string                 `json:"code,omitempty"`
+	Message string                 `json:"message,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	switch {
+	case e.Code != "" && e.Message != "":
+		return e.Code + ": " + e.Message
+	case e.Message != "":
+		return e.Message
+	case e.Code != "":
+		return e.Code
+	}
+	return fmt.Sprintf("API error %d", e.StatusCode)
+}
+
+// DecodeAPIError may be used as a Client's ErrorDecoder. It decodes a JSON body
+// with code, message, and details fields into an *APIError. A message in an
+// error field, as checked by RestError, is also accepted. It returns nil for
+// bodies which aren't JSON or don't contain these fields.
+func DecodeAPIError(resp *http.Response) error {
+	ctype, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !isJSONContentType(ctype) {
+		return nil
+	}
+
+	var body struct {
+		APIError
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil
+	}
+	apiErr := body.APIError
+	if apiErr.Message == "" {
+		apiErr.Message = body.Error
+	}
+	if apiErr.Code == "" && apiErr.Message == "" && len(apiErr.Details) == 0 {
+		return nil
+	}
+	apiErr.StatusCode = resp.StatusCode
+	return &apiErr
+}
+
+// decodeError calls the client's ErrorDecoder for a failed response. The body
+// is read first and restored afterwards, so it remains available through the
+// response and RestError.Body.
+func (c *Client) decodeError(resp *http.Response) error {
+	if c.ErrorDecoder == nil || resp.Body == nil {
+		return nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	decoded := c.ErrorDecoder(resp)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return decoded
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestErrorDecoder(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	var ctype, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ctype)
+		w.WriteHeader(404)
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+	client.ErrorDecoder = DecodeAPIError
+
+	// a typed error
+	ctype = "application/json"
+	body = `{"code": "not_found", "message": "no such job", "details": {"job": "web"}}`
+	resp, err := client.Do(client.NewJsonRequest(GET, "/jobs/web", nil))
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, err.Error(), "error in response: 404 Not Found - not_found: no such job")
+	var apiErr *APIError
+	tt.TestEqual(t, errors.As(err, &apiErr), true)
+	tt.TestEqual(t, apiErr, &APIError{
+		StatusCode: 404,
+		Code:       "not_found",
+		Message:    "no such job",
+		Details:    map[string]interface{}{"job": "web"},
+	})
+
+	// the body is still available
+	tt.TestEqual(t, err.(*RestError).Body(), body)
+	b, err := ioutil.ReadAll(resp.Body)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, string(b), body)
+
+	// an error field is used as the message
+	body = `{"error": "some error"}`
+	err = client.Get("/", nil)
+	tt.TestEqual(t, errors.As(err, &apiErr), true)
+	tt.TestEqual(t, apiErr.Message, "some error")
+	tt.TestEqual(t, err.Error(), "error in response: 404 Not Found - some error")
+
+	// other bodies keep the default error
+	for _, tc := range []struct{ ctype, body string }{
+		{"text/plain", "Didn't work"},
+		{"application/json", "Didn't work"},
+		{"application/json", `{"other": 1}`},
+	} {
+		ctype, body = tc.ctype, tc.body
+		err = client.Get("/", nil)
+		tt.TestEqual(t, errors.As(err, &apiErr), false)
+		tt.TestEqual(t, err.Error(), "error in response: 404 Not Found - "+tc.body)
+	}
+}
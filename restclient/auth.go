@@ -0,0 +1,148 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AuthProvider adds credentials to a request. A Client's Auth is called before
+// each request is sent, after the client's and request's headers are applied.
+type AuthProvider interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthProviderFunc adapts a function to the AuthProvider interface.
+type AuthProviderFunc func(req *http.Request) error
+
+// Authenticate calls f(req).
+func (f AuthProviderFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// SetDefaultHeader sets a header sent with every request made by the client,
+// replacing any existing value. It is equivalent to c.Headers.Set.
+func (c *Client) SetDefaultHeader(key, value string) {
+	c.Headers.Set(key, value)
+}
+
+// BasicAuth returns an AuthProvider using HTTP basic authentication.
+func BasicAuth(username, password string) AuthProvider {
+	return AuthProviderFunc(func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
+
+// BearerToken returns an AuthProvider sending a fixed bearer token.
+func BearerToken(token string) AuthProvider {
+	return AuthProviderFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// RefreshingBearerToken is an AuthProvider sending a bearer token which is
+// fetched when first needed and again whenever it is about to expire.
+type RefreshingBearerToken struct {
+	// Refresh returns a new token and when it expires. A zero expiry means
+	// the token doesn't expire.
+	Refresh func() (token string, expires time.Time, err error)
+
+	// Margin is how long before the expiry the token is refreshed, allowing
+	// for clock skew and the time taken by the request.
+	Margin time.Duration
+
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewRefreshingBearerToken returns a RefreshingBearerToken using the refresh
+// function, with a Margin of 30 seconds.
+func NewRefreshingBearerToken(refresh func() (string, time.Time, error)) *RefreshingBearerToken {
+	return &RefreshingBearerToken{Refresh: refresh, Margin: 30 * time.Second}
+}
+
+// Authenticate sets the Authorization header, refreshing the token if needed.
+func (b *RefreshingBearerToken) Authenticate(req *http.Request) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.token == "" || (!b.expires.IsZero() && time.Now().Add(b.Margin).After(b.expires)) {
+		token, expires, err := b.Refresh()
+		if err != nil {
+			return fmt.Errorf("error refreshing token: %s", err)
+		}
+		b.token, b.expires = token, expires
+	}
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	return nil
+}
+
+// Invalidate discards the current token so the next request fetches a new one,
+// such as after a response with a 401 status.
+func (b *RefreshingBearerToken) Invalidate() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.token = ""
+}
+
+// ErrStreamedBody is returned by HMACAuth for requests whose bodies are
+// streamed, such as those made by NewStreamRequest, NewMultipartRequest, and
+// NewRequest, since signing them would require buffering the whole body.
+var ErrStreamedBody = errors.New("cannot sign a streamed request body")
+
+// HMACAuth returns an AuthProvider signing requests with HMAC-SHA256 using the
+// secret. The Date header is set, and the signature covers the method, the
+// request URI, the date, and a SHA-256 digest of the body, separated by
+// newlines. It is sent as:
+//
+//	Authorization: HMAC-SHA256 keyId="<keyID>",signature="<base64 signature>"
+//
+// Only bodies which are already held in memory, such as those of requests made
+// by NewJsonRequest, NewCodecRequest, and NewFormRequest, can be signed.
+// ErrStreamedBody is returned for other requests with a body.
+func HMACAuth(keyID string, secret []byte) AuthProvider {
+	return AuthProviderFunc(func(req *http.Request) error {
+		var body []byte
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return ErrStreamedBody
+			}
+			rc, err := req.GetBody()
+			if err != nil {
+				return fmt.Errorf("error reading body to sign: %s", err)
+			}
+			body, err = ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return fmt.Errorf("error reading body to sign: %s", err)
+			}
+		}
+
+		date := time.Now().UTC().Format(http.TimeFormat)
+		req.Header.Set("Date", date)
+		signature := HMACSignature(secret, req.Method, req.URL.RequestURI(), date, body)
+		req.Header.Set("Authorization", fmt.Sprintf("HMAC-SHA256 keyId=%q,signature=%q", keyID, signature))
+		return nil
+	})
+}
+
+// HMACSignature returns the base64 encoded signature sent by HMACAuth, so
+// servers can verify it.
+func HMACSignature(secret []byte, method, requestURI, date string, body []byte) string {
+	digest := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", method, requestURI, date, hex.EncodeToString(digest[:]))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,129 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// authServer records the headers and bodies of the requests it receives.
+type authServer struct {
+	headers []http.Header
+	bodies  []string
+	uris    []string
+}
+
+func (s *authServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	s.headers = append(s.headers, req.Header)
+	s.bodies = append(s.bodies, string(body))
+	s.uris = append(s.uris, req.RequestURI)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestAuthProviders(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	handler := &authServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+	client.SetDefaultHeader("X-Client", "test")
+
+	client.Auth = BasicAuth("user", "pass")
+	tt.TestExpectSuccess(t, client.Get("/", nil))
+	client.Auth = BearerToken("static")
+	tt.TestExpectSuccess(t, client.Get("/", nil))
+
+	tt.TestEqual(t, handler.headers[0].Get("Authorization"), "Basic dXNlcjpwYXNz")
+	tt.TestEqual(t, handler.headers[1].Get("Authorization"), "Bearer static")
+	tt.TestEqual(t, handler.headers[1].Get("X-Client"), "test")
+
+	// the credentials aren't added to the client's headers
+	tt.TestEqual(t, client.Headers.Get("Authorization"), "")
+
+	// errors prevent the request
+	client.Auth = AuthProviderFunc(func(*http.Request) error { return fmt.Errorf("no credentials") })
+	err = client.Get("/", nil)
+	tt.TestEqual(t, err.Error(), "error authenticating request: no credentials")
+	tt.TestEqual(t, len(handler.headers), 2)
+}
+
+func TestRefreshingBearerToken(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	handler := &authServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+
+	refreshes := 0
+	expires := time.Now().Add(time.Hour)
+	auth := NewRefreshingBearerToken(func() (string, time.Time, error) {
+		refreshes++
+		return fmt.Sprintf("token-%d", refreshes), expires, nil
+	})
+	client.Auth = auth
+
+	tt.TestExpectSuccess(t, client.Get("/", nil))
+	tt.TestExpectSuccess(t, client.Get("/", nil))
+
+	// a token within the margin of its expiry is refreshed
+	auth.Margin = 2 * time.Hour
+	tt.TestExpectSuccess(t, client.Get("/", nil))
+	auth.Margin = 0
+
+	auth.Invalidate()
+	tt.TestExpectSuccess(t, client.Get("/", nil))
+
+	var tokens []string
+	for _, h := range handler.headers {
+		tokens = append(tokens, h.Get("Authorization"))
+	}
+	tt.TestEqual(t, tokens, []string{"Bearer token-1", "Bearer token-1", "Bearer token-2", "Bearer token-3"})
+}
+
+func TestHMACAuth(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	handler := &authServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+
+	secret := []byte("secret")
+	client.Auth = HMACAuth("key1", secret)
+	tt.TestExpectSuccess(t, client.Post("/items?x=1", map[string]string{"a": "b"}, nil))
+
+	h := handler.headers[0]
+	tt.TestNotEqual(t, h.Get("Date"), "")
+	tt.TestEqual(t, handler.bodies[0], "{\"a\":\"b\"}\n")
+	signature := HMACSignature(secret, "POST", handler.uris[0], h.Get("Date"), []byte(handler.bodies[0]))
+	tt.TestEqual(t, h.Get("Authorization"), fmt.Sprintf(`HMAC-SHA256 keyId="key1",signature=%q`, signature))
+	tt.TestEqual(t, handler.uris[0], "/items?x=1")
+
+	// streamed bodies aren't buffered to sign them
+	req := client.NewStreamRequest(PUT, "/blob", strings.NewReader("data"), "text/plain")
+	tt.TestErrorIs(t, client.Result(req, nil), ErrStreamedBody)
+	tt.TestEqual(t, len(handler.headers), 1)
+
+	// requests without a body are signed over an empty body
+	tt.TestExpectSuccess(t, client.Get("/items", nil))
+	h = handler.headers[1]
+	signature = HMACSignature(secret, "GET", "/items", h.Get("Date"), nil)
+	tt.TestEqual(t, h.Get("Authorization"), fmt.Sprintf(`HMAC-SHA256 keyId="key1",signature=%q`, signature))
+}
@@ -0,0 +1,182 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"sync"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+// Codec encodes request bodies and decodes response bodies of a media type.
+type Codec interface {
+	// ContentType is the media type of the encoding, such as
+	// "application/json".
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// The codecs registered by default.
+var (
+	// JSONCodec encodes with package encoding/json. It decodes any of the
+	// media types accepted before codecs were added: application/json,
+	// application/vnd* and *+json.
+	JSONCodec Codec = jsonCodec{}
+
+	// XMLCodec encodes with package encoding/xml. It decodes
+	// application/xml, text/xml and *+xml.
+	XMLCodec Codec = xmlCodec{}
+
+	// ProtobufCodec encodes application/x-protobuf using the Marshal and
+	// Unmarshal methods of generated protocol buffer messages, as generated
+	// by gogoproto, so no particular protobuf library is required.
+	ProtobufCodec Codec = protobufCodec{}
+
+	// MsgpackCodec encodes application/x-msgpack with package
+	// gopkg.in/vmihailenco/msgpack.v2, using "msgpack" struct tags. It also
+	// decodes application/msgpack.
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+var (
+	codecs      = []Codec{JSONCodec, XMLCodec, ProtobufCodec, MsgpackCodec}
+	codecsMutex sync.RWMutex
+)
+
+// RegisterCodec adds a codec used to decode responses with its content type,
+// such as a YAML codec. A codec registered for a content type which already
+// has one replaces it.
+func RegisterCodec(codec Codec) {
+	codecsMutex.Lock()
+	defer codecsMutex.Unlock()
+	for i, c := range codecs {
+		if c.ContentType() == codec.ContentType() {
+			codecs[i] = codec
+			return
+		}
+	}
+	codecs = append(codecs, codec)
+}
+
+// codecFor returns the codec to decode a response of the media type,
+// preferring the given codec, or nil if there isn't one.
+func codecFor(mediaType string, preferred Codec) Codec {
+	if preferred != nil && codecMatches(preferred, mediaType, true) {
+		return preferred
+	}
+
+	codecsMutex.RLock()
+	defer codecsMutex.RUnlock()
+	for _, c := range codecs {
+		if codecMatches(c, mediaType, false) {
+			return c
+		}
+	}
+	// the loose matches are checked from the most recently registered, so
+	// *+xml isn't taken by JSON's application/vnd*
+	for i := len(codecs) - 1; i >= 0; i-- {
+		if codecMatches(codecs[i], mediaType, true) {
+			return codecs[i]
+		}
+	}
+	return nil
+}
+
+// codecMatches returns whether the codec decodes the media type. If loose is
+// set, the related media types accepted by the built in codecs are included.
+func codecMatches(codec Codec, mediaType string, loose bool) bool {
+	if codec.ContentType() == mediaType {
+		return true
+	}
+	if !loose {
+		return false
+	}
+	switch codec.(type) {
+	case jsonCodec:
+		return isJSONContentType(mediaType)
+	case xmlCodec:
+		return mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+	case msgpackCodec:
+		return mediaType == "application/msgpack"
+	}
+	return false
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := json.NewEncoder(&buffer).Encode(v); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Unmarshal decodes the first JSON value, ignoring anything after it.
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string {
+	return "application/xml"
+}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(interface {
+		Marshal() ([]byte, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("%T is not a protocol buffer message", v)
+	}
+	return m.Marshal()
+}
+
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(interface {
+		Unmarshal([]byte) error
+	})
+	if !ok {
+		return fmt.Errorf("%T is not a protocol buffer message", v)
+	}
+	return m.Unmarshal(data)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string {
+	return "application/x-msgpack"
+}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
@@ -0,0 +1,150 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// fakeMessage implements the methods of a generated protocol buffer message.
+type fakeMessage struct {
+	data string
+}
+
+func (m *fakeMessage) Marshal() ([]byte, error) {
+	return []byte(m.data), nil
+}
+
+func (m *fakeMessage) Unmarshal(b []byte) error {
+	m.data = string(b)
+	return nil
+}
+
+// textCodec is a codec for text/plain, decoding into a *string.
+type textCodec struct{}
+
+func (textCodec) ContentType() string {
+	return "text/plain"
+}
+
+func (textCodec) Marshal(v interface{}) ([]byte, error) {
+	return []byte(fmt.Sprint(v)), nil
+}
+
+func (textCodec) Unmarshal(data []byte, v interface{}) error {
+	*(v.(*string)) = string(data)
+	return nil
+}
+
+// echoServer responds with the request body and content type.
+func echoServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		ctype := req.Header.Get("Content-Type")
+		if ctype == "" {
+			ctype = req.Header.Get("Accept")
+		}
+		w.Header().Set("Content-Type", ctype+"; charset=utf-8")
+		w.Write(body)
+	}))
+}
+
+func TestCodecs(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	server := echoServer()
+	defer server.Close()
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+
+	// the client's codec is used by the HTTP verb methods
+	type xmlPerson struct {
+		XMLName xml.Name `xml:"person"`
+		Name    string   `xml:"name"`
+		Age     int      `xml:"age"`
+	}
+	client.Codec = XMLCodec
+	var p xmlPerson
+	tt.TestExpectSuccess(t, client.Post("/", xmlPerson{Name: "Molly", Age: 45}, &p))
+	tt.TestEqual(t, p.Name, "Molly")
+	tt.TestEqual(t, p.Age, 45)
+	client.Codec = nil
+
+	// a codec for a single request
+	req := client.NewCodecRequest(POST, "/", ProtobufCodec, &fakeMessage{"\x0a\x05Molly"})
+	tt.TestEqual(t, req.Headers.Get("Accept"), "application/x-protobuf")
+	var m fakeMessage
+	tt.TestExpectSuccess(t, client.Result(req, &m))
+	tt.TestEqual(t, m.data, "\x0a\x05Molly")
+	tt.TestExpectError(t, client.Result(client.NewCodecRequest(POST, "/", ProtobufCodec, "not a message"), nil))
+
+	// msgpack, with struct tags and nested values
+	type msgpackPerson struct {
+		Name string            `msgpack:"name"`
+		Age  int               `msgpack:"age"`
+		Tags []string          `msgpack:"tags"`
+		Meta map[string]string `msgpack:"meta"`
+	}
+	in := msgpackPerson{Name: "Molly", Age: 45, Tags: []string{"a", "b"}, Meta: map[string]string{"k": "v"}}
+	req = client.NewCodecRequest(POST, "/", MsgpackCodec, in)
+	tt.TestEqual(t, req.Headers.Get("Accept"), "application/x-msgpack")
+	var out msgpackPerson
+	tt.TestExpectSuccess(t, client.Result(req, &out))
+	tt.TestEqual(t, out, in)
+	body, err := MsgpackCodec.Marshal(in)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, body[0], byte(0x84)) // a fixmap of 4 fields
+	var generic map[string]interface{}
+	tt.TestExpectSuccess(t, MsgpackCodec.Unmarshal(body, &generic))
+	tt.TestEqual(t, generic["name"], "Molly")
+	tt.TestExpectError(t, MsgpackCodec.Unmarshal([]byte{0xc1}, &out))
+
+	// responses are decoded with the registered codec for their type
+	var s string
+	req = client.NewRequest(GET, "/", "text/plain", nil)
+	req.Headers.Set("Accept", "text/plain")
+	err = client.Result(req, &s)
+	tt.TestEqual(t, err, fmt.Errorf("unexpected response: 200 OK text/plain"))
+	restoreCodecs(testHelper)
+	RegisterCodec(textCodec{})
+	tt.TestExpectSuccess(t, client.Result(req, &s))
+}
+
+// restoreCodecs restores the registered codecs when the test finishes, so
+// codecs registered by the test don't affect other tests.
+func restoreCodecs(testHelper *tt.TestTool) {
+	codecsMutex.RLock()
+	saved := append([]Codec(nil), codecs...)
+	codecsMutex.RUnlock()
+	testHelper.AddTestFinalizer(func() {
+		codecsMutex.Lock()
+		codecs = saved
+		codecsMutex.Unlock()
+	})
+}
+
+func TestCodecFor(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	tt.TestEqual(t, codecFor("application/json", nil), JSONCodec)
+	tt.TestEqual(t, codecFor("application/vnd.foo", nil), JSONCodec)
+	tt.TestEqual(t, codecFor("application/foo+json", nil), JSONCodec)
+	tt.TestEqual(t, codecFor("application/xml", nil), XMLCodec)
+	tt.TestEqual(t, codecFor("text/xml", nil), XMLCodec)
+	tt.TestEqual(t, codecFor("application/vnd.foo+xml", nil), XMLCodec)
+	tt.TestEqual(t, codecFor("application/x-protobuf", nil), ProtobufCodec)
+	tt.TestEqual(t, codecFor("application/x-msgpack", nil), MsgpackCodec)
+	tt.TestEqual(t, codecFor("application/msgpack", nil), MsgpackCodec)
+	tt.TestEqual(t, codecFor("application/vnd.foo+xml", JSONCodec), JSONCodec)
+	tt.TestEqual(t, codecFor("application/xml", JSONCodec), XMLCodec)
+	tt.TestEqual(t, codecFor("image/png", nil), nil)
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// cachedResponse is a response stored in Client.Cache.
+type cachedResponse struct {
+	etag       string
+	status     string
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// response returns a new *http.Response for the cached response.
+func (cr *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        cr.status,
+		StatusCode:    cr.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cr.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(cr.body)),
+		ContentLength: int64(len(cr.body)),
+		Request:       req,
+	}
+}
+
+// requestCacheKey returns the key of a request in Client.Cache, or "" if the
+// request can't be cached.
+func requestCacheKey(req *http.Request) string {
+	if req.Method != string(GET) || req.Header.Get("If-None-Match") != "" {
+		return ""
+	}
+	return req.URL.String()
+}
+
+// cachedRequest adds If-None-Match to the request if its response is cached,
+// returning the cached response.
+func (c *Client) cachedRequest(req *http.Request, key string) *cachedResponse {
+	v, ok := c.Cache.Get(key)
+	if !ok {
+		return nil
+	}
+	cr := v.(*cachedResponse)
+	req.Header.Set("If-None-Match", cr.etag)
+	return cr
+}
+
+// cacheResponse returns the cached response when the server replied 304 Not
+// Modified, and otherwise stores a successful response with an ETag, reading
+// its body into memory.
+func (c *Client) cacheResponse(req *http.Request, resp *http.Response, key string, cached *cachedResponse) *http.Response {
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		return cached.response(req)
+	}
+
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		return resp
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		// leave the error to be returned when the body is read
+		resp.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), errorReader{err}))
+		return resp
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	// the length is set from the body when the response is reused
+	header := resp.Header.Clone()
+	header.Del("Content-Length")
+	c.Cache.Set(key, &cachedResponse{
+		etag:       etag,
+		status:     resp.Status,
+		statusCode: resp.StatusCode,
+		header:     header,
+		body:       body,
+	})
+	return resp
+}
+
+// errorReader returns err from every Read.
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,67 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apcera/util/cache"
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestResponseCache(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	molly := person{Name: "Molly", Age: 45}
+	etag := `"v1"`
+	var requests, notModified int
+	var ifNoneMatch []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		ifNoneMatch = append(ifNoneMatch, req.Header.Get("If-None-Match"))
+		if req.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("ETag", etag)
+		gw := gzip.NewWriter(w)
+		json.NewEncoder(gw).Encode(molly)
+		gw.Close()
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+	client.Cache = cache.New(10, 0)
+
+	for i := 0; i < 3; i++ {
+		var p person
+		tt.TestExpectSuccess(t, client.Get("/person", &p))
+		tt.TestEqual(t, p, molly)
+	}
+	tt.TestEqual(t, requests, 3)
+	tt.TestEqual(t, notModified, 2)
+	tt.TestEqual(t, ifNoneMatch, []string{"", etag, etag})
+
+	// a changed resource replaces the cached response
+	etag, molly = `"v2"`, person{Name: "Molly", Age: 46}
+	var p person
+	tt.TestExpectSuccess(t, client.Get("/person", &p))
+	tt.TestEqual(t, p, molly)
+	tt.TestExpectSuccess(t, client.Get("/person", &p))
+	tt.TestEqual(t, p, molly)
+	tt.TestEqual(t, ifNoneMatch[3:], []string{`"v1"`, `"v2"`})
+
+	// other methods aren't cached
+	tt.TestExpectSuccess(t, client.Post("/person", molly, &p))
+	tt.TestEqual(t, ifNoneMatch[5], "")
+	tt.TestEqual(t, client.Cache.Len(), 1)
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/apcera/util/ratelimit"
+)
+
+// ErrLimitExceeded is returned, within a *RestError, for requests rejected by a
+// fail-fast Limiter.
+var ErrLimitExceeded = errors.New("request limit exceeded")
+
+// Limiter limits the rate of requests and the number of requests in flight for
+// a Client. A request is in flight until its response body is closed.
+type Limiter struct {
+	// FailFast makes requests which would have to wait fail with
+	// ErrLimitExceeded instead. It should be set before the limiter is used.
+	FailFast bool
+
+	bucket *ratelimit.TokenBucket
+	slots  chan struct{}
+}
+
+// NewLimiter returns a Limiter allowing rate requests per second, with bursts
+// of up to burst requests, and at most maxInFlight requests at once. A rate or
+// maxInFlight of zero means no limit.
+func NewLimiter(rate float64, burst, maxInFlight int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	l := &Limiter{}
+	if rate > 0 {
+		l.bucket = ratelimit.NewTokenBucket(rate, burst)
+	}
+	if maxInFlight > 0 {
+		l.slots = make(chan struct{}, maxInFlight)
+	}
+	return l
+}
+
+// Acquire waits until a request may be made, returning a function to call once
+// it has finished. It returns an error if the context is done first, or
+// ratelimit.ErrExceedsDeadline if its deadline would pass while waiting for
+// the rate limit. A fail-fast limiter returns ErrLimitExceeded instead of
+// waiting.
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	if l.FailFast {
+		return l.tryAcquire()
+	}
+
+	if l.bucket != nil {
+		if err := l.bucket.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if l.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return l.releaseSlot(), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// tryAcquire acquires an in-flight slot and then a token from the rate limit
+// without waiting. The slot is taken first so that a request refused for
+// having too many in flight doesn't use up the rate.
+func (l *Limiter) tryAcquire() (release func(), err error) {
+	release = func() {}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+			release = l.releaseSlot()
+		default:
+			return nil, ErrLimitExceeded
+		}
+	}
+	if l.bucket != nil && !l.bucket.Allow() {
+		release()
+		return nil, ErrLimitExceeded
+	}
+	return release, nil
+}
+
+// releaseSlot returns a function which releases an in-flight slot once.
+func (l *Limiter) releaseSlot() func() {
+	var once sync.Once
+	return func() { once.Do(func() { <-l.slots }) }
+}
+
+// releasingBody calls release when the body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,134 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestLimiterRate(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	// the burst is allowed immediately, and then one every 20ms
+	l := NewLimiter(50, 2, 0)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		release, err := l.Acquire(context.Background())
+		tt.TestExpectSuccess(t, err)
+		release()
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("5 requests took %v, expected about 60ms", elapsed)
+	}
+
+	// a cancelled wait returns the context's error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := l.Acquire(ctx)
+	tt.TestEqual(t, err, context.Canceled)
+
+	// fail-fast rejects rather than waiting
+	l = NewLimiter(1, 1, 0)
+	l.FailFast = true
+	_, err = l.Acquire(context.Background())
+	tt.TestExpectSuccess(t, err)
+	_, err = l.Acquire(context.Background())
+	tt.TestEqual(t, err, ErrLimitExceeded)
+
+	// requests refused a slot don't use up the rate
+	l = NewLimiter(1, 1, 1)
+	l.FailFast = true
+	l.slots <- struct{}{}
+	for i := 0; i < 3; i++ {
+		_, err = l.Acquire(context.Background())
+		tt.TestEqual(t, err, ErrLimitExceeded)
+	}
+	<-l.slots
+	release, err := l.Acquire(context.Background())
+	tt.TestExpectSuccess(t, err)
+	release()
+}
+
+func TestLimiterInFlight(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	var mutex sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mutex.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mutex.Lock()
+		inFlight--
+		mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+	client.Limiter = NewLimiter(0, 0, 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.Get("/", nil); err != nil {
+				t.Errorf("Get failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	tt.TestEqual(t, maxInFlight, 2)
+
+	// an unclosed response holds its slot
+	client.Limiter.FailFast = true
+	resp1, err := client.Do(client.NewJsonRequest(GET, "/", nil))
+	tt.TestExpectSuccess(t, err)
+	resp2, err := client.Do(client.NewJsonRequest(GET, "/", nil))
+	tt.TestExpectSuccess(t, err)
+	_, err = client.Do(client.NewJsonRequest(GET, "/", nil))
+	tt.TestEqual(t, errors.Is(err, ErrLimitExceeded), true)
+	resp1.Body.Close()
+	resp2.Body.Close()
+	resp2.Body.Close()
+	_, err = client.Do(client.NewJsonRequest(GET, "/", nil))
+	tt.TestExpectSuccess(t, err)
+}
+
+func TestLimiterErrorResponse(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "no such thing", http.StatusNotFound)
+	}))
+	defer server.Close()
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+	client.Limiter = NewLimiter(0, 0, 1)
+	client.Limiter.FailFast = true
+
+	// failed responses release their slot without the body being closed
+	var restErr *RestError
+	for i := 0; i < 3; i++ {
+		err := client.Get("/", nil)
+		tt.TestErrorAs(t, err, &restErr)
+		tt.TestEqual(t, restErr.Resp.StatusCode, http.StatusNotFound)
+	}
+	tt.TestEqual(t, restErr.Body(), "no such thing\n")
+}
@@ -0,0 +1,167 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Paginator iterates over the pages of a listing endpoint, following the
+// RFC 5988 Link header of each response to the next page. Create one with
+// Client.Paginate:
+//
+//	p := client.Paginate("items")
+//	var items []Item
+//	for p.Next(&items) {
+//		// use this page of items
+//	}
+//	if err := p.Err(); err != nil {
+//		// handle the error
+//	}
+type Paginator struct {
+	// NextLink returns the URL of the page after the given response, or ""
+	// if it was the last page. A relative URL is resolved against the URL of
+	// the response. It defaults to LinkHeaderNext, and may be replaced for
+	// services which return a cursor in some other way.
+	NextLink func(resp *http.Response) (string, error)
+
+	client *Client
+	next   *url.URL
+	err    error
+}
+
+// Paginate returns a Paginator whose first page is a GET of the endpoint.
+func (c *Client) Paginate(endpoint string) *Paginator {
+	return &Paginator{
+		NextLink: LinkHeaderNext,
+		client:   c,
+		next:     resourceURL(c.BaseURL(), endpoint),
+	}
+}
+
+// Next fetches the next page and unmarshals it into page, which is normally a
+// pointer to a slice. A slice is replaced rather than reused, so the previous
+// page remains valid. It returns false once there are no more pages or an
+// error occurs, which is then returned by Err.
+func (p *Paginator) Next(page interface{}) bool {
+	if p.next == nil || p.err != nil {
+		return false
+	}
+
+	req := p.client.newRequest(GET, "")
+	req.URL = p.next
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.err = err
+		return false
+	}
+
+	link, err := p.NextLink(resp)
+	if err != nil {
+		resp.Body.Close()
+		p.err = err
+		return false
+	}
+	p.next = nil
+	if link != "" {
+		ref, err := url.Parse(link)
+		if err != nil {
+			resp.Body.Close()
+			p.err = fmt.Errorf("invalid next page link %q: %v", link, err)
+			return false
+		}
+		p.next = resp.Request.URL.ResolveReference(ref)
+	}
+
+	if rv := reflect.ValueOf(page); rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Slice {
+		rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+	}
+	if err := unmarshal(resp, page, p.client.Codec); err != nil {
+		p.err = err
+		return false
+	}
+	return true
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (p *Paginator) Err() error {
+	return p.err
+}
+
+// All fetches every remaining page and appends their items to the slice that
+// v points to.
+func (p *Paginator) All(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("All requires a pointer to a slice, not %T", v)
+	}
+	all := rv.Elem()
+	page := reflect.New(all.Type())
+	for p.Next(page.Interface()) {
+		all.Set(reflect.AppendSlice(all, page.Elem()))
+	}
+	return p.Err()
+}
+
+// LinkHeaderNext returns the URL of the link with the relation "next" from the
+// RFC 5988 Link headers of the response, or "" if there isn't one.
+func LinkHeaderNext(resp *http.Response) (string, error) {
+	return parseLinks(resp.Header["Link"])["next"], nil
+}
+
+// parseLinks parses RFC 5988 Link header values, returning the URL of each
+// relation.
+func parseLinks(values []string) map[string]string {
+	links := make(map[string]string)
+	for _, value := range values {
+		for _, link := range splitLinks(value) {
+			params := strings.Split(link, ";")
+			target := strings.TrimSpace(params[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			target = target[1 : len(target)-1]
+
+			for _, param := range params[1:] {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				if len(kv) != 2 || !strings.EqualFold(strings.TrimSpace(kv[0]), "rel") {
+					continue
+				}
+				// rel may hold several space separated relations
+				for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(kv[1]), `"`)) {
+					rel = strings.ToLower(rel)
+					if _, ok := links[rel]; !ok {
+						links[rel] = target
+					}
+				}
+			}
+		}
+	}
+	return links
+}
+
+// splitLinks splits a Link header value on the commas between links, ignoring
+// commas within the URLs and quoted parameters.
+func splitLinks(value string) []string {
+	var links []string
+	var inURL, inQuote bool
+	start := 0
+	for i, c := range value {
+		switch {
+		case c == '<' && !inQuote:
+			inURL = true
+		case c == '>' && !inQuote:
+			inURL = false
+		case c == '"' && !inURL:
+			inQuote = !inQuote
+		case c == ',' && !inURL && !inQuote:
+			links = append(links, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(links, value[start:])
+}
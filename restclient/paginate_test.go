@@ -0,0 +1,96 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// pagedServer serves the people in pages of two, linking to the next page.
+func pagedServer(people []person) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start, _ := strconv.Atoi(req.URL.Query().Get("start"))
+		end := start + 2
+		if end > len(people) {
+			end = len(people)
+		}
+		if end < len(people) {
+			w.Header().Add("Link", `<https://other.example.com/>; rel="help"`)
+			w.Header().Add("Link", fmt.Sprintf(`</people?start=%d>; rel="next last", <https://example.com/a,b>; title="a, b"; rel=first`, end))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(people[start:end])
+	}))
+}
+
+func TestPaginator(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	people := []person{{"Ann", 1}, {"Bob", 2}, {"Cat", 3}, {"Dan", 4}, {"Eve", 5}}
+	server := pagedServer(people)
+	defer server.Close()
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+
+	var pages [][]person
+	p := client.Paginate("/people")
+	var page []person
+	for p.Next(&page) {
+		pages = append(pages, page)
+	}
+	tt.TestExpectSuccess(t, p.Err())
+	tt.TestEqual(t, pages, [][]person{people[0:2], people[2:4], people[4:5]})
+	tt.TestEqual(t, p.Next(&page), false)
+
+	var all []person
+	tt.TestExpectSuccess(t, client.Paginate("/people").All(&all))
+	tt.TestEqual(t, all, people)
+
+	tt.TestExpectError(t, client.Paginate("/people").All(all))
+}
+
+func TestPaginatorNextLink(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	people := []person{{"Ann", 1}, {"Bob", 2}, {"Cat", 3}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cursor, _ := strconv.Atoi(req.URL.Query().Get("cursor"))
+		if cursor < len(people)-1 {
+			w.Header().Set("X-Next-Cursor", strconv.Itoa(cursor+1))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(people[cursor : cursor+1])
+	}))
+	defer server.Close()
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+
+	p := client.Paginate("/people")
+	p.NextLink = func(resp *http.Response) (string, error) {
+		if cursor := resp.Header.Get("X-Next-Cursor"); cursor != "" {
+			return "?cursor=" + cursor, nil
+		}
+		return "", nil
+	}
+	var all []person
+	tt.TestExpectSuccess(t, p.All(&all))
+	tt.TestEqual(t, all, people)
+
+	// errors stop the iteration
+	p = client.Paginate("/people")
+	p.NextLink = func(*http.Response) (string, error) {
+		return "", fmt.Errorf("bad cursor")
+	}
+	var page []person
+	tt.TestEqual(t, p.Next(&page), false)
+	tt.TestExpectError(t, p.Err())
+}
@@ -0,0 +1,160 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// acceptEncoding is sent on requests which don't set Accept-Encoding
+// themselves. Brotli isn't supported by the standard library, so it isn't
+// requested.
+const acceptEncoding = "gzip, deflate"
+
+// ErrUnsupportedEncoding is matched by the error returned for a response with a
+// Content-Encoding which can't be decoded, such as br, rather than passing the
+// encoded body on to be unmarshaled.
+var ErrUnsupportedEncoding = errors.New("unsupported Content-Encoding")
+
+// ErrTruncatedResponse is matched by the *TruncatedResponseError returned when a
+// response body is shorter than its Content-Length, such as when a proxy drops
+// the connection part way through the response.
+var ErrTruncatedResponse = errors.New("response body truncated")
+
+// TruncatedResponseError is returned when reading a response body which ended
+// before its Content-Length was received.
+type TruncatedResponseError struct {
+	// ContentLength is the length declared by the response.
+	ContentLength int64
+
+	// Received is the number of bytes received before the body ended.
+	Received int64
+}
+
+func (e *TruncatedResponseError) Error() string {
+	return fmt.Sprintf("%v: received %d of %d bytes", ErrTruncatedResponse, e.Received, e.ContentLength)
+}
+
+// Is allows errors.Is to match the error against ErrTruncatedResponse.
+func (e *TruncatedResponseError) Is(target error) bool {
+	return target == ErrTruncatedResponse
+}
+
+// lengthCheckingReader returns a *TruncatedResponseError when the body ends
+// before its expected length.
+type lengthCheckingReader struct {
+	body     io.ReadCloser
+	expected int64
+	received int64
+}
+
+func (r *lengthCheckingReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.received += int64(n)
+	if (err == io.EOF || err == io.ErrUnexpectedEOF) && r.received < r.expected {
+		err = &TruncatedResponseError{ContentLength: r.expected, Received: r.received}
+	}
+	return n, err
+}
+
+func (r *lengthCheckingReader) Close() error {
+	return r.body.Close()
+}
+
+// decodedBody reads a body through a decompressor, closing both when closed.
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	var err error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if cerr := b.closers[i].Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// decodeResponse replaces the response body with one which verifies it against
+// the Content-Length, and decompresses it according to its Content-Encoding.
+// The Content-Encoding and Content-Length are removed when the body is
+// decompressed, as done by http.Transport for gzip.
+func decodeResponse(resp *http.Response) error {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+	if resp.ContentLength > 0 {
+		resp.Body = &lengthCheckingReader{body: resp.Body, expected: resp.ContentLength}
+	}
+
+	var encodings []string
+	for _, value := range resp.Header["Content-Encoding"] {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding != "" && encoding != "identity" {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+	if len(encodings) == 0 {
+		return nil
+	}
+
+	// encodings are listed in the order they were applied
+	body := &decodedBody{Reader: resp.Body, closers: []io.Closer{resp.Body}}
+	for i := len(encodings) - 1; i >= 0; i-- {
+		switch encodings[i] {
+		case "gzip", "x-gzip":
+			zr, err := gzip.NewReader(body.Reader)
+			if err != nil {
+				body.Close()
+				return fmt.Errorf("error decoding gzip response: %s", err)
+			}
+			body.Reader = zr
+			body.closers = append(body.closers, zr)
+		case "deflate":
+			rc, err := newDeflateReader(body.Reader)
+			if err != nil {
+				body.Close()
+				return fmt.Errorf("error decoding deflate response: %s", err)
+			}
+			body.Reader = rc
+			body.closers = append(body.closers, rc)
+		default:
+			body.Close()
+			return fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encodings[i])
+		}
+	}
+
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// newDeflateReader returns a reader for a deflate encoded body. HTTP specifies
+// the zlib format, but some servers send raw deflate data, so the zlib header is
+// checked for first.
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
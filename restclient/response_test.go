@@ -0,0 +1,149 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestCompressedResponses(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	body := `{"Name":"Molly","Age":45}`
+	encoders := map[string]func(w io.Writer) io.WriteCloser{
+		"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		"raw-deflate": func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		},
+	}
+
+	for name, encoder := range encoders {
+		var acceptEncoding string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			acceptEncoding = req.Header.Get("Accept-Encoding")
+			var buf bytes.Buffer
+			ew := encoder(&buf)
+			io.WriteString(ew, body)
+			ew.Close()
+
+			encoding := name
+			if name == "raw-deflate" {
+				encoding = "deflate"
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", encoding)
+			w.Header().Set("Content-Length", fmt.Sprint(buf.Len()))
+			w.Write(buf.Bytes())
+		}))
+
+		client, err := New(server.URL)
+		tt.TestExpectSuccess(t, err)
+		var p person
+		tt.TestExpectSuccess(t, client.Get("/", &p))
+		tt.TestEqual(t, p, person{Name: "Molly", Age: 45})
+		tt.TestEqual(t, acceptEncoding, "gzip, deflate")
+		server.Close()
+	}
+}
+
+func TestCompressedResponseWithoutBody(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		if req.Method == "HEAD" {
+			w.Header().Set("Content-Length", "100")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+	client.Limiter = NewLimiter(0, 0, 1)
+	client.Limiter.FailFast = true
+	for _, method := range []Method{"HEAD", GET} {
+		resp, err := client.Do(client.NewRequest(method, "/", "", nil))
+		tt.TestExpectSuccess(t, err)
+		tt.TestExpectSuccess(t, resp.Body.Close())
+	}
+}
+
+func TestUnsupportedEncoding(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "br")
+		io.WriteString(w, "not really brotli")
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+	var p person
+	err = client.Get("/", &p)
+	tt.TestExpectError(t, err)
+	_, ok := err.(*RestError)
+	tt.TestTrue(t, ok)
+	tt.TestErrorIs(t, err, ErrUnsupportedEncoding)
+	tt.TestContains(t, err.Error(), "unsupported Content-Encoding: br")
+}
+
+func TestTruncatedResponse(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	for _, gzipped := range []bool{false, true} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var data bytes.Buffer
+			if gzipped {
+				zw := gzip.NewWriter(&data)
+				io.WriteString(zw, `{"Name":"Molly","Age":45}`)
+				zw.Close()
+			} else {
+				data.WriteString(`{"Name":"Molly","Age":45}`)
+			}
+
+			// declare the full length but only send part of the body
+			conn, bufrw, err := w.(http.Hijacker).Hijack()
+			tt.TestExpectSuccess(t, err)
+			defer conn.Close()
+			fmt.Fprintf(bufrw, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n", data.Len())
+			if gzipped {
+				fmt.Fprintf(bufrw, "Content-Encoding: gzip\r\n")
+			}
+			fmt.Fprintf(bufrw, "\r\n")
+			bufrw.Write(data.Bytes()[:data.Len()-10])
+			bufrw.Flush()
+		}))
+
+		client, err := New(server.URL)
+		tt.TestExpectSuccess(t, err)
+		var p person
+		err = client.Get("/", &p)
+		tt.TestExpectError(t, err)
+		tt.TestTrue(t, errors.Is(err, ErrTruncatedResponse))
+		terr, ok := err.(*TruncatedResponseError)
+		tt.TestTrue(t, ok)
+		tt.TestEqual(t, terr.Received, terr.ContentLength-10)
+		server.Close()
+	}
+}
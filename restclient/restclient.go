@@ -14,6 +14,7 @@ package restclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -24,7 +25,11 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/apcera/util/cache"
+	"github.com/apcera/util/uuid"
 )
 
 // Method wraps HTTP verbs for stronger typing.
@@ -53,6 +58,40 @@ type Client struct {
 	Headers http.Header
 	// KeepAlives enabled
 	KeepAlives bool
+	// RequestIDHeader, if set, is the name of a header which is populated
+	// with a newly generated UUID on each request, unless the request
+	// already has a value for it.
+	RequestIDHeader string
+	// Codec, if set, encodes the bodies of requests made by Get, Post, Put,
+	// and Delete, and is preferred when decoding responses. JSON is used if
+	// it is nil.
+	Codec Codec
+	// Cache, if set, stores successful GET responses which have an ETag,
+	// keyed by URL. Later requests for the URL are sent with If-None-Match,
+	// and a 304 Not Modified response is replaced by the cached response.
+	// The bodies of cached responses are read into memory.
+	Cache *cache.Cache
+	// ErrorDecoder, if set, is called with responses which aren't in the 2xx
+	// family to decode their body into an error, such as DecodeAPIError. A
+	// non-nil result is included in the *RestError returned, and can be
+	// retrieved with errors.As. Returning nil keeps the default error.
+	ErrorDecoder func(resp *http.Response) error
+	// Auth, if set, adds credentials to each request before it is sent.
+	Auth AuthProvider
+	// Limiter, if set, limits the rate of requests and the number in flight.
+	// A successful request is in flight until its response body is closed;
+	// the bodies of failed responses are read and closed by Do.
+	Limiter *Limiter
+	// Tracer, if set, is notified of each request, and may add trace
+	// propagation headers to it.
+	Tracer Tracer
+
+	// hostOverrides maps hosts to the addresses that should be dialed for
+	// them. See SetHostOverride.
+	hostOverrides      map[string]string
+	hostOverridesMutex sync.RWMutex
+	// overrideTransport is the transport installed by SetHostOverride.
+	overrideTransport *http.Transport
 }
 
 // New returns a *Client with the specified base URL endpoint, expected to
@@ -113,6 +152,98 @@ func (c *Client) SetAccessToken(token string) {
 	c.Headers.Set(http.CanonicalHeaderKey("Authorization"), "Bearer "+token)
 }
 
+// SetHostOverride causes connections to host to be made to addr instead,
+// bypassing DNS. This allows tests and split-horizon environments to point a
+// name at a specific address without editing /etc/hosts. The host may include a
+// port, in which case only connections to that port are overridden, and addr
+// may omit the port, in which case the original port is used. The request URL,
+// Host header, and TLS server name are not changed.
+//
+// The first override replaces the client's transport with a clone which dials
+// through the original transport's dialer, so a transport shared with other
+// clients isn't modified. An error is returned if the client's Driver uses a
+// custom http.RoundTripper which isn't an *http.Transport, since its dialer
+// can't be wrapped.
+func (c *Client) SetHostOverride(host, addr string) error {
+	c.hostOverridesMutex.Lock()
+	defer c.hostOverridesMutex.Unlock()
+	if err := c.installOverrideDialer(); err != nil {
+		return err
+	}
+	if c.hostOverrides == nil {
+		c.hostOverrides = make(map[string]string)
+	}
+	c.hostOverrides[host] = addr
+	return nil
+}
+
+// installOverrideDialer ensures the client's transport dials through
+// overrideAddress, installing a clone of the transport if needed. The
+// hostOverridesMutex must be held.
+func (c *Client) installOverrideDialer() error {
+	var base *http.Transport
+	switch t := c.Driver.Transport.(type) {
+	case nil:
+		base = http.DefaultTransport.(*http.Transport)
+	case *http.Transport:
+		if t == c.overrideTransport {
+			return nil
+		}
+		base = t
+	default:
+		return fmt.Errorf("host overrides are not supported with transport %T", t)
+	}
+
+	dial := base.DialContext
+	if dial == nil && base.Dial != nil {
+		baseDial := base.Dial
+		dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			return baseDial(network, address)
+		}
+	} else if dial == nil {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}
+		dial = dialer.DialContext
+	}
+
+	transport := base.Clone()
+	transport.Dial = nil
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dial(ctx, network, c.overrideAddress(address))
+	}
+	if !c.KeepAlives {
+		transport.DisableKeepAlives = true
+	}
+	c.Driver.Transport = transport
+	c.overrideTransport = transport
+	return nil
+}
+
+// overrideAddress returns the address to dial in place of address, which is
+// address itself unless it has a host override.
+func (c *Client) overrideAddress(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+
+	c.hostOverridesMutex.RLock()
+	override, ok := c.hostOverrides[address]
+	if !ok {
+		override, ok = c.hostOverrides[host]
+	}
+	c.hostOverridesMutex.RUnlock()
+	if !ok {
+		return address
+	}
+	if _, _, err := net.SplitHostPort(override); err != nil {
+		override = net.JoinHostPort(override, port)
+	}
+	return override
+}
+
 // SetTimeout sets the timeout of a client to the given duration.
 func (c *Client) SetTimeout(duration time.Duration) {
 	c.Driver.Timeout = duration
@@ -120,10 +251,11 @@ func (c *Client) SetTimeout(duration time.Duration) {
 
 // Get issues a GET request to the specified endpoint and parses the response
 // into resp. It will return an error if it failed to send the request, a
-// *RestError if the response wasn't a 2xx status code, or an error from package
-// json's Decode.
+// *RestError if the response wasn't a 2xx status code, a
+// *TruncatedResponseError if the response was cut short, or an error from
+// package json's Decode.
 func (c *Client) Get(endpoint string, resp interface{}) error {
-	return c.Result(c.NewJsonRequest(GET, endpoint, nil), resp)
+	return c.Result(c.newBodyRequest(GET, endpoint, nil), resp)
 }
 
 // Post issues a POST request to the specified endpoint with the req payload
@@ -131,7 +263,7 @@ func (c *Client) Get(endpoint string, resp interface{}) error {
 // if it failed to send the request, a *RestError if the response wasn't a 2xx
 // status code, or an error from package json's Decode.
 func (c *Client) Post(endpoint string, req interface{}, resp interface{}) error {
-	return c.Result(c.NewJsonRequest(POST, endpoint, req), resp)
+	return c.Result(c.newBodyRequest(POST, endpoint, req), resp)
 }
 
 // Put issues a PUT request to the specified endpoint with the req payload
@@ -139,7 +271,7 @@ func (c *Client) Post(endpoint string, req interface{}, resp interface{}) error
 // if it failed to send the request, a *RestError if the response wasn't a 2xx
 // status code, or an error from package json's Decode.
 func (c *Client) Put(endpoint string, req interface{}, resp interface{}) error {
-	return c.Result(c.NewJsonRequest(PUT, endpoint, req), resp)
+	return c.Result(c.newBodyRequest(PUT, endpoint, req), resp)
 }
 
 // Delete issues a DELETE request to the specified endpoint and parses the
@@ -147,35 +279,91 @@ func (c *Client) Put(endpoint string, req interface{}, resp interface{}) error {
 // *RestError if the response wasn't a 2xx status code, or an error from package
 // json's Decode.
 func (c *Client) Delete(endpoint string, resp interface{}) error {
-	return c.Result(c.NewJsonRequest(DELETE, endpoint, nil), resp)
+	return c.Result(c.newBodyRequest(DELETE, endpoint, nil), resp)
 }
 
 // Result performs the request described by req and unmarshals a successful
-// HTTP response into resp. If resp is nil, the response is discarded.
+// HTTP response into resp. If resp is nil, the response is discarded. The
+// response is decoded with the codec registered for its Content-Type,
+// preferring the codec of a request made by NewCodecRequest or else the
+// client's Codec.
 func (c *Client) Result(req *Request, resp interface{}) error {
 	result, err := c.Do(req)
 	if err != nil {
 		return err
 	}
-	return unmarshal(result, resp)
+	preferred := req.codec
+	if preferred == nil {
+		preferred = c.Codec
+	}
+	return unmarshal(result, resp, preferred)
 }
 
 // Do performs the HTTP request described by req and returns the *http.Response.
 // Also returns a non-nil *RestError if an error occurs or the response is not
-// in the 2xx family.
+// in the 2xx family. Responses compressed with gzip or deflate are decompressed,
+// and reading a body shorter than its Content-Length returns a
+// *TruncatedResponseError. Brotli (br) isn't supported, so it isn't requested,
+// and a response encoded with it returns a *RestError matching
+// ErrUnsupportedEncoding. If the client has a Cache, GET requests may be
+// answered from it as described for Client.Cache.
 func (c *Client) Do(req *Request) (*http.Response, error) {
 	hreq, err := req.HTTPRequest()
 	if err != nil {
 		return nil, &RestError{Req: hreq, err: fmt.Errorf("error preparing request: %s", err)}
 	}
 
+	// The body is closed by c.Driver once the request is handed to it, and
+	// must be closed here if the request fails before then, so that streamed
+	// and multipart bodies release their readers.
+	sent := false
+	defer func() {
+		if !sent && hreq.Body != nil {
+			hreq.Body.Close()
+		}
+	}()
+
 	if !c.KeepAlives {
 		hreq.Close = true
 	}
 
+	// Request compressed responses, which are decoded below. The headers are
+	// copied so the Request isn't modified.
+	hreq.Header = hreq.Header.Clone()
+	if hreq.Header.Get("Accept-Encoding") == "" {
+		hreq.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	if c.Auth != nil {
+		if err := c.Auth.Authenticate(hreq); err != nil {
+			return nil, &RestError{Req: hreq, err: fmt.Errorf("error authenticating request: %w", err)}
+		}
+	}
+
+	var cacheKey string
+	var cached *cachedResponse
+	if c.Cache != nil {
+		cacheKey = requestCacheKey(hreq)
+		if cacheKey != "" {
+			cached = c.cachedRequest(hreq, cacheKey)
+		}
+	}
+
+	release := func() {}
+	if c.Limiter != nil {
+		release, err = c.Limiter.Acquire(hreq.Context())
+		if err != nil {
+			return nil, &RestError{Req: hreq, err: fmt.Errorf("error waiting for request limit: %w", err)}
+		}
+	}
+
 	// Internally, this uses c.Driver's CheckRedirect policy.
+	endSpan := c.startSpan(req, hreq)
+	sent = true
 	resp, err := c.Driver.Do(hreq)
+	endSpan(resp, err)
 	if err != nil {
+		release()
 		if opErr, ok := err.(*net.OpError); ok {
 			if opErr.Timeout() {
 				return nil, &RestError{Req: hreq, err: fmt.Errorf("timed out making request")}
@@ -183,8 +371,30 @@ func (c *Client) Do(req *Request) (*http.Response, error) {
 		}
 		return resp, &RestError{Req: hreq, Resp: resp, err: fmt.Errorf("error sending request: %s", err)}
 	}
+
+	// The body is decoded before it is wrapped, so that responses without a
+	// body are still recognized as http.NoBody. The body is closed when
+	// decoding fails.
+	if err := decodeResponse(resp); err != nil {
+		release()
+		return resp, &RestError{Req: hreq, Resp: resp, err: err}
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	if cacheKey != "" {
+		resp = c.cacheResponse(hreq, resp, cacheKey, cached)
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return resp, &RestError{Req: hreq, Resp: resp, err: fmt.Errorf("error in response: %s", resp.Status)}
+		restErr := &RestError{
+			Req:     hreq,
+			Resp:    resp,
+			err:     fmt.Errorf("error in response: %s", resp.Status),
+			decoded: c.decodeError(resp),
+		}
+		// Callers such as Result don't close the body of a failed response,
+		// so read it now, which closes the original body and releases its
+		// Limiter slot. The body remains available from the RestError.
+		restErr.Body()
+		return resp, restErr
 	}
 	return resp, nil
 }
@@ -225,8 +435,7 @@ func (c *Client) NewJsonRequest(method Method, endpoint string, obj interface{})
 		}
 
 		// set to the request
-		httpReq.Body = ioutil.NopCloser(&buffer)
-		httpReq.ContentLength = int64(buffer.Len())
+		setBody(httpReq, buffer.Bytes())
 		httpReq.Header.Set("Content-Type", "application/json")
 		return nil
 	}
@@ -234,6 +443,43 @@ func (c *Client) NewJsonRequest(method Method, endpoint string, obj interface{})
 	return req
 }
 
+// NewCodecRequest generates a new Request object with obj encoded by the codec
+// as its body, if obj isn't nil, and with an Accept header for the codec's
+// content type. The codec is also preferred when Result decodes the response.
+func (c *Client) NewCodecRequest(method Method, endpoint string, codec Codec, obj interface{}) *Request {
+	req := c.newRequest(method, endpoint)
+	req.codec = codec
+	if req.Headers.Get("Accept") == "" {
+		req.Headers.Set("Accept", codec.ContentType())
+	}
+	if obj == nil {
+		return req
+	}
+
+	req.prepare = func(httpReq *http.Request) error {
+		data, err := codec.Marshal(obj)
+		if err != nil {
+			return err
+		}
+
+		// set to the request
+		setBody(httpReq, data)
+		httpReq.Header.Set("Content-Type", codec.ContentType())
+		return nil
+	}
+
+	return req
+}
+
+// newBodyRequest returns a Request with obj encoded using the client's Codec,
+// or JSON if it isn't set.
+func (c *Client) newBodyRequest(method Method, endpoint string, obj interface{}) *Request {
+	if c.Codec == nil {
+		return c.NewJsonRequest(method, endpoint, obj)
+	}
+	return c.NewCodecRequest(method, endpoint, c.Codec, obj)
+}
+
 // NewFormRequest generates a new Request object with a form encoded body based
 // on the params map.
 func (c *Client) NewFormRequest(method Method, endpoint string, params map[string]string) *Request {
@@ -248,8 +494,7 @@ func (c *Client) NewFormRequest(method Method, endpoint string, params map[strin
 		encoded := form.Encode()
 
 		// set to the request
-		httpReq.Body = ioutil.NopCloser(bytes.NewReader([]byte(encoded)))
-		httpReq.ContentLength = int64(len(encoded))
+		setBody(httpReq, []byte(encoded))
 		httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		return nil
 	}
@@ -257,6 +502,16 @@ func (c *Client) NewFormRequest(method Method, endpoint string, params map[strin
 	return req
 }
 
+// setBody sets the body of the request to data. GetBody is also set, so that
+// the body may be read again, such as by HMACAuth, without consuming it.
+func setBody(hr *http.Request, data []byte) {
+	hr.Body = ioutil.NopCloser(bytes.NewReader(data))
+	hr.ContentLength = int64(len(data))
+	hr.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
 // newRequest returns a *Request ready to be used by one of Client's exported
 // methods like NewFormRequest.
 func (c *Client) newRequest(method Method, endpoint string) *Request {
@@ -274,6 +529,10 @@ func (c *Client) newRequest(method Method, endpoint string) *Request {
 		}
 	}
 
+	if c.RequestIDHeader != "" && req.Headers.Get(c.RequestIDHeader) == "" {
+		req.Headers.Set(c.RequestIDHeader, uuid.Variant4().String())
+	}
+
 	return req
 }
 
@@ -282,8 +541,14 @@ type Request struct {
 	Method  Method
 	URL     *url.URL
 	Headers http.Header
+	// Template, if set, is reported to the client's Tracer instead of the
+	// URL's path, so requests for different resources can be grouped, such
+	// as "/jobs/{uuid}".
+	Template string
 
 	prepare func(*http.Request) error
+	// codec is set by NewCodecRequest.
+	codec Codec
 }
 
 // HTTPRequest returns an *http.Request populated with data from r. It may be
@@ -300,6 +565,9 @@ func (r *Request) HTTPRequest() (*http.Request, error) {
 	// generate the body
 	if r.prepare != nil {
 		if err := r.prepare(req); err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
 			return nil, err
 		}
 	}
@@ -321,10 +589,11 @@ func splitPathQuery(relPath string) (retPath, rawQuery string) {
 	return
 }
 
-// unmarshal unmarshals a JSON object from the response object's body. If the
-// Content-Type is not application/json or application/vnd* (or we can't detect
-// the media type) an error is returned. The response body is always closed.
-func unmarshal(resp *http.Response, v interface{}) error {
+// unmarshal unmarshals the response object's body using the codec for its
+// Content-Type, preferring the given codec. If there is no codec for the
+// Content-Type (or we can't detect the media type) an error is returned. The
+// response body is always closed.
+func unmarshal(resp *http.Response, v interface{}, preferred Codec) error {
 	defer resp.Body.Close()
 
 	// Don't Unmarshal Body if v is nil
@@ -337,11 +606,19 @@ func unmarshal(resp *http.Response, v interface{}) error {
 		return err
 	}
 
-	if !isJSONContentType(ctype) {
+	codec := codecFor(ctype, preferred)
+	if codec == nil {
 		return fmt.Errorf("unexpected response: %s %s", resp.Status, ctype)
 	}
 
-	return json.NewDecoder(resp.Body).Decode(v)
+	// Read the whole body, so a truncated response or a corrupt compressed
+	// body is reported even when the truncation came after the end of the
+	// encoded object.
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(data, v)
 }
 
 // isJSONContentType returns whether or not the media type should be expected to
@@ -370,12 +647,18 @@ type RestError struct {
 	// ErrBody is the body of the request that errored.
 	// Not named Body since there is an accessor method.
 	ErrBody *string
+	// decoded is the error returned by the client's ErrorDecoder.
+	decoded error
 }
 
 func (r *RestError) Error() string {
 	msg := r.err.Error()
 	prefix := msg + " - "
 
+	if r.decoded != nil {
+		return prefix + r.decoded.Error()
+	}
+
 	// Make sure the Error reads the cached body so
 	// you can call error multiple times with no issues.
 	// Also handle json from the endpoint and look for
@@ -402,6 +685,15 @@ func (r *RestError) Error() string {
 	return msg
 }
 
+// Unwrap returns the error decoded by the client's ErrorDecoder, if any, or
+// else the underlying error.
+func (r *RestError) Unwrap() error {
+	if r.decoded != nil {
+		return r.decoded
+	}
+	return r.err
+}
+
 func (r *RestError) Body() string {
 	// Return the body if we have it.
 	if r.ErrBody != nil {
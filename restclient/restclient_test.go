@@ -3,17 +3,21 @@
 package restclient
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	tt "github.com/apcera/util/testtool"
+	"github.com/apcera/util/uuid"
 )
 
 type person struct {
@@ -130,6 +134,91 @@ func TestNewRequestHeaders(t *testing.T) {
 	tt.TestEqual(t, headerValue, "applesauce")
 }
 
+func TestRequestIDHeader(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	// create a test server
+	var requestIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer req.Body.Close()
+		requestIDs = append(requestIDs, req.Header.Get("X-Request-Id"))
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+	client.RequestIDHeader = "X-Request-Id"
+
+	// each request gets its own ID
+	tt.TestExpectSuccess(t, client.Get("/", nil))
+	tt.TestExpectSuccess(t, client.Get("/", nil))
+	tt.TestEqual(t, len(requestIDs), 2)
+	_, err = uuid.FromString(requestIDs[0])
+	tt.TestExpectSuccess(t, err)
+	tt.TestNotEqual(t, requestIDs[0], requestIDs[1])
+
+	// an existing value is preserved
+	client.Headers.Set("X-Request-Id", "provided")
+	tt.TestExpectSuccess(t, client.Get("/", nil))
+	tt.TestEqual(t, requestIDs[2], "provided")
+}
+
+func TestHostOverride(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	// create a test server
+	host := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer req.Body.Close()
+		host = req.Host
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	tt.TestExpectSuccess(t, err)
+	serverHost, serverPort, err := net.SplitHostPort(serverURL.Host)
+	tt.TestExpectSuccess(t, err)
+
+	// override just the host, using the port from the URL
+	client, err := New("http://api.invalid:" + serverPort)
+	tt.TestExpectSuccess(t, err)
+	tt.TestExpectSuccess(t, client.SetHostOverride("api.invalid", serverHost))
+	tt.TestExpectSuccess(t, client.Get("/", nil))
+	tt.TestEqual(t, host, "api.invalid:"+serverPort)
+
+	// override a specific host and port on a caller provided transport,
+	// which is left unmodified while its dialer is still used
+	client, err = New("http://api.invalid:1")
+	tt.TestExpectSuccess(t, err)
+	var dialed []string
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed = append(dialed, address)
+			return (&net.Dialer{}).DialContext(ctx, network, address)
+		},
+	}
+	client.Driver.Transport = transport
+	tt.TestExpectSuccess(t, client.SetHostOverride("api.invalid:1", serverURL.Host))
+	tt.TestExpectSuccess(t, client.SetHostOverride("api.invalid:2", "127.0.0.2:2"))
+	tt.TestExpectSuccess(t, client.Get("/", nil))
+	tt.TestEqual(t, host, "api.invalid:1")
+	tt.TestEqual(t, dialed, []string{serverURL.Host})
+	tt.TestTrue(t, client.Driver.Transport != transport)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = transport.DialContext(ctx, "tcp", "api.invalid:1")
+	tt.TestExpectError(t, err)
+	tt.TestEqual(t, dialed, []string{serverURL.Host, "api.invalid:1"})
+
+	// custom round trippers are not supported
+	client.Driver.Transport = http.NewFileTransport(http.Dir("/"))
+	tt.TestExpectError(t, client.SetHostOverride("api.invalid", serverHost))
+}
+
 func TestBasicJsonRequest(t *testing.T) {
 	testHelper := tt.StartTest(t)
 	defer testHelper.FinishTest()
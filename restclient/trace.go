@@ -0,0 +1,133 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Tracer is notified of each request made by a Client, allowing tracing and
+// metrics systems such as OpenTelemetry to be integrated without this package
+// depending on them.
+type Tracer interface {
+	// StartRequest is called before the request is sent. It may add trace
+	// propagation headers to the request. The returned RequestSpan is ended
+	// once the response headers are received or the request fails.
+	StartRequest(req *http.Request, template string) RequestSpan
+}
+
+// RequestSpan is a request started by a Tracer.
+type RequestSpan interface {
+	EndRequest(info RequestInfo)
+}
+
+// RequestInfo describes a finished request.
+type RequestInfo struct {
+	Method string
+	URL    string
+	// Template is the Request's Template, or the path of the URL if it
+	// isn't set.
+	Template string
+	// StatusCode is zero if no response was received.
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// startSpan starts tracing the request, returning a function to end it.
+func (c *Client) startSpan(req *Request, hreq *http.Request) func(resp *http.Response, err error) {
+	if c.Tracer == nil {
+		return func(*http.Response, error) {}
+	}
+
+	template := req.Template
+	if template == "" {
+		template = hreq.URL.Path
+	}
+	span := c.Tracer.StartRequest(hreq, template)
+	start := time.Now()
+	return func(resp *http.Response, err error) {
+		info := RequestInfo{
+			Method:   hreq.Method,
+			URL:      hreq.URL.String(),
+			Template: template,
+			Duration: time.Since(start),
+			Err:      err,
+		}
+		if resp != nil {
+			info.StatusCode = resp.StatusCode
+		}
+		span.EndRequest(info)
+	}
+}
+
+// RequestMetrics is a Tracer which counts requests and their total duration by
+// method, template, and status code.
+type RequestMetrics struct {
+	mutex   sync.Mutex
+	metrics map[RequestMetricKey]*RequestMetric
+}
+
+// RequestMetricKey identifies a group of requests in RequestMetrics.
+type RequestMetricKey struct {
+	Method     string
+	Template   string
+	StatusCode int
+}
+
+// RequestMetric is the count and total duration of a group of requests.
+type RequestMetric struct {
+	RequestMetricKey
+	Count    int64
+	Errors   int64
+	Duration time.Duration
+}
+
+// StartRequest implements Tracer.
+func (m *RequestMetrics) StartRequest(*http.Request, string) RequestSpan {
+	return m
+}
+
+// EndRequest implements RequestSpan.
+func (m *RequestMetrics) EndRequest(info RequestInfo) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.metrics == nil {
+		m.metrics = make(map[RequestMetricKey]*RequestMetric)
+	}
+	key := RequestMetricKey{Method: info.Method, Template: info.Template, StatusCode: info.StatusCode}
+	metric := m.metrics[key]
+	if metric == nil {
+		metric = &RequestMetric{RequestMetricKey: key}
+		m.metrics[key] = metric
+	}
+	metric.Count++
+	if info.Err != nil {
+		metric.Errors++
+	}
+	metric.Duration += info.Duration
+}
+
+// Snapshot returns the metrics, sorted by template, method, and status code.
+func (m *RequestMetrics) Snapshot() []RequestMetric {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	snapshot := make([]RequestMetric, 0, len(m.metrics))
+	for _, metric := range m.metrics {
+		snapshot = append(snapshot, *metric)
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		a, b := snapshot[i], snapshot[j]
+		if a.Template != b.Template {
+			return a.Template < b.Template
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.StatusCode < b.StatusCode
+	})
+	return snapshot
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// headerTracer adds a trace header to each request and records the spans.
+type headerTracer struct {
+	templates []string
+	infos     []RequestInfo
+}
+
+func (h *headerTracer) StartRequest(req *http.Request, template string) RequestSpan {
+	req.Header.Set("Traceparent", "00-trace-span-01")
+	h.templates = append(h.templates, template)
+	return h
+}
+
+func (h *headerTracer) EndRequest(info RequestInfo) {
+	h.infos = append(h.infos, info)
+}
+
+func TestTracer(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	var traceparents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		traceparents = append(traceparents, req.Header.Get("Traceparent"))
+		if req.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+
+	tracer := &headerTracer{}
+	client.Tracer = tracer
+	req := client.NewJsonRequest(GET, "/jobs/1234", nil)
+	req.Template = "/jobs/{id}"
+	tt.TestExpectSuccess(t, client.Result(req, nil))
+	tt.TestExpectError(t, client.Get("/missing", nil))
+
+	tt.TestEqual(t, traceparents, []string{"00-trace-span-01", "00-trace-span-01"})
+	tt.TestEqual(t, tracer.templates, []string{"/jobs/{id}", "/missing"})
+	tt.TestEqual(t, len(tracer.infos), 2)
+	tt.TestEqual(t, tracer.infos[0].Method, "GET")
+	tt.TestEqual(t, tracer.infos[0].URL, server.URL+"/jobs/1234")
+	tt.TestEqual(t, tracer.infos[0].StatusCode, 204)
+	tt.TestEqual(t, tracer.infos[1].StatusCode, 404)
+	tt.TestEqual(t, tracer.infos[1].Err, nil)
+
+	// the request's headers aren't changed
+	tt.TestEqual(t, req.Headers.Get("Traceparent"), "")
+}
+
+func TestRequestMetrics(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+	metrics := &RequestMetrics{}
+	client.Tracer = metrics
+
+	tt.TestExpectSuccess(t, client.Get("/a", nil))
+	tt.TestExpectSuccess(t, client.Get("/a", nil))
+	tt.TestExpectSuccess(t, client.Delete("/b", nil))
+	server.Close()
+	tt.TestExpectError(t, client.Get("/a", nil))
+
+	snapshot := metrics.Snapshot()
+	tt.TestEqual(t, len(snapshot), 3)
+	for i := range snapshot {
+		snapshot[i].Duration = 0
+	}
+	tt.TestEqual(t, snapshot, []RequestMetric{
+		{RequestMetricKey: RequestMetricKey{"GET", "/a", 0}, Count: 1, Errors: 1},
+		{RequestMetricKey: RequestMetricKey{"GET", "/a", 204}, Count: 2},
+		{RequestMetricKey: RequestMetricKey{"DELETE", "/b", 204}, Count: 1},
+	})
+}
@@ -0,0 +1,196 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"sort"
+	"strings"
+)
+
+// MultipartFile is a file included in the body of a request created by
+// NewMultipartRequest.
+type MultipartFile struct {
+	// FieldName is the name of the form field holding the file.
+	FieldName string
+	// FileName is the file name sent for the file.
+	FileName string
+	// ContentType is the type of the file. It defaults to
+	// application/octet-stream.
+	ContentType string
+	// Reader supplies the contents of the file. It is closed once the body
+	// has been sent, or the request has failed, if it is an io.Closer.
+	Reader io.Reader
+}
+
+// NewStreamRequest generates a new Request object which streams the body from
+// the reader without buffering it. The Content-Length is set when the length
+// of the body can be determined, such as for a *bytes.Reader, *strings.Reader
+// or regular *os.File, and otherwise the body is sent with chunked encoding.
+// Since the reader is consumed, the request can only be sent once.
+func (c *Client) NewStreamRequest(method Method, endpoint string, body io.Reader, contentType string) *Request {
+	req := c.newRequest(method, endpoint)
+	if body == nil {
+		return req
+	}
+
+	req.prepare = func(hr *http.Request) error {
+		rc, ok := body.(io.ReadCloser)
+		if !ok {
+			rc = ioutil.NopCloser(body)
+		}
+		hr.Body = rc
+		hr.ContentLength = readerLength(body)
+		if contentType != "" {
+			hr.Header.Set("Content-Type", contentType)
+		}
+		return nil
+	}
+	return req
+}
+
+// NewMultipartRequest generates a new Request object with a multipart/form-data
+// body containing the form fields followed by the files. The files are streamed
+// into the body as it is sent rather than being buffered. When the lengths of
+// all of the files are known, as described for NewStreamRequest, the
+// Content-Length is set, and otherwise the body is sent with chunked encoding.
+// Since the files are consumed, the request can only be sent once.
+func (c *Client) NewMultipartRequest(method Method, endpoint string, fields map[string]string, files ...MultipartFile) *Request {
+	req := c.newRequest(method, endpoint)
+
+	req.prepare = func(hr *http.Request) error {
+		pr, pw := io.Pipe()
+		mw := multipart.NewWriter(pw)
+
+		length, err := multipartLength(mw.Boundary(), fields, files)
+		if err != nil {
+			closeFiles(files)
+			return err
+		}
+
+		// The writer exits once the body is written or the reader is
+		// closed, which the transport or Client.Do does even when the
+		// request isn't sent.
+		go func() {
+			pw.CloseWithError(writeMultipart(mw, fields, files, true))
+			closeFiles(files)
+		}()
+
+		hr.Body = pr
+		hr.ContentLength = length
+		hr.Header.Set("Content-Type", mw.FormDataContentType())
+		return nil
+	}
+	return req
+}
+
+// writeMultipart writes the fields and files to mw. When copyFiles is false the
+// contents of the files are left out, which is used to measure the size of the
+// rest of the body.
+func writeMultipart(mw *multipart.Writer, fields map[string]string, files []MultipartFile, copyFiles bool) error {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := mw.WriteField(name, fields[name]); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		ctype := file.ContentType
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", `form-data; name="`+quoteEscaper.Replace(file.FieldName)+
+			`"; filename="`+quoteEscaper.Replace(file.FileName)+`"`)
+		header.Set("Content-Type", ctype)
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if !copyFiles {
+			continue
+		}
+		if _, err := io.Copy(part, file.Reader); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// closeFiles closes the readers of the files which are io.Closers.
+func closeFiles(files []MultipartFile) {
+	for _, file := range files {
+		if closer, ok := file.Reader.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
+
+// multipartLength returns the length of the multipart body, or -1 if the length
+// of a file is unknown.
+func multipartLength(boundary string, fields map[string]string, files []MultipartFile) (int64, error) {
+	var length int64
+	for _, file := range files {
+		n := readerLength(file.Reader)
+		if n < 0 {
+			return -1, nil
+		}
+		length += n
+	}
+
+	var counter countingWriter
+	mw := multipart.NewWriter(&counter)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return 0, err
+	}
+	if err := writeMultipart(mw, fields, files, false); err != nil {
+		return 0, err
+	}
+	return length + counter.n, nil
+}
+
+// readerLength returns the number of bytes remaining to be read from r, or -1
+// if it can't be determined.
+func readerLength(r io.Reader) int64 {
+	switch v := r.(type) {
+	case interface {
+		Len() int
+	}:
+		return int64(v.Len())
+	case *os.File:
+		fi, err := v.Stat()
+		if err != nil || !fi.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return fi.Size() - offset
+	}
+	return -1
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// quoteEscaper escapes quoted strings in a Content-Disposition header, as done
+// by package mime/multipart.
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
@@ -0,0 +1,185 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package restclient
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// uploadServer records the requests it receives along with their bodies.
+type uploadServer struct {
+	requests []*http.Request
+	bodies   []string
+}
+
+func (s *uploadServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	s.requests = append(s.requests, req)
+	s.bodies = append(s.bodies, string(body))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestStreamRequest(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	handler := &uploadServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+
+	// a reader with a known length
+	req := client.NewStreamRequest(PUT, "/blob", strings.NewReader("known length"), "text/plain")
+	tt.TestExpectSuccess(t, client.Result(req, nil))
+
+	// a file, read from its current offset
+	path := filepath.Join(testHelper.TempDir(), "file")
+	tt.TestExpectSuccess(t, ioutil.WriteFile(path, []byte("skip file contents"), 0644))
+	f, err := os.Open(path)
+	tt.TestExpectSuccess(t, err)
+	_, err = f.Seek(5, io.SeekStart)
+	tt.TestExpectSuccess(t, err)
+	req = client.NewStreamRequest(PUT, "/blob", f, "application/octet-stream")
+	tt.TestExpectSuccess(t, client.Result(req, nil))
+
+	// an unknown length is sent chunked
+	pr, pw := io.Pipe()
+	go func() {
+		io.WriteString(pw, "unknown ")
+		io.WriteString(pw, "length")
+		pw.Close()
+	}()
+	req = client.NewStreamRequest(POST, "/blob", pr, "")
+	tt.TestExpectSuccess(t, client.Result(req, nil))
+
+	tt.TestEqual(t, len(handler.requests), 3)
+	tt.TestEqual(t, handler.bodies, []string{"known length", "file contents", "unknown length"})
+	tt.TestEqual(t, handler.requests[0].ContentLength, int64(12))
+	tt.TestEqual(t, handler.requests[0].Header.Get("Content-Type"), "text/plain")
+	tt.TestEqual(t, handler.requests[1].ContentLength, int64(13))
+	tt.TestEqual(t, handler.requests[2].ContentLength, int64(-1))
+	tt.TestEqual(t, handler.requests[2].TransferEncoding, []string{"chunked"})
+	tt.TestEqual(t, handler.requests[2].Header.Get("Content-Type"), "")
+}
+
+func TestMultipartRequest(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	type upload struct {
+		contentLength int64
+		fields        map[string]string
+		files         map[string]string
+		fileNames     map[string]string
+		fileTypes     map[string]string
+	}
+	var uploads []upload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		u := upload{
+			contentLength: req.ContentLength,
+			fields:        map[string]string{},
+			files:         map[string]string{},
+			fileNames:     map[string]string{},
+			fileTypes:     map[string]string{},
+		}
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for name, values := range req.MultipartForm.Value {
+			u.fields[name] = values[0]
+		}
+		for name, headers := range req.MultipartForm.File {
+			f, _ := headers[0].Open()
+			b, _ := ioutil.ReadAll(f)
+			f.Close()
+			u.files[name] = string(b)
+			u.fileNames[name] = headers[0].Filename
+			u.fileTypes[name] = headers[0].Header.Get("Content-Type")
+		}
+		uploads = append(uploads, u)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	client, err := New(server.URL)
+	tt.TestExpectSuccess(t, err)
+
+	fields := map[string]string{"name": "app", "version": "2"}
+	req := client.NewMultipartRequest(POST, "/upload", fields,
+		MultipartFile{FieldName: "package", FileName: "app.tar", ContentType: "application/x-tar", Reader: strings.NewReader("tar data")},
+		MultipartFile{FieldName: "manifest", FileName: "manifest.json", Reader: strings.NewReader("{}")})
+	tt.TestExpectSuccess(t, client.Result(req, nil))
+
+	// a file of unknown length sends the body chunked
+	pr, pw := io.Pipe()
+	go func() {
+		io.WriteString(pw, "streamed")
+		pw.Close()
+	}()
+	req = client.NewMultipartRequest(POST, "/upload", nil,
+		MultipartFile{FieldName: "log", FileName: "log.txt", Reader: pr})
+	tt.TestExpectSuccess(t, client.Result(req, nil))
+
+	tt.TestEqual(t, len(uploads), 2)
+	tt.TestNotEqual(t, uploads[0].contentLength, int64(-1))
+	tt.TestEqual(t, uploads[0].fields, fields)
+	tt.TestEqual(t, uploads[0].files, map[string]string{"package": "tar data", "manifest": "{}"})
+	tt.TestEqual(t, uploads[0].fileNames, map[string]string{"package": "app.tar", "manifest": "manifest.json"})
+	tt.TestEqual(t, uploads[0].fileTypes, map[string]string{"package": "application/x-tar", "manifest": "application/octet-stream"})
+	tt.TestEqual(t, uploads[1].contentLength, int64(-1))
+	tt.TestEqual(t, uploads[1].files, map[string]string{"log": "streamed"})
+}
+
+// closeRecorder is a reader which records when it has been closed.
+type closeRecorder struct {
+	io.Reader
+	closed chan struct{}
+}
+
+func (r *closeRecorder) Close() error {
+	close(r.closed)
+	return nil
+}
+
+func TestMultipartRequestNotSent(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	client, err := New("http://127.0.0.1:1")
+	tt.TestExpectSuccess(t, err)
+	client.Auth = AuthProviderFunc(func(req *http.Request) error {
+		return errors.New("no credentials")
+	})
+
+	// the body is large enough that the writer blocks on the pipe
+	files := []*closeRecorder{
+		{Reader: strings.NewReader(strings.Repeat("x", 1<<20)), closed: make(chan struct{})},
+		{Reader: strings.NewReader("{}"), closed: make(chan struct{})},
+	}
+	req := client.NewMultipartRequest(POST, "/upload", map[string]string{"name": "app"},
+		MultipartFile{FieldName: "package", FileName: "app.tar", Reader: files[0]},
+		MultipartFile{FieldName: "manifest", FileName: "manifest.json", Reader: files[1]})
+	_, err = client.Do(req)
+	tt.TestExpectError(t, err)
+
+	// the writer exits, closing the files, without the body being read
+	for _, f := range files {
+		select {
+		case <-f.closed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("file was not closed")
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// Package retry provides backoff policies and a helper for retrying an
+// operation until it succeeds, the policy gives up, or a context is done.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Policy decides how long to wait before each retry.
+type Policy interface {
+	// Next returns the wait before the retry following the given number of
+	// failed attempts, starting at 1, or false to give up.
+	Next(attempts int) (time.Duration, bool)
+}
+
+// Exponential is a Policy whose wait grows exponentially, optionally capped
+// and randomized so that many clients failing at once don't retry in step.
+type Exponential struct {
+	// Initial is the wait before the first retry.
+	Initial time.Duration
+
+	// Multiplier is the growth of the wait after each retry. It defaults
+	// to 2.
+	Multiplier float64
+
+	// Max caps the wait, before jitter is applied. Zero means no cap.
+	Max time.Duration
+
+	// Jitter randomizes each wait by up to this fraction of it in either
+	// direction, so 0.2 gives waits between 80% and 120% of the computed
+	// value.
+	Jitter float64
+
+	// MaxAttempts is the number of attempts, including the first, after
+	// which Next gives up. Zero means no limit, leaving the context to
+	// end the retries.
+	MaxAttempts int
+}
+
+// DefaultPolicy is a reasonable policy for retrying requests to a service,
+// making up to 5 attempts over a few seconds.
+var DefaultPolicy Policy = Exponential{
+	Initial:     200 * time.Millisecond,
+	Multiplier:  2,
+	Max:         5 * time.Second,
+	Jitter:      0.2,
+	MaxAttempts: 5,
+}
+
+// Next implements Policy.
+func (e Exponential) Next(attempts int) (time.Duration, bool) {
+	if e.MaxAttempts > 0 && attempts >= e.MaxAttempts {
+		return 0, false
+	}
+	multiplier := e.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	d := float64(e.Initial) * math.Pow(multiplier, float64(attempts-1))
+	if e.Max > 0 && d > float64(e.Max) {
+		d = float64(e.Max)
+	}
+	// without a cap the wait eventually overflows
+	if d > math.MaxInt64/2 {
+		d = math.MaxInt64 / 2
+	}
+	if e.Jitter > 0 {
+		d += d * math.Min(e.Jitter, 1) * (2*random() - 1)
+	}
+	return time.Duration(d), true
+}
+
+// Constant is a Policy which always waits for the same interval.
+type Constant struct {
+	Interval time.Duration
+
+	// MaxAttempts is the number of attempts, including the first, after
+	// which Next gives up. Zero means no limit.
+	MaxAttempts int
+}
+
+// Next implements Policy.
+func (c Constant) Next(attempts int) (time.Duration, bool) {
+	if c.MaxAttempts > 0 && attempts >= c.MaxAttempts {
+		return 0, false
+	}
+	return c.Interval, true
+}
+
+// random is shared by the policies, since a Policy is a value which can't hold
+// its own source.
+var (
+	randMutex sync.Mutex
+	randSrc   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+func random() float64 {
+	randMutex.Lock()
+	defer randMutex.Unlock()
+	return randSrc.Float64()
+}
+
+// permanentError marks an error which shouldn't be retried.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps an error returned to Do to stop it retrying. Do returns the
+// wrapped error itself, unless the permanent error was wrapped again.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// sleep waits for the duration or until the context is done. It is overridden
+// within the tests.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Do calls fn until it returns nil, waiting between attempts as directed by
+// the policy. It returns nil once fn succeeds, the error wrapped by Permanent
+// if fn returns one, the last error from fn if the policy gives up, or the
+// context's error if it is done first.
+func Do(ctx context.Context, policy Policy, fn func(ctx context.Context) error) error {
+	return DoNotify(ctx, policy, fn, nil)
+}
+
+// DoNotify is like Do but calls notify, if it isn't nil, with each error which
+// will be retried and the wait before the retry, such as to log it.
+func DoNotify(ctx context.Context, policy Policy, fn func(ctx context.Context) error, notify func(err error, wait time.Duration)) error {
+	for attempts := 1; ; attempts++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		var p *permanentError
+		if errors.As(err, &p) {
+			if err == error(p) {
+				return p.err
+			}
+			return err
+		}
+
+		wait, ok := policy.Next(attempts)
+		if !ok {
+			return err
+		}
+		if notify != nil {
+			notify(err, wait)
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// recordSleeps replaces sleep with one which records the waits rather than
+// sleeping.
+func recordSleeps(testHelper *tt.TestTool) *[]time.Duration {
+	var waits []time.Duration
+	sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	testHelper.AddTestFinalizer(func() { sleep = realSleep })
+	return &waits
+}
+
+var realSleep = sleep
+
+func TestExponential(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	e := Exponential{Initial: 100 * time.Millisecond, Max: time.Second, MaxAttempts: 6}
+	var waits []time.Duration
+	for attempts := 1; ; attempts++ {
+		d, ok := e.Next(attempts)
+		if !ok {
+			break
+		}
+		waits = append(waits, d)
+	}
+	tt.TestEqual(t, waits, []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+	})
+
+	e = Exponential{Initial: time.Second, Multiplier: 1.5}
+	d, ok := e.Next(3)
+	tt.TestTrue(t, ok)
+	tt.TestEqual(t, d, 2250*time.Millisecond)
+
+	// without a cap or a limit the wait doesn't overflow
+	d, ok = Exponential{Initial: time.Hour, Jitter: 0.5}.Next(1000)
+	tt.TestTrue(t, ok)
+	tt.TestGreater(t, d, time.Duration(0))
+
+	e = Exponential{Initial: time.Second, Jitter: 0.25}
+	for i := 0; i < 100; i++ {
+		d, _ := e.Next(2)
+		tt.TestGreater(t, d, 1499*time.Millisecond)
+		tt.TestLess(t, d, 2501*time.Millisecond)
+	}
+}
+
+func TestConstant(t *testing.T) {
+	c := Constant{Interval: time.Second, MaxAttempts: 3}
+	d, ok := c.Next(2)
+	tt.TestTrue(t, ok)
+	tt.TestEqual(t, d, time.Second)
+	_, ok = c.Next(3)
+	tt.TestFalse(t, ok)
+	_, ok = Constant{}.Next(1000)
+	tt.TestTrue(t, ok)
+}
+
+func TestDo(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+	waits := recordSleeps(testHelper)
+
+	policy := Exponential{Initial: time.Second, MaxAttempts: 4}
+	calls := 0
+	err := Do(context.Background(), policy, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, calls, 3)
+	tt.TestEqual(t, *waits, []time.Duration{time.Second, 2 * time.Second})
+
+	// the policy gives up, returning the last error
+	*waits = nil
+	calls = 0
+	var notified []string
+	err = DoNotify(context.Background(), policy, func(ctx context.Context) error {
+		calls++
+		return fmt.Errorf("failure %d", calls)
+	}, func(err error, wait time.Duration) {
+		notified = append(notified, fmt.Sprintf("%s, %v", err, wait))
+	})
+	tt.TestEqual(t, err.Error(), "failure 4")
+	tt.TestEqual(t, calls, 4)
+	tt.TestEqual(t, notified, []string{"failure 1, 1s", "failure 2, 2s", "failure 3, 4s"})
+
+	// permanent errors stop the retries
+	base := errors.New("bad request")
+	calls = 0
+	err = Do(context.Background(), policy, func(ctx context.Context) error {
+		calls++
+		return Permanent(base)
+	})
+	tt.TestEqual(t, err, base)
+	tt.TestEqual(t, calls, 1)
+	err = Do(context.Background(), policy, func(ctx context.Context) error {
+		return fmt.Errorf("wrapped: %w", Permanent(base))
+	})
+	tt.TestErrorIs(t, err, base)
+	tt.TestEqual(t, err.Error(), "wrapped: bad request")
+	tt.TestEqual(t, Permanent(nil), nil)
+}
+
+func TestDoContext(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	// a cancelled context stops the wait
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	start := time.Now()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	err := Do(ctx, Constant{Interval: time.Hour}, func(ctx context.Context) error {
+		calls++
+		return errors.New("failure")
+	})
+	tt.TestEqual(t, err, context.Canceled)
+	tt.TestEqual(t, calls, 1)
+	tt.TestLess(t, time.Since(start), 5*time.Second)
+
+	// fn isn't called once the context is done
+	calls = 0
+	err = Do(ctx, DefaultPolicy, func(ctx context.Context) error {
+		calls++
+		return nil
+	})
+	tt.TestEqual(t, err, context.Canceled)
+	tt.TestEqual(t, calls, 0)
+}
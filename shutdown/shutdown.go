@@ -0,0 +1,223 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// Package shutdown coordinates the graceful shutdown of a process. Subsystems
+// register hooks with a Manager, which runs them in order, each bounded by its
+// own timeout, when shutdown is triggered by a signal or programmatically.
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Hook is a function run during shutdown. The context is cancelled when the
+// hook's timeout expires.
+type Hook func(ctx context.Context) error
+
+// HookResult is the outcome of running a single hook.
+type HookResult struct {
+	// Name is the name the hook was registered with.
+	Name string `json:"name"`
+
+	// Duration is how long the hook ran, or how long was waited for it if it
+	// timed out.
+	Duration time.Duration `json:"duration"`
+
+	// Err is the error returned by the hook, or the context's error if it
+	// timed out.
+	Err error `json:"-"`
+
+	// TimedOut is true if the hook did not return within its timeout.
+	TimedOut bool `json:"timed_out"`
+}
+
+// Report is the outcome of a shutdown.
+type Report struct {
+	// Reason is why shutdown was triggered, such as the signal received.
+	Reason string `json:"reason"`
+
+	// Results contains the result of each hook in the order they were run.
+	Results []HookResult `json:"results"`
+}
+
+// TimedOut returns the names of the hooks which timed out.
+func (r *Report) TimedOut() []string {
+	var names []string
+	for _, res := range r.Results {
+		if res.TimedOut {
+			names = append(names, res.Name)
+		}
+	}
+	return names
+}
+
+// Failed returns the names of the hooks which returned an error or timed out.
+func (r *Report) Failed() []string {
+	var names []string
+	for _, res := range r.Results {
+		if res.Err != nil {
+			names = append(names, res.Name)
+		}
+	}
+	return names
+}
+
+type hook struct {
+	name    string
+	order   int
+	seq     int
+	timeout time.Duration
+	f       Hook
+}
+
+// Manager runs the registered hooks when shutdown is triggered. The zero value
+// is not usable, a Manager should be created with NewManager.
+type Manager struct {
+	mutex   sync.Mutex
+	hooks   []*hook
+	started bool
+	report  *Report
+	done    chan struct{}
+}
+
+// NewManager returns a new Manager.
+func NewManager() *Manager {
+	return &Manager{done: make(chan struct{})}
+}
+
+// Register adds a hook to be run at shutdown. Hooks are run one at a time in
+// ascending order, with hooks of the same order run in the order they were
+// registered. If the hook does not return within timeout then it is reported
+// as timed out and shutdown moves on to the next hook. A timeout of zero or
+// less means no timeout.
+//
+// Hooks registered once shutdown has started are ignored.
+func (m *Manager) Register(name string, order int, timeout time.Duration, f Hook) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.started {
+		return
+	}
+	m.hooks = append(m.hooks, &hook{
+		name:    name,
+		order:   order,
+		seq:     len(m.hooks),
+		timeout: timeout,
+		f:       f,
+	})
+}
+
+// Shutdown runs the registered hooks and returns the report. Only the first
+// call runs the hooks, later calls wait for it to complete and return the same
+// report.
+func (m *Manager) Shutdown(reason string) *Report {
+	m.mutex.Lock()
+	if m.started {
+		m.mutex.Unlock()
+		<-m.done
+		return m.report
+	}
+	m.started = true
+	hooks := make([]*hook, len(m.hooks))
+	copy(hooks, m.hooks)
+	m.mutex.Unlock()
+
+	sort.Sort(byOrder(hooks))
+	report := &Report{Reason: reason, Results: make([]HookResult, 0, len(hooks))}
+	for _, h := range hooks {
+		report.Results = append(report.Results, h.run())
+	}
+
+	m.mutex.Lock()
+	m.report = report
+	m.mutex.Unlock()
+	close(m.done)
+	return report
+}
+
+// Done returns a channel which is closed once shutdown has completed.
+func (m *Manager) Done() <-chan struct{} {
+	return m.done
+}
+
+// Report returns the report of a completed shutdown, or nil if shutdown has
+// not completed.
+func (m *Manager) Report() *Report {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.report
+}
+
+// HandleSignals triggers shutdown when one of the signals is received. If no
+// signals are provided then SIGINT and SIGTERM are used. The returned function
+// stops handling the signals.
+func (m *Manager) HandleSignals(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, sigs...)
+
+	quit := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-sigch:
+			m.Shutdown(fmt.Sprintf("received signal: %s", sig))
+		case <-quit:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigch)
+			close(quit)
+		})
+	}
+}
+
+// run runs the hook, bounded by its timeout.
+func (h *hook) run() HookResult {
+	ctx := context.Background()
+	var cancel context.CancelFunc
+	if h.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	res := HookResult{Name: h.name}
+	start := time.Now()
+	errch := make(chan error, 1)
+	go func() {
+		errch <- h.f(ctx)
+	}()
+
+	select {
+	case res.Err = <-errch:
+	case <-ctx.Done():
+		res.Err = ctx.Err()
+		res.TimedOut = true
+	}
+	res.Duration = time.Since(start)
+	return res
+}
+
+// byOrder sorts hooks by their order, then registration sequence.
+type byOrder []*hook
+
+func (s byOrder) Len() int      { return len(s) }
+func (s byOrder) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byOrder) Less(i, j int) bool {
+	if s[i].order != s[j].order {
+		return s[i].order < s[j].order
+	}
+	return s[i].seq < s[j].seq
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	m := NewManager()
+	var mutex sync.Mutex
+	var ran []string
+	record := func(name string) Hook {
+		return func(ctx context.Context) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			ran = append(ran, name)
+			return nil
+		}
+	}
+
+	m.Register("c", 2, time.Second, record("c"))
+	m.Register("a", 1, time.Second, record("a"))
+	m.Register("d", 2, time.Second, record("d"))
+	m.Register("b", 1, 0, record("b"))
+
+	report := m.Shutdown("test")
+	tt.TestEqual(t, ran, []string{"a", "b", "c", "d"})
+	tt.TestEqual(t, report.Reason, "test")
+	tt.TestEqual(t, len(report.Results), 4)
+	tt.TestEqual(t, len(report.TimedOut()), 0)
+	tt.TestEqual(t, len(report.Failed()), 0)
+
+	// later calls return the same report without running the hooks again
+	m.Register("e", 0, time.Second, record("e"))
+	tt.TestTrue(t, m.Shutdown("again") == report)
+	tt.TestTrue(t, m.Report() == report)
+	tt.TestEqual(t, len(ran), 4)
+
+	select {
+	case <-m.Done():
+	default:
+		tt.Fatalf(t, "Done was not closed")
+	}
+}
+
+func TestShutdownTimeoutsAndErrors(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	m := NewManager()
+	testErr := errors.New("failed")
+	m.Register("slow", 0, 10*time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	m.Register("error", 1, time.Second, func(ctx context.Context) error {
+		return testErr
+	})
+	m.Register("ok", 2, time.Second, func(ctx context.Context) error {
+		return nil
+	})
+
+	start := time.Now()
+	report := m.Shutdown("test")
+	tt.TestTrue(t, time.Since(start) < 500*time.Millisecond)
+	tt.TestEqual(t, report.TimedOut(), []string{"slow"})
+	tt.TestEqual(t, report.Failed(), []string{"slow", "error"})
+	tt.TestEqual(t, report.Results[0].Err, context.DeadlineExceeded)
+	tt.TestEqual(t, report.Results[1].Err, testErr)
+	tt.TestEqual(t, report.Results[2].Err, nil)
+}
+
+func TestHandleSignals(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	m := NewManager()
+	stop := m.HandleSignals(syscall.SIGUSR1)
+	defer stop()
+
+	ran := make(chan struct{})
+	m.Register("hook", 0, time.Second, func(ctx context.Context) error {
+		close(ran)
+		return nil
+	})
+
+	tt.TestExpectSuccess(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	select {
+	case <-m.Done():
+	case <-time.After(5 * time.Second):
+		tt.Fatalf(t, "Shutdown was not triggered by the signal")
+	}
+	<-ran
+	tt.TestEqual(t, m.Report().Reason, "received signal: user defined signal 1")
+}
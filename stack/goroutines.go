@@ -0,0 +1,226 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package stack
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Goroutine is a goroutine from a dump of all goroutines' stacks.
+type Goroutine struct {
+	ID int64 `json:"id"`
+
+	// State is what the goroutine is doing, such as "running" or
+	// "chan receive".
+	State string `json:"state"`
+
+	// Waiting is how long the goroutine has been blocked, which the
+	// runtime only reports in whole minutes.
+	Waiting time.Duration `json:"waiting,omitempty"`
+
+	LockedToThread bool `json:"locked_to_thread,omitempty"`
+
+	Frames []Frame `json:"frames"`
+
+	// CreatedBy is where the goroutine was started, which is nil for the
+	// main goroutine.
+	CreatedBy *Frame `json:"created_by,omitempty"`
+}
+
+// Goroutines returns the stacks of all goroutines.
+func Goroutines() []Goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return ParseGoroutines(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// ParseGoroutines parses a dump of goroutine stacks in the format written by
+// runtime.Stack and by the runtime when a program crashes. Lines which can't
+// be parsed are ignored.
+func ParseGoroutines(dump []byte) []Goroutine {
+	var goroutines []Goroutine
+	var g *Goroutine
+	var frame *Frame
+
+	scanner := bufio.NewScanner(bytes.NewReader(dump))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "goroutine "):
+			if g != nil {
+				goroutines = append(goroutines, *g)
+			}
+			g = parseGoroutineHeader(line)
+			frame = nil
+		case g == nil || line == "":
+		case strings.HasPrefix(line, "\t"):
+			// the file and line of the preceding function
+			if frame != nil {
+				frame.File, frame.Line = parseFileLine(strings.TrimPrefix(line, "\t"))
+				frame = nil
+			}
+		case strings.HasPrefix(line, "created by "):
+			fn := strings.TrimPrefix(line, "created by ")
+			if i := strings.Index(fn, " in goroutine "); i >= 0 {
+				fn = fn[:i]
+			}
+			g.CreatedBy = &Frame{Function: fn}
+			frame = g.CreatedBy
+		case strings.HasPrefix(line, "..."):
+			// elided frames
+		default:
+			fn := line
+			if i := strings.LastIndex(fn, "("); i > 0 && strings.HasSuffix(fn, ")") {
+				fn = fn[:i]
+			}
+			g.Frames = append(g.Frames, Frame{Function: fn})
+			frame = &g.Frames[len(g.Frames)-1]
+		}
+	}
+	if g != nil {
+		goroutines = append(goroutines, *g)
+	}
+	return goroutines
+}
+
+// parseGoroutineHeader parses a line such as
+// "goroutine 7 [chan receive, 3 minutes]:".
+func parseGoroutineHeader(line string) *Goroutine {
+	g := &Goroutine{}
+	fields := strings.Fields(line)
+	if len(fields) > 1 {
+		g.ID, _ = strconv.ParseInt(fields[1], 10, 64)
+	}
+	start := strings.Index(line, "[")
+	end := strings.LastIndex(line, "]")
+	if start < 0 || end < start {
+		return g
+	}
+	for i, part := range strings.Split(line[start+1:end], ", ") {
+		switch {
+		case i == 0:
+			g.State = part
+		case part == "locked to thread":
+			g.LockedToThread = true
+		case strings.HasSuffix(part, " minutes"):
+			if n, err := strconv.Atoi(strings.TrimSuffix(part, " minutes")); err == nil {
+				g.Waiting = time.Duration(n) * time.Minute
+			}
+		}
+	}
+	return g
+}
+
+// parseFileLine parses a line such as "/src/main.go:10 +0x1d".
+func parseFileLine(s string) (string, int) {
+	if i := strings.LastIndex(s, " +0x"); i >= 0 {
+		s = s[:i]
+	}
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return s, 0
+	}
+	line, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return s, 0
+	}
+	return s[:i], line
+}
+
+// FormatGoroutines formats the goroutines for logging, grouping goroutines
+// with the same state and stack so a dump of a busy daemon stays readable.
+func FormatGoroutines(goroutines []Goroutine) string {
+	type group struct {
+		g   Goroutine
+		ids []string
+	}
+	var groups []*group
+	byKey := make(map[string]*group)
+	for _, g := range goroutines {
+		key := g.State + "\n" + fmt.Sprint(g.Frames)
+		if g.CreatedBy != nil {
+			key += "\n" + g.CreatedBy.String()
+		}
+		grp := byKey[key]
+		if grp == nil {
+			grp = &group{g: g}
+			byKey[key] = grp
+			groups = append(groups, grp)
+		}
+		grp.ids = append(grp.ids, strconv.FormatInt(g.ID, 10))
+	}
+
+	var buf bytes.Buffer
+	for i, grp := range groups {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		if len(grp.ids) == 1 {
+			fmt.Fprintf(&buf, "goroutine %s [%s]:\n", grp.ids[0], grp.g.State)
+		} else {
+			fmt.Fprintf(&buf, "%d goroutines [%s]: %s\n",
+				len(grp.ids), grp.g.State, strings.Join(grp.ids, ", "))
+		}
+		for _, f := range grp.g.Frames {
+			fmt.Fprintf(&buf, "    %s\n", f)
+		}
+		if grp.g.CreatedBy != nil {
+			fmt.Fprintf(&buf, "    created by %s\n", grp.g.CreatedBy)
+		}
+	}
+	return buf.String()
+}
+
+// DumpOnSignal writes the formatted stacks of all goroutines to w each time
+// the process receives one of the signals, such as syscall.SIGUSR1, so a
+// long-running daemon can be inspected without being stopped. If no signals are
+// given, syscall.SIGUSR1 is used, as signal.Notify would otherwise relay every
+// signal, swallowing SIGINT and SIGTERM. On Windows, which has no SIGUSR1,
+// nothing is handled unless signals are given. The returned function stops
+// handling the signals.
+func DumpOnSignal(w io.Writer, sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = defaultDumpSignals
+	}
+	if len(sigs) == 0 {
+		return func() {}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				fmt.Fprintf(w, "goroutine dump on %s at %s:\n%s",
+					sig, time.Now().Format(time.RFC3339), FormatGoroutines(Goroutines()))
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package stack
+
+import (
+	"bytes"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+const testDump = `goroutine 1 [running]:
+main.main()
+	/src/main.go:10 +0x1d
+
+goroutine 7 [chan receive, 3 minutes, locked to thread]:
+github.com/apcera/util/stack.(*worker).run(0xc000010000, {0x1, 0x2})
+	/src/worker.go:42 +0x5a
+...additional frames elided...
+created by github.com/apcera/util/stack.start in goroutine 1
+	/src/worker.go:20 +0x65
+
+goroutine 8 [chan receive, 3 minutes, locked to thread]:
+github.com/apcera/util/stack.(*worker).run(0xc000010008, {0x1, 0x2})
+	/src/worker.go:42 +0x5a
+created by github.com/apcera/util/stack.start in goroutine 1
+	/src/worker.go:20 +0x65
+`
+
+func TestParseGoroutines(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	goroutines := ParseGoroutines([]byte(testDump))
+	tt.TestEqual(t, len(goroutines), 3)
+	tt.TestEqual(t, goroutines[0], Goroutine{
+		ID:     1,
+		State:  "running",
+		Frames: []Frame{{Function: "main.main", File: "/src/main.go", Line: 10}},
+	})
+	tt.TestEqual(t, goroutines[1], Goroutine{
+		ID:             7,
+		State:          "chan receive",
+		Waiting:        3 * time.Minute,
+		LockedToThread: true,
+		Frames: []Frame{{
+			Function: "github.com/apcera/util/stack.(*worker).run",
+			File:     "/src/worker.go",
+			Line:     42,
+		}},
+		CreatedBy: &Frame{
+			Function: "github.com/apcera/util/stack.start",
+			File:     "/src/worker.go",
+			Line:     20,
+		},
+	})
+
+	tt.TestEqual(t, FormatGoroutines(goroutines), `goroutine 1 [running]:
+    main.main (/src/main.go:10)
+
+2 goroutines [chan receive]: 7, 8
+    github.com/apcera/util/stack.(*worker).run (/src/worker.go:42)
+    created by github.com/apcera/util/stack.start (/src/worker.go:20)
+`)
+
+	tt.TestEqual(t, len(ParseGoroutines([]byte("garbage\n\tmore garbage\n"))), 0)
+}
+
+func TestGoroutines(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	block := make(chan struct{})
+	defer close(block)
+	go func() { <-block }()
+
+	var current bool
+	for _, g := range Goroutines() {
+		tt.TestTrue(t, g.ID > 0)
+		for _, f := range g.Frames {
+			if f.Function == "github.com/apcera/util/stack.TestGoroutines" && g.State == "running" {
+				current = true
+				tt.TestTrue(t, strings.HasSuffix(f.File, "goroutines_test.go"))
+				tt.TestTrue(t, f.Line > 0)
+			}
+		}
+	}
+	tt.TestTrue(t, current)
+
+	// the blocked goroutine may not have reached the receive yet
+	tt.Timeout(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		for _, g := range Goroutines() {
+			if g.State == "chan receive" && g.CreatedBy != nil &&
+				g.CreatedBy.Function == "github.com/apcera/util/stack.TestGoroutines" {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func TestDumpOnSignal(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	var buf syncBuffer
+	stop := DumpOnSignal(&buf, syscall.SIGUSR1)
+	defer stop()
+
+	tt.TestExpectSuccess(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	tt.Timeout(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		return strings.Contains(buf.String(), "stack.TestDumpOnSignal")
+	})
+	tt.TestHasPrefix(t, buf.String(), "goroutine dump on user defined signal 1 at ")
+	stop()
+}
+
+func TestDumpOnSignalDefault(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	var buf syncBuffer
+	stop := DumpOnSignal(&buf)
+	defer stop()
+
+	// other signals are left alone, so SIGINT and SIGTERM still stop the
+	// process
+	other := make(chan os.Signal, 1)
+	signal.Notify(other, syscall.SIGUSR2)
+	defer signal.Stop(other)
+	tt.TestExpectSuccess(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	select {
+	case <-other:
+	case <-time.After(5 * time.Second):
+		tt.Fatalf(t, "SIGUSR2 was not received")
+	}
+	tt.TestEqual(t, buf.String(), "")
+
+	tt.TestExpectSuccess(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	tt.Timeout(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		return strings.Contains(buf.String(), "stack.TestDumpOnSignalDefault")
+	})
+	tt.TestHasPrefix(t, buf.String(), "goroutine dump on user defined signal 1 at ")
+}
@@ -0,0 +1,13 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// +build !windows
+
+package stack
+
+import (
+	"os"
+	"syscall"
+)
+
+// defaultDumpSignals are the signals DumpOnSignal handles if none are given.
+var defaultDumpSignals = []os.Signal{syscall.SIGUSR1}
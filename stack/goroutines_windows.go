@@ -0,0 +1,13 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// +build windows
+
+package stack
+
+import (
+	"os"
+)
+
+// defaultDumpSignals are the signals DumpOnSignal handles if none are given.
+// Windows has no signal suited to it.
+var defaultDumpSignals []os.Signal
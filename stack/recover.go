@@ -0,0 +1,87 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package stack
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// PanicError is the error passed to a recovery handler when the panic value
+// was not itself an error.
+type PanicError struct {
+	Value interface{}
+}
+
+// Error implements the error interface.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Recover recovers from a panic in the current goroutine and passes the panic,
+// converted to an error, along with the stack at the point of the panic to the
+// handler. It must be called directly by defer:
+//
+//	defer stack.Recover(func(err error, frames []stack.Frame) {
+//		log.Printf("recovered: %s", err)
+//	})
+//
+// If there is no panic then the handler is not called. A nil handler simply
+// swallows the panic.
+func Recover(handler func(err error, stack []Frame)) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if handler != nil {
+		handler(panicToError(r), panicStack())
+	}
+}
+
+// Handler wraps the provided http.Handler so that any panic while serving a
+// request is recovered, passed to the recovery handler, and a 500 Internal
+// Server Error is returned to the client. The recovery handler may be nil.
+//
+// A panic with http.ErrAbortHandler is not recovered, so the server can abort
+// the response as intended.
+func Handler(h http.Handler, handler func(err error, stack []Frame)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+			if handler != nil {
+				handler(panicToError(r), panicStack())
+			}
+			http.Error(w, http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError)
+		}()
+		h.ServeHTTP(w, req)
+	})
+}
+
+// panicToError converts a recovered value into an error.
+func panicToError(r interface{}) error {
+	if err, ok := r.(error); ok {
+		return err
+	}
+	return &PanicError{Value: r}
+}
+
+// panicStack returns the stack of a panicking goroutine starting at the frame
+// which panicked. It must be called from within the deferred function.
+func panicStack() []Frame {
+	frames := CaptureStack(1)
+	// Trim everything up to and including the runtime's panic handling so
+	// the first frame is where the panic happened.
+	for i, f := range frames {
+		if f.Function == "runtime.gopanic" {
+			return frames[i+1:]
+		}
+	}
+	return frames
+}
@@ -0,0 +1,61 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// Package stack provides structured access to goroutine stacks and helpers for
+// recovering from panics.
+package stack
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// maxDepth is the maximum number of frames captured.
+const maxDepth = 256
+
+// Frame is a single frame within a stack.
+type Frame struct {
+	// Function is the fully qualified name of the function, such as
+	// github.com/apcera/util/stack.CaptureStack.
+	Function string `json:"function"`
+
+	// File is the full path to the source file.
+	File string `json:"file"`
+
+	// Line is the line number within the source file.
+	Line int `json:"line"`
+}
+
+// String returns the frame in the form "function (file:line)".
+func (f Frame) String() string {
+	return fmt.Sprintf("%s (%s:%d)", f.Function, f.File, f.Line)
+}
+
+// CaptureStack returns the frames of the calling goroutine's stack. The skip
+// argument is the number of frames to skip, with 0 identifying the caller of
+// CaptureStack.
+func CaptureStack(skip int) []Frame {
+	pc := make([]uintptr, maxDepth)
+	n := runtime.Callers(skip+2, pc)
+	return framesFromPCs(pc[:n])
+}
+
+// framesFromPCs resolves the program counters into frames.
+func framesFromPCs(pc []uintptr) []Frame {
+	frames := make([]Frame, 0, len(pc))
+	if len(pc) == 0 {
+		return frames
+	}
+	iter := runtime.CallersFrames(pc)
+	for {
+		f, more := iter.Next()
+		frames = append(frames, Frame{
+			Function: f.Function,
+			File:     f.File,
+			Line:     f.Line,
+		})
+		if !more {
+			break
+		}
+	}
+	return frames
+}
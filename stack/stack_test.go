@@ -0,0 +1,104 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package stack
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestCaptureStack(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	frames := CaptureStack(0)
+	tt.TestTrue(t, len(frames) > 0)
+	tt.TestEqual(t, frames[0].Function, "github.com/apcera/util/stack.TestCaptureStack")
+	tt.TestTrue(t, strings.HasSuffix(frames[0].File, "stack_test.go"))
+	tt.TestTrue(t, frames[0].Line > 0)
+
+	// skipping a frame should start at the caller
+	frames = func() []Frame { return CaptureStack(1) }()
+	tt.TestEqual(t, frames[0].Function, "github.com/apcera/util/stack.TestCaptureStack")
+}
+
+func panicker(v interface{}) {
+	panic(v)
+}
+
+func TestRecover(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	var recovered error
+	var frames []Frame
+	handler := func(err error, stack []Frame) {
+		recovered = err
+		frames = stack
+	}
+
+	// an error value is passed through as is
+	testErr := errors.New("test error")
+	func() {
+		defer Recover(handler)
+		panicker(testErr)
+	}()
+	tt.TestEqual(t, recovered, testErr)
+	tt.TestTrue(t, len(frames) > 0)
+	tt.TestEqual(t, frames[0].Function, "github.com/apcera/util/stack.panicker")
+
+	// other values are wrapped
+	func() {
+		defer Recover(handler)
+		panicker("boom")
+	}()
+	tt.TestEqual(t, recovered.Error(), "panic: boom")
+	pe, ok := recovered.(*PanicError)
+	tt.TestTrue(t, ok)
+	tt.TestEqual(t, pe.Value, "boom")
+
+	// no panic, no call
+	recovered = nil
+	func() {
+		defer Recover(handler)
+	}()
+	tt.TestEqual(t, recovered, nil)
+
+	// a nil handler swallows the panic
+	func() {
+		defer Recover(nil)
+		panicker("boom")
+	}()
+}
+
+func TestHandler(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	var recovered error
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/panic" {
+			panicker("boom")
+		}
+		w.Write([]byte("ok"))
+	}), func(err error, stack []Frame) {
+		recovered = err
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	tt.TestEqual(t, w.Code, http.StatusOK)
+	tt.TestEqual(t, w.Body.String(), "ok")
+	tt.TestEqual(t, recovered, nil)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+	tt.TestEqual(t, w.Code, http.StatusInternalServerError)
+	tt.TestExpectError(t, recovered)
+	tt.TestEqual(t, recovered.Error(), "panic: boom")
+}
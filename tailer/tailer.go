@@ -0,0 +1,242 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// Package tailer follows a file as it grows, like tail -F. The file is
+// reopened when it is rotated, reread from the start when it is truncated, and
+// waited for when it doesn't exist. On Linux, inotify is used to notice changes
+// promptly, falling back to polling when it isn't available.
+package tailer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultPollInterval is used when Tailer.PollInterval is not set.
+const DefaultPollInterval = 250 * time.Millisecond
+
+// Tailer follows a single file. Its fields should be set before calling Lines
+// or Bytes, and only one of those may be called, once.
+type Tailer struct {
+	// PollInterval is how often the file is checked for changes. When
+	// inotify is used, it bounds how long a missed notification can delay
+	// new data.
+	PollInterval time.Duration
+
+	// FromStart can be set to true to output the existing contents of the
+	// file. By default only data written after the file is first opened is
+	// output. Files which replace it after a rotation, or which didn't exist
+	// when the tailer started, are always read from the start.
+	FromStart bool
+
+	path string
+
+	mutex sync.Mutex
+	err   error
+}
+
+// watcher notifies of changes within a directory.
+type watcher struct {
+	file   io.Closer
+	events chan struct{}
+}
+
+// New returns a Tailer for the file at path.
+func New(path string) *Tailer {
+	return &Tailer{path: path}
+}
+
+// Bytes follows the file, sending the data written to it as it is read. The
+// channel is closed when the context is done or when an error occurs, which
+// is then returned by Err.
+func (t *Tailer) Bytes(ctx context.Context) <-chan []byte {
+	ch := make(chan []byte)
+	go func() {
+		defer close(ch)
+		t.setErr(t.follow(ctx, func(data []byte) bool {
+			select {
+			case ch <- data:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}, nil))
+	}()
+	return ch
+}
+
+// Lines follows the file, sending each line written to it without the trailing
+// newline. A final line without a newline is sent once the file is rotated.
+// The channel is closed when the context is done or when an error occurs,
+// which is then returned by Err.
+func (t *Tailer) Lines(ctx context.Context) <-chan string {
+	ch := make(chan string)
+	send := func(line []byte) bool {
+		select {
+		case ch <- string(line):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		defer close(ch)
+		var partial []byte
+		t.setErr(t.follow(ctx, func(data []byte) bool {
+			partial = append(partial, data...)
+			for {
+				i := bytes.IndexByte(partial, '\n')
+				if i < 0 {
+					return true
+				}
+				if !send(partial[:i]) {
+					return false
+				}
+				partial = partial[i+1:]
+			}
+		}, func() bool {
+			if len(partial) == 0 {
+				return true
+			}
+			line := partial
+			partial = nil
+			return send(line)
+		}))
+	}()
+	return ch
+}
+
+// Err returns the error which stopped the tailer, or nil if it was stopped by
+// its context.
+func (t *Tailer) Err() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.err
+}
+
+func (t *Tailer) setErr(err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.err = err
+}
+
+// follow passes the data written to the file to data, and calls rotated, if
+// set, when switching to a new file. Either returning false stops following.
+func (t *Tailer) follow(ctx context.Context, data func([]byte) bool, rotated func() bool) error {
+	// without a watcher the file is only polled
+	var events chan struct{}
+	if w, err := newWatcher(filepath.Dir(t.path)); err == nil {
+		defer w.file.Close()
+		events = w.events
+	}
+
+	interval := t.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	wait := func() bool {
+		timer := time.NewTimer(interval)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return false
+		case <-events:
+		case <-timer.C:
+		}
+		return true
+	}
+
+	buf := make([]byte, 32*1024)
+	fromStart := t.FromStart
+	for {
+		f, err := os.Open(t.path)
+		if os.IsNotExist(err) {
+			// wait for the file to be created
+			fromStart = true
+			if !wait() {
+				return nil
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		stop, err := t.followFile(f, fromStart, buf, data, wait)
+		f.Close()
+		if err != nil || stop {
+			return err
+		}
+		if rotated != nil && !rotated() {
+			return nil
+		}
+		fromStart = true
+	}
+}
+
+// followFile follows an open file until it is replaced or removed, returning
+// true if following should stop.
+func (t *Tailer) followFile(f *os.File, fromStart bool, buf []byte, data func([]byte) bool, wait func() bool) (bool, error) {
+	var offset int64
+	if !fromStart {
+		var err error
+		if offset, err = f.Seek(0, io.SeekEnd); err != nil {
+			return true, err
+		}
+	}
+
+	// readAvailable reads until the end of the file
+	readAvailable := func() (bool, error) {
+		for {
+			n, err := f.Read(buf)
+			if n > 0 {
+				offset += int64(n)
+				if !data(append([]byte(nil), buf[:n]...)) {
+					return true, nil
+				}
+			}
+			if err == io.EOF {
+				return false, nil
+			} else if err != nil {
+				return true, err
+			}
+		}
+	}
+
+	for {
+		if stop, err := readAvailable(); stop || err != nil {
+			return stop, err
+		}
+
+		fi, err := f.Stat()
+		if err != nil {
+			return true, err
+		}
+		if fi.Size() < offset {
+			// truncated, start again from the beginning
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return true, err
+			}
+			offset = 0
+			continue
+		}
+
+		current, err := os.Stat(t.path)
+		if err != nil && !os.IsNotExist(err) {
+			return true, err
+		}
+		if err != nil || !os.SameFile(fi, current) {
+			// rotated or removed, read anything written since before moving
+			// on to the replacement
+			return readAvailable()
+		}
+
+		if !wait() {
+			return true, nil
+		}
+	}
+}
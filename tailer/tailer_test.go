@@ -0,0 +1,131 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package tailer
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func appendFile(t *testing.T, name, data string) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	tt.TestExpectSuccess(t, err)
+	_, err = f.WriteString(data)
+	tt.TestExpectSuccess(t, err)
+	tt.TestExpectSuccess(t, f.Close())
+}
+
+func expectLines(t *testing.T, lines <-chan string, want ...string) {
+	for _, w := range want {
+		select {
+		case line := <-lines:
+			tt.TestEqual(t, line, w)
+		case <-time.After(5 * time.Second):
+			tt.Fatalf(t, "timed out waiting for line %q", w)
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	name := filepath.Join(testHelper.TempDir(), "log")
+	appendFile(t, name, "existing\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tailer := New(name)
+	tailer.PollInterval = 10 * time.Millisecond
+	lines := tailer.Lines(ctx)
+
+	// existing contents are skipped, and partial lines are buffered
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, name, "one\ntw")
+	appendFile(t, name, "o\n")
+	expectLines(t, lines, "one", "two")
+
+	// truncation starts again from the beginning
+	tt.TestExpectSuccess(t, ioutil.WriteFile(name, []byte("a\n"), 0644))
+	expectLines(t, lines, "a")
+
+	// rotation flushes the partial line and follows the new file
+	appendFile(t, name, "partial")
+	time.Sleep(50 * time.Millisecond)
+	tt.TestExpectSuccess(t, os.Rename(name, name+".1"))
+	appendFile(t, name, "new\n")
+	expectLines(t, lines, "partial", "new")
+
+	// removal waits for the file to be created again
+	tt.TestExpectSuccess(t, os.Remove(name))
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, name, "again\n")
+	expectLines(t, lines, "again")
+
+	// cancelling closes the channel without an error
+	cancel()
+	tt.Timeout(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		_, ok := <-lines
+		return !ok
+	})
+	tt.TestExpectSuccess(t, tailer.Err())
+}
+
+func TestBytesFromStart(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	name := filepath.Join(testHelper.TempDir(), "log")
+	appendFile(t, name, "existing")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tailer := New(name)
+	tailer.PollInterval = 10 * time.Millisecond
+	tailer.FromStart = true
+	chunks := tailer.Bytes(ctx)
+
+	var data []byte
+	tt.Timeout(t, 5*time.Second, time.Millisecond, func() bool {
+		select {
+		case chunk := <-chunks:
+			data = append(data, chunk...)
+		default:
+		}
+		return string(data) == "existing"
+	})
+
+	appendFile(t, name, " more")
+	tt.Timeout(t, 5*time.Second, time.Millisecond, func() bool {
+		select {
+		case chunk := <-chunks:
+			data = append(data, chunk...)
+		default:
+		}
+		return string(data) == "existing more"
+	})
+}
+
+func TestMissingFile(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	name := filepath.Join(testHelper.TempDir(), "log")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tailer := New(name)
+	tailer.PollInterval = 10 * time.Millisecond
+	lines := tailer.Lines(ctx)
+
+	// a file which didn't exist is read from the start
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, name, "first\n")
+	expectLines(t, lines, "first")
+}
@@ -0,0 +1,45 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// +build linux
+
+package tailer
+
+import (
+	"os"
+	"syscall"
+)
+
+const watchMask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CREATE |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// newWatcher returns a watcher using inotify to watch the directory. The
+// events aren't decoded, since any change just causes the file to be checked.
+func newWatcher(dir string) (*watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := syscall.InotifyAddWatch(fd, dir, watchMask); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	// the descriptor is non-blocking, so closing the file interrupts a
+	// pending read
+	file := os.NewFile(uintptr(fd), "inotify")
+	w := &watcher{file: file, events: make(chan struct{}, 1)}
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			if _, err := file.Read(buf); err != nil {
+				return
+			}
+			select {
+			case w.events <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return w, nil
+}
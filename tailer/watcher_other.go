@@ -0,0 +1,14 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// +build !linux
+
+package tailer
+
+import (
+	"fmt"
+)
+
+// newWatcher is not supported outside of Linux, so files are polled.
+func newWatcher(dir string) (*watcher, error) {
+	return nil, fmt.Errorf("watching files is not supported on this platform")
+}
@@ -0,0 +1,65 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package tarhelper
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// ChecksumAlgorithm is a hash algorithm used to checksum file contents. Only
+// SHA-2 algorithms are supported, so checksums can be used in deployments
+// which don't allow MD5 or SHA-1.
+type ChecksumAlgorithm string
+
+const (
+	SHA256 = ChecksumAlgorithm("sha256")
+	SHA512 = ChecksumAlgorithm("sha512")
+
+	// DefaultChecksumAlgorithm is used when no algorithm is set.
+	DefaultChecksumAlgorithm = SHA256
+)
+
+// New returns a new hash for the algorithm. The zero value uses the
+// DefaultChecksumAlgorithm.
+func (a ChecksumAlgorithm) New() (hash.Hash, error) {
+	switch a {
+	case "", SHA256:
+		return sha256.New(), nil
+	case SHA512:
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum algorithm: %q", string(a))
+}
+
+// Digest formats a checksum computed with the algorithm as a digest of the
+// form "<algorithm>:<hex>", such as "sha256:e3b0c442...".
+func (a ChecksumAlgorithm) Digest(sum []byte) string {
+	if a == "" {
+		a = DefaultChecksumAlgorithm
+	}
+	return string(a) + ":" + hex.EncodeToString(sum)
+}
+
+// ParseDigest splits a digest formatted by ChecksumAlgorithm.Digest into its
+// algorithm and checksum.
+func ParseDigest(digest string) (ChecksumAlgorithm, []byte, error) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 {
+		return "", nil, fmt.Errorf("invalid digest %q: missing algorithm", digest)
+	}
+	a := ChecksumAlgorithm(parts[0])
+	h, err := a.New()
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid digest %q: %v", digest, err)
+	}
+	sum, err := hex.DecodeString(parts[1])
+	if err != nil || len(sum) != h.Size() {
+		return "", nil, fmt.Errorf("invalid digest %q: malformed checksum", digest)
+	}
+	return a, sum, nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package tarhelper
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// DiffTar produces an incremental archive of the changes from an old directory
+// to a new one. Entries which were added or changed in the new directory are
+// archived, and entries removed from it are written as AUFS style whiteouts,
+// so that extracting the archive over the old directory with Untar.Whiteouts
+// set produces the new directory. This is the form used by layered images.
+type DiffTar struct {
+	// Tar archives the new directory, and its options, such as Compression
+	// and IncludeOwners, apply to the diff.
+	*Tar
+
+	// The directory being compared against.
+	old string
+
+	// CompareContent can be set to true to compare the contents of regular
+	// files with the same size and modification time, rather than assuming
+	// they are unchanged.
+	CompareContent bool
+}
+
+// NewDiffTar returns a DiffTar writing the changes from oldDir to newDir to w.
+func NewDiffTar(w io.Writer, oldDir, newDir string) *DiffTar {
+	return &DiffTar{
+		Tar: NewTar(w, newDir),
+		old: oldDir,
+	}
+}
+
+// Archive writes the diff.
+func (d *DiffTar) Archive() error {
+	removed, err := d.removed()
+	if err != nil {
+		return err
+	}
+
+	whiteouts := d.Tar.Whiteouts
+	defer func() {
+		d.Tar.Whiteouts = whiteouts
+		d.Tar.unchanged = nil
+	}()
+	d.Tar.Whiteouts = append(append([]string(nil), whiteouts...), removed...)
+	d.Tar.unchanged = d.unchanged
+	return d.Tar.Archive()
+}
+
+// removed returns the entries within the old directory which no longer exist
+// within the new one. Only the top most directory is returned for a directory
+// which has been removed.
+func (d *DiffTar) removed() ([]string, error) {
+	var removed []string
+	walk := func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(d.old, p)
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			return nil
+		}
+		if d.shouldBeExcluded(name, fi.IsDir()) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		nfi, err := os.Lstat(filepath.Join(d.target, name))
+		switch {
+		case os.IsNotExist(err):
+			removed = append(removed, name)
+		case err != nil:
+			return err
+		case nfi.IsDir():
+			// walk the directory for removals within it
+			return nil
+		}
+
+		// the directory was removed or replaced, which is handled when the
+		// replacement is archived
+		if fi.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}
+	if err := filepath.Walk(d.old, walk); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// unchanged reports whether the entry within the new directory is the same as
+// within the old one. A directory which was replaced by another type of entry
+// has a whiteout written for it first, so it is removed before the replacement
+// is extracted.
+func (d *DiffTar) unchanged(fullName string, f os.FileInfo) (bool, error) {
+	oldName := filepath.Join(d.old, fullName)
+	ofi, err := os.Lstat(oldName)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if ofi.IsDir() && !f.IsDir() {
+		return false, d.Tar.writeWhiteout(whiteoutName(fullName))
+	}
+
+	if ofi.Mode() != f.Mode() ||
+		uidForFileInfo(ofi) != uidForFileInfo(f) ||
+		gidForFileInfo(ofi) != gidForFileInfo(f) {
+		return false, nil
+	}
+
+	newName := filepath.Join(d.target, fullName)
+	switch mode := f.Mode(); {
+	case mode.IsDir():
+		return ofi.ModTime().Equal(f.ModTime()), nil
+
+	case mode&os.ModeSymlink != 0:
+		oldLink, err := os.Readlink(oldName)
+		if err != nil {
+			return false, err
+		}
+		newLink, err := os.Readlink(newName)
+		if err != nil {
+			return false, err
+		}
+		return oldLink == newLink, nil
+
+	case mode&(os.ModeDevice|os.ModeCharDevice) != 0:
+		oldMajor, oldMinor := osDeviceNumbersForFileInfo(ofi)
+		newMajor, newMinor := osDeviceNumbersForFileInfo(f)
+		return oldMajor == newMajor && oldMinor == newMinor, nil
+
+	case mode.IsRegular():
+		if ofi.Size() != f.Size() {
+			return false, nil
+		}
+		if !d.CompareContent {
+			return ofi.ModTime().Equal(f.ModTime()), nil
+		}
+		return sameContents(oldName, newName)
+	}
+
+	return ofi.ModTime().Equal(f.ModTime()), nil
+}
+
+// sameContents compares the contents of two files of the same size.
+func sameContents(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufa := make([]byte, 32*1024)
+	bufb := make([]byte, 32*1024)
+	for {
+		na, erra := io.ReadFull(fa, bufa)
+		nb, errb := io.ReadFull(fb, bufb)
+		if na != nb || !bytes.Equal(bufa[:na], bufb[:nb]) {
+			return false, nil
+		}
+		if erra == io.EOF || erra == io.ErrUnexpectedEOF {
+			return errb == io.EOF || errb == io.ErrUnexpectedEOF, nil
+		} else if erra != nil {
+			return false, erra
+		} else if errb != nil {
+			return false, errb
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package tarhelper
+
+import (
+	"fmt"
+	"os"
+)
+
+// deferredLink is a hard link waiting for its target to be extracted.
+type deferredLink struct {
+	// name is the path of the link and link the path of its target.
+	name string
+	link string
+
+	// header is the name of the entry within the archive.
+	header string
+}
+
+// resolveDeferredLinks creates the hard links deferred during extraction. Links
+// may target other deferred links, so the remaining links are retried for as
+// long as at least one of them could be created.
+func (u *Untar) resolveDeferredLinks() error {
+	pending := u.deferredLinks
+	u.deferredLinks = nil
+
+	for len(pending) > 0 {
+		var remaining []deferredLink
+		for _, dl := range pending {
+			if _, err := os.Lstat(dl.link); os.IsNotExist(err) {
+				remaining = append(remaining, dl)
+				continue
+			}
+			// the path was cleared when the link was deferred, so anything
+			// there now came from a later entry, which takes precedence
+			if _, err := os.Lstat(dl.name); err == nil {
+				continue
+			}
+			if err := os.Link(dl.link, dl.name); err != nil {
+				return err
+			}
+		}
+		if len(remaining) == len(pending) {
+			dl := remaining[0]
+			return fmt.Errorf("hard link target for %q does not exist: %s", dl.header, dl.link)
+		}
+		pending = remaining
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package tarhelper
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"runtime"
+	"sync"
+)
+
+const (
+	// parallelGzipBlockSize is the amount of input compressed by each worker.
+	parallelGzipBlockSize = 1 << 20
+
+	// parallelGzipDictSize is the amount of the previous block used to prime
+	// the compression of the next, matching the deflate window size.
+	parallelGzipDictSize = 32 << 10
+)
+
+// parallelGzipWriter is an io.WriteCloser producing a single gzip stream, which
+// is compatible with any gzip reader, while compressing blocks of the input
+// concurrently. Each block is deflated independently using the tail of the
+// previous block as its dictionary and ends with a sync flush, so the deflated
+// blocks can be written out in order one after another.
+type parallelGzipWriter struct {
+	dest      io.Writer
+	level     int
+	blockSize int
+
+	block []byte
+	dict  []byte
+	crc   uint32
+	size  uint32
+
+	// queue holds the results of in-flight blocks in the order they must be
+	// written. Its capacity bounds the number of blocks in flight.
+	queue chan chan []byte
+	done  chan struct{}
+
+	mu     sync.Mutex
+	err    error
+	closed bool
+}
+
+// newParallelGzipWriter returns a parallelGzipWriter writing to dest with the
+// given gzip compression level, using up to GOMAXPROCS workers.
+func newParallelGzipWriter(dest io.Writer, level int) (*parallelGzipWriter, error) {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return nil, fmt.Errorf("invalid gzip compression level: %d", level)
+	}
+	w := &parallelGzipWriter{
+		dest:      dest,
+		level:     level,
+		blockSize: parallelGzipBlockSize,
+		queue:     make(chan chan []byte, runtime.GOMAXPROCS(0)),
+		done:      make(chan struct{}),
+	}
+	go w.writeLoop()
+	return w, nil
+}
+
+// Write buffers p, handing off each full block to be compressed.
+func (w *parallelGzipWriter) Write(p []byte) (int, error) {
+	if err := w.error(); err != nil {
+		return 0, err
+	}
+	if w.closed {
+		return 0, fmt.Errorf("write to closed gzip writer")
+	}
+
+	w.crc = crc32.Update(w.crc, crc32.IEEETable, p)
+	w.size += uint32(len(p))
+
+	n := len(p)
+	for len(p) > 0 {
+		if w.block == nil {
+			w.block = make([]byte, 0, w.blockSize)
+		}
+		c := copy(w.block[len(w.block):cap(w.block)], p)
+		w.block = w.block[:len(w.block)+c]
+		p = p[c:]
+		if len(w.block) == cap(w.block) {
+			w.dispatch(false)
+		}
+	}
+	return n, nil
+}
+
+// Close compresses any remaining input and writes the gzip trailer once every
+// block has been written. It does not close the underlying writer.
+func (w *parallelGzipWriter) Close() error {
+	if w.closed {
+		return w.error()
+	}
+	w.closed = true
+	w.dispatch(true)
+	close(w.queue)
+	<-w.done
+
+	if err := w.error(); err != nil {
+		return err
+	}
+	var trailer [8]byte
+	binary.LittleEndian.PutUint32(trailer[0:4], w.crc)
+	binary.LittleEndian.PutUint32(trailer[4:8], w.size)
+	_, err := w.dest.Write(trailer[:])
+	return err
+}
+
+// dispatch starts compressing the current block.
+func (w *parallelGzipWriter) dispatch(final bool) {
+	block, dict := w.block, w.dict
+	if len(block) >= parallelGzipDictSize {
+		w.dict = block[len(block)-parallelGzipDictSize:]
+	} else {
+		w.dict = append(append([]byte(nil), dict...), block...)
+		if len(w.dict) > parallelGzipDictSize {
+			w.dict = w.dict[len(w.dict)-parallelGzipDictSize:]
+		}
+	}
+	w.block = nil
+
+	result := make(chan []byte, 1)
+	w.queue <- result
+	go func() {
+		var buf bytes.Buffer
+		// the level was validated in newParallelGzipWriter
+		fw, _ := flate.NewWriterDict(&buf, w.level, dict)
+		fw.Write(block)
+		if final {
+			fw.Close()
+		} else {
+			fw.Flush()
+		}
+		result <- buf.Bytes()
+	}()
+}
+
+// writeLoop writes the gzip header followed by each compressed block in order.
+func (w *parallelGzipWriter) writeLoop() {
+	defer close(w.done)
+
+	// magic, deflate, no flags, no modification time, no extra flags, and an
+	// unknown OS, matching compress/gzip's defaults
+	header := []byte{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 255}
+	_, err := w.dest.Write(header)
+	w.setError(err)
+
+	for result := range w.queue {
+		data := <-result
+		if w.error() == nil {
+			_, err := w.dest.Write(data)
+			w.setError(err)
+		}
+	}
+}
+
+func (w *parallelGzipWriter) error() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *parallelGzipWriter) setError(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package tarhelper
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// extractState is the progress of an extraction recorded in Untar.StateFile.
+type extractState struct {
+	// Entries is the number of entries from the start of the archive which
+	// have been completed.
+	Entries int `json:"entries"`
+
+	// Last is the name of the last completed entry, used to detect when the
+	// state file is being used with a different archive.
+	Last string `json:"last"`
+}
+
+// loadState reads the StateFile, if one is set and exists.
+func (u *Untar) loadState() (*extractState, error) {
+	state := &extractState{}
+	if u.StateFile == "" {
+		return state, nil
+	}
+	data, err := ioutil.ReadFile(u.StateFile)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		// A corrupt state file just means starting over.
+		return &extractState{}, nil
+	}
+	return state, nil
+}
+
+// The progress of an extraction is saved to the StateFile after this many
+// entries, or this many bytes of file data, have been completed since it was
+// last saved. Entries completed since then are verified again when resuming.
+var (
+	stateSaveEntries       = 100
+	stateSaveBytes   int64 = 64 << 20
+)
+
+// saveState atomically writes the state to the StateFile, if one is set. The
+// file and its directory are synced, so the state survives a crash.
+func (u *Untar) saveState(state *extractState) error {
+	if u.StateFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := u.StateFile + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(0644))
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, u.StateFile); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(u.StateFile))
+}
+
+// syncDir syncs the directory, making the renames within it durable.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		// directories can't be synced on windows
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// removeState removes the StateFile once extraction has completed.
+func (u *Untar) removeState() error {
+	if u.StateFile == "" {
+		return nil
+	}
+	if err := os.Remove(u.StateFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// verifyCompleted checks whether an entry completed by a previous extraction
+// still exists on disk as expected. It returns false if the entry should be
+// extracted again. If mismatched is true the entry is known not to match the
+// state and is never considered complete.
+//
+// When VerifyResumeChecksums is set the entry's data is read from the archive
+// to be compared, so it can't be extracted again. Instead a file whose contents
+// differ is repaired in place, and repaired is returned as true. Any error once
+// the entry's data has started to be read is returned, since the entry can no
+// longer be extracted.
+func (u *Untar) verifyCompleted(header *tar.Header, mismatched bool) (complete, repaired bool, err error) {
+	if mismatched {
+		return false, false, nil
+	}
+
+	// entries which were skipped don't exist on disk
+	if checkName(header.Name) != nil || !u.checkEntryAgainstWhitelist(header) {
+		return true, false, nil
+	}
+	if include, err := u.checkEntryAgainstFilters(header); err != nil {
+		return false, false, nil
+	} else if !include {
+		return true, false, nil
+	}
+	switch header.Typeflag {
+	case tar.TypeBlock, tar.TypeChar, tar.TypeFifo:
+		if u.SkipSpecialDevices {
+			return true, false, nil
+		}
+	}
+
+	name, err := u.destination(header)
+	if err != nil {
+		return false, false, nil
+	}
+	fi, err := os.Lstat(name)
+	if err != nil {
+		return false, false, nil
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		return fi.IsDir(), false, nil
+	case tar.TypeSymlink:
+		return fi.Mode()&os.ModeSymlink != 0, false, nil
+	case tar.TypeReg, tar.TypeRegA:
+		if !fi.Mode().IsRegular() || fi.Size() != header.Size {
+			return false, false, nil
+		}
+		if !u.VerifyResumeChecksums {
+			return true, false, nil
+		}
+		f, err := os.OpenFile(name, os.O_RDWR, 0)
+		if err != nil {
+			return false, false, nil
+		}
+		repaired, err = repairContents(f, u.archive)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err == nil, repaired, err
+	default:
+		return true, false, nil
+	}
+}
+
+// repairContents compares the file against the reader block by block. If they
+// differ, the rest of the reader is written over the file starting at the first
+// block which differs. It returns whether the file was modified.
+func repairContents(f *os.File, r io.Reader) (bool, error) {
+	fbuf := make([]byte, 32*1024)
+	rbuf := make([]byte, len(fbuf))
+	var offset int64
+	for {
+		n, err := io.ReadFull(r, rbuf)
+		if err == io.EOF {
+			return false, nil
+		} else if err != nil && err != io.ErrUnexpectedEOF {
+			return false, err
+		}
+
+		if _, ferr := io.ReadFull(f, fbuf[:n]); ferr != nil || !bytes.Equal(fbuf[:n], rbuf[:n]) {
+			// rewrite from the block which differed
+			if _, err := f.Seek(offset, os.SEEK_SET); err != nil {
+				return true, err
+			}
+			_, err := io.Copy(f, io.MultiReader(bytes.NewReader(rbuf[:n]), r))
+			return true, err
+		}
+
+		offset += int64(n)
+		if err == io.ErrUnexpectedEOF {
+			return false, nil
+		}
+	}
+}
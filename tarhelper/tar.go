@@ -6,6 +6,7 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // User options enumeration type. This encodes the control options provided
@@ -54,6 +56,10 @@ type Tar struct {
 	// The destination writer
 	dest io.Writer
 
+	// Additional writers which receive a copy of the archive, added by
+	// AddWriter and AddDigestWriter.
+	writers []io.Writer
+
 	// The archive/tar reader that we will use to extract each
 	// element from the tar file. This will be set when Extract()
 	// is called.
@@ -62,6 +68,17 @@ type Tar struct {
 	// The Compression being used in this tar.
 	Compression Compression
 
+	// CompressionLevel is the gzip compression level used with GZIP
+	// compression, such as gzip.BestSpeed. Zero uses gzip.DefaultCompression.
+	CompressionLevel int
+
+	// ParallelCompression can be set to true to compress blocks of the archive
+	// concurrently across GOMAXPROCS workers when using GZIP compression,
+	// which greatly reduces the time taken to archive large directories. The
+	// output is a standard gzip stream, slightly larger than it would
+	// otherwise be.
+	ParallelCompression bool
+
 	// Set to true if archiving should attempt to preserve
 	// permissions as it was on the filesystem. If this is false then
 	// files will be archived with basic file/directory permissions.
@@ -90,6 +107,52 @@ type Tar struct {
 	// inode again later.
 	hardLinks map[uint64]string
 
+	// unchanged is set by DiffTar to skip entries which haven't changed.
+	unchanged func(fullName string, f os.FileInfo) (bool, error)
+
+	// IncludeXattrs can be set to true to include the extended attributes of
+	// files and directories, such as security.capability, in the archive.
+	// They are written as PAX records in the form used by GNU tar and Docker.
+	// Extended attributes are only read on Linux.
+	IncludeXattrs bool
+
+	// Whiteouts lists paths, relative to the target, which are written to the
+	// archive as AUFS style whiteout entries marking them as deleted. This,
+	// along with OpaqueDirs, is used when producing layers applied on top of
+	// other layers, such as Docker image layers. See Untar.Whiteouts.
+	Whiteouts []string
+
+	// OpaqueDirs lists directories, relative to the target, which are written
+	// with an opaque marker, meaning their contents replace rather than merge
+	// with the contents of the layers below.
+	OpaqueDirs []string
+
+	// ConvertOverlayWhiteouts can be set to true when archiving the upper
+	// directory of an overlay filesystem to convert its whiteouts, which are
+	// character devices with a device number of 0/0, and opaque directories,
+	// which have the trusted.overlay.opaque extended attribute, into AUFS
+	// style whiteout entries.
+	ConvertOverlayWhiteouts bool
+
+	// DedupContent can be set to true to write regular files which have the
+	// same content, mode, and ownership as a file already in the archive as a
+	// hard link to that file rather than including their data again. This can
+	// significantly shrink archives of trees with many duplicated files.
+	DedupContent bool
+
+	// ChecksumAlgorithm is the algorithm used to compare file contents for
+	// DedupContent. The zero value uses DefaultChecksumAlgorithm.
+	ChecksumAlgorithm ChecksumAlgorithm
+
+	// contentLinks tracks the first entry seen with a given content when
+	// DedupContent is enabled.
+	contentLinks map[contentKey]string
+
+	// links records every entry written as a hard link, mapped to the name of
+	// the entry it links to, along with the resulting statistics.
+	links     map[string]string
+	linkStats LinkStats
+
 	// OwnerMappingFunc is used to give the caller the ability to control the
 	// mapping of UIDs in the tar into what they should be on the host. The
 	// function is only used when IncludeOwners is true. The function is passed in
@@ -136,11 +199,38 @@ type Tar struct {
 	SuffixHook TarCustomHook
 }
 
+// LinkStats contains statistics on the hard links written to an archive.
+type LinkStats struct {
+	// HardLinks is the number of entries written as hard links because they
+	// shared an inode with an earlier entry.
+	HardLinks int
+
+	// ContentLinks is the number of entries written as hard links because
+	// DedupContent found they had the same content as an earlier entry.
+	ContentLinks int
+
+	// BytesSaved is the total size of the file data which was not written
+	// due to the links.
+	BytesSaved int64
+}
+
+// contentKey identifies files which can be deduplicated with each other.
+type contentKey struct {
+	hash string
+	size int64
+	mode int64
+	uid  int
+	gid  int
+}
+
 // UserOption definitions.
 const (
 	c_DEREF UserOption = 1 << iota // Follow symbolic links when archiving.
 )
 
+// paxXattrPrefix is the prefix of PAX records containing extended attributes.
+const paxXattrPrefix = "SCHILY.xattr."
+
 // Mode constants from the tar spec.
 const (
 	c_ISUID  = 04000 // Set uid
@@ -160,6 +250,8 @@ func NewTar(w io.Writer, targetDir string) *Tar {
 		target:             targetDir,
 		dest:               w,
 		hardLinks:          make(map[uint64]string),
+		contentLinks:       make(map[contentKey]string),
+		links:              make(map[string]string),
 		IncludePermissions: true,
 		IncludeOwners:      false,
 		OwnerMappingFunc:   defaultMappingFunc,
@@ -168,22 +260,53 @@ func NewTar(w io.Writer, targetDir string) *Tar {
 }
 
 func (t *Tar) Archive() error {
+	// compressor is set when compressing the archive. Both are closed
+	// explicitly on success to report their errors, and here otherwise.
+	var compressor io.WriteCloser
 	defer func() {
+		// the archive must be closed first to flush it to the compressor
 		if t.archive != nil {
 			t.archive.Close()
 			t.archive = nil
 		}
+		if compressor != nil {
+			compressor.Close()
+		}
 	}()
 
+	// fail early rather than part way through the archive
+	if _, err := t.ChecksumAlgorithm.New(); err != nil {
+		return err
+	}
+
+	// copy the archive to any additional writers as it is written
+	output := t.dest
+	if len(t.writers) > 0 {
+		output = io.MultiWriter(append([]io.Writer{t.dest}, t.writers...)...)
+	}
+
 	// Create a TarWriter that wraps the proper io.Writer object
 	// the implements the expected compression for this file.
 	switch t.Compression {
 	case NONE:
-		t.archive = tar.NewWriter(t.dest)
+		t.archive = tar.NewWriter(output)
 	case GZIP:
-		dest := gzip.NewWriter(t.dest)
-		defer dest.Close()
-		t.archive = tar.NewWriter(dest)
+		level := t.CompressionLevel
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		var err error
+		if t.ParallelCompression {
+			compressor, err = newParallelGzipWriter(output, level)
+		} else {
+			compressor, err = gzip.NewWriterLevel(output, level)
+		}
+		if err != nil {
+			// the typed nil writer must not be closed
+			compressor = nil
+			return err
+		}
+		t.archive = tar.NewWriter(compressor)
 	case BZIP2:
 		return fmt.Errorf("bzip2 compression is not supported")
 	case DETECT:
@@ -219,6 +342,18 @@ func (t *Tar) Archive() error {
 		return err
 	}
 
+	// add any explicit whiteouts
+	for _, dir := range t.OpaqueDirs {
+		if err := t.writeWhiteout(filepath.Join(dir, whiteoutOpaque)); err != nil {
+			return err
+		}
+	}
+	for _, name := range t.Whiteouts {
+		if err := t.writeWhiteout(whiteoutName(name)); err != nil {
+			return err
+		}
+	}
+
 	if t.SuffixHook != nil {
 		err = t.SuffixHook(t.archive)
 		if err != nil {
@@ -226,7 +361,33 @@ func (t *Tar) Archive() error {
 		}
 	}
 
-	return nil
+	// The end of the archive is only flushed, and errors writing to the
+	// destination from the background by a parallel compressor are only
+	// reported, when they are closed.
+	err = t.archive.Close()
+	t.archive = nil
+	if compressor != nil {
+		if cerr := compressor.Close(); err == nil {
+			err = cerr
+		}
+		compressor = nil
+	}
+	return err
+}
+
+// AddWriter adds a writer which receives a copy of the archive, after any
+// compression, as it is written to the destination. A failure to write to it
+// fails the archiving. It must be called before Archive.
+func (t *Tar) AddWriter(w io.Writer) {
+	t.writers = append(t.writers, w)
+}
+
+// AddDigestWriter adds a hash which is fed the archive, after any compression,
+// as it is written, so its digest can be computed without buffering the
+// archive. Once Archive returns, h.Sum gives the digest, such as the blobsum of
+// a Docker layer when using a sha256 hash. It must be called before Archive.
+func (t *Tar) AddDigestWriter(h hash.Hash) {
+	t.AddWriter(h)
 }
 
 // ExcludePath appends a path, file, or pattern relative to the toplevel path to
@@ -298,6 +459,17 @@ func (t *Tar) processEntry(fullName string, f os.FileInfo, dirStack []string) er
 		return nil
 	}
 
+	// Skip unchanged entries when producing a diff, though directories are
+	// still walked for changes within them.
+	skipHeader := false
+	if t.unchanged != nil {
+		if skipHeader, err = t.unchanged(fullName, f); err != nil {
+			return err
+		} else if skipHeader && !f.IsDir() {
+			return nil
+		}
+	}
+
 	// set base header parameters
 	header, err := tar.FileInfoHeader(f, "")
 	if err != nil {
@@ -325,6 +497,25 @@ func (t *Tar) processEntry(fullName string, f os.FileInfo, dirStack []string) er
 		header.Gid = 500
 	}
 
+	// include extended attributes, symlinks can't have them read without
+	// following the link
+	if t.IncludeXattrs && f.Mode()&os.ModeSymlink == 0 {
+		xattrs, err := getXattrs(filepath.Join(t.target, fullName))
+		if err != nil {
+			return fmt.Errorf("failed to read xattrs for %q: %v", header.Name, err)
+		}
+		for name, value := range xattrs {
+			if t.ConvertOverlayWhiteouts && strings.HasPrefix(name, overlayXattrPrefix) {
+				// converted to whiteout entries instead
+				continue
+			}
+			if header.PAXRecords == nil {
+				header.PAXRecords = make(map[string]string)
+			}
+			header.PAXRecords[paxXattrPrefix+name] = value
+		}
+	}
+
 	// Check for any custom handlers that will process it.
 	for _, handler := range t.CustomHandlers {
 		bypass, err := handler(filepath.Join(t.target, fullName), f, header)
@@ -355,13 +546,26 @@ func (t *Tar) processEntry(fullName string, f os.FileInfo, dirStack []string) er
 		header.Name = header.Name + "/"
 
 		// write the header
-		if !t.excludeRootPath(header.Name) {
+		if !t.excludeRootPath(header.Name) && !skipHeader {
 			err = t.archive.WriteHeader(header)
 			if err != nil {
 				return err
 			}
 		}
 
+		// convert overlay opaque directories
+		if t.ConvertOverlayWhiteouts {
+			xattrs, err := getXattrs(filepath.Join(t.target, fullName))
+			if err != nil {
+				return fmt.Errorf("failed to read xattrs for %q: %v", header.Name, err)
+			}
+			if xattrs[overlayOpaqueXattr] == "y" {
+				if err := t.writeWhiteout(filepath.Join(fullName, whiteoutOpaque)); err != nil {
+					return err
+				}
+			}
+		}
+
 		// Push the directory to stack
 		p, err := filepath.Abs(fullName)
 		if err != nil {
@@ -466,9 +670,8 @@ func (t *Tar) processEntry(fullName string, f os.FileInfo, dirStack []string) er
 			inode := inodeForFileInfo(f)
 			if dst, ok := t.hardLinks[inode]; ok {
 				// update the header if it is
-				header.Typeflag = tar.TypeLink
-				header.Linkname = dst
-				header.Size = 0
+				t.linkHeader(header, dst)
+				t.linkStats.HardLinks++
 			} else {
 				// push it on the list, and continue to write it as a file
 				// this is our first time seeing it
@@ -476,6 +679,20 @@ func (t *Tar) processEntry(fullName string, f os.FileInfo, dirStack []string) er
 			}
 		}
 
+		// check to see if the same content has already been written
+		if t.DedupContent && header.Typeflag == tar.TypeReg && header.Size > 0 {
+			key, err := t.contentKey(filepath.Join(t.target, fullName), header)
+			if err != nil {
+				return err
+			}
+			if dst, ok := t.contentLinks[key]; ok {
+				t.linkHeader(header, dst)
+				t.linkStats.ContentLinks++
+			} else {
+				t.contentLinks[key] = header.Name
+			}
+		}
+
 		// write the header
 		err = t.archive.WriteHeader(header)
 		if err != nil {
@@ -513,6 +730,12 @@ func (t *Tar) processEntry(fullName string, f os.FileInfo, dirStack []string) er
 		fi, err := os.Stat(filepath.Join(t.target, fullName))
 		header.Devmajor, header.Devminor = osDeviceNumbersForFileInfo(fi)
 
+		// overlay whiteouts are character devices with a device number of 0/0
+		if t.ConvertOverlayWhiteouts && mode&os.ModeCharDevice != 0 &&
+			header.Devmajor == 0 && header.Devminor == 0 {
+			return t.writeWhiteout(whiteoutName(fullName))
+		}
+
 		// write the header
 		err = t.archive.WriteHeader(header)
 		if err != nil {
@@ -528,6 +751,82 @@ func (t *Tar) processEntry(fullName string, f os.FileInfo, dirStack []string) er
 	return nil
 }
 
+// writeWhiteout writes an empty whiteout entry with the given name, which is
+// relative to the target.
+func (t *Tar) writeWhiteout(name string) error {
+	header := &tar.Header{
+		Name:     path.Join(".", filepath.ToSlash(t.VirtualPath), filepath.ToSlash(name)),
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		ModTime:  time.Now(),
+	}
+	if t.IncludeOwners {
+		header.Uid, header.Gid = 0, 0
+	} else {
+		header.Uid, header.Gid = 500, 500
+	}
+	return t.archive.WriteHeader(header)
+}
+
+// Links returns the entries which were written to the archive as hard links,
+// mapped to the name of the entry they link to.
+func (t *Tar) Links() map[string]string {
+	links := make(map[string]string, len(t.links))
+	for k, v := range t.links {
+		links[k] = v
+	}
+	return links
+}
+
+// LinkStats returns statistics on the hard links written to the archive.
+func (t *Tar) LinkStats() LinkStats {
+	return t.linkStats
+}
+
+// linkHeader converts the header of a regular file into a hard link to dst and
+// records it.
+func (t *Tar) linkHeader(header *tar.Header, dst string) {
+	if t.links == nil {
+		t.links = make(map[string]string)
+	}
+	t.links[header.Name] = dst
+	t.linkStats.BytesSaved += header.Size
+
+	header.Typeflag = tar.TypeLink
+	header.Linkname = dst
+	header.Size = 0
+}
+
+// contentKey hashes the file and returns the key used to find other files it
+// can be deduplicated with. Files are only deduplicated with others with the
+// same mode and ownership, since hard links share those as well.
+func (t *Tar) contentKey(name string, header *tar.Header) (contentKey, error) {
+	if t.contentLinks == nil {
+		t.contentLinks = make(map[contentKey]string)
+	}
+	key := contentKey{
+		size: header.Size,
+		mode: header.Mode,
+		uid:  header.Uid,
+		gid:  header.Gid,
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return key, err
+	}
+	defer f.Close()
+	h, err := t.ChecksumAlgorithm.New()
+	if err != nil {
+		return key, err
+	}
+	if _, err := io.Copy(h, f); err != nil {
+		return key, err
+	}
+	key.hash = string(h.Sum(nil))
+	return key, nil
+}
+
 func cleanLinkName(targetDir, name string) (string, error) {
 	dir := filepath.Dir(name)
 
@@ -5,11 +5,16 @@ package tarhelper
 import (
 	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"runtime"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -690,3 +695,424 @@ func (m staticFileInfo) Mode() os.FileMode  { return 7777 }
 func (m staticFileInfo) ModTime() time.Time { return time.Now() }
 func (m staticFileInfo) IsDir() bool        { return false }
 func (m staticFileInfo) Sys() interface{}   { return nil }
+
+func TestTarLinkStats(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	dir := testHelper.TempDir()
+	mode := os.FileMode(0644)
+	tt.TestExpectSuccess(t, ioutil.WriteFile(path.Join(dir, "a"), []byte("hello world"), mode))
+	tt.TestExpectSuccess(t, os.Link(path.Join(dir, "a"), path.Join(dir, "b")))
+	tt.TestExpectSuccess(t, ioutil.WriteFile(path.Join(dir, "c"), []byte("hello world"), mode))
+	tt.TestExpectSuccess(t, ioutil.WriteFile(path.Join(dir, "d"), []byte("hello there"), mode))
+	tt.TestExpectSuccess(t, ioutil.WriteFile(path.Join(dir, "e"), []byte("hello world"), os.FileMode(0755)))
+
+	// without deduplication only the real hard link is linked
+	w := bytes.NewBufferString("")
+	tw := NewTar(w, dir)
+	tt.TestExpectSuccess(t, tw.Archive())
+	tt.TestEqual(t, tw.Links(), map[string]string{"b": "a"})
+	tt.TestEqual(t, tw.LinkStats(), LinkStats{HardLinks: 1, BytesSaved: 11})
+
+	// with deduplication files with the same content and mode are linked
+	w = bytes.NewBufferString("")
+	tw = NewTar(w, dir)
+	tw.DedupContent = true
+	tt.TestExpectSuccess(t, tw.Archive())
+	tt.TestEqual(t, tw.Links(), map[string]string{"b": "a", "c": "a"})
+	tt.TestEqual(t, tw.LinkStats(), LinkStats{
+		HardLinks:    1,
+		ContentLinks: 1,
+		BytesSaved:   22,
+	})
+
+	archive := tar.NewReader(bytes.NewReader(w.Bytes()))
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		tt.TestExpectSuccess(t, err)
+		switch header.Name {
+		case "b", "c":
+			tt.TestEqual(t, header.Typeflag, byte(tar.TypeLink))
+			tt.TestEqual(t, header.Linkname, "a")
+		case "a", "d", "e":
+			tt.TestEqual(t, header.Typeflag, byte(tar.TypeReg))
+		}
+	}
+
+	// the deduplicated archive extracts to the original content
+	dir = testHelper.TempDir()
+	u := NewUntar(w, dir)
+	u.AbsoluteRoot = dir
+	tt.TestExpectSuccess(t, u.Extract())
+	contents, err := ioutil.ReadFile(path.Join(dir, "c"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, string(contents), "hello world")
+}
+
+func TestTarXattrs(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	if runtime.GOOS != "linux" {
+		t.Skip("extended attributes are only supported on linux")
+	}
+
+	dir := testHelper.TempDir()
+	apath := path.Join(dir, "a")
+	tt.TestExpectSuccess(t, ioutil.WriteFile(apath, []byte("hello world"), os.FileMode(0644)))
+	if err := setXattr(apath, "user.test", "value"); err != nil {
+		t.Skipf("the temp directory does not support user xattrs: %v", err)
+	}
+
+	// without IncludeXattrs nothing is recorded
+	w := bytes.NewBufferString("")
+	tw := NewTar(w, dir)
+	tt.TestExpectSuccess(t, tw.Archive())
+	archive := tar.NewReader(bytes.NewReader(w.Bytes()))
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		tt.TestExpectSuccess(t, err)
+		tt.TestEqual(t, len(header.PAXRecords), 0)
+	}
+
+	// with IncludeXattrs they are recorded as PAX records
+	w = bytes.NewBufferString("")
+	tw = NewTar(w, dir)
+	tw.IncludeXattrs = true
+	tt.TestExpectSuccess(t, tw.Archive())
+	archive = tar.NewReader(bytes.NewReader(w.Bytes()))
+	found := false
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		tt.TestExpectSuccess(t, err)
+		if header.Name == "a" {
+			tt.TestEqual(t, header.PAXRecords["SCHILY.xattr.user.test"], "value")
+			found = true
+		}
+	}
+	tt.TestTrue(t, found)
+
+	// and restored with PreserveXattrs
+	dir = testHelper.TempDir()
+	u := NewUntar(bytes.NewReader(w.Bytes()), dir)
+	u.PreserveXattrs = true
+	tt.TestExpectSuccess(t, u.Extract())
+	xattrs, err := getXattrs(path.Join(dir, "a"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, xattrs["user.test"], "value")
+
+	// but not without it
+	dir = testHelper.TempDir()
+	u = NewUntar(bytes.NewReader(w.Bytes()), dir)
+	tt.TestExpectSuccess(t, u.Extract())
+	xattrs, err = getXattrs(path.Join(dir, "a"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, xattrs["user.test"], "")
+}
+
+func TestTarWhiteouts(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	dir := testHelper.TempDir()
+	tt.TestExpectSuccess(t, os.Mkdir(path.Join(dir, "sub"), os.FileMode(0755)))
+	tt.TestExpectSuccess(t, ioutil.WriteFile(path.Join(dir, "sub", "a"), []byte("a"), os.FileMode(0644)))
+
+	w := bytes.NewBufferString("")
+	tw := NewTar(w, dir)
+	tw.Whiteouts = []string{"removed", "sub/removed"}
+	tw.OpaqueDirs = []string{"sub"}
+	tt.TestExpectSuccess(t, tw.Archive())
+
+	names := []string{}
+	archive := tar.NewReader(bytes.NewReader(w.Bytes()))
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		tt.TestExpectSuccess(t, err)
+		names = append(names, header.Name)
+	}
+	tt.TestEqual(t, names, []string{"./", "sub/", "sub/a", "sub/.wh..wh..opq", ".wh.removed", "sub/.wh.removed"})
+
+	// overlay whiteouts are converted when requested
+	if runtime.GOOS != "linux" {
+		return
+	}
+	if err := syscall.Mknod(path.Join(dir, "gone"), syscall.S_IFCHR|0600, 0); err != nil {
+		t.Skipf("unable to create an overlay whiteout: %v", err)
+	}
+
+	w = bytes.NewBufferString("")
+	tw = NewTar(w, dir)
+	tw.ConvertOverlayWhiteouts = true
+	tt.TestExpectSuccess(t, tw.Archive())
+
+	found := false
+	archive = tar.NewReader(bytes.NewReader(w.Bytes()))
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		tt.TestExpectSuccess(t, err)
+		tt.TestNotEqual(t, header.Name, "gone")
+		if header.Name == ".wh.gone" {
+			tt.TestEqual(t, header.Typeflag, byte(tar.TypeReg))
+			found = true
+		}
+	}
+	tt.TestTrue(t, found)
+}
+
+func TestTarParallelGzip(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	// the output must be a single valid gzip stream across many blocks,
+	// including blocks smaller than the dictionary
+	for _, blockSize := range []int{100, 40000, parallelGzipBlockSize} {
+		var data []byte
+		for i := 0; len(data) < 300000; i++ {
+			data = append(data, fmt.Sprintf("line %d of %d\n", i%1000, i)...)
+		}
+		data = data[:300000]
+
+		compressed := bytes.NewBufferString("")
+		w, err := newParallelGzipWriter(compressed, gzip.BestSpeed)
+		tt.TestExpectSuccess(t, err)
+		w.blockSize = blockSize
+		for len(data) > 0 {
+			n := 12345
+			if n > len(data) {
+				n = len(data)
+			}
+			_, err := w.Write(data[:n])
+			tt.TestExpectSuccess(t, err)
+			data = data[n:]
+		}
+		tt.TestExpectSuccess(t, w.Close())
+
+		r, err := gzip.NewReader(bytes.NewReader(compressed.Bytes()))
+		tt.TestExpectSuccess(t, err)
+		r.Multistream(false)
+		decompressed, err := ioutil.ReadAll(r)
+		tt.TestExpectSuccess(t, err)
+		tt.TestEqual(t, len(decompressed), 300000)
+		tt.TestTrue(t, bytes.HasPrefix(decompressed, []byte("line 0 of 0\nline 1 of 1\n")))
+	}
+
+	_, err := newParallelGzipWriter(ioutil.Discard, 42)
+	tt.TestExpectError(t, err)
+
+	// and round trip through Tar and Untar
+	dir := testHelper.TempDir()
+	for i := 0; i < 20; i++ {
+		contents := bytes.Repeat([]byte(fmt.Sprintf("file %d\n", i)), 10000*i)
+		tt.TestExpectSuccess(t, ioutil.WriteFile(path.Join(dir, fmt.Sprintf("file%d", i)), contents, os.FileMode(0644)))
+	}
+	w := bytes.NewBufferString("")
+	tw := NewTar(w, dir)
+	tw.Compression = GZIP
+	tw.CompressionLevel = gzip.BestCompression
+	tw.ParallelCompression = true
+	tt.TestExpectSuccess(t, tw.Archive())
+
+	extracted := testHelper.TempDir()
+	u := NewUntar(bytes.NewReader(w.Bytes()), extracted)
+	u.Compression = GZIP
+	tt.TestExpectSuccess(t, u.Extract())
+	for i := 0; i < 20; i++ {
+		contents, err := ioutil.ReadFile(path.Join(extracted, fmt.Sprintf("file%d", i)))
+		tt.TestExpectSuccess(t, err)
+		tt.TestEqual(t, len(contents), len(fmt.Sprintf("file %d\n", i))*10000*i)
+	}
+}
+
+func TestDiffTar(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	write := func(root, name, contents string) {
+		fn := path.Join(root, name)
+		tt.TestExpectSuccess(t, os.MkdirAll(path.Dir(fn), os.FileMode(0755)))
+		tt.TestExpectSuccess(t, ioutil.WriteFile(fn, []byte(contents), os.FileMode(0644)))
+		tt.TestExpectSuccess(t, os.Chtimes(fn, mtime, mtime))
+	}
+
+	oldDir, newDir := testHelper.TempDir(), testHelper.TempDir()
+	for _, root := range []string{oldDir, newDir} {
+		write(root, "same", "same")
+		write(root, "dir/same", "same")
+		tt.TestExpectSuccess(t, os.Symlink("same", path.Join(root, "link")))
+	}
+	write(oldDir, "changed", "old")
+	write(newDir, "changed", "new contents")
+	write(oldDir, "touched", "abc")
+	write(newDir, "touched", "abc")
+	write(oldDir, "removed", "removed")
+	write(oldDir, "removeddir/a", "a")
+	write(oldDir, "dir/removed", "removed")
+	write(oldDir, "replaced/a", "a")
+	write(newDir, "replaced", "now a file")
+	write(newDir, "added/a", "a")
+	for _, root := range []string{oldDir, newDir} {
+		for _, dir := range []string{"", "dir"} {
+			tt.TestExpectSuccess(t, os.Chtimes(path.Join(root, dir), mtime, mtime))
+		}
+	}
+	// same content, different modification time
+	later := mtime.Add(time.Minute)
+	tt.TestExpectSuccess(t, os.Chtimes(path.Join(newDir, "touched"), later, later))
+
+	archive := func(compareContent bool) ([]string, []byte) {
+		w := bytes.NewBufferString("")
+		dt := NewDiffTar(w, oldDir, newDir)
+		dt.CompareContent = compareContent
+		tt.TestExpectSuccess(t, dt.Archive())
+
+		names := []string{}
+		r := tar.NewReader(bytes.NewReader(w.Bytes()))
+		for {
+			header, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			tt.TestExpectSuccess(t, err)
+			names = append(names, header.Name)
+		}
+		return names, w.Bytes()
+	}
+
+	names, _ := archive(false)
+	tt.TestEqual(t, names, []string{
+		"added/", "added/a", "changed", ".wh.replaced", "replaced", "touched",
+		"dir/.wh.removed", ".wh.removed", ".wh.removeddir",
+	})
+
+	// comparing content skips files which were only touched
+	names, diff := archive(true)
+	tt.TestEqual(t, names, []string{
+		"added/", "added/a", "changed", ".wh.replaced", "replaced",
+		"dir/.wh.removed", ".wh.removed", ".wh.removeddir",
+	})
+
+	// applying the diff to the old directory produces the new one
+	u := NewUntar(bytes.NewReader(diff), oldDir)
+	u.Whiteouts = true
+	tt.TestExpectSuccess(t, u.Extract())
+	for _, name := range []string{"same", "dir/same", "changed", "touched", "replaced", "added/a"} {
+		have, err := ioutil.ReadFile(path.Join(oldDir, name))
+		tt.TestExpectSuccess(t, err)
+		want, err := ioutil.ReadFile(path.Join(newDir, name))
+		tt.TestExpectSuccess(t, err)
+		tt.TestEqual(t, string(have), string(want))
+	}
+	for _, name := range []string{"removed", "removeddir", "dir/removed"} {
+		_, err := os.Lstat(path.Join(oldDir, name))
+		tt.TestTrue(t, os.IsNotExist(err))
+	}
+}
+
+func TestTarDigestWriter(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	for _, compression := range []Compression{NONE, GZIP} {
+		w := bytes.NewBufferString("")
+		copied := bytes.NewBufferString("")
+		digest := sha256.New()
+		tw := NewTar(w, makeTestDir(t))
+		tw.Compression = compression
+		tw.AddWriter(copied)
+		tw.AddDigestWriter(digest)
+		tt.TestExpectSuccess(t, tw.Archive())
+
+		want := sha256.Sum256(w.Bytes())
+		tt.TestEqual(t, digest.Sum(nil), want[:])
+		tt.TestEqual(t, copied.Bytes(), w.Bytes())
+	}
+
+	// a failing writer fails the archive
+	tw := NewTar(ioutil.Discard, makeTestDir(t))
+	tw.AddWriter(failingWriter{})
+	tt.TestExpectError(t, tw.Archive())
+}
+
+func TestTarFailingDestination(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	// compressed output may only be written when the compressor is closed,
+	// which must still fail the archive
+	dir := makeTestDir(t)
+	for _, parallel := range []bool{false, true} {
+		tw := NewTar(failingWriter{}, dir)
+		tw.Compression = GZIP
+		tw.ParallelCompression = parallel
+		tt.TestExpectError(t, tw.Archive())
+
+		tw = NewTar(ioutil.Discard, dir)
+		tw.Compression = GZIP
+		tw.ParallelCompression = parallel
+		tw.AddWriter(failingWriter{})
+		tt.TestExpectError(t, tw.Archive())
+	}
+	tt.TestExpectError(t, NewTar(failingWriter{}, dir).Archive())
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("write failed")
+}
+
+func TestChecksumAlgorithm(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	for _, a := range []ChecksumAlgorithm{SHA256, SHA512} {
+		h, err := a.New()
+		tt.TestExpectSuccess(t, err)
+		h.Write([]byte("hello world"))
+		digest := a.Digest(h.Sum(nil))
+		tt.TestTrue(t, strings.HasPrefix(digest, string(a)+":"))
+
+		parsed, sum, err := ParseDigest(digest)
+		tt.TestExpectSuccess(t, err)
+		tt.TestEqual(t, parsed, a)
+		tt.TestEqual(t, sum, h.Sum(nil))
+	}
+	tt.TestEqual(t, ChecksumAlgorithm("").Digest([]byte{0xab}), "sha256:ab")
+
+	for _, digest := range []string{"abc", "md5:d41d8cd98f00b204e9800998ecf8427e", "sha256:xyz", "sha256:abcd"} {
+		_, _, err := ParseDigest(digest)
+		tt.TestExpectError(t, err)
+	}
+
+	// deduplication uses the selected algorithm
+	dir := testHelper.TempDir()
+	tt.TestExpectSuccess(t, ioutil.WriteFile(path.Join(dir, "a"), []byte("hello world"), 0644))
+	tt.TestExpectSuccess(t, ioutil.WriteFile(path.Join(dir, "b"), []byte("hello world"), 0644))
+	tw := NewTar(ioutil.Discard, dir)
+	tw.DedupContent = true
+	tw.ChecksumAlgorithm = SHA512
+	tt.TestExpectSuccess(t, tw.Archive())
+	tt.TestEqual(t, tw.Links(), map[string]string{"b": "a"})
+
+	tw = NewTar(ioutil.Discard, dir)
+	tw.ChecksumAlgorithm = ChecksumAlgorithm("md5")
+	tt.TestExpectError(t, tw.Archive())
+}
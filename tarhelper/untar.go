@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
@@ -31,6 +32,10 @@ const (
 // file. For more information, see Untar.CustomerHandlers description.
 type UntarCustomHandler func(rootpath string, header *tar.Header, reader io.Reader) (bool, error)
 
+// UntarHeaderHandler are used to rewrite or skip entries in a tar file before
+// they are processed. For more information, see Untar.HeaderHandlers.
+type UntarHeaderHandler func(header *tar.Header) (bool, error)
+
 type resolvedLink struct {
 	src string
 	dst string
@@ -50,6 +55,18 @@ type Untar struct {
 	// location of the AbsoluteRoot.
 	resolvedLinks []resolvedLink
 
+	// The entries extracted and the opaque directories seen, used to apply
+	// whiteouts once the archive has been extracted.
+	extracted  map[string]bool
+	opaqueDirs []string
+
+	// ignorePaths are the patterns added by IncludePath and ExcludePath.
+	ignorePaths []ignoreInfo
+
+	// deferredLinks are the hard links whose targets had not been extracted
+	// yet when DeferHardLinks is set.
+	deferredLinks []deferredLink
+
 	// The AbsoluteRoot is intended to be the root of the target and allows us
 	// to create files that follow through links that are absolute paths, but
 	// ensure the file is created relative to the AbsoluteRoot and not the root
@@ -100,6 +117,12 @@ type Untar struct {
 	// (/etc/file) or directories (/etc/dir/) will be allowed.
 	PathWhitelist []string
 
+	// FilterFunc, if set, is called for each entry which passes the
+	// PathWhitelist and the patterns added by IncludePath and ExcludePath. The
+	// entry is only extracted if it returns true, and an error aborts the
+	// extraction. Whiteouts are filtered by the name of the entry they delete.
+	FilterFunc func(header *tar.Header) (bool, error)
+
 	// OwnerMappingFunc is used to give the caller the ability to control the
 	// mapping of UIDs in the tar into what they should be on the host. It is only
 	// used when PreserveOwners is true. The function is passed in the UID of the
@@ -126,6 +149,79 @@ type Untar struct {
 	// *tar.Header entry, and an io.Reader to the entry's contents (if it is a
 	// file).
 	CustomHandlers []UntarCustomHandler
+
+	// HeaderHandlers are called in order for each entry as it is read, before
+	// any other processing, and are the counterpart to Tar.CustomHandlers.
+	// They can modify the *tar.Header, such as rewriting its Name or Linkname,
+	// and the entry is then processed as if the archive contained the modified
+	// header, including the security checks on its name. They return true to
+	// skip the entry entirely, or an error to abort the extraction. Content can
+	// be redirected using CustomHandlers.
+	HeaderHandlers []UntarHeaderHandler
+
+	// StateFile, if set, is the path of a file used to record the progress of
+	// the extraction. If the extraction is interrupted, a later Extract of the
+	// same archive with the same StateFile will skip the entries which were
+	// already completed, after verifying they still exist on disk with the
+	// expected type and size. If verification fails, extraction resumes from
+	// that entry instead. Progress is saved in batches of entries and when
+	// extraction fails, so after a crash the most recent entries are verified
+	// again. The state file is removed once extraction completes
+	// successfully.
+	StateFile string
+
+	// VerifyResumeChecksums can be set to true to also compare the contents
+	// of already extracted files against the archive when resuming with a
+	// StateFile, rather than only their size.
+	VerifyResumeChecksums bool
+
+	// PreserveXattrs can be set to true to restore extended attributes, such
+	// as security.capability, recorded as PAX records within the archive.
+	// Setting some namespaces requires privileges, and a failure to set an
+	// attribute fails the extraction. Extended attributes are only supported
+	// on Linux.
+	PreserveXattrs bool
+
+	// MaxTotalSize, MaxEntrySize, and MaxEntries limit the total size of all
+	// files, the size of any single file, and the number of entries within
+	// the archive. If one is exceeded, Extract fails with an
+	// *ExtractLimitError. This can be used to defend against decompression
+	// bombs when extracting untrusted archives. A value of zero means no
+	// limit.
+	MaxTotalSize int64
+	MaxEntrySize int64
+	MaxEntries   int
+
+	// Whiteouts can be set to true to interpret AUFS style whiteout entries,
+	// as used within Docker image layers, as deletions within the target
+	// rather than extracting them. An entry named ".wh.<name>" removes <name>,
+	// and a ".wh..wh..opq" entry marks its directory as opaque, removing
+	// anything within it which was not extracted from this archive.
+	Whiteouts bool
+
+	// DeferHardLinks can be set to true to allow hard links to appear in the
+	// archive before the entry they link to. Such links are created once the
+	// rest of the archive has been extracted, and Extract fails if their
+	// target still doesn't exist.
+	DeferHardLinks bool
+}
+
+// ExtractLimitError is returned by Extract when the archive exceeds one of the
+// Untar limits.
+type ExtractLimitError struct {
+	// Limit is the name of the limit which was exceeded, such as
+	// "MaxTotalSize".
+	Limit string
+
+	// Max is the value of the limit.
+	Max int64
+
+	// Name is the name of the entry which exceeded the limit.
+	Name string
+}
+
+func (e *ExtractLimitError) Error() string {
+	return fmt.Sprintf("archive exceeds %s of %d at entry %q", e.Limit, e.Max, e.Name)
 }
 
 // NewUntar returns an Untar to use to extract the contents of r into targetDir.
@@ -167,58 +263,163 @@ func NewUntar(r io.Reader, targetDir string) *Untar {
 // Extract unpacks the tar reader that was passed into New(). This is
 // broken out from new to give the caller time to set various
 // settings in the Untar object.
-func (u *Untar) Extract() error {
+func (u *Untar) Extract() (err error) {
+	_, closeArchive, err := u.openArchive()
+	if err != nil {
+		return err
+	}
+	defer closeArchive()
+
+	// load any progress from a previous extraction
+	state, err := u.loadState()
+	if err != nil {
+		return err
+	}
+	resuming := state.Entries > 0
+
+	// progress is saved in batches, since saving it costs a write and fsyncs,
+	// and again if the extraction fails
+	var progress extractState
+	var unsavedEntries int
+	var unsavedBytes int64
+	defer func() {
+		if err != nil && unsavedEntries > 0 {
+			u.saveState(&progress)
+		}
+	}()
+
+	u.extracted = make(map[string]bool)
+	u.opaqueDirs = nil
+	u.deferredLinks = nil
+
+	var totalSize int64
+	for index := 0; ; index++ {
+		header, err := u.archive.Next()
+		if err == io.EOF {
+			// EOF, ok, break to return
+			break
+		}
+		if err != nil {
+			// See note on logging above.
+			return err
+		}
+
+		// enforce the limits before anything is written
+		totalSize += header.Size
+		if err := u.checkLimits(header, index+1, totalSize); err != nil {
+			return err
+		}
+
+		// let the header handlers rewrite or skip the entry
+		skip := false
+		for _, handler := range u.HeaderHandlers {
+			if skip, err = handler(header); err != nil {
+				return err
+			} else if skip {
+				break
+			}
+		}
+
+		// skip entries completed by a previous extraction, so long as they
+		// still check out
+		complete := skip
+		if !complete && u.Whiteouts {
+			// whiteouts are always reapplied since removal is idempotent
+			if complete, err = u.processWhiteout(header); err != nil {
+				return err
+			}
+		}
+		if !complete && resuming && index < state.Entries {
+			var repaired bool
+			mismatched := index == state.Entries-1 && header.Name != state.Last
+			complete, repaired, err = u.verifyCompleted(header, mismatched)
+			if err != nil {
+				return err
+			}
+			if !complete || repaired {
+				// stop trusting the previous extraction
+				resuming = false
+			}
+		}
+
+		if !complete {
+			err = u.processEntry(header)
+			if err != nil {
+				// See note on logging above.
+				return err
+			}
+		}
+
+		progress = extractState{Entries: index + 1, Last: header.Name}
+		unsavedEntries++
+		unsavedBytes += header.Size
+		if unsavedEntries >= stateSaveEntries || unsavedBytes >= stateSaveBytes {
+			if err := u.saveState(&progress); err != nil {
+				return err
+			}
+			unsavedEntries, unsavedBytes = 0, 0
+		}
+	}
+
+	if err := u.resolveDeferredLinks(); err != nil {
+		return err
+	}
+	if err := u.applyOpaqueDirs(); err != nil {
+		return err
+	}
+	return u.removeState()
+}
+
+// openArchive sets up the tar reader for the source, decompressing it as
+// needed. It returns the decompressed stream the tar reader reads from, and a
+// function which releases the decompressor.
+func (u *Untar) openArchive() (io.Reader, func(), error) {
 	// check for detect mode before the main setup, we'll change compression
 	// to the intended type and setup a new reader to re-read the header
+	var arch io.Reader
 	switch u.Compression {
 	case NONE:
-		u.archive = tar.NewReader(u.source)
+		arch = u.source
 
 	case DETECT:
-		arch, err := DetectArchiveCompression(u.source)
-		if err != nil {
-			return err
+		var err error
+		if arch, err = detectDecompression(u.source); err != nil {
+			return nil, nil, err
 		}
-		u.archive = arch
 
 	default:
 		// Look up the compression handler
 		comp, exists := decompressorTypes[string(u.Compression)]
 		if !exists {
-			return fmt.Errorf("unrecognized decompression type %q", u.Compression)
+			return nil, nil, fmt.Errorf("unrecognized decompression type %q", u.Compression)
 		}
 
 		// Create the reader
-		arch, err := comp.NewReader(u.source)
-		if err != nil {
-			return err
+		var err error
+		if arch, err = comp.NewReader(u.source); err != nil {
+			return nil, nil, err
 		}
-		defer func() {
-			if cl, ok := arch.(io.ReadCloser); ok {
-				cl.Close()
-			}
-		}()
-		u.archive = tar.NewReader(arch)
 	}
 
-	for {
-		header, err := u.archive.Next()
-		if err == io.EOF {
-			// EOF, ok, break to return
-			break
-		}
-		if err != nil {
-			// See note on logging above.
-			return err
+	u.archive = tar.NewReader(arch)
+	return arch, func() {
+		if cl, ok := arch.(io.ReadCloser); ok {
+			cl.Close()
 		}
+	}, nil
+}
 
-		err = u.processEntry(header)
-		if err != nil {
-			// See note on logging above.
-			return err
-		}
+// checkLimits returns an *ExtractLimitError if the entry causes any of the
+// extraction limits to be exceeded.
+func (u *Untar) checkLimits(header *tar.Header, entries int, totalSize int64) error {
+	switch {
+	case u.MaxEntries > 0 && entries > u.MaxEntries:
+		return &ExtractLimitError{Limit: "MaxEntries", Max: int64(u.MaxEntries), Name: header.Name}
+	case u.MaxEntrySize > 0 && header.Size > u.MaxEntrySize:
+		return &ExtractLimitError{Limit: "MaxEntrySize", Max: u.MaxEntrySize, Name: header.Name}
+	case u.MaxTotalSize > 0 && totalSize > u.MaxTotalSize:
+		return &ExtractLimitError{Limit: "MaxTotalSize", Max: u.MaxTotalSize, Name: header.Name}
 	}
-
 	return nil
 }
 
@@ -269,17 +470,15 @@ func (u *Untar) processEntry(header *tar.Header) error {
 	if !u.checkEntryAgainstWhitelist(header) {
 		return nil
 	}
+	if include, err := u.checkEntryAgainstFilters(header); err != nil || !include {
+		return err
+	}
 
-	name := filepath.Join(u.target, header.Name)
-
-	// resolve the destination and then reset the name based on the resolution
-	destDir, err := u.resolveDestination(filepath.Dir(name))
+	name, err := u.destination(header)
 	if err != nil {
 		return err
 	}
 
-	name = filepath.Join(destDir, filepath.Base(name))
-
 	// The path length of the extracted file might exceed Windows maximum of
 	// 260 chars.
 	if runtime.GOOS == "windows" {
@@ -384,6 +583,14 @@ func (u *Untar) processEntry(header *tar.Header) error {
 		// find the full path, need to ensure it exists
 		link := filepath.Join(u.target, header.Linkname)
 
+		// the target may come later in the archive
+		if u.DeferHardLinks {
+			if _, err := os.Lstat(link); os.IsNotExist(err) {
+				u.deferredLinks = append(u.deferredLinks, deferredLink{name: name, link: link, header: header.Name})
+				return nil
+			}
+		}
+
 		// do the link... no permissions or owners, those carry over
 		if err := os.Link(link, name); err != nil {
 			return err
@@ -480,9 +687,36 @@ func (u *Untar) processEntry(header *tar.Header) error {
 		os.Chown(name, header.Uid, header.Gid)
 	}
 
+	// Restore extended attributes after the chown, as changing the owner
+	// clears security.capability.
+	if u.PreserveXattrs && header.Typeflag != tar.TypeSymlink && header.Typeflag != tar.TypeLink {
+		for key, value := range header.PAXRecords {
+			if !strings.HasPrefix(key, paxXattrPrefix) {
+				continue
+			}
+			xattr := strings.TrimPrefix(key, paxXattrPrefix)
+			if err := setXattr(name, xattr, value); err != nil {
+				return fmt.Errorf("failed to set xattr %q on %q: %v", xattr, name, err)
+			}
+		}
+	}
+
 	return nil
 }
 
+// destination returns the path the entry will be extracted to.
+func (u *Untar) destination(header *tar.Header) (string, error) {
+	name := filepath.Join(u.target, header.Name)
+
+	// resolve the destination and then reset the name based on the resolution
+	destDir, err := u.resolveDestination(filepath.Dir(name))
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(destDir, filepath.Base(name)), nil
+}
+
 func (u *Untar) resolveDestination(name string) (string, error) {
 	pathParts := strings.Split(name, string(os.PathSeparator))
 
@@ -676,6 +910,57 @@ func (u *Untar) checkEntryAgainstWhitelist(header *tar.Header) bool {
 	return false
 }
 
+// ExcludePath appends a path, file, or pattern relative to the root of the
+// archive for entries which are not extracted. pathRE is a regex that will be
+// anchored at the start and end then applied to the entire entry name (full
+// path and basename). See Tar.ExcludePath.
+func (u *Untar) ExcludePath(pathRE string) {
+	if pathRE != "" {
+		re, err := regexp.Compile("^" + pathRE + "$")
+		if err != nil {
+			return
+		}
+		u.ignorePaths = append(u.ignorePaths, ignoreInfo{regexp: re, exclude: true, dirOnly: false})
+	}
+}
+
+// IncludePath appends a path, file, or pattern relative to the root of the
+// archive for entries which are extracted even if they matched a previous
+// ExcludePath. pathRE is a regex that will be anchored at the start and end
+// then applied to the entire entry name (full path and basename). See
+// Tar.IncludePath.
+func (u *Untar) IncludePath(pathRE string) {
+	if pathRE != "" {
+		re, err := regexp.Compile("^" + pathRE + "$")
+		if err != nil {
+			return
+		}
+		u.ignorePaths = append(u.ignorePaths, ignoreInfo{regexp: re, exclude: false, dirOnly: false})
+	}
+}
+
+// checkEntryAgainstFilters checks if the entry should be extracted against the
+// patterns added by IncludePath and ExcludePath, and then the FilterFunc.
+// Patterns are considered in order so that entries excluded by one can be
+// reincluded by a later one.
+func (u *Untar) checkEntryAgainstFilters(header *tar.Header) (bool, error) {
+	name := filepath.ToSlash(filepath.Clean(header.Name))
+	exclude := false
+	for _, re := range u.ignorePaths {
+		if re.regexp.MatchString(name) || re.regexp.MatchString(filepath.Base(name)) {
+			exclude = re.exclude
+		}
+	}
+	if exclude {
+		return false, nil
+	}
+
+	if u.FilterFunc != nil {
+		return u.FilterFunc(header)
+	}
+	return true, nil
+}
+
 func lazyChmod(name string, m os.FileMode) {
 	if fi, err := os.Stat(name); err == nil {
 		os.Chmod(name, fi.Mode()|m)
@@ -5,6 +5,9 @@ package tarhelper
 import (
 	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
@@ -620,3 +623,561 @@ func TestUntarCustomHandler(t *testing.T) {
 	fileNotExists("/foobar")
 	fileExists("/foobar2")
 }
+
+func TestUntarResume(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	// create the archive
+	buffer := bytes.NewBufferString("")
+	archive := tar.NewWriter(buffer)
+	writeFile := func(name, contents string) {
+		header := &tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644 | c_ISREG,
+			ModTime:  time.Now(),
+			Size:     int64(len(contents)),
+		}
+		tt.TestExpectSuccess(t, archive.WriteHeader(header))
+		_, err := archive.Write([]byte(contents))
+		tt.TestExpectSuccess(t, err)
+	}
+	tt.TestExpectSuccess(t, archive.WriteHeader(&tar.Header{
+		Name:     "./",
+		Typeflag: tar.TypeDir,
+		Mode:     0755 | c_ISDIR,
+		ModTime:  time.Now(),
+	}))
+	writeFile("./a", "aaaa")
+	writeFile("./b", "bbbb")
+	writeFile("./c", "cccc")
+	writeFile("./d", "dddd")
+	tt.TestExpectSuccess(t, archive.Close())
+
+	tempDir := testHelper.TempDir()
+	stateFile := filepath.Join(testHelper.TempDir(), "state")
+
+	// extract, recording which entries are processed
+	var processed []string
+	extract := func(verifyChecksums bool) {
+		processed = nil
+		u := NewUntar(bytes.NewReader(buffer.Bytes()), tempDir)
+		u.StateFile = stateFile
+		u.VerifyResumeChecksums = verifyChecksums
+		u.CustomHandlers = []UntarCustomHandler{
+			func(rootpath string, header *tar.Header, reader io.Reader) (bool, error) {
+				processed = append(processed, header.Name)
+				return false, nil
+			},
+		}
+		tt.TestExpectSuccess(t, u.Extract())
+
+		// the state file is removed on completion
+		_, err := os.Stat(stateFile)
+		tt.TestTrue(t, os.IsNotExist(err))
+	}
+	writeState := func(entries int, last string) {
+		tt.TestExpectSuccess(t, ioutil.WriteFile(stateFile,
+			[]byte(`{"entries":`+strconv.Itoa(entries)+`,"last":"`+last+`"}`), 0644))
+	}
+	readFile := func(name string) string {
+		b, err := ioutil.ReadFile(filepath.Join(tempDir, name))
+		tt.TestExpectSuccess(t, err)
+		return string(b)
+	}
+
+	// no state file, everything is extracted
+	extract(false)
+	tt.TestEqual(t, processed, []string{"./", "./a", "./b", "./c", "./d"})
+
+	// resume after the first three entries
+	writeState(3, "./b")
+	extract(false)
+	tt.TestEqual(t, processed, []string{"./c", "./d"})
+
+	// a missing file causes extraction to resume from it
+	tt.TestExpectSuccess(t, os.Remove(filepath.Join(tempDir, "a")))
+	writeState(4, "./c")
+	extract(false)
+	tt.TestEqual(t, processed, []string{"./a", "./b", "./c", "./d"})
+	tt.TestEqual(t, readFile("a"), "aaaa")
+
+	// a file of the wrong size is extracted again
+	tt.TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(tempDir, "b"), []byte("bb"), 0644))
+	writeState(4, "./c")
+	extract(false)
+	tt.TestEqual(t, processed, []string{"./b", "./c", "./d"})
+	tt.TestEqual(t, readFile("b"), "bbbb")
+
+	// a state file from a different archive is not trusted
+	writeState(4, "./x")
+	extract(false)
+	tt.TestEqual(t, processed, []string{"./c", "./d"})
+
+	// with checksums a file with the wrong contents is repaired
+	tt.TestExpectSuccess(t, ioutil.WriteFile(filepath.Join(tempDir, "b"), []byte("bbxx"), 0644))
+	writeState(4, "./c")
+	extract(true)
+	tt.TestEqual(t, processed, []string{"./c", "./d"})
+	tt.TestEqual(t, readFile("b"), "bbbb")
+
+	// an error reading an entry being verified fails the extraction rather
+	// than extracting the partly read entry, which starts at byte 2048
+	writeState(4, "./c")
+	processed = nil
+	u := NewUntar(&failingReader{r: bytes.NewReader(buffer.Bytes()), n: 2050}, tempDir)
+	u.StateFile = stateFile
+	u.VerifyResumeChecksums = true
+	u.CustomHandlers = []UntarCustomHandler{
+		func(rootpath string, header *tar.Header, reader io.Reader) (bool, error) {
+			processed = append(processed, header.Name)
+			return false, nil
+		},
+	}
+	tt.TestEqual(t, u.Extract(), errReadFailed)
+	tt.TestEqual(t, len(processed), 0)
+	tt.TestEqual(t, readFile("b"), "bbbb")
+}
+
+func TestUntarResumeBatches(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	defer func(entries int) { stateSaveEntries = entries }(stateSaveEntries)
+	stateSaveEntries = 2
+
+	buffer := bytes.NewBufferString("")
+	archive := tar.NewWriter(buffer)
+	for _, name := range []string{"./a", "./b", "./c", "./d", "./e"} {
+		tt.TestExpectSuccess(t, archive.WriteHeader(&tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644 | c_ISREG,
+			ModTime:  time.Now(),
+			Size:     1,
+		}))
+		_, err := archive.Write([]byte("x"))
+		tt.TestExpectSuccess(t, err)
+	}
+	tt.TestExpectSuccess(t, archive.Close())
+
+	stateFile := filepath.Join(testHelper.TempDir(), "state")
+	readState := func() string {
+		b, err := ioutil.ReadFile(stateFile)
+		tt.TestExpectSuccess(t, err)
+		return string(b)
+	}
+
+	// the state is saved every two entries, and when extraction fails
+	var states []string
+	u := NewUntar(bytes.NewReader(buffer.Bytes()), testHelper.TempDir())
+	u.StateFile = stateFile
+	u.CustomHandlers = []UntarCustomHandler{
+		func(rootpath string, header *tar.Header, reader io.Reader) (bool, error) {
+			switch header.Name {
+			case "./c":
+				states = append(states, readState())
+			case "./d":
+				return false, errors.New("interrupted")
+			}
+			return false, nil
+		},
+	}
+	tt.TestExpectError(t, u.Extract())
+	tt.TestEqual(t, states, []string{`{"entries":2,"last":"./b"}`})
+	tt.TestEqual(t, readState(), `{"entries":3,"last":"./c"}`)
+}
+
+var errReadFailed = errors.New("read failed")
+
+// failingReader returns errReadFailed after reading n bytes from r.
+type failingReader struct {
+	r io.Reader
+	n int
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if f.n <= 0 {
+		return 0, errReadFailed
+	}
+	if len(p) > f.n {
+		p = p[:f.n]
+	}
+	n, err := f.r.Read(p)
+	f.n -= n
+	return n, err
+}
+
+func TestUntarLimits(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	// create an archive with three 10 byte files
+	buffer := bytes.NewBufferString("")
+	archive := tar.NewWriter(buffer)
+	for _, name := range []string{"./a", "./b", "./c"} {
+		header := &tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644 | c_ISREG,
+			ModTime:  time.Now(),
+			Size:     10,
+		}
+		tt.TestExpectSuccess(t, archive.WriteHeader(header))
+		_, err := archive.Write([]byte("0123456789"))
+		tt.TestExpectSuccess(t, err)
+	}
+	tt.TestExpectSuccess(t, archive.Close())
+
+	extract := func(setLimits func(u *Untar)) error {
+		u := NewUntar(bytes.NewReader(buffer.Bytes()), testHelper.TempDir())
+		setLimits(u)
+		return u.Extract()
+	}
+
+	// within the limits
+	tt.TestExpectSuccess(t, extract(func(u *Untar) {
+		u.MaxEntries = 3
+		u.MaxEntrySize = 10
+		u.MaxTotalSize = 30
+	}))
+
+	tests := []struct {
+		limit     string
+		setLimits func(u *Untar)
+		max       int64
+		name      string
+	}{
+		{"MaxEntries", func(u *Untar) { u.MaxEntries = 2 }, 2, "./c"},
+		{"MaxEntrySize", func(u *Untar) { u.MaxEntrySize = 9 }, 9, "./a"},
+		{"MaxTotalSize", func(u *Untar) { u.MaxTotalSize = 25 }, 25, "./c"},
+	}
+	for _, test := range tests {
+		err := extract(test.setLimits)
+		tt.TestExpectError(t, err)
+		limitErr, ok := err.(*ExtractLimitError)
+		tt.TestTrue(t, ok)
+		tt.TestEqual(t, limitErr.Limit, test.limit)
+		tt.TestEqual(t, limitErr.Max, test.max)
+		tt.TestEqual(t, limitErr.Name, test.name)
+	}
+}
+
+func TestUntarWhiteouts(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	dir := testHelper.TempDir()
+	for _, name := range []string{"keep", "removed", "sub/removed", "opaque/lower", "opaque/nested/lower"} {
+		fn := filepath.Join(dir, name)
+		tt.TestExpectSuccess(t, os.MkdirAll(filepath.Dir(fn), 0755))
+		tt.TestExpectSuccess(t, ioutil.WriteFile(fn, []byte("lower"), 0644))
+	}
+
+	buffer := bytes.NewBufferString("")
+	archive := tar.NewWriter(buffer)
+	for _, name := range []string{"./.wh.removed", "./sub/.wh.removed", "./opaque/nested/upper", "./opaque/.wh..wh..opq"} {
+		header := &tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644 | c_ISREG,
+			ModTime:  time.Now(),
+		}
+		tt.TestExpectSuccess(t, archive.WriteHeader(header))
+	}
+	tt.TestExpectSuccess(t, archive.Close())
+
+	// without Whiteouts the entries are extracted as is
+	plain := testHelper.TempDir()
+	u := NewUntar(bytes.NewReader(buffer.Bytes()), plain)
+	tt.TestExpectSuccess(t, u.Extract())
+	_, err := os.Stat(filepath.Join(plain, ".wh.removed"))
+	tt.TestExpectSuccess(t, err)
+
+	u = NewUntar(bytes.NewReader(buffer.Bytes()), dir)
+	u.Whiteouts = true
+	tt.TestExpectSuccess(t, u.Extract())
+
+	exists := func(name string) bool {
+		_, err := os.Lstat(filepath.Join(dir, name))
+		return err == nil
+	}
+	tt.TestTrue(t, exists("keep"))
+	tt.TestFalse(t, exists("removed"))
+	tt.TestFalse(t, exists(".wh.removed"))
+	tt.TestTrue(t, exists("sub"))
+	tt.TestFalse(t, exists("sub/removed"))
+	tt.TestFalse(t, exists("opaque/lower"))
+	tt.TestFalse(t, exists("opaque/nested/lower"))
+	tt.TestTrue(t, exists("opaque/nested/upper"))
+	tt.TestFalse(t, exists("opaque/.wh..wh..opq"))
+
+	// whiteouts can't be used to escape the target
+	buffer = bytes.NewBufferString("")
+	archive = tar.NewWriter(buffer)
+	tt.TestExpectSuccess(t, archive.WriteHeader(&tar.Header{
+		Name:     "./.wh..",
+		Typeflag: tar.TypeReg,
+		Mode:     0644 | c_ISREG,
+		ModTime:  time.Now(),
+	}))
+	tt.TestExpectSuccess(t, archive.Close())
+	u = NewUntar(bytes.NewReader(buffer.Bytes()), dir)
+	u.Whiteouts = true
+	tt.TestExpectError(t, u.Extract())
+	tt.TestTrue(t, exists("keep"))
+}
+
+func TestUntarFilters(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	buffer := bytes.NewBufferString("")
+	archive := tar.NewWriter(buffer)
+	for _, name := range []string{"./a.txt", "./b.log", "./keep.log", "./dir/c.txt", "./dir/d.bin"} {
+		header := &tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644 | c_ISREG,
+			ModTime:  time.Now(),
+			Size:     int64(len(name)),
+		}
+		tt.TestExpectSuccess(t, archive.WriteHeader(header))
+		_, err := archive.Write([]byte(name))
+		tt.TestExpectSuccess(t, err)
+	}
+	tt.TestExpectSuccess(t, archive.Close())
+
+	tempDir := testHelper.TempDir()
+	u := NewUntar(bytes.NewReader(buffer.Bytes()), tempDir)
+	u.ExcludePath(".*\\.log")
+	u.IncludePath("keep\\.log")
+	filtered := []string{}
+	u.FilterFunc = func(header *tar.Header) (bool, error) {
+		filtered = append(filtered, header.Name)
+		return !strings.HasSuffix(header.Name, ".bin"), nil
+	}
+	tt.TestExpectSuccess(t, u.Extract())
+	tt.TestEqual(t, filtered, []string{"./a.txt", "./keep.log", "./dir/c.txt", "./dir/d.bin"})
+
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(tempDir, name))
+		return err == nil
+	}
+	tt.TestTrue(t, exists("a.txt"))
+	tt.TestFalse(t, exists("b.log"))
+	tt.TestTrue(t, exists("keep.log"))
+	tt.TestTrue(t, exists("dir/c.txt"))
+	tt.TestFalse(t, exists("dir/d.bin"))
+
+	// errors from the FilterFunc abort the extraction
+	u = NewUntar(bytes.NewReader(buffer.Bytes()), testHelper.TempDir())
+	u.FilterFunc = func(header *tar.Header) (bool, error) {
+		return false, fmt.Errorf("filter failed")
+	}
+	tt.TestExpectError(t, u.Extract())
+}
+
+func TestUntarHeaderHandlers(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	buffer := bytes.NewBufferString("")
+	archive := tar.NewWriter(buffer)
+	for _, name := range []string{"./a", "./b", "./c"} {
+		header := &tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644 | c_ISREG,
+			ModTime:  time.Now(),
+			Size:     int64(len(name)),
+		}
+		tt.TestExpectSuccess(t, archive.WriteHeader(header))
+		_, err := archive.Write([]byte(name))
+		tt.TestExpectSuccess(t, err)
+	}
+	tt.TestExpectSuccess(t, archive.Close())
+
+	tempDir := testHelper.TempDir()
+	u := NewUntar(bytes.NewReader(buffer.Bytes()), tempDir)
+	u.HeaderHandlers = []UntarHeaderHandler{
+		// skip b
+		func(header *tar.Header) (bool, error) {
+			return header.Name == "./b", nil
+		},
+		// move everything else under prefix
+		func(header *tar.Header) (bool, error) {
+			header.Name = path.Join("prefix", header.Name)
+			return false, nil
+		},
+	}
+	tt.TestExpectSuccess(t, u.Extract())
+
+	contents, err := ioutil.ReadFile(filepath.Join(tempDir, "prefix", "a"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, string(contents), "./a")
+	_, err = os.Stat(filepath.Join(tempDir, "prefix", "c"))
+	tt.TestExpectSuccess(t, err)
+	_, err = os.Stat(filepath.Join(tempDir, "prefix", "b"))
+	tt.TestTrue(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(tempDir, "a"))
+	tt.TestTrue(t, os.IsNotExist(err))
+
+	// rewritten names are still checked
+	u = NewUntar(bytes.NewReader(buffer.Bytes()), testHelper.TempDir())
+	u.HeaderHandlers = []UntarHeaderHandler{
+		func(header *tar.Header) (bool, error) {
+			header.Name = "../escape"
+			return false, nil
+		},
+	}
+	tt.TestExpectError(t, u.Extract())
+
+	// and errors abort the extraction
+	u = NewUntar(bytes.NewReader(buffer.Bytes()), testHelper.TempDir())
+	u.HeaderHandlers = []UntarHeaderHandler{
+		func(header *tar.Header) (bool, error) {
+			return false, fmt.Errorf("handler failed")
+		},
+	}
+	tt.TestExpectError(t, u.Extract())
+}
+
+func TestUntarListAndVerify(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	makeArchive := func(headers ...*tar.Header) []byte {
+		buffer := bytes.NewBufferString("")
+		archive := tar.NewWriter(buffer)
+		for _, header := range headers {
+			header.ModTime = time.Now()
+			if header.Typeflag == tar.TypeReg {
+				header.Size = int64(len(header.Name))
+			}
+			tt.TestExpectSuccess(t, archive.WriteHeader(header))
+			if header.Typeflag == tar.TypeReg {
+				_, err := archive.Write([]byte(header.Name))
+				tt.TestExpectSuccess(t, err)
+			}
+		}
+		tt.TestExpectSuccess(t, archive.Close())
+		return buffer.Bytes()
+	}
+
+	valid := makeArchive(
+		&tar.Header{Name: "./dir/", Typeflag: tar.TypeDir, Mode: 0755},
+		&tar.Header{Name: "./dir/a", Typeflag: tar.TypeReg, Mode: 0644},
+		&tar.Header{Name: "./dir/b", Typeflag: tar.TypeLink, Linkname: "./dir/a"},
+		&tar.Header{Name: "./dir/c", Typeflag: tar.TypeSymlink, Linkname: "../dir/a"},
+		&tar.Header{Name: "./dir/d", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+	)
+
+	tempDir := testHelper.TempDir()
+	headers, err := NewUntar(bytes.NewReader(valid), tempDir).List()
+	tt.TestExpectSuccess(t, err)
+	names := []string{}
+	for _, header := range headers {
+		names = append(names, header.Name)
+	}
+	tt.TestEqual(t, names, []string{"./dir/", "./dir/a", "./dir/b", "./dir/c", "./dir/d"})
+	tt.TestExpectSuccess(t, NewUntar(bytes.NewReader(valid), tempDir).Verify())
+
+	// nothing is extracted
+	files, err := ioutil.ReadDir(tempDir)
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(files), 0)
+
+	// compressed archives are checked through to the end
+	compressed := bytes.NewBufferString("")
+	gz := gzip.NewWriter(compressed)
+	_, err = gz.Write(valid)
+	tt.TestExpectSuccess(t, err)
+	tt.TestExpectSuccess(t, gz.Close())
+	u := NewUntar(bytes.NewReader(compressed.Bytes()), tempDir)
+	u.Compression = DETECT
+	tt.TestExpectSuccess(t, u.Verify())
+	corrupt := append([]byte(nil), compressed.Bytes()...)
+	corrupt[len(corrupt)-5] ^= 0xff
+	u = NewUntar(bytes.NewReader(corrupt), tempDir)
+	u.Compression = GZIP
+	tt.TestExpectError(t, u.Verify())
+
+	// invalid archives
+	invalid := [][]byte{
+		makeArchive(&tar.Header{Name: "../escape", Typeflag: tar.TypeReg, Mode: 0644}),
+		makeArchive(&tar.Header{Name: "/abs", Typeflag: tar.TypeReg, Mode: 0644}),
+		makeArchive(&tar.Header{Name: "./link", Typeflag: tar.TypeLink, Linkname: "../escape"}),
+		makeArchive(&tar.Header{Name: "./dir/link", Typeflag: tar.TypeSymlink, Linkname: "../../escape"}),
+		valid[:len(valid)-1024-100],
+	}
+	for _, archive := range invalid {
+		tt.TestExpectError(t, NewUntar(bytes.NewReader(archive), tempDir).Verify())
+		_, err := NewUntar(bytes.NewReader(archive), tempDir).List()
+		tt.TestExpectError(t, err)
+	}
+
+	// limits are enforced
+	u = NewUntar(bytes.NewReader(valid), tempDir)
+	u.MaxEntries = 2
+	err = u.Verify()
+	_, ok := err.(*ExtractLimitError)
+	tt.TestTrue(t, ok)
+}
+
+func TestUntarDeferHardLinks(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	// links appear before their targets, including a link to a link
+	buffer := bytes.NewBufferString("")
+	archive := tar.NewWriter(buffer)
+	for _, header := range []*tar.Header{
+		{Name: "./link2", Typeflag: tar.TypeLink, Linkname: "./link1"},
+		{Name: "./link1", Typeflag: tar.TypeLink, Linkname: "./file"},
+		{Name: "./file", Typeflag: tar.TypeReg, Mode: 0644 | c_ISREG, Size: 4},
+	} {
+		header.ModTime = time.Now()
+		tt.TestExpectSuccess(t, archive.WriteHeader(header))
+		if header.Typeflag == tar.TypeReg {
+			_, err := archive.Write([]byte("file"))
+			tt.TestExpectSuccess(t, err)
+		}
+	}
+	tt.TestExpectSuccess(t, archive.Close())
+
+	// without deferring the links, extraction fails
+	u := NewUntar(bytes.NewReader(buffer.Bytes()), testHelper.TempDir())
+	tt.TestExpectError(t, u.Extract())
+
+	tempDir := testHelper.TempDir()
+	u = NewUntar(bytes.NewReader(buffer.Bytes()), tempDir)
+	u.DeferHardLinks = true
+	tt.TestExpectSuccess(t, u.Extract())
+
+	fileInfo, err := os.Stat(filepath.Join(tempDir, "file"))
+	tt.TestExpectSuccess(t, err)
+	for _, name := range []string{"link1", "link2"} {
+		linkInfo, err := os.Stat(filepath.Join(tempDir, name))
+		tt.TestExpectSuccess(t, err)
+		tt.TestTrue(t, os.SameFile(fileInfo, linkInfo))
+	}
+
+	// links whose target never appears still fail
+	buffer = bytes.NewBufferString("")
+	archive = tar.NewWriter(buffer)
+	tt.TestExpectSuccess(t, archive.WriteHeader(&tar.Header{
+		Name:     "./link",
+		Typeflag: tar.TypeLink,
+		Linkname: "./missing",
+		ModTime:  time.Now(),
+	}))
+	tt.TestExpectSuccess(t, archive.Close())
+
+	u = NewUntar(bytes.NewReader(buffer.Bytes()), testHelper.TempDir())
+	u.DeferHardLinks = true
+	err = u.Extract()
+	tt.TestExpectError(t, err)
+	tt.TestTrue(t, strings.Contains(err.Error(), "./link"))
+}
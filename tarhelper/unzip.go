@@ -0,0 +1,306 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package tarhelper
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// UnzipCustomHandler is like UntarCustomHandler for Unzip. It is passed the
+// directory being extracted into and the entry. Returning true skips the built
+// in handling of the entry.
+type UnzipCustomHandler func(rootpath string, file *zip.File) (bool, error)
+
+// Unzip manages state of a zip archive to be extracted. It mirrors Untar for
+// archives which are in the zip format.
+type Unzip struct {
+	// The directory that the files will be extracted into.
+	target string
+
+	// The source, which must be seekable since the zip directory is at the
+	// end of the archive.
+	source io.ReaderAt
+	size   int64
+
+	// ignorePaths are the patterns added by IncludePath and ExcludePath.
+	ignorePaths []ignoreInfo
+
+	// PreservePermissions can be set to false to extract files and
+	// directories with basic permissions rather than those in the archive.
+	// NewUnzip sets it to true.
+	PreservePermissions bool
+
+	// IncludedPermissionMask is combined with the permissions of each entry
+	// when PreservePermissions is set. See Untar.IncludedPermissionMask.
+	IncludedPermissionMask os.FileMode
+
+	// CustomHandlers can inject custom logic for how to handle entries being
+	// extracted. See Untar.CustomHandlers.
+	CustomHandlers []UnzipCustomHandler
+}
+
+// NewUnzip returns an Unzip to use to extract the zip archive of the given size
+// read from r into targetDir. Extraction is handled by Extract().
+func NewUnzip(r io.ReaderAt, size int64, targetDir string) *Unzip {
+	return &Unzip{
+		target:              targetDir,
+		source:              r,
+		size:                size,
+		PreservePermissions: true,
+	}
+}
+
+// ExcludePath appends a path, file, or pattern relative to the root of the
+// archive for entries which are not extracted. See Untar.ExcludePath.
+func (u *Unzip) ExcludePath(pathRE string) {
+	if pathRE != "" {
+		re, err := regexp.Compile("^" + pathRE + "$")
+		if err != nil {
+			return
+		}
+		u.ignorePaths = append(u.ignorePaths, ignoreInfo{regexp: re, exclude: true, dirOnly: false})
+	}
+}
+
+// IncludePath appends a path, file, or pattern relative to the root of the
+// archive for entries which are extracted even if they matched a previous
+// ExcludePath. See Untar.IncludePath.
+func (u *Unzip) IncludePath(pathRE string) {
+	if pathRE != "" {
+		re, err := regexp.Compile("^" + pathRE + "$")
+		if err != nil {
+			return
+		}
+		u.ignorePaths = append(u.ignorePaths, ignoreInfo{regexp: re, exclude: false, dirOnly: false})
+	}
+}
+
+// Extract unpacks the archive. Entries are never written through a symlink:
+// an entry whose parent directory is a symlink is rejected, as is a symlink
+// which resolves to somewhere outside of the target directory. Symlinks are
+// created after every other entry has been extracted, and are checked again
+// once they all exist since later links can change where earlier ones lead.
+func (u *Unzip) Extract() error {
+	r, err := zip.NewReader(u.source, u.size)
+	if err != nil {
+		return err
+	}
+
+	var symlinks []*zip.File
+	for _, file := range r.File {
+		if file.Mode()&os.ModeSymlink != 0 {
+			symlinks = append(symlinks, file)
+			continue
+		}
+		if err := u.processEntry(file); err != nil {
+			return err
+		}
+	}
+	for _, file := range symlinks {
+		if err := u.processEntry(file); err != nil {
+			return err
+		}
+	}
+	for _, file := range symlinks {
+		name := filepath.FromSlash(strings.TrimSuffix(file.Name, "/"))
+		if _, err := os.Lstat(filepath.Join(u.target, name)); err != nil {
+			// skipped by a filter or custom handler
+			continue
+		}
+		if _, err := u.resolveWithin(name); err != nil {
+			os.Remove(filepath.Join(u.target, name))
+			return fmt.Errorf("invalid symlink %q: %v", file.Name, err)
+		}
+	}
+	return nil
+}
+
+// checkParents returns an error if any of the existing parent directories of
+// name, which is relative to the target, is a symlink.
+func (u *Unzip) checkParents(name string) error {
+	dir := u.target
+	parts := strings.Split(filepath.Dir(name), string(os.PathSeparator))
+	for _, part := range parts {
+		if part == "." {
+			continue
+		}
+		dir = filepath.Join(dir, part)
+		fi, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("parent directory %q is a symlink", part)
+		}
+	}
+	return nil
+}
+
+// maxSymlinkHops limits the symlinks followed by resolveWithin, so that loops
+// are detected.
+const maxSymlinkHops = 255
+
+// resolveWithin resolves name, which is relative to the target, following any
+// symlinks which exist on disk, and returns the resolved path relative to the
+// target. An error is returned if the path leads outside of the target.
+func (u *Unzip) resolveWithin(name string) (string, error) {
+	var resolved []string
+	parts := strings.Split(name, string(os.PathSeparator))
+	hops := 0
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return "", fmt.Errorf("%q leads outside of the target directory", name)
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+
+		current := filepath.Join(append([]string{u.target}, append(resolved, part)...)...)
+		fi, err := os.Lstat(current)
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			resolved = append(resolved, part)
+			continue
+		}
+		if hops++; hops > maxSymlinkHops {
+			return "", fmt.Errorf("too many levels of symlinks in %q", name)
+		}
+		link, err := os.Readlink(current)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(link) {
+			return "", fmt.Errorf("%q leads to the absolute path %q", name, link)
+		}
+		// the link is relative to the directory containing it
+		parts = append(strings.Split(link, string(os.PathSeparator)), parts...)
+	}
+	return filepath.Join(resolved...), nil
+}
+
+// processEntry extracts a single entry of the archive.
+func (u *Unzip) processEntry(file *zip.File) error {
+	// zip names always use forward slashes
+	name := strings.TrimSuffix(file.Name, "/")
+	if name == "" || name == "." {
+		return nil
+	}
+	if path.IsAbs(name) || strings.Contains(name, `\`) {
+		return fmt.Errorf("invalid name %q: No absolute paths allowed.", file.Name)
+	}
+	if err := checkName(filepath.FromSlash(name)); err != nil {
+		return fmt.Errorf("invalid name %q: %v", file.Name, err)
+	}
+
+	mode := file.Mode()
+	if matchIgnorePaths(u.ignorePaths, name, mode.IsDir()) {
+		return nil
+	}
+
+	for _, handler := range u.CustomHandlers {
+		bypass, err := handler(u.target, file)
+		if err != nil {
+			return err
+		}
+		if bypass {
+			return nil
+		}
+	}
+
+	if err := u.checkParents(filepath.FromSlash(name)); err != nil {
+		return fmt.Errorf("invalid name %q: %v", file.Name, err)
+	}
+	dest := filepath.Join(u.target, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	switch {
+	case mode.IsDir():
+		perm := os.FileMode(0755)
+		if u.PreservePermissions {
+			perm = mode.Perm() | u.IncludedPermissionMask
+		}
+		if fi, err := os.Lstat(dest); err == nil && !fi.IsDir() {
+			os.RemoveAll(dest)
+		}
+		if err := os.MkdirAll(dest, perm); err != nil {
+			return err
+		}
+		return os.Chmod(dest, perm)
+
+	case mode&os.ModeSymlink != 0:
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		link, err := ioutil.ReadAll(io.LimitReader(rc, 4096))
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if len(link) == 0 {
+			return fmt.Errorf("empty symlink target for %q", file.Name)
+		}
+		target := filepath.FromSlash(string(link))
+		if filepath.IsAbs(target) || strings.Contains(string(link), `\`) {
+			return fmt.Errorf("invalid symlink %q: No absolute paths allowed.", file.Name)
+		}
+		// not filepath.Join, which would drop ".." elements without
+		// following the links before them
+		linked := filepath.Dir(filepath.FromSlash(name)) + string(os.PathSeparator) + target
+		if _, err := u.resolveWithin(linked); err != nil {
+			return fmt.Errorf("invalid symlink %q: %v", file.Name, err)
+		}
+		os.RemoveAll(dest)
+		return os.Symlink(target, dest)
+
+	case mode&os.ModeType == 0:
+		perm := os.FileMode(0644)
+		if u.PreservePermissions {
+			perm = mode.Perm() | u.IncludedPermissionMask
+		}
+		os.RemoveAll(dest)
+		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		// Perform a chmod after creation to ensure modes are applied directly,
+		// regardless of umask.
+		if err := os.Chmod(dest, perm); err != nil {
+			return err
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		// reading to the end verifies the entry's checksum
+		if _, err := io.Copy(f, rc); err != nil {
+			return fmt.Errorf("failed to extract %q: %v", file.Name, err)
+		}
+		return f.Close()
+
+	default:
+		// special files can't be represented in zip archives
+		return nil
+	}
+}
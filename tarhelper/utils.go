@@ -18,6 +18,19 @@ func defaultMappingFunc(id int) (int, error) {
 // compression type to use, if any. It will return a *tar.Reader that can be
 // used to read the archive.
 func DetectArchiveCompression(r io.Reader) (*tar.Reader, error) {
+	arch, err := detectDecompression(r)
+	if err != nil {
+		return nil, err
+	}
+	if cl, ok := arch.(io.ReadCloser); ok {
+		defer cl.Close()
+	}
+	return tar.NewReader(arch), nil
+}
+
+// detectDecompression returns a reader for the decompressed contents of r,
+// determining the compression type from the data.
+func detectDecompression(r io.Reader) (io.Reader, error) {
 	var comp Decompressor
 
 	// setup a buffered reader
@@ -34,18 +47,8 @@ func DetectArchiveCompression(r io.Reader) (*tar.Reader, error) {
 	// Create the reader if a compression handler was found, else fall back on
 	// using no compression.
 	if comp != nil {
-		// Create the reader
-		arch, err := comp.NewReader(br)
-		if err != nil {
-			return nil, err
-		}
-		defer func() {
-			if cl, ok := arch.(io.ReadCloser); ok {
-				cl.Close()
-			}
-		}()
-		return tar.NewReader(arch), nil
+		return comp.NewReader(br)
 	}
 
-	return tar.NewReader(br), nil
+	return br, nil
 }
@@ -0,0 +1,102 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package tarhelper
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// List reads the whole archive without extracting it and returns the headers
+// of its entries. The archive is validated as it would be by Verify, and an
+// error is returned for the first problem found.
+func (u *Untar) List() ([]*tar.Header, error) {
+	var headers []*tar.Header
+	err := u.walk(func(header *tar.Header) {
+		headers = append(headers, header)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// Verify reads the whole archive without extracting it, checking that the
+// names and link targets of its entries would be allowed by Extract, that it
+// is within the MaxTotalSize, MaxEntrySize, and MaxEntries limits, and that
+// the archive is intact, including the header checksums and the checksums of
+// the compression. This can be used to cheaply validate an untrusted archive
+// before extracting it. Like Extract, it consumes the source reader.
+func (u *Untar) Verify() error {
+	return u.walk(func(*tar.Header) {})
+}
+
+// walk validates each entry of the archive, passing it to f.
+func (u *Untar) walk(f func(header *tar.Header)) error {
+	stream, closeArchive, err := u.openArchive()
+	if err != nil {
+		return err
+	}
+	defer closeArchive()
+
+	var totalSize int64
+	for index := 0; ; index++ {
+		header, err := u.archive.Next()
+		if err == io.EOF {
+			// read through to the end of the stream so the decompressor
+			// verifies its trailing checksum
+			_, err := io.Copy(ioutil.Discard, stream)
+			return err
+		} else if err != nil {
+			return err
+		}
+
+		totalSize += header.Size
+		if err := u.checkLimits(header, index+1, totalSize); err != nil {
+			return err
+		}
+		if err := checkName(header.Name); err != nil {
+			return fmt.Errorf("invalid name %q: %v", header.Name, err)
+		}
+		if err := checkLinkTarget(header); err != nil {
+			return err
+		}
+
+		// reading the data verifies the archive isn't truncated and the
+		// decompressor's checksums
+		if _, err := io.Copy(ioutil.Discard, u.archive); err != nil {
+			return fmt.Errorf("failed to read %q: %v", header.Name, err)
+		}
+
+		f(header)
+	}
+}
+
+// checkLinkTarget validates the target of a link entry. Hard links must refer
+// to another entry within the archive. Symlinks may be absolute, since they are
+// resolved relative to Untar.AbsoluteRoot, but relative symlinks may not point
+// outside of the archive.
+func checkLinkTarget(header *tar.Header) error {
+	switch header.Typeflag {
+	case tar.TypeLink:
+		if err := checkName(header.Linkname); err != nil {
+			return fmt.Errorf("invalid hard link target %q for %q: %v", header.Linkname, header.Name, err)
+		}
+	case tar.TypeSymlink:
+		if header.Linkname == "" {
+			return fmt.Errorf("empty symlink target for %q", header.Name)
+		}
+		if path.IsAbs(header.Linkname) {
+			return nil
+		}
+		target := path.Join(path.Dir(path.Clean(header.Name)), header.Linkname)
+		if target == ".." || strings.HasPrefix(target, "../") {
+			return fmt.Errorf("symlink target %q for %q is outside of the archive", header.Linkname, header.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package tarhelper
+
+import (
+	"archive/tar"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// AUFS style whiteout markers, as used within Docker image layers. A file named
+// with whiteoutPrefix marks the file of the same name without the prefix as
+// deleted, and a whiteoutOpaque file marks its directory as opaque.
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = whiteoutPrefix + whiteoutPrefix + ".opq"
+)
+
+// Extended attributes used by overlayfs.
+const (
+	overlayXattrPrefix = "trusted.overlay."
+	overlayOpaqueXattr = overlayXattrPrefix + "opaque"
+)
+
+// whiteoutName returns the name of the whiteout entry for name.
+func whiteoutName(name string) string {
+	dir, base := filepath.Split(name)
+	return filepath.Join(dir, whiteoutPrefix+base)
+}
+
+// parseWhiteout checks whether the cleaned entry name is a whiteout. For a
+// whiteout it returns the name of the deleted entry, and for an opaque marker
+// it returns the name of the directory with opaque set.
+func parseWhiteout(name string) (target string, opaque bool, ok bool) {
+	dir, base := path.Split(name)
+	switch {
+	case base == whiteoutOpaque:
+		return path.Clean(dir), true, true
+	case strings.HasPrefix(base, whiteoutPrefix):
+		return dir + strings.TrimPrefix(base, whiteoutPrefix), false, true
+	}
+	return "", false, false
+}
+
+// processWhiteout records the entry for whiteout processing, and applies it if
+// it is a whiteout. It returns true if the entry was a whiteout, in which case
+// it must not be extracted.
+func (u *Untar) processWhiteout(header *tar.Header) (bool, error) {
+	if err := checkName(header.Name); err != nil {
+		return false, err
+	}
+	name := path.Clean(header.Name)
+	target, opaque, ok := parseWhiteout(name)
+	switch {
+	case !ok:
+		// parent directories may not have their own entries
+		for p := name; p != "." && p != "/" && !u.extracted[p]; p = path.Dir(p) {
+			u.extracted[p] = true
+		}
+		return false, nil
+	case opaque:
+		u.opaqueDirs = append(u.opaqueDirs, target)
+		return true, nil
+	}
+
+	// the deleted name must pass the same checks as any other entry, to
+	// ensure names like ".wh..." can't escape the target, and must name
+	// something within its directory
+	if err := checkName(target); err != nil {
+		return false, err
+	}
+	if path.Base(target) == "." {
+		return false, fmt.Errorf("Invalid whiteout %q.", header.Name)
+	}
+	deleted := &tar.Header{Name: target}
+	if !u.checkEntryAgainstWhitelist(deleted) {
+		return true, nil
+	}
+	if include, err := u.checkEntryAgainstFilters(deleted); err != nil || !include {
+		return true, err
+	}
+	dest, err := u.destination(deleted)
+	if err != nil {
+		return false, err
+	}
+	return true, os.RemoveAll(dest)
+}
+
+// applyOpaqueDirs removes the contents of opaque directories which were not
+// extracted from the current archive. This is done once the whole archive is
+// extracted since the marker may come after entries within the directory.
+func (u *Untar) applyOpaqueDirs() error {
+	for _, dir := range u.opaqueDirs {
+		if err := checkName(dir); err != nil {
+			return err
+		}
+		if err := u.removeNotExtracted(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeNotExtracted recursively removes everything within dir which was not
+// extracted from the current archive.
+func (u *Untar) removeNotExtracted(dir string) error {
+	dest, err := u.resolveDestination(filepath.Join(u.target, dir))
+	if err != nil {
+		return err
+	}
+	entries, err := ioutil.ReadDir(dest)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := path.Join(dir, e.Name())
+		switch {
+		case !u.extracted[name]:
+			if err := os.RemoveAll(filepath.Join(dest, e.Name())); err != nil {
+				return err
+			}
+		case e.IsDir():
+			if err := u.removeNotExtracted(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// +build linux
+
+package tarhelper
+
+import (
+	"bytes"
+	"syscall"
+)
+
+// getXattrs returns the extended attributes of the file. Filesystems which do
+// not support extended attributes return no attributes rather than an error.
+func getXattrs(path string) (map[string]string, error) {
+	// ask for the size of the list first
+	sz, err := syscall.Listxattr(path, nil)
+	if err == syscall.ENOTSUP {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	} else if sz == 0 {
+		return nil, nil
+	}
+	buf := make([]byte, sz)
+	sz, err = syscall.Listxattr(path, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	xattrs := make(map[string]string)
+	for _, name := range bytes.Split(buf[:sz], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		sz, err := syscall.Getxattr(path, string(name), nil)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, sz)
+		sz, err = syscall.Getxattr(path, string(name), value)
+		if err != nil {
+			return nil, err
+		}
+		xattrs[string(name)] = string(value[:sz])
+	}
+	return xattrs, nil
+}
+
+// setXattr sets an extended attribute on the file.
+func setXattr(path, name, value string) error {
+	return syscall.Setxattr(path, name, []byte(value), 0)
+}
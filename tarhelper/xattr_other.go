@@ -0,0 +1,21 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// +build !linux
+
+package tarhelper
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// getXattrs returns no attributes as they are not supported on this platform.
+func getXattrs(path string) (map[string]string, error) {
+	return nil, nil
+}
+
+// setXattr returns an error as extended attributes are not supported on this
+// platform.
+func setXattr(path, name, value string) error {
+	return fmt.Errorf("extended attributes are not supported on %s", runtime.GOOS)
+}
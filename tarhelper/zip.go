@@ -0,0 +1,254 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package tarhelper
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ZipCustomHandler is like TarCustomHandler for Zip. It is passed the path of
+// the entry on disk, its os.FileInfo, and the *zip.FileHeader which will be
+// written for it. Returning true writes the header without any contents in
+// place of the built in handling.
+type ZipCustomHandler func(fullpath string, fileInfo os.FileInfo, header *zip.FileHeader) (bool, error)
+
+// Zip manages state for a zip archive. It mirrors Tar for archives which need
+// to be in the zip format. Zip has no equivalent of hard links, special files,
+// or ownership, so hard links are written as regular files and special files
+// are skipped.
+type Zip struct {
+	target string
+
+	// The destination writer
+	dest io.Writer
+
+	// The zip writer, set while Archive is running.
+	archive *zip.Writer
+
+	// Method is the compression method used for files, either zip.Deflate or
+	// zip.Store. NewZip sets it to zip.Deflate.
+	Method uint16
+
+	// Set to true if archiving should attempt to preserve permissions as they
+	// are on the filesystem. If this is false then files will be archived
+	// with basic file/directory permissions.
+	IncludePermissions bool
+
+	// ignorePaths contains the patterns added by ExcludePath, IncludePath,
+	// ExcludeRegexp, and IncludeRegexp.
+	ignorePaths []ignoreInfo
+
+	// If set, this will be a virtual path that is prepended to the file
+	// location. See Tar.VirtualPath.
+	VirtualPath string
+
+	// CustomHandlers can inject custom logic for how to handle entries being
+	// written to the zip file. See Tar.CustomHandlers.
+	CustomHandlers []ZipCustomHandler
+}
+
+// NewZip returns a Zip ready to write the contents of targetDir to w.
+func NewZip(w io.Writer, targetDir string) *Zip {
+	return &Zip{
+		target:             targetDir,
+		dest:               w,
+		Method:             zip.Deflate,
+		IncludePermissions: true,
+	}
+}
+
+// Archive writes the zip archive.
+func (z *Zip) Archive() error {
+	z.archive = zip.NewWriter(z.dest)
+	defer func() {
+		if z.archive != nil {
+			z.archive.Close()
+			z.archive = nil
+		}
+	}()
+
+	// ensure the target exists
+	f, err := os.Stat(z.target)
+	if err != nil {
+		return err
+	}
+
+	// If the target is a file rather than a directory, archive just that file.
+	startFullName := "."
+	if !f.IsDir() {
+		z.target = filepath.Dir(z.target)
+		startFullName = f.Name()
+	}
+
+	if err := z.processEntry(startFullName, f); err != nil {
+		return err
+	}
+
+	err = z.archive.Close()
+	z.archive = nil
+	return err
+}
+
+// ExcludePath appends a path, file, or pattern relative to the toplevel path to
+// be archived that is then excluded from the final archive. See
+// Tar.ExcludePath.
+func (z *Zip) ExcludePath(pathRE string) {
+	if pathRE != "" {
+		re, err := regexp.Compile("^" + pathRE + "$")
+		if err != nil {
+			return
+		}
+		z.ignorePaths = append(z.ignorePaths, ignoreInfo{regexp: re, exclude: true, dirOnly: false})
+	}
+}
+
+// IncludePath appends a path, file, or pattern relative to the toplevel path to
+// be archived that is included even if it matched a previous ExcludePath. See
+// Tar.IncludePath.
+func (z *Zip) IncludePath(pathRE string) {
+	if pathRE != "" {
+		re, err := regexp.Compile("^" + pathRE + "$")
+		if err != nil {
+			return
+		}
+		z.ignorePaths = append(z.ignorePaths, ignoreInfo{regexp: re, exclude: false, dirOnly: false})
+	}
+}
+
+// IncludeRegexp is like Tar.IncludeRegexp.
+func (z *Zip) IncludeRegexp(re *regexp.Regexp, dirOnly bool) {
+	z.ignorePaths = append(z.ignorePaths, ignoreInfo{regexp: re, exclude: false, dirOnly: dirOnly})
+}
+
+// ExcludeRegexp is like Tar.ExcludeRegexp.
+func (z *Zip) ExcludeRegexp(re *regexp.Regexp, dirOnly bool) {
+	z.ignorePaths = append(z.ignorePaths, ignoreInfo{regexp: re, exclude: true, dirOnly: dirOnly})
+}
+
+func (z *Zip) processEntry(fullName string, f os.FileInfo) error {
+	if matchIgnorePaths(z.ignorePaths, fullName, f.IsDir()) {
+		return nil
+	}
+
+	mode := f.Mode()
+	if mode&(os.ModeDevice|os.ModeCharDevice|os.ModeNamedPipe|os.ModeSocket) != 0 {
+		// zip has no representation for special files
+		return nil
+	}
+
+	header, err := zip.FileInfoHeader(f)
+	if err != nil {
+		return err
+	}
+	header.Name = path.Clean(path.Join(filepath.ToSlash(z.VirtualPath), filepath.ToSlash(fullName)))
+	if mode.IsRegular() {
+		header.Method = z.Method
+	} else {
+		header.Method = zip.Store
+	}
+
+	if !z.IncludePermissions {
+		switch {
+		case f.IsDir():
+			header.SetMode(os.ModeDir | 0755)
+		case mode&os.ModeSymlink != 0:
+			header.SetMode(os.ModeSymlink | 0755)
+		default:
+			header.SetMode(0644)
+		}
+	}
+
+	// Check for any custom handlers that will process it.
+	for _, handler := range z.CustomHandlers {
+		bypass, err := handler(filepath.Join(z.target, fullName), f, header)
+		if err != nil {
+			return err
+		}
+		if bypass {
+			_, err := z.archive.CreateHeader(header)
+			return err
+		}
+	}
+
+	switch {
+	case f.IsDir():
+		// the root of the archive has no entry of its own
+		if header.Name != "." {
+			header.Name += "/"
+			if _, err := z.archive.CreateHeader(header); err != nil {
+				return err
+			}
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(z.target, fullName))
+		if err != nil {
+			return err
+		}
+		for _, child := range files {
+			if err := z.processEntry(filepath.Join(fullName, child.Name()), child); err != nil {
+				return err
+			}
+		}
+
+	case mode&os.ModeSymlink != 0:
+		// links are stored as a file containing the link target, as done by
+		// Info-ZIP, made relative if they point within the target like Tar
+		link, err := cleanLinkName(z.target, fullName)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(link, z.target) {
+			link, err = filepath.Rel(filepath.Join(z.target, filepath.Dir(fullName)), link)
+			if err != nil {
+				return err
+			}
+		}
+		w, err := z.archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, filepath.ToSlash(link)); err != nil {
+			return err
+		}
+
+	default:
+		w, err := z.archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		data, err := os.Open(filepath.Join(z.target, fullName))
+		if err != nil {
+			return err
+		}
+		defer data.Close()
+		if _, err := io.Copy(w, data); err != nil {
+			return fmt.Errorf("failed to archive %q: %v", fullName, err)
+		}
+	}
+
+	return nil
+}
+
+// matchIgnorePaths returns whether the name is excluded by the patterns.
+// Patterns are considered in order so that names excluded by one can be
+// reincluded by a later one.
+func matchIgnorePaths(ignorePaths []ignoreInfo, name string, isDir bool) bool {
+	name = filepath.ToSlash(filepath.Clean(name))
+	exclude := false
+	for _, re := range ignorePaths {
+		if re.regexp.MatchString(name) || re.regexp.MatchString(path.Base(name)) {
+			if !re.dirOnly || isDir {
+				exclude = re.exclude
+			}
+		}
+	}
+	return exclude
+}
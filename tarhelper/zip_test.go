@@ -0,0 +1,204 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package tarhelper
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+func TestZipRoundTrip(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks are not created on windows")
+	}
+
+	dir := testHelper.TempDir()
+	tt.TestExpectSuccess(t, os.MkdirAll(path.Join(dir, "bin"), 0750))
+	tt.TestExpectSuccess(t, ioutil.WriteFile(path.Join(dir, "bin", "run"), []byte("#!/bin/sh\n"), 0755))
+	tt.TestExpectSuccess(t, ioutil.WriteFile(path.Join(dir, "data"), bytes.Repeat([]byte("data"), 1000), 0600))
+	tt.TestExpectSuccess(t, ioutil.WriteFile(path.Join(dir, "skip.log"), []byte("log"), 0644))
+	tt.TestExpectSuccess(t, os.Symlink(path.Join(dir, "bin", "run"), path.Join(dir, "link")))
+
+	buffer := bytes.NewBufferString("")
+	z := NewZip(buffer, dir)
+	z.VirtualPath = "app"
+	z.ExcludePath(`.*\.log`)
+	tt.TestExpectSuccess(t, z.Archive())
+
+	r, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	tt.TestExpectSuccess(t, err)
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	tt.TestEqual(t, names, []string{"app/", "app/bin/", "app/bin/run", "app/data", "app/link"})
+
+	extracted := testHelper.TempDir()
+	u := NewUnzip(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()), extracted)
+	tt.TestExpectSuccess(t, u.Extract())
+
+	fi, err := os.Stat(path.Join(extracted, "app", "bin"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, fi.Mode().Perm(), os.FileMode(0750))
+	fi, err = os.Stat(path.Join(extracted, "app", "bin", "run"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, fi.Mode().Perm(), os.FileMode(0755))
+	contents, err := ioutil.ReadFile(path.Join(extracted, "app", "data"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, len(contents), 4000)
+	link, err := os.Readlink(path.Join(extracted, "app", "link"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, link, path.Join("bin", "run"))
+
+	// without permissions, basic modes are used
+	extracted = testHelper.TempDir()
+	u = NewUnzip(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()), extracted)
+	u.PreservePermissions = false
+	u.ExcludePath("app/bin(/.*)?")
+	tt.TestExpectSuccess(t, u.Extract())
+	fi, err = os.Stat(path.Join(extracted, "app", "data"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, fi.Mode().Perm(), os.FileMode(0644))
+	_, err = os.Stat(path.Join(extracted, "app", "bin"))
+	tt.TestTrue(t, os.IsNotExist(err))
+}
+
+func TestZipCustomHandlers(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	dir := testHelper.TempDir()
+	tt.TestExpectSuccess(t, ioutil.WriteFile(path.Join(dir, "a"), []byte("a"), 0644))
+	tt.TestExpectSuccess(t, ioutil.WriteFile(path.Join(dir, "b"), []byte("b"), 0644))
+
+	buffer := bytes.NewBufferString("")
+	z := NewZip(buffer, dir)
+	z.CustomHandlers = []ZipCustomHandler{
+		func(fullpath string, fileInfo os.FileInfo, header *zip.FileHeader) (bool, error) {
+			return header.Name == "b", nil
+		},
+	}
+	tt.TestExpectSuccess(t, z.Archive())
+
+	var seen []string
+	extracted := testHelper.TempDir()
+	u := NewUnzip(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()), extracted)
+	u.CustomHandlers = []UnzipCustomHandler{
+		func(rootpath string, file *zip.File) (bool, error) {
+			seen = append(seen, file.Name)
+			return file.Name == "a", nil
+		},
+	}
+	tt.TestExpectSuccess(t, u.Extract())
+	tt.TestEqual(t, seen, []string{"a", "b"})
+
+	_, err := os.Stat(path.Join(extracted, "a"))
+	tt.TestTrue(t, os.IsNotExist(err))
+	contents, err := ioutil.ReadFile(path.Join(extracted, "b"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, string(contents), "")
+}
+
+func TestUnzipInvalidNames(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	for _, name := range []string{"../escape", "/absolute", "a/../../escape", `..\escape`} {
+		buffer := bytes.NewBufferString("")
+		w := zip.NewWriter(buffer)
+		f, err := w.Create(name)
+		tt.TestExpectSuccess(t, err)
+		_, err = f.Write([]byte("data"))
+		tt.TestExpectSuccess(t, err)
+		tt.TestExpectSuccess(t, w.Close())
+
+		u := NewUnzip(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()), testHelper.TempDir())
+		tt.TestExpectError(t, u.Extract())
+	}
+}
+
+func TestUnzipSymlinkEscapes(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks are not created on windows")
+	}
+
+	type entry struct {
+		name, link, contents string
+	}
+	writeZip := func(entries ...entry) *bytes.Reader {
+		buffer := bytes.NewBufferString("")
+		w := zip.NewWriter(buffer)
+		for _, e := range entries {
+			header := &zip.FileHeader{Name: e.name}
+			data := e.contents
+			if e.link != "" {
+				header.SetMode(os.ModeSymlink | 0777)
+				data = e.link
+			} else {
+				header.SetMode(0644)
+			}
+			f, err := w.CreateHeader(header)
+			tt.TestExpectSuccess(t, err)
+			_, err = f.Write([]byte(data))
+			tt.TestExpectSuccess(t, err)
+		}
+		tt.TestExpectSuccess(t, w.Close())
+		return bytes.NewReader(buffer.Bytes())
+	}
+
+	tests := []struct {
+		name    string
+		entries []entry
+	}{
+		{"parent", []entry{{name: "a", link: ".."}}},
+		{"absolute", []entry{{name: "a", link: "/etc"}}},
+		// each link is fine on its own, but together c leads to the parent
+		// of the target
+		{"chain", []entry{{name: "c", link: "b/.."}, {name: "b", link: "a"}, {name: "a", link: "."}}},
+		{"through link", []entry{{name: "a", link: "."}, {name: "a/b", link: ".."}}},
+		// a is left by a previous extraction, linking to the parent
+		{"file through link", []entry{{name: "a/file", contents: "data"}}},
+	}
+	for _, test := range tests {
+		parent := testHelper.TempDir()
+		target := path.Join(parent, "target")
+		tt.TestExpectSuccess(t, os.Mkdir(target, 0755))
+		if test.name == "file through link" {
+			tt.TestExpectSuccess(t, os.Symlink(parent, path.Join(target, "a")))
+		}
+		r := writeZip(test.entries...)
+		u := NewUnzip(r, r.Size(), target)
+		tt.TestExpectError(t, u.Extract(), test.name)
+
+		// nothing leads outside of the target
+		_, err := os.Stat(path.Join(parent, "file"))
+		tt.TestTrue(t, os.IsNotExist(err))
+		for _, e := range test.entries {
+			if resolved, err := filepath.EvalSymlinks(path.Join(target, e.name)); err == nil {
+				tt.TestHasPrefix(t, resolved, target, test.name)
+			}
+		}
+	}
+
+	// links within the target are allowed
+	r := writeZip(entry{name: "dir/file", contents: "data"}, entry{name: "dir/link", link: "../dir/file"}, entry{name: "other", link: "dir"})
+	target := testHelper.TempDir()
+	tt.TestExpectSuccess(t, NewUnzip(r, r.Size(), target).Extract())
+	contents, err := ioutil.ReadFile(path.Join(target, "other", "link"))
+	tt.TestExpectSuccess(t, err)
+	tt.TestEqual(t, string(contents), "data")
+}
@@ -0,0 +1,228 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package testtool
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// eventuallyInterval is how often TestEventuallyEqual calls its function.
+var eventuallyInterval = 10 * time.Millisecond
+
+// -----------------------------------------------------------------------
+// Containment tests.
+// -----------------------------------------------------------------------
+
+// TestContains fails the test if have doesn't contain want. If have is a
+// string, want must be a substring of it. If have is a slice or array, one of
+// its elements must equal want as it would for TestEqual. If have is a map,
+// want must be one of its keys.
+func TestContains(t Logger, have, want interface{}, msg ...string) {
+	if !contains(t, have, want) {
+		Fatalf(t, "Not Contained%s\n%s\n%s",
+			reasonString(msg), haveLine("%#v", have), wantLine("%#v", want))
+	}
+}
+
+// TestNotContains fails the test if have contains want, as defined by
+// TestContains.
+func TestNotContains(t Logger, have, want interface{}, msg ...string) {
+	if contains(t, have, want) {
+		Fatalf(t, "Containment not expected%s\n%s\n%s",
+			reasonString(msg), haveLine("%#v", have), wantLine("%#v", want))
+	}
+}
+
+// TestHasPrefix fails the test if have doesn't begin with prefix.
+func TestHasPrefix(t Logger, have, prefix string, msg ...string) {
+	if !strings.HasPrefix(have, prefix) {
+		Fatalf(t, "Missing prefix%s\n%s\n%s",
+			reasonString(msg), haveLine("%q", have), wantLine("%q...", prefix))
+	}
+}
+
+// TestHasSuffix fails the test if have doesn't end with suffix.
+func TestHasSuffix(t Logger, have, suffix string, msg ...string) {
+	if !strings.HasSuffix(have, suffix) {
+		Fatalf(t, "Missing suffix%s\n%s\n%s",
+			reasonString(msg), haveLine("%q", have), wantLine("...%q", suffix))
+	}
+}
+
+// contains reports whether have contains want, failing the test if have
+// isn't a type that can contain values.
+func contains(t Logger, have, want interface{}) bool {
+	if s, ok := have.(string); ok {
+		sub, ok := want.(string)
+		if !ok {
+			Fatalf(t, "Cannot look for %T in a string", want)
+		}
+		return strings.Contains(s, sub)
+	}
+
+	haveValue := reflect.ValueOf(have)
+	wantValue := reflect.ValueOf(want)
+	switch haveValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < haveValue.Len(); i++ {
+			elem := haveValue.Index(i)
+			if elem.Kind() == reflect.Interface && !elem.IsNil() {
+				elem = elem.Elem()
+			}
+			if len(deepValueEqual("", elem, wantValue, make(map[uintptr]*visit))) == 0 {
+				return true
+			}
+		}
+		return false
+	case reflect.Map:
+		for _, key := range haveValue.MapKeys() {
+			if len(deepValueEqual("", key, wantValue, make(map[uintptr]*visit))) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+	Fatalf(t, "Cannot look for values in a %T", have)
+	return false
+}
+
+// -----------------------------------------------------------------------
+// Ordering tests.
+// -----------------------------------------------------------------------
+
+// TestGreater fails the test if have is not greater than than. Both must be
+// the same integer, float, or string type, which includes types such as
+// time.Duration.
+func TestGreater(t Logger, have, than interface{}, msg ...string) {
+	if compare(t, have, than) <= 0 {
+		Fatalf(t, "Not Greater%s\n%s\n%s",
+			reasonString(msg), haveLine("%#v", have), wantLine("> %#v", than))
+	}
+}
+
+// TestLess fails the test if have is not less than than, with the same
+// restrictions as TestGreater.
+func TestLess(t Logger, have, than interface{}, msg ...string) {
+	if compare(t, have, than) >= 0 {
+		Fatalf(t, "Not Less%s\n%s\n%s",
+			reasonString(msg), haveLine("%#v", have), wantLine("< %#v", than))
+	}
+}
+
+// compare returns -1, 0, or 1 as a is less than, equal to, or greater than b,
+// failing the test if they can't be ordered.
+func compare(t Logger, a, b interface{}) int {
+	av := reflect.ValueOf(a)
+	bv := reflect.ValueOf(b)
+	if !av.IsValid() || !bv.IsValid() || av.Type() != bv.Type() {
+		Fatalf(t, "Cannot order values of different types, have: '%T', want: '%T'", a, b)
+	}
+
+	switch av.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return order(av.Int() < bv.Int(), av.Int() > bv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return order(av.Uint() < bv.Uint(), av.Uint() > bv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return order(av.Float() < bv.Float(), av.Float() > bv.Float())
+	case reflect.String:
+		return order(av.String() < bv.String(), av.String() > bv.String())
+	}
+	Fatalf(t, "Cannot order values of type '%T'", a)
+	return 0
+}
+
+func order(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// -----------------------------------------------------------------------
+// Error chain tests.
+// -----------------------------------------------------------------------
+
+// TestErrorIs fails the test if errors.Is(err, target) is false.
+func TestErrorIs(t Logger, err, target error, msg ...string) {
+	if !errors.Is(err, target) {
+		Fatalf(t, "Error does not match%s\n%s\n%s",
+			reasonString(msg), haveLine("%v", errorChain(err)), wantLine("%v", target))
+	}
+}
+
+// TestErrorAs fails the test if errors.As(err, target) is false. As with
+// errors.As, target must be a non-nil pointer to an interface or to a type
+// implementing error, and is set to the matching error.
+func TestErrorAs(t Logger, err error, target interface{}, msg ...string) {
+	if !errors.As(err, target) {
+		Fatalf(t, "Error does not match%s\n%s\n%s",
+			reasonString(msg), haveLine("%v", errorChain(err)),
+			wantLine("%s", reflect.TypeOf(target).Elem()))
+	}
+}
+
+// errorChain describes each error in err's chain along with its type.
+func errorChain(err error) string {
+	if err == nil {
+		return "nil"
+	}
+	var parts []string
+	for ; err != nil; err = errors.Unwrap(err) {
+		parts = append(parts, fmt.Sprintf("%T(%q)", err, err.Error()))
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// -----------------------------------------------------------------------
+// Polling tests.
+// -----------------------------------------------------------------------
+
+// TestEventuallyEqual calls f until it returns a value equal to want, as
+// defined by TestEqual. If that doesn't happen before the timeout the test
+// fails showing the differences from the last value returned.
+func TestEventuallyEqual(t Logger, timeout time.Duration, f func() interface{}, want interface{}, msg ...string) {
+	end := time.Now().Add(timeout)
+	for {
+		diffs := equalDiffs(f(), want)
+		if len(diffs) == 0 {
+			return
+		}
+		if !time.Now().Before(end) {
+			Fatalf(t, "Not Equal after %v%s\n%s", timeout, reasonString(msg), strings.Join(diffs, "\n"))
+			return
+		}
+		time.Sleep(eventuallyInterval)
+	}
+}
+
+// equalDiffs returns the differences between have and want in the form shown
+// by TestEqual, or nothing if they are equal.
+func equalDiffs(have, want interface{}) []string {
+	haveNil := isNil(have)
+	wantNil := isNil(want)
+	switch {
+	case haveNil && wantNil:
+		return nil
+	case haveNil:
+		return []string{": not equal.", haveLine("%s", "nil"), wantLine("%#v", want)}
+	case wantNil:
+		return []string{": not equal.", haveLine("%#v", have), wantLine("%s", "nil")}
+	}
+	return deepValueEqual("", reflect.ValueOf(have), reflect.ValueOf(want), make(map[uintptr]*visit))
+}
+
+// reasonString formats the optional message passed to the Test functions.
+func reasonString(msg []string) string {
+	if len(msg) == 0 {
+		return ""
+	}
+	return ": " + strings.Join(msg, "")
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package testtool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTestContains(t *testing.T) {
+	m := &MockLogger{}
+
+	// Non failure conditions.
+	m.RunTest(t, false, func() { TestContains(m, "abcdef", "cde") })
+	m.RunTest(t, false, func() { TestContains(m, "abcdef", "") })
+	m.RunTest(t, false, func() { TestContains(m, []string{"A", "B"}, "B") })
+	m.RunTest(t, false, func() { TestContains(m, [2]int{1, 2}, 1) })
+	m.RunTest(t, false, func() { TestContains(m, []interface{}{"A", 1}, 1) })
+	m.RunTest(t, false, func() { TestContains(m, []buzz{{1, 2, 3}}, buzz{1, 2, 3}) })
+	m.RunTest(t, false, func() { TestContains(m, map[string]int{"A": 1}, "A") })
+	m.RunTest(t, false, func() { TestNotContains(m, "abcdef", "xyz") })
+	m.RunTest(t, false, func() { TestNotContains(m, []string{"A", "B"}, "C") })
+	m.RunTest(t, false, func() { TestNotContains(m, map[string]int{"A": 1}, "B") })
+
+	// Expected failure conditions.
+	m.RunTest(t, true, func() { TestContains(m, "abcdef", "xyz") })
+	m.RunTest(t, true, func() { TestContains(m, "abcdef", 1) })
+	m.RunTest(t, true, func() { TestContains(m, []string{"A", "B"}, "C") })
+	m.RunTest(t, true, func() { TestContains(m, []int{1, 2}, int64(1)) })
+	m.RunTest(t, true, func() { TestContains(m, map[string]int{"A": 1}, 1) })
+	m.RunTest(t, true, func() { TestContains(m, 12, 1) })
+	m.RunTest(t, true, func() { TestNotContains(m, []string{"A", "B"}, "A") })
+}
+
+func TestTestHasPrefixSuffix(t *testing.T) {
+	m := &MockLogger{}
+
+	m.RunTest(t, false, func() { TestHasPrefix(m, "abcdef", "abc") })
+	m.RunTest(t, false, func() { TestHasSuffix(m, "abcdef", "def") })
+	m.RunTest(t, true, func() { TestHasPrefix(m, "abcdef", "def") })
+	m.RunTest(t, true, func() { TestHasSuffix(m, "abcdef", "abc") })
+}
+
+func TestTestGreaterLess(t *testing.T) {
+	m := &MockLogger{}
+
+	// Non failure conditions.
+	m.RunTest(t, false, func() { TestGreater(m, 2, 1) })
+	m.RunTest(t, false, func() { TestGreater(m, uint8(2), uint8(1)) })
+	m.RunTest(t, false, func() { TestGreater(m, 1.5, 1.25) })
+	m.RunTest(t, false, func() { TestGreater(m, "b", "a") })
+	m.RunTest(t, false, func() { TestGreater(m, time.Second, time.Millisecond) })
+	m.RunTest(t, false, func() { TestLess(m, -1, 1) })
+	m.RunTest(t, false, func() { TestLess(m, "a", "b") })
+
+	// Expected failure conditions.
+	m.RunTest(t, true, func() { TestGreater(m, 1, 1) })
+	m.RunTest(t, true, func() { TestGreater(m, 1, 2) })
+	m.RunTest(t, true, func() { TestLess(m, 1, 1) })
+	m.RunTest(t, true, func() { TestLess(m, 2.0, 1.0) })
+	m.RunTest(t, true, func() { TestGreater(m, 2, int64(1)) })
+	m.RunTest(t, true, func() { TestGreater(m, nil, 1) })
+	m.RunTest(t, true, func() { TestGreater(m, true, false) })
+}
+
+func TestTestErrorIsAs(t *testing.T) {
+	m := &MockLogger{}
+
+	base := errors.New("base")
+	wrapped := fmt.Errorf("wrapped: %w", base)
+	pathErr := fmt.Errorf("wrapped: %w", &os.PathError{Op: "open", Path: "/x", Err: base})
+
+	m.RunTest(t, false, func() { TestErrorIs(m, base, base) })
+	m.RunTest(t, false, func() { TestErrorIs(m, wrapped, base) })
+	m.RunTest(t, false, func() { TestErrorIs(m, nil, nil) })
+	m.RunTest(t, true, func() { TestErrorIs(m, errors.New("base"), base) })
+	m.RunTest(t, true, func() { TestErrorIs(m, nil, base) })
+
+	var perr *os.PathError
+	m.RunTest(t, false, func() { TestErrorAs(m, pathErr, &perr) })
+	if perr == nil || perr.Path != "/x" {
+		t.Fatalf("TestErrorAs didn't set the target: %#v", perr)
+	}
+	m.RunTest(t, true, func() { TestErrorAs(m, wrapped, &perr) })
+	m.RunTest(t, true, func() { TestErrorAs(m, nil, &perr) })
+}
+
+func TestTestEventuallyEqual(t *testing.T) {
+	m := &MockLogger{}
+
+	var n int32
+	incr := func() interface{} { return atomic.AddInt32(&n, 1) }
+	m.RunTest(t, false, func() { TestEventuallyEqual(m, time.Second, incr, int32(3)) })
+	m.RunTest(t, false, func() { TestEventuallyEqual(m, 0, func() interface{} { return nil }, nil) })
+
+	var output string
+	m.funcFatalf = func(format string, args ...interface{}) { output = fmt.Sprintf(format, args...) }
+	m.RunTest(t, true, func() {
+		TestEventuallyEqual(m, 50*time.Millisecond, func() interface{} { return "have" }, "want")
+	})
+	if !contains(m, output, "Not Equal after 50ms") || !contains(m, output, `"have"`) {
+		t.Fatalf("Unexpected failure output: %s", output)
+	}
+	m.RunTest(t, true, func() {
+		TestEventuallyEqual(m, 0, func() interface{} { return nil }, "want")
+	})
+}
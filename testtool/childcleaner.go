@@ -43,31 +43,27 @@ func init() {
 }
 
 // RootTempDir creates a directory that will exist until the process running the
-// tests exits.
+// tests exits. It is created once per TestTool, even when called from parallel
+// subtests.
 func RootTempDir(t *TestTool) string {
-	if rd, ok := t.Parameters["RootDir"].(string); ok && rd != "" {
-		return rd
-	}
-
-	var rootDirectory string
-
-	var err error
+	rd := t.ParameterOrCreate("RootDir", func() interface{} {
+		mode := os.FileMode(0777)
+		rootDirectory, err := ioutil.TempDir("", t.RandomTestString)
+		if rootDirectory == "" {
+			Fatalf(t, "ioutil.TempFile() return an empty string.")
+		} else if err != nil {
+			Fatalf(t, "ioutil.TempFile() return an err: %s", err)
+		} else if err := os.Chmod(rootDirectory, mode); err != nil {
+			Fatalf(t, "os.Chmod error: %s", err)
+		}
 
-	mode := os.FileMode(0777)
-	rootDirectory, err = ioutil.TempDir("", t.RandomTestString)
-	if rootDirectory == "" {
-		Fatalf(t, "ioutil.TempFile() return an empty string.")
-	} else if err != nil {
-		Fatalf(t, "ioutil.TempFile() return an err: %s", err)
-	} else if err := os.Chmod(rootDirectory, mode); err != nil {
-		Fatalf(t, "os.Chmod error: %s", err)
-	}
-
-	t.Parameters["RootDir"] = rootDirectory
+		// The mutex is held, so append directly rather than calling
+		// AddTestFinalizer.
+		t.Finalizers = append(t.Finalizers, func() {
+			os.RemoveAll(rootDirectory)
+		})
 
-	t.AddTestFinalizer(func() {
-		os.RemoveAll(rootDirectory)
+		return rootDirectory
 	})
-
-	return rootDirectory
+	return rd.(string)
 }
@@ -0,0 +1,143 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package testtool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandTimeout is how long RunCommand waits for a command to finish.
+var CommandTimeout = time.Minute
+
+// CommandResult is the outcome of a command run by RunCommand.
+type CommandResult struct {
+	// Command is the command line that was run.
+	Command string
+
+	Stdout string
+	Stderr string
+
+	// ExitCode is the exit status of the command, or -1 if it was killed by
+	// a signal.
+	ExitCode int
+}
+
+// RunCommand runs the command, waiting up to CommandTimeout for it to finish.
+// Its output is written to the test log. The test fails if the command can't
+// be started or times out, but not if it exits with an error; use
+// ExpectExitCode for that.
+func RunCommand(tt *TestTool, name string, args ...string) *CommandResult {
+	tt.Helper()
+	return RunCommandTimeout(tt, CommandTimeout, name, args...)
+}
+
+// RunCommandTimeout is like RunCommand but with the given timeout.
+func RunCommandTimeout(tt *TestTool, timeout time.Duration, name string, args ...string) *CommandResult {
+	tt.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	result := &CommandResult{Command: commandLine(name, args)}
+
+	err := cmd.Run()
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	tt.Logf("$ %s\nstdout:\n%s\nstderr:\n%s", result.Command, result.Stdout, result.Stderr)
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		Fatalf(tt, "Command %q timed out after %v", result.Command, timeout)
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		Fatalf(tt, "Error running %q: %s", result.Command, err)
+	}
+	return result
+}
+
+// ExpectExitCode fails the test if the command didn't exit with the code.
+func (r *CommandResult) ExpectExitCode(l Logger, code int) {
+	if r.ExitCode != code {
+		Fatalf(l, "Unexpected exit code from %q\n%s\n%s\n stderr: %s",
+			r.Command, haveLine("%d", r.ExitCode), wantLine("%d", code),
+			formatValue(r.Stderr))
+	}
+}
+
+// StartCommand starts a long running command, such as a daemon needed by the
+// test, and returns without waiting for it. Its output is written to the test
+// log as it is produced. The command is killed when the test finishes if it
+// is still running.
+func StartCommand(tt *TestTool, name string, args ...string) *exec.Cmd {
+	tt.Helper()
+	line := commandLine(name, args)
+	stdout := &logWriter{tt: tt, prefix: line + " stdout: "}
+	stderr := &logWriter{tt: tt, prefix: line + " stderr: "}
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		Fatalf(tt, "Error starting %q: %s", line, err)
+	}
+	tt.Logf("$ %s &", line)
+
+	tt.AddTestFinalizer(func() {
+		// Waiting ensures all of the output has been logged before the
+		// test completes.
+		cmd.Process.Kill()
+		cmd.Wait()
+		stdout.flush()
+		stderr.flush()
+	})
+	return cmd
+}
+
+// commandLine formats a command for messages.
+func commandLine(name string, args []string) string {
+	return strings.Join(append([]string{name}, args...), " ")
+}
+
+// logWriter writes each complete line to the test log.
+type logWriter struct {
+	tt     *TestTool
+	prefix string
+
+	mutex sync.Mutex
+	buf   []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.tt.Logf("%s%s", w.prefix, w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush logs any incomplete final line.
+func (w *logWriter) flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.buf) > 0 {
+		w.tt.Logf("%s%s", w.prefix, w.buf)
+		w.buf = nil
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package testtool
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRunCommand(t *testing.T) {
+	testHelper := StartTest(t)
+	defer testHelper.FinishTest()
+
+	r := RunCommand(testHelper, "sh", "-c", "echo out; echo err >&2; exit 3")
+	TestEqual(t, r.Command, "sh -c echo out; echo err >&2; exit 3")
+	TestEqual(t, r.Stdout, "out\n")
+	TestEqual(t, r.Stderr, "err\n")
+	TestEqual(t, r.ExitCode, 3)
+
+	m := &MockLogger{}
+	m.RunTest(t, false, func() { r.ExpectExitCode(m, 3) })
+	m.RunTest(t, true, func() { r.ExpectExitCode(m, 0) })
+
+	r = RunCommand(testHelper, "true")
+	r.ExpectExitCode(t, 0)
+}
+
+// fatalTB records a failure instead of failing the test.
+type fatalTB struct {
+	testing.TB
+	failed bool
+}
+
+func (f *fatalTB) Fatalf(format string, args ...interface{}) {
+	f.failed = true
+	runtime.Goexit()
+}
+
+// commandFails reports whether f fails the test.
+func commandFails(t *testing.T, f func(testHelper *TestTool)) bool {
+	testHelper := StartTest(t)
+	defer testHelper.FinishTest()
+	tb := &fatalTB{TB: t}
+	testHelper.TB = tb
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(testHelper)
+	}()
+	<-done
+	return tb.failed
+}
+
+func TestRunCommandFailures(t *testing.T) {
+	start := time.Now()
+	TestTrue(t, commandFails(t, func(testHelper *TestTool) {
+		RunCommandTimeout(testHelper, 50*time.Millisecond, "sleep", "10")
+	}))
+	TestLess(t, time.Since(start), 5*time.Second)
+
+	TestTrue(t, commandFails(t, func(testHelper *TestTool) {
+		RunCommand(testHelper, "/nonexistent/command")
+	}))
+}
+
+func TestStartCommand(t *testing.T) {
+	var cmd *exec.Cmd
+	t.Run("sub", func(t *testing.T) {
+		testHelper := StartTest(t)
+		cmd = StartCommand(testHelper, "sh", "-c", "echo started; exec sleep 60")
+	})
+	if cmd.ProcessState == nil {
+		t.Fatalf("Expected the command to be killed when the test finished")
+	}
+	TestFalse(t, cmd.ProcessState.Success())
+}
@@ -80,7 +80,7 @@ func TestEqual(t Logger, have, want interface{}, msg ...string) {
 	} else if haveNil && !wantNil {
 		Fatalf(t, "%sExpected non nil, got nil.", reason)
 	} else if !haveNil && wantNil {
-		Fatalf(t, "%sExpected nil, got non nil: %#v", reason, have)
+		Fatalf(t, "%sExpected nil, got non nil: %s", reason, formatValue(fmt.Sprintf("%#v", have)))
 	}
 	haveValue := reflect.ValueOf(have)
 	wantValue := reflect.ValueOf(want)
@@ -107,7 +107,7 @@ func TestNotEqual(t Logger, have, want interface{}, msg ...string) {
 	r := deepValueEqual("", haveValue, wantValue, make(map[uintptr]*visit))
 	if len(r) == 0 {
 		Fatalf(t,
-			"Equality not expected%s\nhave: %#v", reason, have)
+			"Equality not expected%s\n%s", reason, haveLine("%#v", have))
 	}
 }
 
@@ -135,15 +135,15 @@ func deepValueEqual(description string, have, want reflect.Value, visited map[ui
 		// This is rare, not sure how to document this better.
 		return []string{
 			fmt.Sprintf("%s: wanted an invalid object", description),
-			fmt.Sprintf(" have: %#v", have),
-			fmt.Sprintf(" want: %#v", want),
+			haveLine("%#v", have),
+			wantLine("%#v", want),
 		}
 	} else if want.IsValid() && !have.IsValid() {
 		// This is rare, not sure how to document this better.
 		return []string{
 			fmt.Sprintf("%s: wanted an valid object", description),
-			fmt.Sprintf(" have: %#v", have),
-			fmt.Sprintf(" want: %#v", want),
+			haveLine("%#v", have),
+			wantLine("%#v", want),
 		}
 	}
 
@@ -184,13 +184,13 @@ func deepValueEqual(description string, have, want reflect.Value, visited map[ui
 	checkNil := func() bool {
 		if want.IsNil() && !have.IsNil() {
 			diffs = append(diffs, fmt.Sprintf("%s: not equal.", description))
-			diffs = append(diffs, fmt.Sprintf(" have: %#v", have))
-			diffs = append(diffs, " want: nil")
+			diffs = append(diffs, haveLine("%#v", have))
+			diffs = append(diffs, wantLine("%s", "nil"))
 			return true
 		} else if !want.IsNil() && have.IsNil() {
 			diffs = append(diffs, fmt.Sprintf("%s: not equal.", description))
-			diffs = append(diffs, " have: nil")
-			diffs = append(diffs, fmt.Sprintf(" want: %#v", want))
+			diffs = append(diffs, haveLine("%s", "nil"))
+			diffs = append(diffs, wantLine("%#v", want))
 			return true
 		}
 		return false
@@ -202,8 +202,8 @@ func deepValueEqual(description string, have, want reflect.Value, visited map[ui
 			diffs = append(diffs, fmt.Sprintf(
 				"%s: (len(have): %d, len(want): %d)",
 				description, have.Len(), want.Len()))
-			diffs = append(diffs, fmt.Sprintf(" have: %#v", have.Interface()))
-			diffs = append(diffs, fmt.Sprintf(" want: %#v", want.Interface()))
+			diffs = append(diffs, haveLine("%#v", have.Interface()))
+			diffs = append(diffs, wantLine("%#v", want.Interface()))
 			return true
 		}
 		return false
@@ -264,9 +264,9 @@ func deepValueEqual(description string, have, want reflect.Value, visited map[ui
 					// Add the error.
 					diffs = append(diffs, fmt.Sprintf(
 						"%sExpected key [%q] is missing.", description, k))
-					diffs = append(diffs, " have: not present")
+					diffs = append(diffs, haveLine("%s", "not present"))
 					diffs = append(diffs,
-						fmt.Sprintf(" want: %#v", want.MapIndex(k).Interface()))
+						wantLine("%#v", want.MapIndex(k).Interface()))
 					continue
 				}
 				newdiffs := deepValueEqual(
@@ -278,8 +278,8 @@ func deepValueEqual(description string, have, want reflect.Value, visited map[ui
 				if !want.MapIndex(k).IsValid() {
 					// Add the error.
 					diffs = append(diffs, fmt.Sprintf("%sUnexpected key [%q].", description, k))
-					diffs = append(diffs, fmt.Sprintf(" have: %#v", have.MapIndex(k).Interface()))
-					diffs = append(diffs, " want: not present")
+					diffs = append(diffs, haveLine("%#v", have.MapIndex(k).Interface()))
+					diffs = append(diffs, wantLine("%s", "not present"))
 				}
 			}
 		}
@@ -298,8 +298,8 @@ func deepValueEqual(description string, have, want reflect.Value, visited map[ui
 				fmt.Sprintf(
 					"%s: len(have) %d != len(want) %d.",
 					description, len(s1), len(s2)),
-				fmt.Sprintf(" have: %#v", s1),
-				fmt.Sprintf(" want: %#v", s2),
+				haveLine("%#v", s1),
+				wantLine("%#v", s2),
 			}
 		}
 		for i := range s1 {
@@ -308,8 +308,8 @@ func deepValueEqual(description string, have, want reflect.Value, visited map[ui
 					fmt.Sprintf(
 						"%s: difference at index %d.",
 						description, i),
-					fmt.Sprintf(" have: %#v", s1),
-					fmt.Sprintf(" want: %#v", s2),
+					haveLine("%#v", s1),
+					wantLine("%#v", s2),
 				}
 			}
 		}
@@ -364,8 +364,8 @@ func timesEqual(description string, have, want reflect.Value) (diffs []string) {
 		t1, t2 := have.Interface().(time.Time), want.Interface().(time.Time)
 		if !t1.Equal(t2) {
 			return []string{"Not equal (using time.Equal):",
-				fmt.Sprintf(" have: %v", t1),
-				fmt.Sprintf(" want: %v", t2),
+				haveLine("%v", t1),
+				wantLine("%v", t2),
 			}
 		}
 		return
@@ -375,22 +375,22 @@ func timesEqual(description string, have, want reflect.Value) (diffs []string) {
 	wantParts := strings.Split(strings.Trim(fmt.Sprintf("%v", want), "{}"), " ")
 	if len(haveParts) != len(wantParts) || len(haveParts) != 3 {
 		return []string{"Unexpected time.Time format; can't compare:",
-			fmt.Sprintf(" have: %#v", have),
-			fmt.Sprintf(" want: %#v", want),
+			haveLine("%#v", have),
+			wantLine("%#v", want),
 		}
 	}
 	if haveParts[0] != wantParts[0] {
 		diffs = append(diffs, []string{
 			fmt.Sprintf("%s: time.Time seconds not equal", description),
-			fmt.Sprintf(" have: %v", haveParts[0]),
-			fmt.Sprintf(" want: %v", wantParts[0]),
+			haveLine("%v", haveParts[0]),
+			wantLine("%v", wantParts[0]),
 		}...)
 	}
 	if haveParts[1] != wantParts[1] {
 		diffs = append(diffs, []string{
 			fmt.Sprintf("%s: time.Time nanoseconds not equal", description),
-			fmt.Sprintf(" have: %v", haveParts[1]),
-			fmt.Sprintf(" want: %v", wantParts[1]),
+			haveLine("%v", haveParts[1]),
+			wantLine("%v", wantParts[1]),
 		}...)
 	}
 	return diffs
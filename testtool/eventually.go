@@ -0,0 +1,120 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package testtool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// errConditionFalse is the error reported by Timeout when its function never
+// returned true.
+var errConditionFalse = errors.New("condition returned false")
+
+// PollOptions controls how often Eventually and Consistently call their
+// function.
+type PollOptions struct {
+	// Interval is the wait after the first call. It defaults to 10ms.
+	Interval time.Duration
+
+	// Multiplier, if greater than 1, multiplies the wait after each call,
+	// backing off exponentially up to MaxInterval.
+	Multiplier float64
+
+	// MaxInterval limits the wait when backing off. Zero means no limit.
+	MaxInterval time.Duration
+}
+
+// next returns the wait following one of d.
+func (o PollOptions) next(d time.Duration) time.Duration {
+	if o.Multiplier <= 1 {
+		return d
+	}
+	d = time.Duration(float64(d) * o.Multiplier)
+	if o.MaxInterval > 0 && d > o.MaxInterval {
+		d = o.MaxInterval
+	}
+	return d
+}
+
+// Eventually calls f until it returns nil, failing the test if the context is
+// done first. The failure shows the last error returned by f, so f should
+// describe what it is still waiting for; CheckEqual returns an error showing
+// the same differences as TestEqual. The context should have a deadline, or
+// this may wait forever.
+func Eventually(ctx context.Context, l Logger, f func() error) {
+	EventuallyWith(ctx, l, PollOptions{}, f)
+}
+
+// EventuallyWith is like Eventually but with the given poll options.
+func EventuallyWith(ctx context.Context, l Logger, opts PollOptions, f func() error) {
+	start := time.Now()
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = 10 * time.Millisecond
+	}
+	for attempts := 1; ; attempts++ {
+		err := f()
+		if err == nil {
+			return
+		}
+		if !sleepContext(ctx, interval) {
+			Fatalf(l, "Condition not met after %v and %d attempts: %s\n last error: %s",
+				time.Since(start).Round(time.Millisecond), attempts, ctx.Err(), err)
+			return
+		}
+		interval = opts.next(interval)
+	}
+}
+
+// Consistently calls f until the context is done, failing the test as soon as
+// f returns an error. The context should have a deadline, or this may wait
+// forever.
+func Consistently(ctx context.Context, l Logger, f func() error) {
+	ConsistentlyWith(ctx, l, PollOptions{}, f)
+}
+
+// ConsistentlyWith is like Consistently but with the given poll options.
+func ConsistentlyWith(ctx context.Context, l Logger, opts PollOptions, f func() error) {
+	start := time.Now()
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = 10 * time.Millisecond
+	}
+	for attempts := 1; ; attempts++ {
+		if err := f(); err != nil {
+			Fatalf(l, "Condition failed after %v and %d attempts: %s",
+				time.Since(start).Round(time.Millisecond), attempts, err)
+			return
+		}
+		if !sleepContext(ctx, interval) {
+			return
+		}
+		interval = opts.next(interval)
+	}
+}
+
+// CheckEqual returns nil if have and want are equal as defined by TestEqual,
+// and otherwise an error showing their differences in the same form.
+func CheckEqual(have, want interface{}) error {
+	diffs := equalDiffs(have, want)
+	if len(diffs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Not Equal\n%s", strings.Join(diffs, "\n"))
+}
+
+// sleepContext waits for d, returning false if the context is done first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
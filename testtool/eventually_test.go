@@ -0,0 +1,100 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package testtool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEventually(t *testing.T) {
+	m := &MockLogger{}
+
+	n := 0
+	m.RunTest(t, false, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		Eventually(ctx, m, func() error {
+			n++
+			return CheckEqual(n, 3)
+		})
+	})
+	TestEqual(t, n, 3)
+
+	var output string
+	m.funcFatalf = func(format string, args ...interface{}) { output = fmt.Sprintf(format, args...) }
+	m.RunTest(t, true, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		Eventually(ctx, m, func() error { return CheckEqual("have", "want") })
+	})
+	TestContains(t, output, "Condition not met after")
+	TestContains(t, output, "context deadline exceeded")
+	TestContains(t, output, `"have"`)
+	TestContains(t, output, `"want"`)
+}
+
+func TestEventuallyBackoff(t *testing.T) {
+	var calls []time.Time
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	opts := PollOptions{Interval: 5 * time.Millisecond, Multiplier: 2, MaxInterval: 20 * time.Millisecond}
+	EventuallyWith(ctx, t, opts, func() error {
+		calls = append(calls, time.Now())
+		if len(calls) < 5 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+
+	// Waits of 5, 10, 20, and 20ms.
+	TestEqual(t, len(calls), 5)
+	TestGreater(t, calls[4].Sub(calls[0]), 54*time.Millisecond)
+	TestEqual(t, opts.next(5*time.Millisecond), 10*time.Millisecond)
+	TestEqual(t, opts.next(15*time.Millisecond), 20*time.Millisecond)
+	TestEqual(t, PollOptions{}.next(5*time.Millisecond), 5*time.Millisecond)
+}
+
+func TestConsistently(t *testing.T) {
+	m := &MockLogger{}
+
+	n := 0
+	m.RunTest(t, false, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		Consistently(ctx, m, func() error {
+			n++
+			return nil
+		})
+	})
+	TestGreater(t, n, 1)
+
+	n = 0
+	m.RunTest(t, true, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		Consistently(ctx, m, func() error {
+			n++
+			return CheckEqual(n < 3, true)
+		})
+	})
+	TestEqual(t, n, 3)
+}
+
+func TestTimeout(t *testing.T) {
+	m := &MockLogger{}
+
+	n := 0
+	m.RunTest(t, false, func() {
+		Timeout(m, time.Second, time.Millisecond, func() bool {
+			n++
+			return n == 3
+		})
+	})
+	m.RunTest(t, true, func() {
+		Timeout(m, 20*time.Millisecond, time.Millisecond, func() bool { return false })
+	})
+}
@@ -0,0 +1,176 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package testtool
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FlakyReport is a single record written to the flaky test report file. One
+// JSON encoded record is written per line for every test run through Flaky
+// which failed at least one attempt.
+type FlakyReport struct {
+	// Test is the name of the test, if it could be determined.
+	Test string `json:"test"`
+
+	// Attempts is the number of attempts that were made.
+	Attempts int `json:"attempts"`
+
+	// Failures is the number of attempts that failed.
+	Failures int `json:"failures"`
+
+	// Passed is true if one of the attempts passed.
+	Passed bool `json:"passed"`
+
+	// Errors contains the failure messages of every failed attempt.
+	Errors []string `json:"errors,omitempty"`
+
+	// Time is when the report was generated.
+	Time time.Time `json:"time"`
+}
+
+// flakyAttempt is the Logger handed to the body of a Flaky test. It records
+// failures rather than failing the real test so that the body can be retried.
+type flakyAttempt struct {
+	l        Logger
+	failed   bool
+	skipped  bool
+	messages []string
+}
+
+func (a *flakyAttempt) record(msg string) {
+	a.failed = true
+	a.messages = append(a.messages, msg)
+}
+
+func (a *flakyAttempt) Error(args ...interface{}) {
+	a.record(fmt.Sprint(args...))
+}
+
+func (a *flakyAttempt) Errorf(format string, args ...interface{}) {
+	a.record(fmt.Sprintf(format, args...))
+}
+
+func (a *flakyAttempt) Failed() bool {
+	return a.failed
+}
+
+func (a *flakyAttempt) Fatal(args ...interface{}) {
+	a.record(fmt.Sprint(args...))
+	runtime.Goexit()
+}
+
+func (a *flakyAttempt) Fatalf(format string, args ...interface{}) {
+	a.record(fmt.Sprintf(format, args...))
+	runtime.Goexit()
+}
+
+func (a *flakyAttempt) Skip(args ...interface{}) {
+	a.skipped = true
+	a.messages = append(a.messages, fmt.Sprint(args...))
+	runtime.Goexit()
+}
+
+func (a *flakyAttempt) Skipf(format string, args ...interface{}) {
+	a.skipped = true
+	a.messages = append(a.messages, fmt.Sprintf(format, args...))
+	runtime.Goexit()
+}
+
+func (a *flakyAttempt) Log(args ...interface{}) {
+	a.l.Log(args...)
+}
+
+func (a *flakyAttempt) Logf(format string, args ...interface{}) {
+	a.l.Logf(format, args...)
+}
+
+// Flaky runs f up to attempts times, stopping at the first attempt that does
+// not fail. The test is only failed if every attempt fails. This is intended to
+// quarantine known flaky tests while still collecting data on them rather than
+// blindly retrying the whole test suite.
+//
+// Any test that fails at least one attempt is recorded in the file named by the
+// environment variable $FLAKY_TESTS_REPORT_FILE, if set, as one JSON encoded
+// FlakyReport per line.
+func Flaky(l Logger, attempts int, f func(l Logger)) {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	report := FlakyReport{Test: flakyTestName(l)}
+	for i := 0; i < attempts; i++ {
+		attempt := &flakyAttempt{l: l}
+
+		// Run the attempt in its own goroutine since Fatal terminates the
+		// calling goroutine.
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f(attempt)
+		}()
+		wg.Wait()
+		report.Attempts++
+
+		if attempt.skipped {
+			l.Skip(strings.Join(attempt.messages, "\n"))
+			return
+		}
+		if !attempt.failed {
+			report.Passed = true
+			break
+		}
+
+		report.Failures++
+		report.Errors = append(report.Errors, strings.Join(attempt.messages, "\n"))
+		l.Logf("testtool: attempt %d of %d failed:\n%s",
+			i+1, attempts, strings.Join(attempt.messages, "\n"))
+	}
+
+	if report.Failures == 0 {
+		return
+	}
+	writeFlakyReport(l, &report)
+
+	if !report.Passed {
+		Fatalf(l, "testtool: all %d attempts failed, last failure:\n%s",
+			report.Attempts, report.Errors[len(report.Errors)-1])
+	}
+	l.Logf("testtool: flaky test passed after %d failed attempts", report.Failures)
+}
+
+// flakyTestName returns the name of the test if the Logger is able to
+// provide it.
+func flakyTestName(l Logger) string {
+	if n, ok := l.(interface {
+		Name() string
+	}); ok {
+		return n.Name()
+	}
+	return ""
+}
+
+// writeFlakyReport appends the report to $FLAKY_TESTS_REPORT_FILE, if set.
+func writeFlakyReport(l Logger, report *FlakyReport) {
+	fn := os.Getenv("FLAKY_TESTS_REPORT_FILE")
+	if fn == "" {
+		return
+	}
+	report.Time = time.Now()
+	b, err := json.Marshal(report)
+	TestExpectSuccess(l, err)
+
+	flags := os.O_WRONLY | os.O_APPEND | os.O_CREATE
+	f, err := os.OpenFile(fn, flags, os.FileMode(0644))
+	TestExpectSuccess(l, err)
+	defer f.Close()
+	_, err = f.Write(append(b, '\n'))
+	TestExpectSuccess(l, err)
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package testtool
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFlaky(t *testing.T) {
+	m := &MockLogger{}
+
+	report, err := ioutil.TempFile("", "flakyreport")
+	if err != nil {
+		t.Fatalf("Error creating report file: %s", err)
+	}
+	report.Close()
+	defer os.Remove(report.Name())
+	os.Setenv("FLAKY_TESTS_REPORT_FILE", report.Name())
+	defer os.Unsetenv("FLAKY_TESTS_REPORT_FILE")
+
+	// Passes on the first attempt, nothing reported.
+	calls := 0
+	m.RunTest(t, false, func() {
+		Flaky(m, 3, func(l Logger) { calls++ })
+	})
+	TestEqual(t, calls, 1)
+
+	// Fails twice then passes.
+	calls = 0
+	m.RunTest(t, false, func() {
+		Flaky(m, 3, func(l Logger) {
+			calls++
+			if calls < 3 {
+				Fatalf(l, "failure %d", calls)
+			}
+		})
+	})
+	TestEqual(t, calls, 3)
+
+	// Never passes.
+	calls = 0
+	m.RunTest(t, true, func() {
+		Flaky(m, 2, func(l Logger) {
+			calls++
+			l.Errorf("always fails")
+		})
+	})
+	TestEqual(t, calls, 2)
+
+	contents, err := ioutil.ReadFile(report.Name())
+	TestExpectSuccess(t, err)
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	TestEqual(t, len(lines), 2)
+
+	var r FlakyReport
+	TestExpectSuccess(t, json.Unmarshal([]byte(lines[0]), &r))
+	TestEqual(t, r.Attempts, 3)
+	TestEqual(t, r.Failures, 2)
+	TestTrue(t, r.Passed)
+
+	r = FlakyReport{}
+	TestExpectSuccess(t, json.Unmarshal([]byte(lines[1]), &r))
+	TestEqual(t, r.Attempts, 2)
+	TestEqual(t, r.Failures, 2)
+	TestFalse(t, r.Passed)
+	TestEqual(t, r.Errors, []string{"always fails", "always fails"})
+}
@@ -0,0 +1,40 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package httphelper
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/apcera/util/testtool"
+)
+
+// AssertRequestJSON fails the test if the request's body isn't JSON which
+// decodes to a value equal to want. The body is decoded into a value of the
+// same type as want, so want is normally a struct or a map.
+func AssertRequestJSON(t testtool.Logger, req *Request, want interface{}, msg ...string) {
+	if req == nil {
+		testtool.Fatalf(t, "Expected a request, got nil")
+		return
+	}
+	if want == nil {
+		testtool.Fatalf(t, "Cannot compare a request body to nil")
+		return
+	}
+	have := reflect.New(reflect.TypeOf(want))
+	if err := json.Unmarshal(req.Body, have.Interface()); err != nil {
+		testtool.Fatalf(t, "Request body is not valid JSON: %s\n body: %s", err, req.Body)
+		return
+	}
+	testtool.TestEqual(t, have.Elem().Interface(), want, msg...)
+}
+
+// AssertHeader fails the test if the request's header with the key doesn't
+// have the value.
+func AssertHeader(t testtool.Logger, req *Request, key, want string, msg ...string) {
+	if req == nil {
+		testtool.Fatalf(t, "Expected a request, got nil")
+		return
+	}
+	testtool.TestEqual(t, req.Header.Get(key), want, msg...)
+}
@@ -0,0 +1,149 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+// Package httphelper provides HTTP servers with canned routes for tests, which
+// record the requests they receive so they can be checked afterwards.
+package httphelper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// Request is a request received by a Server.
+type Request struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+}
+
+// Server is an httptest.Server which serves canned routes and records every
+// request it receives. Requests which don't match a route get a 404 response.
+type Server struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	routes   []route
+	requests []*Request
+}
+
+type route struct {
+	method  string
+	path    string
+	handler http.Handler
+}
+
+// NewServer starts a Server which is closed when the test finishes.
+func NewServer(tb testing.TB) *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// NewTLSServer starts a Server using TLS which is closed when the test
+// finishes. Its Client trusts the server's certificate.
+func NewTLSServer(tb testing.TB) *Server {
+	s := &Server{}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// Handle routes requests with the method and path to the handler. An empty
+// method matches any method. Routes added later take precedence, so a test can
+// override a route set up by a shared helper.
+func (s *Server) Handle(method, path string, handler http.Handler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.routes = append(s.routes, route{method: method, path: path, handler: handler})
+}
+
+// HandleFunc is like Handle but takes a handler function.
+func (s *Server) HandleFunc(method, path string, f func(http.ResponseWriter, *http.Request)) {
+	s.Handle(method, path, http.HandlerFunc(f))
+}
+
+// Respond routes requests with the method and path to a canned response with
+// the status code and body.
+func (s *Server) Respond(method, path string, status int, body string) {
+	s.HandleFunc(method, path, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	})
+}
+
+// RespondJSON routes requests with the method and path to a canned response
+// with the status code and v encoded as JSON. It panics if v can't be encoded.
+func (s *Server) RespondJSON(method, path string, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("httphelper: can't encode response for %s %s: %s", method, path, err))
+	}
+	s.HandleFunc(method, path, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(body)
+	})
+}
+
+// Requests returns the requests received so far, oldest first.
+func (s *Server) Requests() []*Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*Request(nil), s.requests...)
+}
+
+// LastRequest returns the most recent request, or nil if there hasn't been
+// one.
+func (s *Server) LastRequest() *Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+// ClearRequests forgets the requests received so far.
+func (s *Server) ClearRequests() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = nil
+}
+
+// serveHTTP records the request and passes it to the matching route.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	s.mutex.Lock()
+	s.requests = append(s.requests, &Request{
+		Method: r.Method,
+		URL:    r.URL,
+		Header: r.Header,
+		Body:   body,
+	})
+	var handler http.Handler = http.NotFoundHandler()
+	for i := len(s.routes) - 1; i >= 0; i-- {
+		rt := s.routes[i]
+		if (rt.method == "" || rt.method == r.Method) && rt.path == r.URL.Path {
+			handler = rt.handler
+			break
+		}
+	}
+	s.mutex.Unlock()
+
+	handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package httphelper
+
+import (
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+
+	tt "github.com/apcera/util/testtool"
+)
+
+// failLogger records whether a test assertion failed.
+type failLogger struct {
+	tt.Logger
+	failed bool
+}
+
+func (l *failLogger) Fatalf(format string, args ...interface{}) {
+	l.failed = true
+	runtime.Goexit()
+}
+
+// fails reports whether f fails the test.
+func fails(t *testing.T, f func(l tt.Logger)) bool {
+	l := &failLogger{Logger: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(l)
+	}()
+	<-done
+	return l.failed
+}
+
+func TestServer(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	s := NewServer(t)
+	s.Respond("GET", "/a", 200, "a")
+	s.RespondJSON("", "/b", 201, map[string]int{"b": 1})
+	s.Respond("GET", "/a", 202, "override")
+
+	resp, err := http.Get(s.URL + "/a")
+	tt.TestExpectSuccess(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	tt.TestEqual(t, resp.StatusCode, 202)
+	tt.TestEqual(t, string(body), "override")
+
+	req, err := http.NewRequest("PUT", s.URL+"/b?x=1", strings.NewReader(`{"name":"n","n":2}`))
+	tt.TestExpectSuccess(t, err)
+	req.Header.Set("X-Test", "yes")
+	resp, err = http.DefaultClient.Do(req)
+	tt.TestExpectSuccess(t, err)
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	tt.TestEqual(t, resp.StatusCode, 201)
+	tt.TestEqual(t, resp.Header.Get("Content-Type"), "application/json")
+	tt.TestEqual(t, string(body), `{"b":1}`)
+
+	resp, err = http.Get(s.URL + "/missing")
+	tt.TestExpectSuccess(t, err)
+	resp.Body.Close()
+	tt.TestEqual(t, resp.StatusCode, 404)
+
+	requests := s.Requests()
+	tt.TestEqual(t, len(requests), 3)
+	tt.TestEqual(t, requests[1].Method, "PUT")
+	tt.TestEqual(t, requests[1].URL.Query().Get("x"), "1")
+	tt.TestEqual(t, s.LastRequest().URL.Path, "/missing")
+
+	type payload struct {
+		Name string `json:"name"`
+		N    int    `json:"n"`
+	}
+	AssertRequestJSON(t, requests[1], payload{Name: "n", N: 2})
+	AssertRequestJSON(t, requests[1], map[string]interface{}{"name": "n", "n": 2.0})
+	AssertHeader(t, requests[1], "X-Test", "yes")
+
+	tt.TestTrue(t, fails(t, func(l tt.Logger) { AssertRequestJSON(l, requests[1], payload{Name: "m", N: 2}) }))
+	tt.TestTrue(t, fails(t, func(l tt.Logger) { AssertRequestJSON(l, requests[0], payload{}) }))
+	tt.TestTrue(t, fails(t, func(l tt.Logger) { AssertHeader(l, requests[1], "X-Test", "no") }))
+	tt.TestTrue(t, fails(t, func(l tt.Logger) { AssertHeader(l, nil, "X-Test", "yes") }))
+
+	s.ClearRequests()
+	tt.TestEqual(t, s.LastRequest(), (*Request)(nil))
+}
+
+func TestTLSServer(t *testing.T) {
+	testHelper := tt.StartTest(t)
+	defer testHelper.FinishTest()
+
+	s := NewTLSServer(t)
+	s.Respond("", "/", 200, "ok")
+
+	resp, err := s.Client().Get(s.URL)
+	tt.TestExpectSuccess(t, err)
+	resp.Body.Close()
+	tt.TestEqual(t, resp.StatusCode, 200)
+	tt.TestEqual(t, len(s.Requests()), 1)
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package testtool
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"time"
+)
+
+// GetFreePort returns a TCP port on 127.0.0.1 which was free when checked.
+// Another process could take it before the test listens on it, so prefer
+// listening on port 0 when the code under test allows it.
+func GetFreePort(l Logger) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	TestExpectSuccess(l, err)
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// TempUnixSocket returns a path for a unix socket in a temporary directory
+// which is removed once the test is complete. The socket isn't created.
+func TempUnixSocket(tt *TestTool) string {
+	return filepath.Join(tt.TempDir(), "test.sock")
+}
+
+// TLSCertPair is a self-signed certificate and key generated for a test.
+type TLSCertPair struct {
+	// CertFile and KeyFile are the PEM encoded certificate and key.
+	CertFile string
+	KeyFile  string
+
+	// Certificate can be used as a server or client certificate.
+	Certificate tls.Certificate
+
+	// CertPool contains the certificate, so it trusts the pair.
+	CertPool *x509.CertPool
+}
+
+// ServerConfig returns a TLS config for a server using the pair.
+func (p *TLSCertPair) ServerConfig() *tls.Config {
+	return &tls.Config{Certificates: []tls.Certificate{p.Certificate}}
+}
+
+// ClientConfig returns a TLS config for a client trusting the pair.
+func (p *TLSCertPair) ClientConfig() *tls.Config {
+	return &tls.Config{RootCAs: p.CertPool}
+}
+
+// GenerateTestTLSCertPair generates a self-signed certificate valid for a day
+// for the hosts, which are IP addresses or DNS names, defaulting to 127.0.0.1
+// and localhost. The certificate and key are written to files in a temporary
+// directory which is removed once the test is complete.
+func GenerateTestTLSCertPair(tt *TestTool, hosts ...string) *TLSCertPair {
+	if len(hosts) == 0 {
+		hosts = []string{"127.0.0.1", "localhost"}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	TestExpectSuccess(tt, err)
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	TestExpectSuccess(tt, err)
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"testtool"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	TestExpectSuccess(tt, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	TestExpectSuccess(tt, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	dir := tt.TempDir()
+	pair := &TLSCertPair{
+		CertFile: filepath.Join(dir, "cert.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+		CertPool: x509.NewCertPool(),
+	}
+	TestExpectSuccess(tt, ioutil.WriteFile(pair.CertFile, certPEM, 0644))
+	TestExpectSuccess(tt, ioutil.WriteFile(pair.KeyFile, keyPEM, 0600))
+
+	pair.Certificate, err = tls.X509KeyPair(certPEM, keyPEM)
+	TestExpectSuccess(tt, err)
+	pair.CertPool.AppendCertsFromPEM(certPEM)
+	return pair
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package testtool
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestGetFreePort(t *testing.T) {
+	port := GetFreePort(t)
+	TestGreater(t, port, 0)
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	TestExpectSuccess(t, err)
+	listener.Close()
+}
+
+func TestTempUnixSocket(t *testing.T) {
+	var path string
+	t.Run("sub", func(t *testing.T) {
+		testHelper := StartTest(t)
+		path = TempUnixSocket(testHelper)
+		listener, err := net.Listen("unix", path)
+		TestExpectSuccess(t, err)
+		defer listener.Close()
+
+		go func() {
+			if conn, err := listener.Accept(); err == nil {
+				conn.Write([]byte("x"))
+				conn.Close()
+			}
+		}()
+		conn, err := net.Dial("unix", path)
+		TestExpectSuccess(t, err)
+		b := make([]byte, 1)
+		_, err = conn.Read(b)
+		TestExpectSuccess(t, err)
+		conn.Close()
+		TestEqual(t, string(b), "x")
+	})
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected %s to be removed, stat returned: %v", path, err)
+	}
+}
+
+func TestGenerateTestTLSCertPair(t *testing.T) {
+	testHelper := StartTest(t)
+	defer testHelper.FinishTest()
+
+	pair := GenerateTestTLSCertPair(testHelper)
+	_, err := tls.LoadX509KeyPair(pair.CertFile, pair.KeyFile)
+	TestExpectSuccess(t, err)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", pair.ServerConfig())
+	TestExpectSuccess(t, err)
+	defer listener.Close()
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			conn.Write([]byte("x"))
+			conn.Close()
+		}
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), pair.ClientConfig())
+	TestExpectSuccess(t, err)
+	b := make([]byte, 1)
+	_, err = conn.Read(b)
+	TestExpectSuccess(t, err)
+	conn.Close()
+	TestEqual(t, string(b), "x")
+}
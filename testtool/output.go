@@ -0,0 +1,94 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package testtool
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+)
+
+// -----------------------------------------------------------------------
+// Failure output formatting.
+// -----------------------------------------------------------------------
+
+// If this flag is set to true then the have and want values in failure output
+// are colored using ANSI escape codes.
+var colorOutput bool
+
+// Values printed in failure output which are longer than this many bytes are
+// truncated. Zero disables truncation.
+var valueLimit int
+
+// If set, values which are truncated are written in full to files within this
+// directory, and the truncated output refers to the file.
+var valueDumpDir string
+
+const (
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiReset = "\x1b[0m"
+)
+
+func init() {
+	if f := flag.Lookup("color-output"); f == nil {
+		flag.BoolVar(
+			&colorOutput,
+			"color-output",
+			false,
+			"Color the have and want values of failed tests.")
+	}
+	if f := flag.Lookup("value-limit"); f == nil {
+		flag.IntVar(
+			&valueLimit,
+			"value-limit",
+			4096,
+			"Truncate values longer than this in failed tests, 0 for no limit.")
+	}
+	if f := flag.Lookup("value-dump-dir"); f == nil {
+		flag.StringVar(
+			&valueDumpDir,
+			"value-dump-dir",
+			"",
+			"Write truncated values of failed tests in full to this directory.")
+	}
+}
+
+// haveLine formats a have value for failure output.
+func haveLine(format string, v interface{}) string {
+	return colorize(ansiRed, " have: "+formatValue(fmt.Sprintf(format, v)))
+}
+
+// wantLine formats a want value for failure output.
+func wantLine(format string, v interface{}) string {
+	return colorize(ansiGreen, " want: "+formatValue(fmt.Sprintf(format, v)))
+}
+
+// colorize wraps s in the given color if colored output is enabled.
+func colorize(color, s string) string {
+	if !colorOutput {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// formatValue truncates the formatted value if it exceeds the value limit,
+// dumping the full value to a file if a dump directory is set.
+func formatValue(s string) string {
+	if valueLimit <= 0 || len(s) <= valueLimit {
+		return s
+	}
+
+	marker := fmt.Sprintf("... [%d bytes truncated]", len(s)-valueLimit)
+	if valueDumpDir != "" {
+		if f, err := ioutil.TempFile(valueDumpDir, "testtool-value-"); err == nil {
+			_, err = f.WriteString(s)
+			f.Close()
+			if err == nil {
+				marker = fmt.Sprintf("... [%d bytes truncated, full value in %s]",
+					len(s)-valueLimit, f.Name())
+			}
+		}
+	}
+	return s[:valueLimit] + marker
+}
@@ -0,0 +1,55 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package testtool
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFormatValue(t *testing.T) {
+	defer func(limit int, dir string) {
+		valueLimit, valueDumpDir = limit, dir
+	}(valueLimit, valueDumpDir)
+
+	long := strings.Repeat("x", 100)
+
+	// no limit
+	valueLimit = 0
+	TestEqual(t, formatValue(long), long)
+
+	// within the limit
+	valueLimit = 100
+	TestEqual(t, formatValue(long), long)
+
+	// truncated
+	valueLimit = 10
+	TestEqual(t, formatValue(long), "xxxxxxxxxx... [90 bytes truncated]")
+
+	// truncated and dumped
+	dir, err := ioutil.TempDir("", "testtool")
+	TestExpectSuccess(t, err)
+	defer os.RemoveAll(dir)
+	valueDumpDir = dir
+	formatted := formatValue(long)
+	TestMatch(t, formatted, regexp.MustCompile(`^x{10}\.\.\. \[90 bytes truncated, full value in .*\]$`))
+	name := strings.TrimSuffix(formatted[strings.Index(formatted, " in ")+4:], "]")
+	contents, err := ioutil.ReadFile(name)
+	TestExpectSuccess(t, err)
+	TestEqual(t, string(contents), long)
+}
+
+func TestColorOutput(t *testing.T) {
+	defer func(color bool) { colorOutput = color }(colorOutput)
+
+	colorOutput = false
+	TestEqual(t, haveLine("%#v", "a"), ` have: "a"`)
+	TestEqual(t, wantLine("%v", "a"), ` want: a`)
+
+	colorOutput = true
+	TestEqual(t, haveLine("%#v", "a"), "\x1b[31m have: \"a\"\x1b[0m")
+	TestEqual(t, wantLine("%v", "a"), "\x1b[32m want: a\x1b[0m")
+}
@@ -3,6 +3,7 @@
 package testtool
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/base64"
 	"flag"
@@ -14,6 +15,7 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -83,30 +85,78 @@ type TestTool struct {
 
 	// This is a list of functions that will be run on test completion. Having
 	// this allows us to clean up temporary directories or files after the
-	// test is done which is a huge win.
+	// test is done which is a huge win. Use AddTestFinalizer rather than
+	// appending to it directly if the TestTool is shared with parallel
+	// subtests.
 	Finalizers []func()
 
-	// Parameters contains test-specific caches of data.
+	// Parameters contains test-specific caches of data. Use Parameter and
+	// SetParameter rather than accessing it directly if the TestTool is
+	// shared with parallel subtests.
 	Parameters map[string]interface{}
 
 	RandomTestString string
 	PackageHash      string
 
 	*TestData
+
+	// started is when StartTest was called, used for the timing report.
+	started time.Time
+
+	// mutex protects Finalizers, Parameters, finished, and budget, which is
+	// set by MaxDuration for the timing report.
+	mutex    sync.Mutex
+	finished bool
+	budget   time.Duration
 }
 
-// AddTestFinalizer adds a function to be called once the test finishes.
+// AddTestFinalizer adds a function to be called once the test finishes. It is
+// safe to call from parallel subtests.
 func (tt *TestTool) AddTestFinalizer(f func()) {
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
 	tt.Finalizers = append(tt.Finalizers, f)
 }
 
+// Parameter returns the cached value for key, and whether it was set.
+func (tt *TestTool) Parameter(key string) (interface{}, bool) {
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+	v, ok := tt.Parameters[key]
+	return v, ok
+}
+
+// SetParameter caches a value for key.
+func (tt *TestTool) SetParameter(key string, value interface{}) {
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+	tt.Parameters[key] = value
+}
+
+// ParameterOrCreate returns the cached value for key, calling create to make
+// it if it isn't set. Parallel callers with the same key wait for the first
+// create to finish and share its value.
+func (tt *TestTool) ParameterOrCreate(key string, create func() interface{}) interface{} {
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+	if v, ok := tt.Parameters[key]; ok {
+		return v
+	}
+	v := create()
+	tt.Parameters[key] = v
+	return v
+}
+
 // StartTest should be called at the start of a test to setup all the various
-// state bits that are needed.
+// state bits that are needed. FinishTest is registered with tb.Cleanup, so it
+// runs once the test and all of its subtests have completed even if it isn't
+// called explicitly.
 func StartTest(tb testing.TB) *TestTool {
 	tt := TestTool{
 		Parameters:       make(map[string]interface{}),
 		TB:               tb,
 		RandomTestString: RandomTestString(10),
+		started:          time.Now(),
 	}
 
 	tt.TestData = GetTestData(tb)
@@ -123,17 +173,36 @@ func StartTest(tb testing.TB) *TestTool {
 		logray.AddDefaultOutput("stdout://", logray.ALL)
 	}
 
+	addRunningTool(&tt)
+	tb.Cleanup(tt.FinishTest)
+
 	return &tt
 }
 
 // FinishTest is called as a defer to a test in order to clean up after a test
 // run. All tests in this module should call this function as a defer right
-// after calling StartTest()
+// after calling StartTest(). If $TEST_TIMINGS_FILE is set, the test's wall
+// time is recorded in it as a TimingReport.
+//
+// Only the first call has any effect. Tests which share the TestTool with
+// parallel subtests should not defer FinishTest, since it would run before the
+// subtests, and instead let the cleanup registered by StartTest call it.
 func (tt *TestTool) FinishTest() {
-	for i := len(tt.Finalizers) - 1; i >= 0; i-- {
-		tt.Finalizers[i]()
+	tt.mutex.Lock()
+	if tt.finished {
+		tt.mutex.Unlock()
+		return
 	}
+	tt.finished = true
+	finalizers := tt.Finalizers
 	tt.Finalizers = nil
+	tt.mutex.Unlock()
+
+	for i := len(finalizers) - 1; i >= 0; i-- {
+		finalizers[i]()
+	}
+	tt.writeTimingReport()
+	removeRunningTool(tt)
 	if tt.LogBuffer != nil {
 		tt.LogBuffer.FinishTest(tt.TB)
 	}
@@ -216,7 +285,7 @@ func (tt *TestTool) WriteTempFileMode(contents string, mode os.FileMode) string
 		Fatalf(tt.TB, "os.Chmod() returned an error: %s", err)
 	}
 	defer f.Close()
-	tt.Finalizers = append(tt.Finalizers, func() {
+	tt.AddTestFinalizer(func() {
 		os.Remove(f.Name())
 	})
 	contentsBytes := []byte(contents)
@@ -245,7 +314,7 @@ func (tt *TestTool) TempDirMode(mode os.FileMode) string {
 		Fatalf(tt.TB, "os.Chmod failure.")
 	}
 
-	tt.Finalizers = append(tt.Finalizers, func() {
+	tt.AddTestFinalizer(func() {
 		os.RemoveAll(f)
 	})
 	return f
@@ -267,7 +336,7 @@ func (tt *TestTool) TempFileMode(mode os.FileMode) string {
 	}
 	defer f.Close()
 	name := f.Name()
-	tt.Finalizers = append(tt.Finalizers, func() {
+	tt.AddTestFinalizer(func() {
 		os.RemoveAll(name)
 	})
 	return name
@@ -319,16 +388,17 @@ func Fatal(t Logger, args ...interface{}) {
 
 // Timeout runs the given function until 'timeout' has passed, sleeping 'sleep'
 // duration in between runs. If the function returns true this exits, otherwise
-// after timeout this will fail the test.
+// after timeout this will fail the test. Eventually is more flexible and
+// reports why the condition wasn't met.
 func Timeout(l Logger, timeout, sleep time.Duration, f func() bool) {
-	end := time.Now().Add(timeout)
-	for time.Now().Before(end) {
-		if f() == true {
-			return
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	EventuallyWith(ctx, l, PollOptions{Interval: sleep}, func() error {
+		if f() {
+			return nil
 		}
-		time.Sleep(sleep)
-	}
-	Fatalf(l, "testtool: Timeout after %v", timeout)
+		return errConditionFalse
+	})
 }
 
 // -----------------------------------------------------------------------
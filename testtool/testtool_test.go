@@ -0,0 +1,75 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package testtool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFinishTestCleanup(t *testing.T) {
+	var dir string
+	calls := 0
+	t.Run("sub", func(t *testing.T) {
+		testHelper := StartTest(t)
+		testHelper.AddTestFinalizer(func() { calls++ })
+		dir = testHelper.TempDir()
+	})
+
+	TestEqual(t, calls, 1)
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("Expected %s to be removed, stat returned: %v", dir, err)
+	}
+
+	t.Run("explicit", func(t *testing.T) {
+		testHelper := StartTest(t)
+		testHelper.AddTestFinalizer(func() { calls++ })
+		testHelper.FinishTest()
+		testHelper.FinishTest()
+	})
+	TestEqual(t, calls, 2)
+}
+
+func TestTempDirParallel(t *testing.T) {
+	var dirs []string
+	t.Run("group", func(t *testing.T) {
+		testHelper := StartTest(t)
+		dirs = make([]string, 8)
+		for i := range dirs {
+			i := i
+			t.Run(fmt.Sprint(i), func(t *testing.T) {
+				t.Parallel()
+				dirs[i] = testHelper.TempDir()
+				testHelper.WriteTempFile("contents")
+				testHelper.SetParameter(fmt.Sprint(i), i)
+			})
+		}
+	})
+
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Fatalf("Expected %s to be removed, stat returned: %v", dir, err)
+		}
+		TestEqual(t, filepath.Dir(dir), filepath.Dir(dirs[0]), "directories should share a RootTempDir")
+	}
+}
+
+func TestParameters(t *testing.T) {
+	testHelper := StartTest(t)
+	defer testHelper.FinishTest()
+
+	_, ok := testHelper.Parameter("a")
+	TestFalse(t, ok)
+	testHelper.SetParameter("a", 1)
+	v, ok := testHelper.Parameter("a")
+	TestTrue(t, ok)
+	TestEqual(t, v, 1)
+
+	calls := 0
+	create := func() interface{} { calls++; return "b" }
+	TestEqual(t, testHelper.ParameterOrCreate("b", create), "b")
+	TestEqual(t, testHelper.ParameterOrCreate("b", create), "b")
+	TestEqual(t, calls, 1)
+}
@@ -0,0 +1,214 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package testtool
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimingReport is a single record written to the test timings file. One JSON
+// encoded record is written per line for every test which calls StartTest and
+// FinishTest, when the environment variable $TEST_TIMINGS_FILE is set.
+type TimingReport struct {
+	// Test is the name of the test.
+	Test string `json:"test"`
+
+	// Package is the name of the test's package.
+	Package string `json:"package"`
+
+	// Duration is the wall time between StartTest and FinishTest.
+	Duration time.Duration `json:"duration"`
+
+	// Budget is the budget set by MaxDuration, if any.
+	Budget time.Duration `json:"budget,omitempty"`
+
+	// Failed is true if the test had failed by the time FinishTest was
+	// called.
+	Failed bool `json:"failed"`
+
+	// Time is when the report was generated.
+	Time time.Time `json:"time"`
+}
+
+// runningTools holds the TestTools of the tests which have called StartTest
+// but not yet finished, by test name, so MaxDuration can record budgets for
+// their timing reports.
+var (
+	runningTools      = make(map[string]*TestTool)
+	runningToolsMutex sync.Mutex
+)
+
+// addRunningTool records that the TestTool's test is running.
+func addRunningTool(tt *TestTool) {
+	runningToolsMutex.Lock()
+	defer runningToolsMutex.Unlock()
+	runningTools[flakyTestName(tt.TB)] = tt
+}
+
+// removeRunningTool records that the TestTool's test has finished.
+func removeRunningTool(tt *TestTool) {
+	runningToolsMutex.Lock()
+	defer runningToolsMutex.Unlock()
+	name := flakyTestName(tt.TB)
+	if runningTools[name] == tt {
+		delete(runningTools, name)
+	}
+}
+
+// MaxDuration fails the test if it takes longer than d to run, measured from
+// the call to MaxDuration until the test and its subtests complete. The test
+// is allowed to run to completion, so this doesn't replace -timeout, but it
+// catches tests which slowly grow to take too long. The Logger must support
+// Cleanup, as testing.T does.
+func MaxDuration(l Logger, d time.Duration) {
+	c, ok := l.(interface {
+		Cleanup(func())
+	})
+	if !ok {
+		Fatalf(l, "MaxDuration requires a Logger which supports Cleanup")
+		return
+	}
+
+	// the budget is kept on the test's TestTool, if it has one, as the
+	// timing report is written after the cleanup below runs
+	runningToolsMutex.Lock()
+	tt := runningTools[flakyTestName(l)]
+	runningToolsMutex.Unlock()
+	if tt != nil {
+		tt.mutex.Lock()
+		tt.budget = d
+		tt.mutex.Unlock()
+	}
+
+	start := time.Now()
+	c.Cleanup(func() {
+		if elapsed := time.Since(start); elapsed > d {
+			l.Errorf("testtool: test took %v, exceeding its budget of %v", elapsed, d)
+		}
+	})
+}
+
+// writeTimingReport appends a report for the test to $TEST_TIMINGS_FILE, if
+// set.
+func (tt *TestTool) writeTimingReport() {
+	fn := os.Getenv("TEST_TIMINGS_FILE")
+	if fn == "" || tt.started.IsZero() {
+		return
+	}
+
+	report := TimingReport{
+		Test:     tt.TestName,
+		Package:  tt.Package,
+		Duration: time.Since(tt.started),
+		Failed:   tt.Failed(),
+		Time:     time.Now(),
+	}
+	if name := flakyTestName(tt.TB); name != "" {
+		report.Test = name
+	}
+	tt.mutex.Lock()
+	report.Budget = tt.budget
+	tt.mutex.Unlock()
+
+	b, err := json.Marshal(report)
+	TestExpectSuccess(tt, err)
+
+	flags := os.O_WRONLY | os.O_APPEND | os.O_CREATE
+	f, err := os.OpenFile(fn, flags, os.FileMode(0644))
+	TestExpectSuccess(tt, err)
+	defer f.Close()
+	_, err = f.Write(append(b, '\n'))
+	TestExpectSuccess(tt, err)
+}
+
+// ReadTimingReports reads the reports written to a timings file.
+func ReadTimingReports(fn string) ([]TimingReport, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var reports []TimingReport
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var report TimingReport
+		if err := json.Unmarshal(scanner.Bytes(), &report); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", fn, line, err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, scanner.Err()
+}
+
+// ShardTests splits the top level tests within the reports into shards of
+// roughly equal total duration, returning a pattern for each shard suitable
+// for passing to go test -run. When a test appears more than once, its most
+// recent duration is used. Tests which have no report are not included in any
+// shard, so a run of new tests is still needed.
+func ShardTests(reports []TimingReport, shards int) []string {
+	if shards < 1 {
+		shards = 1
+	}
+
+	// the latest duration of each top level test
+	latest := make(map[string]TimingReport)
+	for _, r := range reports {
+		name := strings.SplitN(r.Test, "/", 2)[0]
+		if prev, ok := latest[name]; !ok || !r.Time.Before(prev.Time) {
+			r.Test = name
+			latest[name] = r
+		}
+	}
+	tests := make([]TimingReport, 0, len(latest))
+	for _, r := range latest {
+		tests = append(tests, r)
+	}
+
+	// assign the longest tests first, each to the shard with the least total
+	sort.Slice(tests, func(i, j int) bool {
+		if tests[i].Duration != tests[j].Duration {
+			return tests[i].Duration > tests[j].Duration
+		}
+		return tests[i].Test < tests[j].Test
+	})
+	names := make([][]string, shards)
+	totals := make([]time.Duration, shards)
+	for _, r := range tests {
+		min := 0
+		for i := range totals {
+			if totals[i] < totals[min] {
+				min = i
+			}
+		}
+		names[min] = append(names[min], r.Test)
+		totals[min] += r.Duration
+	}
+
+	patterns := make([]string, shards)
+	for i, shard := range names {
+		if len(shard) == 0 {
+			// matches no test
+			patterns[i] = "^$"
+			continue
+		}
+		sort.Strings(shard)
+		quoted := make([]string, len(shard))
+		for j, name := range shard {
+			quoted[j] = regexp.QuoteMeta(name)
+		}
+		patterns[i] = "^(" + strings.Join(quoted, "|") + ")$"
+	}
+	return patterns
+}
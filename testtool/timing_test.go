@@ -0,0 +1,107 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package testtool
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+type cleanupLogger struct {
+	MockLogger
+	cleanups []func()
+}
+
+func (c *cleanupLogger) Cleanup(f func()) {
+	c.cleanups = append(c.cleanups, f)
+}
+
+func TestMaxDuration(t *testing.T) {
+	m := &cleanupLogger{}
+	m.RunTest(t, false, func() {
+		MaxDuration(m, time.Hour)
+		for _, f := range m.cleanups {
+			f()
+		}
+	})
+
+	m = &cleanupLogger{}
+	m.RunTest(t, true, func() {
+		MaxDuration(m, time.Nanosecond)
+		time.Sleep(time.Millisecond)
+		for _, f := range m.cleanups {
+			f()
+		}
+	})
+
+	// loggers without Cleanup can't be used
+	mock := &MockLogger{}
+	mock.RunTest(t, true, func() {
+		MaxDuration(mock, time.Hour)
+	})
+}
+
+func TestTimingReport(t *testing.T) {
+	report, err := ioutil.TempFile("", "timingreport")
+	if err != nil {
+		t.Fatalf("Error creating report file: %s", err)
+	}
+	report.Close()
+	defer os.Remove(report.Name())
+	os.Setenv("TEST_TIMINGS_FILE", report.Name())
+	defer os.Unsetenv("TEST_TIMINGS_FILE")
+
+	t.Run("sub", func(t *testing.T) {
+		testHelper := StartTest(t)
+		defer testHelper.FinishTest()
+		MaxDuration(t, time.Hour)
+		time.Sleep(10 * time.Millisecond)
+	})
+
+	// FinishTest is left to the cleanup registered by StartTest, which
+	// runs after the one registered by MaxDuration.
+	t.Run("cleanup", func(t *testing.T) {
+		testHelper := StartTest(t)
+		MaxDuration(testHelper, time.Minute)
+	})
+
+	// tests without a budget don't pick up another test's
+	t.Run("none", func(t *testing.T) {
+		testHelper := StartTest(t)
+		defer testHelper.FinishTest()
+	})
+
+	reports, err := ReadTimingReports(report.Name())
+	TestExpectSuccess(t, err)
+	TestEqual(t, len(reports), 3)
+	TestEqual(t, reports[1].Test, "TestTimingReport/cleanup")
+	TestEqual(t, reports[1].Budget, time.Minute)
+	TestEqual(t, reports[2].Test, "TestTimingReport/none")
+	TestEqual(t, reports[2].Budget, time.Duration(0))
+	TestEqual(t, reports[0].Test, "TestTimingReport/sub")
+	TestEqual(t, reports[0].Package, "testtool")
+	TestEqual(t, reports[0].Budget, time.Hour)
+	TestEqual(t, reports[0].Failed, false)
+	TestTrue(t, reports[0].Duration >= 10*time.Millisecond)
+}
+
+func TestShardTests(t *testing.T) {
+	now := time.Now()
+	reports := []TimingReport{
+		{Test: "TestA", Duration: 10 * time.Second, Time: now},
+		{Test: "TestB", Duration: 1 * time.Second, Time: now.Add(-time.Hour)},
+		{Test: "TestB", Duration: 6 * time.Second, Time: now},
+		{Test: "TestC", Duration: 3 * time.Second, Time: now},
+		{Test: "TestD/sub", Duration: 2 * time.Second, Time: now},
+		{Test: "TestE.x", Duration: time.Second, Time: now},
+	}
+
+	TestEqual(t, ShardTests(reports, 2), []string{
+		`^(TestA|TestE\.x)$`,
+		`^(TestB|TestC|TestD)$`,
+	})
+	TestEqual(t, ShardTests(reports, 1), []string{`^(TestA|TestB|TestC|TestD|TestE\.x)$`})
+	TestEqual(t, ShardTests(nil, 2), []string{"^$", "^$"})
+}
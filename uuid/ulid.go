@@ -0,0 +1,179 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package uuid
+
+import (
+	crypto_rand "crypto/rand"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The length of the byte array for a ULID in memory.
+const ULIDByteLen = 16
+
+// The length of a string representation of a ULID.
+const ULIDStringLen = 26
+
+// ULID is a Universally Unique Lexicographically Sortable Identifier. It holds
+// a 48 bit Unix timestamp in milliseconds followed by 80 bits of random data,
+// and is represented as 26 characters of Crockford's base32. ULIDs generated
+// in different milliseconds sort in the order they were generated both in
+// binary and string form. (see https://github.com/ulid/spec)
+type ULID [ULIDByteLen]byte
+
+// Crockford's base32 alphabet, which excludes I, L, O and U.
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Maps characters to their value in ulidAlphabet, or 0xff if invalid. Lower
+// case letters and the commonly confused I, L and O are also accepted.
+var ulidDecode [256]byte
+
+func init() {
+	for i := range ulidDecode {
+		ulidDecode[i] = 0xff
+	}
+	for i := 0; i < len(ulidAlphabet); i++ {
+		c := ulidAlphabet[i]
+		ulidDecode[c] = byte(i)
+		if c >= 'A' && c <= 'Z' {
+			ulidDecode[c+'a'-'A'] = byte(i)
+		}
+	}
+	for _, c := range "Oo" {
+		ulidDecode[c] = 0
+	}
+	for _, c := range "IiLl" {
+		ulidDecode[c] = 1
+	}
+}
+
+// Generates a new ULID using the current time and crypto randomness.
+func NewULID() ULID {
+	return NewULIDWithTime(time.Now())
+}
+
+// Generates a new ULID with the given time and crypto randomness.
+func NewULIDWithTime(t time.Time) (u ULID) {
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+	u[0] = byte(ms >> 40)
+	u[1] = byte(ms >> 32)
+	u[2] = byte(ms >> 24)
+	u[3] = byte(ms >> 16)
+	u[4] = byte(ms >> 8)
+	u[5] = byte(ms)
+	io.ReadFull(crypto_rand.Reader, u[6:])
+	return u
+}
+
+// Returns the time encoded within the ULID, with millisecond precision.
+func (u ULID) Time() time.Time {
+	ms := int64(u[0])<<40 | int64(u[1])<<32 | int64(u[2])<<24 |
+		int64(u[3])<<16 | int64(u[4])<<8 | int64(u[5])
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
+
+// Returns the 26 character Crockford's base32 representation of the ULID.
+func (u ULID) String() string {
+	hi := binary.BigEndian.Uint64(u[0:8])
+	lo := binary.BigEndian.Uint64(u[8:16])
+
+	// 26 characters of 5 bits is 130 bits, the 2 extra are the leading bits
+	// of the first character which are always zero.
+	output := make([]byte, ULIDStringLen)
+	for i := ULIDStringLen - 1; i >= 0; i-- {
+		output[i] = ulidAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(output)
+}
+
+// Error returned when a ULID can not be parsed from a string.
+type BadULIDStringError struct {
+	message string
+}
+
+func (e *BadULIDStringError) Error() string {
+	return e.message
+}
+
+// Converts a string representation of a ULID into a ULID. Parsing is case
+// insensitive.
+func ULIDFromString(s string) (u ULID, e error) {
+	if len(s) != ULIDStringLen {
+		return u, &BadULIDStringError{"length is not 26 bytes: " + s}
+	}
+
+	// The first character can only hold 3 bits.
+	if ulidDecode[s[0]] > 7 {
+		return u, &BadULIDStringError{"value overflows 128 bits: " + s}
+	}
+
+	var hi, lo uint64
+	for i := 0; i < ULIDStringLen; i++ {
+		v := ulidDecode[s[i]]
+		if v == 0xff {
+			return u, &BadULIDStringError{
+				fmt.Sprintf("invalid character at position %d: %s", i, s)}
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+	binary.BigEndian.PutUint64(u[0:8], hi)
+	binary.BigEndian.PutUint64(u[8:16], lo)
+	return u, nil
+}
+
+// Returns the string representation, quoted, as bytes, for JSON encoding.
+func (u ULID) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("\"%s\"", u.String())), nil
+}
+
+// Parses a quoted string representation of a ULID, for JSON decoding.
+func (u *ULID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ULIDFromString(s)
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// Returns the string representation of the ULID for storage in a database.
+func (u ULID) Value() (driver.Value, error) {
+	return u.String(), nil
+}
+
+// Populates the ULID from a database value. Both the string and raw 16 byte
+// forms are accepted.
+func (u *ULID) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case string:
+		parsed, err := ULIDFromString(v)
+		if err != nil {
+			return err
+		}
+		*u = parsed
+	case []byte:
+		if len(v) == ULIDByteLen {
+			copy(u[:], v)
+			return nil
+		}
+		parsed, err := ULIDFromString(string(v))
+		if err != nil {
+			return err
+		}
+		*u = parsed
+	default:
+		return fmt.Errorf("can not scan %T into a ULID", src)
+	}
+	return nil
+}
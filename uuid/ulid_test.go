@@ -0,0 +1,113 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package uuid_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apcera/util/uuid"
+)
+
+// Verifies that NewULID() never generates duplicates and that the string form
+// round trips.
+func TestULID(t *testing.T) {
+	previous := make(map[string]bool)
+
+	for i := 0; i < 10000; i++ {
+		u := uuid.NewULID()
+		s := u.String()
+		if _, exists := previous[s]; exists == true {
+			t.Fatal("Duplicate ULIDs generated from NewULID(): ", s)
+		}
+		previous[s] = true
+
+		parsed, err := uuid.ULIDFromString(strings.ToLower(s))
+		if err != nil {
+			t.Fatal("Failed to parse a generated ULID: " + err.Error())
+		}
+		if parsed != u {
+			t.Fatal("Result of NewULID() -> String() -> ULIDFromString are not equal.")
+		}
+	}
+}
+
+// Verifies the encoding against known values.
+func TestULIDKnownValues(t *testing.T) {
+	var zero uuid.ULID
+	if zero.String() != "00000000000000000000000000" {
+		t.Fatal("Zero ULID encoded incorrectly: " + zero.String())
+	}
+
+	var max uuid.ULID
+	for i := range max {
+		max[i] = 0xff
+	}
+	if max.String() != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Fatal("Max ULID encoded incorrectly: " + max.String())
+	}
+
+	ts := time.Unix(1469918176, 385000000)
+	u := uuid.NewULIDWithTime(ts)
+	if !strings.HasPrefix(u.String(), "01ARYZ6S41") {
+		t.Fatal("ULID timestamp encoded incorrectly: " + u.String())
+	}
+	if !u.Time().Equal(ts) {
+		t.Fatal("ULID time did not round trip: " + u.Time().String())
+	}
+}
+
+// Test to make sure that ULIDFromString rejects bad input.
+func TestULIDFromStringInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"01ARYZ6S41",
+		"80000000000000000000000000",
+		"01ARYZ6S41TSV4RRFFQ69G5FA!",
+		"01ARYZ6S41TSV4RRFFQ69G5FAU",
+	} {
+		if _, err := uuid.ULIDFromString(s); err == nil {
+			t.Fatal("Failed to detect an invalid ULID: " + s)
+		}
+	}
+}
+
+// Verifies that ULIDs survive a round trip through JSON and the database/sql
+// interfaces.
+func TestULIDMarshalling(t *testing.T) {
+	initial := uuid.NewULID()
+	data, err := json.Marshal(initial)
+	if err != nil {
+		t.Fatal("Error marshalling: " + err.Error())
+	}
+	var decoded uuid.ULID
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal("Error unmarshalling: " + err.Error())
+	}
+	if decoded != initial {
+		t.Fatal("ULID did not survive a round trip through JSON.")
+	}
+
+	value, err := initial.Value()
+	if err != nil {
+		t.Fatal("Error getting value: " + err.Error())
+	}
+	for _, src := range []interface{}{value, []byte(initial.String()), initial[:]} {
+		var u uuid.ULID
+		if err := u.Scan(src); err != nil {
+			t.Fatal("Error scanning: " + err.Error())
+		}
+		if u != initial {
+			t.Fatalf("Scanning %T did not produce the same ULID.", src)
+		}
+	}
+}
+
+// Benchmarks the NewULID() function.
+func BenchmarkNewULID(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		uuid.NewULID()
+	}
+}
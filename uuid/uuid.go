@@ -9,7 +9,9 @@ import (
 	"crypto/md5"
 	crypto_rand "crypto/rand"
 	"crypto/sha1"
+	"database/sql/driver"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	math_rand "math/rand"
@@ -212,6 +214,27 @@ func Variant5(namespace UUID, name string) (u UUID) {
 	return u
 }
 
+// Generates a "Variant 7" style UUID. These begin with a 48 bit Unix timestamp
+// in milliseconds followed by random data, so they sort in the order they were
+// generated which makes them well suited to use as database keys.
+func Variant7() (u UUID) {
+	// Output is: tttttttt-tttt-7rrr-Rrrr-rrrrrrrrrrrr
+	// where 7 is mandated, and R must be one of 8, 9, A or B.
+	u = make([]byte, UUIDByteLen)
+	io.ReadFull(crypto_rand.Reader, u[6:])
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	u[0] = byte(ms >> 40)
+	u[1] = byte(ms >> 32)
+	u[2] = byte(ms >> 24)
+	u[3] = byte(ms >> 16)
+	u[4] = byte(ms >> 8)
+	u[5] = byte(ms)
+	u[6] = (u[6] & 0x0f) | 0x70
+	u[8] = (u[8] & 0x3f) | 0x80
+
+	return u
+}
+
 // Generates a Variant 1 UUID. This may change in the future so the semantics
 // should be assumed that this returns a vaguely unique 128bit blob.
 func Generate() UUID {
@@ -283,6 +306,57 @@ func (u UUID) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf("\"%s\"", u.String())), nil
 }
 
+// Parses a quoted string representation of a UUID, for JSON decoding.
+func (u *UUID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := FromString(s)
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// Returns the string representation of the UUID for storage in a database. A
+// nil UUID is stored as NULL.
+func (u UUID) Value() (driver.Value, error) {
+	if u == nil {
+		return nil, nil
+	}
+	return u.String(), nil
+}
+
+// Populates the UUID from a database value. Both the string and raw 16 byte
+// forms are accepted, and NULL results in a nil UUID.
+func (u *UUID) Scan(src interface{}) error {
+	var parsed UUID
+	var err error
+	switch v := src.(type) {
+	case nil:
+		*u = nil
+		return nil
+	case string:
+		parsed, err = FromString(v)
+	case []byte:
+		if len(v) == UUIDByteLen {
+			// Copy since the driver may reuse the buffer.
+			parsed, err = FromBytes(append([]byte(nil), v...))
+		} else {
+			parsed, err = FromString(string(v))
+		}
+	default:
+		return fmt.Errorf("can not scan %T into a UUID", src)
+	}
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
 // Returns the UUID as a series of bytes in an array.
 func (u UUID) Bytes() []byte {
 	return u
@@ -3,8 +3,10 @@
 package uuid_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/apcera/util/uuid"
 )
@@ -100,6 +102,90 @@ func TestVariant5(t *testing.T) {
 	}
 }
 
+// Verifies that Variant7() produces unique UUIDs with the proper reserved bits
+// set, which sort in the order they were generated across milliseconds.
+func TestVariant7(t *testing.T) {
+	previous := make(map[string]bool)
+
+	for i := 0; i < 10000; i++ {
+		u := uuid.Variant7().String()
+		if _, exists := previous[u]; exists == true {
+			t.Fatal("Duplicate UUIDs generated from Variant7(): ", u)
+		}
+		previous[u] = true
+		if _, err := uuid.FromString(u); err != nil {
+			t.Fatal("Variant7() generated an invalid UUID: ", u)
+		}
+		if u[14] != '7' {
+			t.Fatal("Variant7() generated the wrong version: ", u)
+		}
+	}
+
+	first := uuid.Variant7()
+	time.Sleep(2 * time.Millisecond)
+	second := uuid.Variant7()
+	if first.Compare(second) >= 0 || first.String() >= second.String() {
+		t.Fatal("Variant7() UUIDs do not sort in generation order.")
+	}
+}
+
+// Verifies that UUIDs survive a round trip through JSON.
+func TestJSON(t *testing.T) {
+	type doc struct {
+		ID uuid.UUID `json:"id"`
+	}
+	initial := doc{ID: uuid.Variant4()}
+	data, err := json.Marshal(initial)
+	if err != nil {
+		t.Fatal("Error marshalling: " + err.Error())
+	}
+
+	var decoded doc
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal("Error unmarshalling: " + err.Error())
+	}
+	if !initial.ID.Equal(decoded.ID) {
+		t.Fatal("UUID did not survive a round trip through JSON.")
+	}
+
+	if err := json.Unmarshal([]byte(`{"id":"not-a-uuid"}`), &decoded); err == nil {
+		t.Fatal("Failed to detect an invalid UUID in JSON.")
+	}
+}
+
+// Verifies the database/sql Scanner and Valuer implementations.
+func TestScanValue(t *testing.T) {
+	initial := uuid.Variant4()
+	value, err := initial.Value()
+	if err != nil {
+		t.Fatal("Error getting value: " + err.Error())
+	}
+	if value != initial.String() {
+		t.Fatal("Value() did not return the string form.")
+	}
+
+	for _, src := range []interface{}{value, []byte(initial.String()), []byte(initial)} {
+		var u uuid.UUID
+		if err := u.Scan(src); err != nil {
+			t.Fatal("Error scanning: " + err.Error())
+		}
+		if !initial.Equal(u) {
+			t.Fatalf("Scanning %T did not produce the same UUID.", src)
+		}
+	}
+
+	var u uuid.UUID
+	if err := u.Scan(nil); err != nil || u != nil {
+		t.Fatal("Scanning NULL did not produce a nil UUID.")
+	}
+	if value, err := u.Value(); err != nil || value != nil {
+		t.Fatal("A nil UUID did not produce a NULL value.")
+	}
+	if err := u.Scan(1); err == nil {
+		t.Fatal("Failed to detect an unsupported type.")
+	}
+}
+
 // Test to make sure that FromString works by testing a good string as well
 // as a few bad ones.
 func TestFromString(t *testing.T) {
@@ -168,6 +254,13 @@ func BenchmarkVariant4(b *testing.B) {
 	}
 }
 
+// Benchmarks the Variant7() UUIDs.
+func BenchmarkVariant7(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		uuid.Variant7()
+	}
+}
+
 // Benchmarks the Variant5() UUIDs.
 func BenchmarkVariant5(b *testing.B) {
 	u := uuid.NameSpaceURL()
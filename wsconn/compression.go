@@ -0,0 +1,103 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package wsconn
+
+import (
+	"compress/flate"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// CompressionExtension is the Sec-WebSocket-Extensions token used to negotiate
+// compression of binary messages during the websocket handshake. The
+// websocket library in use doesn't support permessage-deflate, so the
+// payloads of binary messages are deflated by the WebsocketConnection
+// instead, which both ends must agree to.
+//
+// A client requests compression with RequestCompression on its handshake
+// headers. A server which sees CompressionRequested accepts by passing
+// AcceptCompression's headers to websocket.Upgrade, and the client checks
+// CompressionAccepted on the response. Both ends then use WithCompression.
+const CompressionExtension = "x-wsconn-deflate"
+
+// RequestCompression adds the request for compression to the client's
+// handshake headers.
+func RequestCompression(h http.Header) {
+	h.Add("Sec-WebSocket-Extensions", CompressionExtension)
+}
+
+// CompressionRequested returns whether the client requested compression.
+func CompressionRequested(r *http.Request) bool {
+	return hasCompressionExtension(r.Header)
+}
+
+// AcceptCompression adds the acceptance of compression to the server's
+// handshake response headers.
+func AcceptCompression(h http.Header) {
+	h.Add("Sec-WebSocket-Extensions", CompressionExtension)
+}
+
+// CompressionAccepted returns whether the server accepted compression.
+func CompressionAccepted(resp *http.Response) bool {
+	return resp != nil && hasCompressionExtension(resp.Header)
+}
+
+func hasCompressionExtension(h http.Header) bool {
+	for _, value := range h["Sec-Websocket-Extensions"] {
+		for _, ext := range strings.Split(value, ",") {
+			// ignore any parameters
+			name := strings.TrimSpace(strings.SplitN(ext, ";", 2)[0])
+			if strings.EqualFold(name, CompressionExtension) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WithCompression deflates the payload of each binary message written, and
+// inflates each binary message read, at the given compression level, such as
+// flate.BestSpeed. Text messages are not compressed. The other end must also
+// use compression, see CompressionExtension.
+func WithCompression(level int) Option {
+	return func(conn *WebsocketConnection) {
+		conn.compress = true
+		conn.compressionLevel = level
+	}
+}
+
+// flateWriters pools the compressors by level, since they are expensive to
+// allocate for every message.
+var (
+	flateWriters      = make(map[int]*sync.Pool)
+	flateWritersMutex sync.Mutex
+)
+
+// writeCompressed deflates b onto w.
+func writeCompressed(w io.Writer, b []byte, level int) error {
+	flateWritersMutex.Lock()
+	pool, ok := flateWriters[level]
+	if !ok {
+		pool = &sync.Pool{}
+		flateWriters[level] = pool
+	}
+	flateWritersMutex.Unlock()
+
+	fw, _ := pool.Get().(*flate.Writer)
+	if fw == nil {
+		var err error
+		if fw, err = flate.NewWriter(w, level); err != nil {
+			return err
+		}
+	} else {
+		fw.Reset(w)
+	}
+	defer pool.Put(fw)
+
+	if _, err := fw.Write(b); err != nil {
+		return err
+	}
+	return fw.Close()
+}
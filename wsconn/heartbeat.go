@@ -0,0 +1,62 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package wsconn
+
+import (
+	"time"
+)
+
+// WithOnPong sets a function called each time a pong is received from the
+// other end. It is called from the goroutine reading the connection, so it
+// should not block.
+func WithOnPong(f func()) Option {
+	return func(conn *WebsocketConnection) { conn.onPong = f }
+}
+
+// WithOnStale sets a function called when the read deadline set by the last
+// pong is less than one ping interval away, meaning the connection is likely
+// to time out unless a pong arrives in response to the next ping. It is called
+// once for each pong, from the ping goroutine, with the deadline which is
+// about to expire. Applications can use it to reconnect or alert before reads
+// start failing.
+func WithOnStale(f func(deadline time.Time)) Option {
+	return func(conn *WebsocketConnection) { conn.onStale = f }
+}
+
+// pongReceived extends the read deadline and records it for the stale check.
+func (conn *WebsocketConnection) pongReceived() {
+	readTimeout, _, _ := conn.timeouts()
+	deadline := time.Now().Add(readTimeout)
+	conn.ws.SetReadDeadline(deadline)
+
+	conn.heartbeatMutex.Lock()
+	conn.pongDeadline = deadline
+	conn.staleNotified = false
+	conn.heartbeatMutex.Unlock()
+
+	if conn.onPong != nil {
+		conn.onPong()
+	}
+}
+
+// checkStale calls the OnStale function if the read deadline from the last
+// pong will expire before the next ping is due.
+func (conn *WebsocketConnection) checkStale() {
+	if conn.onStale == nil {
+		return
+	}
+	_, _, pingInterval := conn.timeouts()
+
+	conn.heartbeatMutex.Lock()
+	deadline := conn.pongDeadline
+	stale := !deadline.IsZero() && !conn.staleNotified &&
+		time.Until(deadline) < pingInterval
+	if stale {
+		conn.staleNotified = true
+	}
+	conn.heartbeatMutex.Unlock()
+
+	if stale {
+		conn.onStale(deadline)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 Apcera Inc. All rights reserved.
+
+package wsconn
+
+import (
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// StatsCollector is called by a WebsocketConnection as it sends and receives
+// data, so its activity can be exported as metrics without wrapping the
+// connection. The methods are called synchronously and concurrently from the
+// reading, writing, and ping goroutines, so they should be quick and safe for
+// concurrent use.
+type StatsCollector interface {
+	// BytesRead and BytesWritten are called with the number of bytes of
+	// application data read from or written to the connection, before any
+	// compression.
+	BytesRead(n int)
+	BytesWritten(n int)
+
+	// MessageRead is called for every message received, with its websocket
+	// message type, such as websocket.BinaryMessage or
+	// websocket.PingMessage. MessageWritten is called for every binary
+	// message written.
+	MessageRead(messageType int)
+	MessageWritten(messageType int)
+
+	// PingSent is called for each keepalive ping sent, and PongReceived for
+	// each pong received in reply.
+	PingSent()
+	PongReceived()
+
+	// Error is called when reading, writing, or sending a ping fails, with
+	// op set to "read", "write", or "ping". The end of the connection is not
+	// reported as an error.
+	Error(op string, err error)
+}
+
+// WithStatsCollector sets the StatsCollector for the connection.
+func WithStatsCollector(c StatsCollector) Option {
+	return func(conn *WebsocketConnection) { conn.stats = c }
+}
+
+// nopStats is used when no StatsCollector is set.
+type nopStats struct{}
+
+func (nopStats) BytesRead(int)       {}
+func (nopStats) BytesWritten(int)    {}
+func (nopStats) MessageRead(int)     {}
+func (nopStats) MessageWritten(int)  {}
+func (nopStats) PingSent()           {}
+func (nopStats) PongReceived()       {}
+func (nopStats) Error(string, error) {}
+
+// Stats is a StatsCollector which counts the activity of one or more
+// connections. Its counters are read with Snapshot.
+type Stats struct {
+	bytesRead        int64
+	bytesWritten     int64
+	messagesRead     int64
+	messagesWritten  int64
+	textMessagesRead int64
+	pingsSent        int64
+	pongsReceived    int64
+	errors           int64
+}
+
+// StatsSnapshot holds the values of a Stats' counters.
+type StatsSnapshot struct {
+	BytesRead        int64 `json:"bytes_read"`
+	BytesWritten     int64 `json:"bytes_written"`
+	MessagesRead     int64 `json:"messages_read"`
+	MessagesWritten  int64 `json:"messages_written"`
+	TextMessagesRead int64 `json:"text_messages_read"`
+	PingsSent        int64 `json:"pings_sent"`
+	PongsReceived    int64 `json:"pongs_received"`
+	Errors           int64 `json:"errors"`
+}
+
+func (s *Stats) BytesRead(n int)    { atomic.AddInt64(&s.bytesRead, int64(n)) }
+func (s *Stats) BytesWritten(n int) { atomic.AddInt64(&s.bytesWritten, int64(n)) }
+func (s *Stats) PingSent()          { atomic.AddInt64(&s.pingsSent, 1) }
+func (s *Stats) PongReceived()      { atomic.AddInt64(&s.pongsReceived, 1) }
+func (s *Stats) Error(string, error) {
+	atomic.AddInt64(&s.errors, 1)
+}
+
+// MessageRead counts binary and text messages. Control messages are counted
+// by PongReceived instead.
+func (s *Stats) MessageRead(messageType int) {
+	switch messageType {
+	case websocket.BinaryMessage:
+		atomic.AddInt64(&s.messagesRead, 1)
+	case websocket.TextMessage:
+		atomic.AddInt64(&s.textMessagesRead, 1)
+	}
+}
+
+func (s *Stats) MessageWritten(int) { atomic.AddInt64(&s.messagesWritten, 1) }
+
+// Snapshot returns the current values of the counters.
+func (s *Stats) Snapshot() StatsSnapshot {
+	return StatsSnapshot{
+		BytesRead:        atomic.LoadInt64(&s.bytesRead),
+		BytesWritten:     atomic.LoadInt64(&s.bytesWritten),
+		MessagesRead:     atomic.LoadInt64(&s.messagesRead),
+		MessagesWritten:  atomic.LoadInt64(&s.messagesWritten),
+		TextMessagesRead: atomic.LoadInt64(&s.textMessagesRead),
+		PingsSent:        atomic.LoadInt64(&s.pingsSent),
+		PongsReceived:    atomic.LoadInt64(&s.pongsReceived),
+		Errors:           atomic.LoadInt64(&s.errors),
+	}
+}
@@ -3,6 +3,7 @@
 package wsconn
 
 import (
+	"compress/flate"
 	"io"
 	"io/ioutil"
 	"net"
@@ -28,15 +29,45 @@ type Conn interface {
 	Close() error
 }
 
+// Default timeouts used by NewWebsocketConnection unless overridden by an
+// Option.
+const (
+	DefaultReadTimeout  = 60 * time.Second
+	DefaultWriteTimeout = 10 * time.Second
+	DefaultPingInterval = 10 * time.Second
+)
+
+// Option configures a WebsocketConnection created by NewWebsocketConnection.
+type Option func(*WebsocketConnection)
+
+// WithReadTimeout sets how long to wait for a pong before reads time out.
+func WithReadTimeout(d time.Duration) Option {
+	return func(conn *WebsocketConnection) { conn.readTimeout = d }
+}
+
+// WithWriteTimeout sets the deadline for writing pings and pongs.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(conn *WebsocketConnection) { conn.writeTimeout = d }
+}
+
+// WithPingInterval sets how often a ping is sent to the other end.
+func WithPingInterval(d time.Duration) Option {
+	return func(conn *WebsocketConnection) { conn.pingInterval = d }
+}
+
 // Returns a websocket connection wrapper to the net.Conn interface.
-func NewWebsocketConnection(ws Conn) net.Conn {
+func NewWebsocketConnection(ws Conn, opts ...Option) net.Conn {
 	wsconn := &WebsocketConnection{
 		ws:           ws,
-		readTimeout:  60 * time.Second,
-		writeTimeout: 10 * time.Second,
-		pingInterval: 10 * time.Second,
+		readTimeout:  DefaultReadTimeout,
+		writeTimeout: DefaultWriteTimeout,
+		pingInterval: DefaultPingInterval,
 		closedChan:   make(chan bool),
 		textChan:     make(chan []byte, 100),
+		stats:        nopStats{},
+	}
+	for _, opt := range opts {
+		opt(wsconn)
 	}
 	wsconn.startPingInterval()
 	return wsconn
@@ -45,29 +76,86 @@ func NewWebsocketConnection(ws Conn) net.Conn {
 // WebsocketConnection is a wrapper around a websocket connect from a lower
 // level API.  It supports things such as automatic ping/pong keepalive.
 type WebsocketConnection struct {
-	ws           Conn
-	reader       io.Reader
-	writeMutex   sync.Mutex
+	ws         Conn
+	reader     io.Reader
+	writeMutex sync.Mutex
+	closedChan chan bool
+	textChan   chan []byte
+
+	// The timeouts are protected by timeoutMutex since they can be changed
+	// while the ping goroutine is running.
+	timeoutMutex sync.RWMutex
 	readTimeout  time.Duration
 	writeTimeout time.Duration
 	pingInterval time.Duration
-	closedChan   chan bool
-	textChan     chan []byte
+
+	// Set by WithCompression.
+	compress         bool
+	compressionLevel int
+
+	// Set by WithStatsCollector.
+	stats StatsCollector
+
+	// Set by WithOnPong and WithOnStale. The deadline set by the last pong is
+	// protected by heartbeatMutex.
+	onPong         func()
+	onStale        func(deadline time.Time)
+	heartbeatMutex sync.Mutex
+	pongDeadline   time.Time
+	staleNotified  bool
+}
+
+// SetReadTimeout sets how long to wait for a pong before reads time out. It
+// applies from the next pong received.
+func (conn *WebsocketConnection) SetReadTimeout(d time.Duration) {
+	conn.timeoutMutex.Lock()
+	defer conn.timeoutMutex.Unlock()
+	conn.readTimeout = d
+}
+
+// SetWriteTimeout sets the deadline for writing pings and pongs.
+func (conn *WebsocketConnection) SetWriteTimeout(d time.Duration) {
+	conn.timeoutMutex.Lock()
+	defer conn.timeoutMutex.Unlock()
+	conn.writeTimeout = d
+}
+
+// SetPingInterval sets how often a ping is sent to the other end. It applies
+// from the ping after next.
+func (conn *WebsocketConnection) SetPingInterval(d time.Duration) {
+	conn.timeoutMutex.Lock()
+	defer conn.timeoutMutex.Unlock()
+	conn.pingInterval = d
+}
+
+// timeouts returns the current timeouts.
+func (conn *WebsocketConnection) timeouts() (read, write, ping time.Duration) {
+	conn.timeoutMutex.RLock()
+	defer conn.timeoutMutex.RUnlock()
+	return conn.readTimeout, conn.writeTimeout, conn.pingInterval
 }
 
 // Begins a goroutine to send a periodic ping to the other end
 func (conn *WebsocketConnection) startPingInterval() {
 	go func() {
 		for {
+			_, _, pingInterval := conn.timeouts()
 			select {
 			case <-conn.closedChan:
 				return
-			case <-time.After(conn.pingInterval):
+			case <-time.After(pingInterval):
 				func() {
+					_, writeTimeout, _ := conn.timeouts()
 					conn.writeMutex.Lock()
 					defer conn.writeMutex.Unlock()
-					conn.ws.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(conn.writeTimeout))
+					err := conn.ws.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(writeTimeout))
+					if err != nil {
+						conn.stats.Error("ping", err)
+					} else {
+						conn.stats.PingSent()
+					}
 				}()
+				conn.checkStale()
 			}
 		}
 	}()
@@ -82,11 +170,15 @@ func (conn *WebsocketConnection) waitForReader() error {
 		if err != nil {
 			return err
 		}
+		conn.stats.MessageRead(opCode)
 
 		switch opCode {
 		case websocket.BinaryMessage:
 			// binary packet
 			conn.reader = reader
+			if conn.compress {
+				conn.reader = flate.NewReader(reader)
+			}
 			return nil
 
 		case websocket.TextMessage:
@@ -99,14 +191,16 @@ func (conn *WebsocketConnection) waitForReader() error {
 		case websocket.PingMessage:
 			// receeived a ping, so send a pong
 			go func() {
+				_, writeTimeout, _ := conn.timeouts()
 				conn.writeMutex.Lock()
 				defer conn.writeMutex.Unlock()
-				conn.ws.WriteControl(websocket.PongMessage, []byte{}, time.Now().Add(conn.writeTimeout))
+				conn.ws.WriteControl(websocket.PongMessage, []byte{}, time.Now().Add(writeTimeout))
 			}()
 
 		case websocket.PongMessage:
 			// received a pong, update read deadline
-			conn.ws.SetReadDeadline(time.Now().Add(conn.readTimeout))
+			conn.stats.PongReceived()
+			conn.pongReceived()
 
 		case websocket.CloseMessage:
 			// received close, so return EOF
@@ -123,6 +217,15 @@ func (conn *WebsocketConnection) GetTextChannel() <-chan []byte {
 
 // Reads slice of bytes off of the websocket connection.
 func (conn *WebsocketConnection) Read(b []byte) (n int, err error) {
+	defer func() {
+		if n > 0 {
+			conn.stats.BytesRead(n)
+		}
+		if err != nil && err != io.EOF {
+			conn.stats.Error("read", err)
+		}
+	}()
+
 	if conn.reader == nil {
 		err = conn.waitForReader()
 		if err != nil {
@@ -133,7 +236,9 @@ func (conn *WebsocketConnection) Read(b []byte) (n int, err error) {
 	rn, rerr := conn.reader.Read(b)
 	switch rerr {
 	case io.EOF:
+		// keep any data returned along with the end of the message
 		conn.reader = nil
+		n = rn
 	default:
 		n, err = rn, rerr
 	}
@@ -144,6 +249,14 @@ func (conn *WebsocketConnection) Read(b []byte) (n int, err error) {
 func (conn *WebsocketConnection) Write(b []byte) (n int, err error) {
 	conn.writeMutex.Lock()
 	defer conn.writeMutex.Unlock()
+	defer func() {
+		if err != nil {
+			conn.stats.Error("write", err)
+		} else {
+			conn.stats.MessageWritten(websocket.BinaryMessage)
+			conn.stats.BytesWritten(n)
+		}
+	}()
 
 	// allocate a writer
 	var writer io.WriteCloser
@@ -153,9 +266,16 @@ func (conn *WebsocketConnection) Write(b []byte) (n int, err error) {
 	}
 
 	// write
-	n, err = writer.Write(b)
-	if err != nil {
-		return
+	if conn.compress {
+		if err = writeCompressed(writer, b, conn.compressionLevel); err != nil {
+			return
+		}
+		n = len(b)
+	} else {
+		n, err = writer.Write(b)
+		if err != nil {
+			return
+		}
 	}
 
 	// close it
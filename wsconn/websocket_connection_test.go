@@ -3,13 +3,18 @@
 package wsconn
 
 import (
+	"compress/flate"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -169,3 +174,288 @@ func TestWebsocketConnection(t *testing.T) {
 	testRead("one last read")
 	testWrite("Another write!")
 }
+
+// pingCountingConn is a Conn which records the pings written to it.
+type pingCountingConn struct {
+	Conn
+	mutex     sync.Mutex
+	pings     int
+	deadlines []time.Duration
+}
+
+func (c *pingCountingConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if messageType == websocket.PingMessage {
+		c.pings++
+		c.deadlines = append(c.deadlines, time.Until(deadline))
+	}
+	return nil
+}
+
+func (c *pingCountingConn) Close() error {
+	return nil
+}
+
+func (c *pingCountingConn) count() (int, []time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.pings, c.deadlines
+}
+
+func TestWebsocketConnectionOptions(t *testing.T) {
+	ws := &pingCountingConn{}
+	conn := NewWebsocketConnection(ws,
+		WithPingInterval(time.Millisecond),
+		WithWriteTimeout(time.Hour),
+		WithReadTimeout(time.Minute)).(*WebsocketConnection)
+	defer conn.Close()
+
+	read, write, ping := conn.timeouts()
+	if read != time.Minute || write != time.Hour || ping != time.Millisecond {
+		t.Fatalf("Options not applied: read %v, write %v, ping %v", read, write, ping)
+	}
+
+	// pings are sent at the interval with the write timeout
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pings, deadlines := ws.count()
+		if pings >= 3 {
+			if deadlines[0] < 59*time.Minute {
+				t.Fatalf("Ping deadline %v doesn't use the write timeout", deadlines[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Only %d pings sent", pings)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// a long interval stops the pings once the current wait ends
+	conn.SetPingInterval(time.Hour)
+	conn.SetWriteTimeout(time.Second)
+	conn.SetReadTimeout(time.Second)
+	time.Sleep(10 * time.Millisecond)
+	before, _ := ws.count()
+	time.Sleep(20 * time.Millisecond)
+	if after, _ := ws.count(); after != before {
+		t.Fatalf("Pings still sent after changing the interval: %d then %d", before, after)
+	}
+	if read, write, ping := conn.timeouts(); read != time.Second || write != time.Second || ping != time.Hour {
+		t.Fatalf("Setters not applied: read %v, write %v, ping %v", read, write, ping)
+	}
+}
+
+func TestWebsocketConnectionCompression(t *testing.T) {
+	// the server echoes messages back as they are on the wire
+	sizes := make(chan int, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !CompressionRequested(r) {
+			http.Error(w, "Compression not requested", 400)
+			return
+		}
+		responseHeader := http.Header{}
+		AcceptCompression(responseHeader)
+		ws, err := websocket.Upgrade(w, r, responseHeader, 1024, 1024)
+		if err != nil {
+			t.Logf("Error when upgrading: %v", err)
+			return
+		}
+		defer ws.Close()
+
+		opCode, reader, err := ws.NextReader()
+		if err != nil || opCode != websocket.BinaryMessage {
+			t.Logf("Bad message: %d %v", opCode, err)
+			return
+		}
+		b, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Logf("Read error: %v", err)
+			return
+		}
+		sizes <- len(b)
+		writer, err := ws.NextWriter(websocket.BinaryMessage)
+		if err != nil {
+			return
+		}
+		writer.Write(b)
+		writer.Close()
+
+		// wait for the client to close
+		ws.NextReader()
+	}))
+	defer server.Close()
+
+	nurl, err := url.ParseRequestURI(server.URL)
+	if err != nil {
+		t.Fatalf("url.ParseRequestURI returned an error: %v", err)
+	}
+	netConn, err := net.Dial("tcp", nurl.Host)
+	if err != nil {
+		t.Fatalf("net.Dial returned an error: %v", err)
+	}
+	headers := http.Header{"Origin": {nurl.String()}}
+	RequestCompression(headers)
+	ws, resp, err := websocket.NewClient(netConn, nurl, headers, 1024, 1024)
+	if err != nil {
+		t.Fatalf("websocket.NewClient returned an error: %v", err)
+	}
+	if !CompressionAccepted(resp) {
+		t.Fatalf("Compression not accepted: %v", resp.Header)
+	}
+
+	conn := NewWebsocketConnection(ws, WithCompression(flate.BestSpeed))
+	defer conn.Close()
+
+	msg := strings.Repeat("compress me ", 1000)
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	if size := <-sizes; size >= len(msg)/10 {
+		t.Fatalf("Message of %d bytes was sent as %d bytes", len(msg), size)
+	}
+
+	got, err := ioutil.ReadAll(io.LimitReader(conn, int64(len(msg))))
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+	if string(got) != msg {
+		t.Fatalf("Read %d bytes, expected the %d bytes written", len(got), len(msg))
+	}
+}
+
+// scriptedConn is a Conn which returns queued messages from NextReader and
+// discards writes.
+type scriptedConn struct {
+	pingCountingConn
+	messages []int
+	payload  string
+}
+
+func (c *scriptedConn) NextReader() (int, io.Reader, error) {
+	if len(c.messages) == 0 {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	messageType := c.messages[0]
+	c.messages = c.messages[1:]
+	return messageType, strings.NewReader(c.payload), nil
+}
+
+func (c *scriptedConn) NextWriter(int) (io.WriteCloser, error) {
+	return nopWriteCloser{ioutil.Discard}, nil
+}
+
+func (c *scriptedConn) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func TestWebsocketConnectionStats(t *testing.T) {
+	ws := &scriptedConn{
+		messages: []int{websocket.PongMessage, websocket.TextMessage, websocket.BinaryMessage},
+		payload:  "hello",
+	}
+	stats := &Stats{}
+	conn := NewWebsocketConnection(ws, WithStatsCollector(stats), WithPingInterval(time.Millisecond))
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("written")); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	b, err := ioutil.ReadAll(conn)
+	if err != io.ErrUnexpectedEOF || string(b) != "hello" {
+		t.Fatalf("Unexpected read: %q %v", b, err)
+	}
+
+	// wait for a ping
+	deadline := time.Now().Add(5 * time.Second)
+	for stats.Snapshot().PingsSent == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("No pings sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	snapshot := stats.Snapshot()
+	snapshot.PingsSent = 0
+	expected := StatsSnapshot{
+		BytesRead:        5,
+		BytesWritten:     7,
+		MessagesRead:     1,
+		MessagesWritten:  1,
+		TextMessagesRead: 1,
+		PongsReceived:    1,
+		Errors:           1,
+	}
+	if snapshot != expected {
+		t.Fatalf("Unexpected stats\nExpected: %+v\nActual: %+v", expected, snapshot)
+	}
+}
+
+func TestWebsocketConnectionHeartbeatCallbacks(t *testing.T) {
+	ws := &scriptedConn{messages: []int{websocket.PongMessage}}
+	var mutex sync.Mutex
+	var pongs, stales int
+	var staleDeadline time.Time
+	conn := NewWebsocketConnection(ws,
+		WithPingInterval(5*time.Millisecond),
+		WithReadTimeout(50*time.Millisecond),
+		WithOnPong(func() {
+			mutex.Lock()
+			defer mutex.Unlock()
+			pongs++
+		}),
+		WithOnStale(func(deadline time.Time) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			stales++
+			staleDeadline = deadline
+		}))
+	defer conn.Close()
+
+	// read the pong, which sets the deadline
+	start := time.Now()
+	if _, err := conn.Read(make([]byte, 1)); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Unexpected read error: %v", err)
+	}
+
+	// wait for the deadline to become close
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mutex.Lock()
+		p, s, d := pongs, stales, staleDeadline
+		mutex.Unlock()
+		if s > 0 {
+			if p != 1 {
+				t.Fatalf("Expected 1 pong callback, got %d", p)
+			}
+			if remaining := time.Until(d); remaining > 5*time.Millisecond {
+				t.Fatalf("Stale callback %v before the deadline", remaining)
+			}
+			if d.Before(start.Add(50 * time.Millisecond)) {
+				t.Fatalf("Stale deadline %v not from the pong", d)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Stale callback not called")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// it is only called once per pong
+	time.Sleep(30 * time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if stales != 1 {
+		t.Fatalf("Expected 1 stale callback, got %d", stales)
+	}
+}